@@ -6,9 +6,12 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sync"
 	"syscall"
 	"time"
+
+	"concurrency/scheduler"
 )
 
 func SimpleGracefulShutdown() {
@@ -67,18 +70,41 @@ func SimpleGracefulShutdown() {
 
 func runScheduler(ctx context.Context) {
 	fmt.Println("Scheduler started.")
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
 
-	for {
-		select {
-		case <-ctx.Done():
-			fmt.Println("Scheduler stopping...")
-			return
-		case t := <-ticker.C:
-			fmt.Println("Scheduled task at", t)
+	// Job state is persisted so a restart catches up the run it missed; it is
+	// kept in the temp dir rather than wherever the demo is started from
+	statePath := filepath.Join(os.TempDir(), "concurrency_scheduler_state.json")
+	s := scheduler.New(scheduler.NewFileStore(statePath), nil)
+	jobs := []scheduler.Job{
+		{
+			Name:     "heartbeat",
+			Schedule: scheduler.Every(2 * time.Second),
+			Run: func(ctx context.Context) error {
+				fmt.Println("Scheduled task at", time.Now())
+				return nil
+			},
+		},
+		{
+			Name:     "report",
+			Schedule: scheduler.MustParse("*/1 * * * *"),
+			Jitter:   5 * time.Second,
+			Catchup:  scheduler.CatchupOnce,
+			Run: func(ctx context.Context) error {
+				fmt.Println("Minutely report at", time.Now())
+				return nil
+			},
+		},
+	}
+	for _, job := range jobs {
+		if err := s.Add(job); err != nil {
+			log.Fatal(err)
 		}
 	}
+
+	if err := s.Run(ctx); err != nil {
+		fmt.Println("Scheduler error:", err)
+	}
+	fmt.Println("Scheduler stopping...")
 }
 
 func doWork(ctx context.Context) {
@@ -1,3 +1,5 @@
 module concurrency
 
 go 1.25.1
+
+require github.com/mattn/go-sqlite3 v1.14.22
@@ -0,0 +1,362 @@
+// Package scheduler runs jobs on cron or fixed-interval schedules with
+// jitter, missed-run catch-up and overlap prevention. Job state is kept in a
+// pluggable Store so a restarted process knows which runs it missed.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// CatchupPolicy decides what happens to runs that were due while the
+// scheduler was not running.
+type CatchupPolicy int
+
+const (
+	// CatchupSkip drops missed runs and waits for the next slot.
+	CatchupSkip CatchupPolicy = iota
+	// CatchupOnce runs the job once to cover all missed slots.
+	CatchupOnce
+	// CatchupAll runs the job once per missed slot, up to Job.MaxCatchup.
+	CatchupAll
+)
+
+const defaultMaxCatchup = 10
+
+// Job is a unit of scheduled work.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Run      func(ctx context.Context) error
+
+	// Jitter delays each run by a random duration in [0, Jitter) so that many
+	// processes sharing a schedule don't fire at the same instant.
+	Jitter time.Duration
+	// Catchup is applied on start when the stored NextRun is in the past.
+	Catchup CatchupPolicy
+	// MaxCatchup bounds the number of runs replayed by CatchupAll.
+	MaxCatchup int
+	// AllowOverlap lets a run start while the previous one is still going.
+	// By default the new run is skipped.
+	AllowOverlap bool
+}
+
+type entry struct {
+	job     Job
+	mu      sync.Mutex
+	state   JobState
+	running bool
+
+	// saveMu orders the writes of state so an older copy never overwrites a
+	// newer one in the store
+	saveMu sync.Mutex
+}
+
+// Scheduler runs registered jobs until its context is cancelled.
+type Scheduler struct {
+	store  Store
+	logger *log.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	started bool
+	wg      sync.WaitGroup
+}
+
+// New creates a scheduler backed by store. A nil store keeps state in memory
+// and a nil logger uses log.Default().
+func New(store Store, logger *log.Logger) *Scheduler {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Scheduler{
+		store:   store,
+		logger:  logger,
+		now:     time.Now,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Add registers a job. Jobs must be added before Run is called.
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" {
+		return errors.New("scheduler: job name is required")
+	}
+	if job.Schedule == nil || job.Run == nil {
+		return fmt.Errorf("scheduler: job %q needs a schedule and a run func", job.Name)
+	}
+	if job.MaxCatchup <= 0 {
+		job.MaxCatchup = defaultMaxCatchup
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return fmt.Errorf("scheduler: cannot add job %q after start", job.Name)
+	}
+	if _, ok := s.entries[job.Name]; ok {
+		return fmt.Errorf("scheduler: duplicate job %q", job.Name)
+	}
+	s.entries[job.Name] = &entry{job: job}
+	return nil
+}
+
+// State returns the last known state of a job.
+func (s *Scheduler) State(name string) (JobState, bool) {
+	s.mu.Lock()
+	e, ok := s.entries[name]
+	s.mu.Unlock()
+	if !ok {
+		return JobState{}, false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.state, true
+}
+
+// Run starts every job and blocks until ctx is cancelled and all in-flight
+// runs have returned.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return errors.New("scheduler: already started")
+	}
+	s.started = true
+	entries := make([]*entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	s.mu.Unlock()
+
+	for _, e := range entries {
+		state, ok, err := s.store.Load(ctx, e.job.Name)
+		if err != nil {
+			return fmt.Errorf("scheduler: load state for %q: %w", e.job.Name, err)
+		}
+		if !ok {
+			state = JobState{Name: e.job.Name}
+		}
+		e.state = state
+	}
+
+	var loops sync.WaitGroup
+	for _, e := range entries {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			s.loop(ctx, e)
+		}()
+	}
+
+	loops.Wait()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Scheduler) loop(ctx context.Context, e *entry) {
+	now := s.now()
+	next := e.state.NextRun
+	if next.IsZero() {
+		next = e.job.Schedule.Next(now)
+		s.saveNext(ctx, e, next)
+	} else if next.Before(now) {
+		next = s.catchup(ctx, e, next, now)
+	}
+
+	for !next.IsZero() {
+		fireAt := next.Add(s.jitter(e.job.Jitter))
+		timer := time.NewTimer(fireAt.Sub(s.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// if the run slot was slept through (e.g. the host was suspended),
+		// skip to the next slot in the future rather than firing in a burst
+		following := e.job.Schedule.Next(later(next, s.now()))
+		if !s.claim(ctx, e, next, following) {
+			next = s.follow(ctx, e, following)
+			continue
+		}
+		s.fire(ctx, e)
+		next = following
+	}
+	s.logger.Printf("scheduler: job %q has no future runs", e.job.Name)
+}
+
+// catchup applies the job's catch-up policy to the slots missed between
+// missed and now, and returns the next slot to wait for. Only the scheduler
+// that claims the missed slot catches up.
+func (s *Scheduler) catchup(ctx context.Context, e *entry, missed, now time.Time) time.Time {
+	next := e.job.Schedule.Next(now)
+	if !s.claim(ctx, e, missed, next) {
+		return s.follow(ctx, e, next)
+	}
+
+	switch e.job.Catchup {
+	case CatchupOnce:
+		s.logger.Printf("scheduler: job %q missed run at %s, catching up once", e.job.Name, missed.Format(time.RFC3339))
+		s.execute(ctx, e)
+	case CatchupAll:
+		for slot, n := missed, 0; !slot.IsZero() && !slot.After(now) && n < e.job.MaxCatchup; slot, n = e.job.Schedule.Next(slot), n+1 {
+			if ctx.Err() != nil {
+				break
+			}
+			s.logger.Printf("scheduler: job %q catching up run at %s", e.job.Name, slot.Format(time.RFC3339))
+			s.execute(ctx, e)
+		}
+	default:
+		s.logger.Printf("scheduler: job %q skipping missed runs since %s", e.job.Name, missed.Format(time.RFC3339))
+	}
+
+	// slots that passed while catching up are skipped too
+	if after := e.job.Schedule.Next(s.now()); !after.Equal(next) && s.claim(ctx, e, next, after) {
+		next = after
+	}
+	return next
+}
+
+// fire starts a run in the background unless the previous one is still in
+// flight and overlap is not allowed.
+func (s *Scheduler) fire(ctx context.Context, e *entry) {
+	e.mu.Lock()
+	if e.running && !e.job.AllowOverlap {
+		e.mu.Unlock()
+		s.logger.Printf("scheduler: job %q still running, skipping this run", e.job.Name)
+		return
+	}
+	e.running = true
+	e.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(ctx, e)
+	}()
+}
+
+// execute runs the job synchronously, used for catch-up runs.
+func (s *Scheduler) execute(ctx context.Context, e *entry) {
+	e.mu.Lock()
+	e.running = true
+	e.mu.Unlock()
+	s.run(ctx, e)
+}
+
+func (s *Scheduler) run(ctx context.Context, e *entry) {
+	started := s.now()
+	err := s.safeRun(ctx, e.job)
+	if err != nil {
+		s.logger.Printf("scheduler: job %q failed: %v", e.job.Name, err)
+	}
+
+	e.mu.Lock()
+	e.running = false
+	e.state.LastRun = started
+	e.state.Runs++
+	e.state.LastError = ""
+	if err != nil {
+		e.state.LastError = err.Error()
+	}
+	e.mu.Unlock()
+
+	// persist even if ctx was cancelled mid-run so the run is not repeated
+	s.save(context.WithoutCancel(ctx), e)
+}
+
+func (s *Scheduler) safeRun(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+func (s *Scheduler) saveNext(ctx context.Context, e *entry, next time.Time) {
+	e.mu.Lock()
+	e.state.NextRun = next
+	e.mu.Unlock()
+
+	s.save(ctx, e)
+}
+
+// claim moves the stored NextRun of e from slot to next and reports whether
+// this scheduler won the slot. Schedulers sharing a store race for every
+// slot and only the winner fires it; a slot that cannot be claimed because
+// the store fails is skipped rather than risk running it twice.
+func (s *Scheduler) claim(ctx context.Context, e *entry, slot, next time.Time) bool {
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
+
+	won, err := s.store.Claim(ctx, e.job.Name, slot, next)
+	if err != nil {
+		s.logger.Printf("scheduler: claim run of %q at %s: %v", e.job.Name, slot.Format(time.RFC3339), err)
+		return false
+	}
+	if won {
+		e.mu.Lock()
+		e.state.NextRun = next
+		e.mu.Unlock()
+	}
+	return won
+}
+
+// follow adopts the state stored by the scheduler that won the last claim
+// and returns its next slot, or fallback if the store cannot tell.
+func (s *Scheduler) follow(ctx context.Context, e *entry, fallback time.Time) time.Time {
+	state, ok, err := s.store.Load(ctx, e.job.Name)
+	if err != nil {
+		s.logger.Printf("scheduler: load state for %q: %v", e.job.Name, err)
+	}
+	if err != nil || !ok || state.NextRun.IsZero() {
+		return fallback
+	}
+
+	e.mu.Lock()
+	e.state = state
+	e.mu.Unlock()
+	return state.NextRun
+}
+
+// save writes the current state of e. The copy is taken once the previous
+// write has returned, so a run finishing while the loop moves to the next slot
+// cannot write back a NextRun that already fired.
+func (s *Scheduler) save(ctx context.Context, e *entry) {
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
+
+	e.mu.Lock()
+	state := e.state
+	e.mu.Unlock()
+
+	if err := s.store.Save(ctx, state); err != nil {
+		s.logger.Printf("scheduler: save state for %q: %v", e.job.Name, err)
+	}
+}
+
+func (s *Scheduler) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(max)))
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,230 @@
+package scheduler
+
+import (
+	"context"
+	"io"
+	"log"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestScheduler(store Store) *Scheduler {
+	return New(store, log.New(io.Discard, "", 0))
+}
+
+func TestSchedulerRunsJob(t *testing.T) {
+	s := newTestScheduler(nil)
+	var runs atomic.Int32
+	err := s.Add(Job{
+		Name:     "tick",
+		Schedule: Every(20 * time.Millisecond),
+		Run: func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	if err := s.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if runs.Load() < 3 {
+		t.Errorf("expected at least 3 runs, got %d", runs.Load())
+	}
+	state, _ := s.State("tick")
+	if state.Runs != int64(runs.Load()) {
+		t.Errorf("state.Runs = %d, want %d", state.Runs, runs.Load())
+	}
+}
+
+func TestSchedulerPreventsOverlap(t *testing.T) {
+	s := newTestScheduler(nil)
+	var running, maxRunning, runs atomic.Int32
+	err := s.Add(Job{
+		Name:     "slow",
+		Schedule: Every(10 * time.Millisecond),
+		Run: func(ctx context.Context) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			if n > maxRunning.Load() {
+				maxRunning.Store(n)
+			}
+			runs.Add(1)
+			time.Sleep(45 * time.Millisecond)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	if maxRunning.Load() != 1 {
+		t.Errorf("expected no overlapping runs, max concurrent = %d", maxRunning.Load())
+	}
+	if runs.Load() == 0 {
+		t.Error("expected the job to run")
+	}
+}
+
+func TestSchedulerCatchup(t *testing.T) {
+	tests := []struct {
+		policy CatchupPolicy
+		want   int32
+	}{
+		{CatchupSkip, 0},
+		{CatchupOnce, 1},
+		{CatchupAll, 5},
+	}
+
+	for _, tt := range tests {
+		store := NewMemoryStore()
+		// the job last ran an hour ago and was due every minute since then
+		store.Save(context.Background(), JobState{
+			Name:    "catchup",
+			NextRun: time.Now().Add(-time.Hour),
+		})
+
+		s := newTestScheduler(store)
+		var runs atomic.Int32
+		s.Add(Job{
+			Name:       "catchup",
+			Schedule:   Every(time.Minute),
+			Catchup:    tt.policy,
+			MaxCatchup: 5,
+			Run: func(ctx context.Context) error {
+				runs.Add(1)
+				return nil
+			},
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		s.Run(ctx)
+		cancel()
+
+		if runs.Load() != tt.want {
+			t.Errorf("policy %d: got %d runs, want %d", tt.policy, runs.Load(), tt.want)
+		}
+		state, _, _ := store.Load(context.Background(), "catchup")
+		if !state.NextRun.After(time.Now()) {
+			t.Errorf("policy %d: NextRun %v should be in the future", tt.policy, state.NextRun)
+		}
+	}
+}
+
+// orderedStore fails the test when a write goes back on an earlier one. The
+// first write of a finished run is held back so the loop saves the next slot
+// meanwhile.
+type orderedStore struct {
+	t       *testing.T
+	mu      sync.Mutex
+	last    JobState
+	delayed bool
+}
+
+func (o *orderedStore) Load(context.Context, string) (JobState, bool, error) {
+	return JobState{}, false, nil
+}
+
+func (o *orderedStore) Save(_ context.Context, state JobState) error {
+	o.mu.Lock()
+	delay := state.Runs == 1 && !o.delayed
+	o.delayed = o.delayed || delay
+	o.mu.Unlock()
+	if delay {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if state.Runs < o.last.Runs || state.NextRun.Before(o.last.NextRun) {
+		o.t.Errorf("saved %+v after %+v", state, o.last)
+	}
+	o.last = state
+	return nil
+}
+
+func (o *orderedStore) Claim(_ context.Context, _ string, slot, next time.Time) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.last.NextRun.Equal(slot) {
+		return false, nil
+	}
+	if next.Before(o.last.NextRun) {
+		o.t.Errorf("claimed %v after %+v", next, o.last)
+	}
+	o.last.NextRun = next
+	return true, nil
+}
+
+func TestSchedulerSavesInOrder(t *testing.T) {
+	store := &orderedStore{t: t}
+	s := newTestScheduler(store)
+	s.Add(Job{
+		Name:     "tick",
+		Schedule: Every(20 * time.Millisecond),
+		Run:      func(ctx context.Context) error { return nil },
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	state, _ := s.State("tick")
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.last.Runs != state.Runs || !store.last.NextRun.Equal(state.NextRun) {
+		t.Errorf("stored %+v, want the current state %+v", store.last, state)
+	}
+}
+
+func TestFileStorePersistsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	ctx := context.Background()
+
+	want := JobState{Name: "cleanup", LastRun: time.Now().UTC().Truncate(time.Second), Runs: 3, LastError: "boom"}
+	if err := NewFileStore(path).Save(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok, err := NewFileStore(path).Load(ctx, "cleanup")
+	if err != nil || !ok {
+		t.Fatalf("Load() = %v, %v", ok, err)
+	}
+	if got.Runs != want.Runs || got.LastError != want.LastError || !got.LastRun.Equal(want.LastRun) {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+
+	if _, ok, _ := NewFileStore(path).Load(ctx, "missing"); ok {
+		t.Error("expected missing job to be reported as not found")
+	}
+}
+
+func TestAddValidation(t *testing.T) {
+	s := newTestScheduler(nil)
+	noop := func(ctx context.Context) error { return nil }
+
+	if err := s.Add(Job{Schedule: Every(time.Second), Run: noop}); err == nil {
+		t.Error("expected error for missing name")
+	}
+	if err := s.Add(Job{Name: "a", Run: noop}); err == nil {
+		t.Error("expected error for missing schedule")
+	}
+	if err := s.Add(Job{Name: "a", Schedule: Every(time.Second), Run: noop}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(Job{Name: "a", Schedule: Every(time.Second), Run: noop}); err == nil {
+		t.Error("expected error for duplicate job")
+	}
+}
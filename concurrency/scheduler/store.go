@@ -0,0 +1,292 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JobState is the persisted state of a job, used to detect missed runs
+// after a restart.
+type JobState struct {
+	Name      string    `json:"name"`
+	LastRun   time.Time `json:"last_run"`
+	NextRun   time.Time `json:"next_run"`
+	LastError string    `json:"last_error,omitempty"`
+	Runs      int64     `json:"runs"`
+}
+
+// Store persists job state between scheduler restarts. Schedulers in
+// several processes may share a FileStore or SQLStore; Claim makes sure each
+// slot fires in one of them only.
+type Store interface {
+	// Load returns the state for name and false if the job has never been saved.
+	Load(ctx context.Context, name string) (JobState, bool, error)
+	Save(ctx context.Context, state JobState) error
+	// Claim atomically moves the NextRun of name from slot to next. It
+	// reports false, without changing anything, if the stored NextRun is no
+	// longer slot because another scheduler claimed it first.
+	Claim(ctx context.Context, name string, slot, next time.Time) (bool, error)
+}
+
+// MemoryStore keeps job state in memory. State is lost on restart, so
+// missed runs are never caught up.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	states map[string]JobState
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]JobState)}
+}
+
+func (m *MemoryStore) Load(_ context.Context, name string) (JobState, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	state, ok := m.states[name]
+	return state, ok, nil
+}
+
+func (m *MemoryStore) Save(_ context.Context, state JobState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[state.Name] = state
+	return nil
+}
+
+func (m *MemoryStore) Claim(_ context.Context, name string, slot, next time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.states[name]
+	if !ok || !state.NextRun.Equal(slot) {
+		return false, nil
+	}
+	state.NextRun = next
+	m.states[name] = state
+	return true, nil
+}
+
+// FileStore keeps the state of all jobs in a single JSON file. Writes go to
+// a temporary file first and are renamed into place so a crash never leaves
+// a truncated file behind. Processes sharing the file take turns through a
+// lock file next to it, so a Claim's read and write are never interleaved
+// with another process.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (f *FileStore) Load(_ context.Context, name string) (JobState, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	states, err := f.read()
+	if err != nil {
+		return JobState{}, false, err
+	}
+	state, ok := states[name]
+	return state, ok, nil
+}
+
+func (f *FileStore) Save(ctx context.Context, state JobState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	unlock, err := f.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	states, err := f.read()
+	if err != nil {
+		return err
+	}
+	states[state.Name] = state
+	return f.write(states)
+}
+
+func (f *FileStore) Claim(ctx context.Context, name string, slot, next time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	unlock, err := f.lock(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	states, err := f.read()
+	if err != nil {
+		return false, err
+	}
+	state, ok := states[name]
+	if !ok || !state.NextRun.Equal(slot) {
+		return false, nil
+	}
+	state.NextRun = next
+	states[name] = state
+	return true, f.write(states)
+}
+
+// fileLockStale is how old a lock file may get before it is taken to be
+// left behind by a crashed process
+const fileLockStale = 30 * time.Second
+
+// lock creates the lock file of the store, waiting while another process
+// holds it, and returns the func that removes it.
+func (f *FileStore) lock(ctx context.Context) (func(), error) {
+	path := f.path + ".lock"
+	for {
+		lock, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			lock.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("scheduler: lock state: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > fileLockStale {
+			os.Remove(path)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("scheduler: lock state: %w", ctx.Err())
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func (f *FileStore) write(states map[string]JobState) error {
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return fmt.Errorf("scheduler: encode state: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("scheduler: write state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("scheduler: write state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("scheduler: write state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("scheduler: write state: %w", err)
+	}
+	return nil
+}
+
+func (f *FileStore) read() (map[string]JobState, error) {
+	states := make(map[string]JobState)
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scheduler: read state: %w", err)
+	}
+	if len(data) == 0 {
+		return states, nil
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("scheduler: decode state: %w", err)
+	}
+	return states, nil
+}
+
+// SQLStore keeps job state in a scheduler_jobs table. Queries use $N
+// placeholders and ON CONFLICT upserts, which both Postgres and SQLite accept.
+type SQLStore struct {
+	db *sql.DB
+}
+
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// Migrate creates the scheduler_jobs table if it does not exist.
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS scheduler_jobs (
+		name VARCHAR(255) PRIMARY KEY,
+		last_run TIMESTAMP,
+		next_run TIMESTAMP,
+		last_error TEXT NOT NULL DEFAULT '',
+		runs BIGINT NOT NULL DEFAULT 0
+	)
+	`
+	_, err := s.db.ExecContext(ctx, query)
+	return err
+}
+
+func (s *SQLStore) Load(ctx context.Context, name string) (JobState, bool, error) {
+	query := `
+	SELECT name, last_run, next_run, last_error, runs
+	FROM scheduler_jobs
+	WHERE name = $1
+	`
+	state := JobState{}
+	var lastRun, nextRun sql.NullTime
+	err := s.db.QueryRowContext(ctx, query, name).Scan(&state.Name, &lastRun, &nextRun, &state.LastError, &state.Runs)
+	if err == sql.ErrNoRows {
+		return JobState{}, false, nil
+	}
+	if err != nil {
+		return JobState{}, false, err
+	}
+	state.LastRun = lastRun.Time
+	state.NextRun = nextRun.Time
+	return state, true, nil
+}
+
+func (s *SQLStore) Save(ctx context.Context, state JobState) error {
+	query := `
+	INSERT INTO scheduler_jobs (name, last_run, next_run, last_error, runs)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (name) DO UPDATE
+	SET last_run = EXCLUDED.last_run, next_run = EXCLUDED.next_run,
+		last_error = EXCLUDED.last_error, runs = EXCLUDED.runs
+	`
+	_, err := s.db.ExecContext(ctx, query, state.Name, nullTime(state.LastRun), nullTime(state.NextRun), state.LastError, state.Runs)
+	return err
+}
+
+// Claim is a compare-and-set on next_run: of the processes claiming the same
+// slot, only the first one updates a row.
+func (s *SQLStore) Claim(ctx context.Context, name string, slot, next time.Time) (bool, error) {
+	query := `
+	UPDATE scheduler_jobs
+	SET next_run = $1
+	WHERE name = $2 AND next_run = $3
+	`
+	res, err := s.db.ExecContext(ctx, query, nullTime(next), name, nullTime(slot))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// nullTime stores t in UTC with the microsecond precision of Postgres
+// timestamps, so a NextRun read back compares equal to the one a Claim passes
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC().Truncate(time.Microsecond), Valid: !t.IsZero()}
+}
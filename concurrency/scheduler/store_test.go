@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// testStores returns a constructor for every Store; the stores a
+// constructor returns for one test share their state like two processes
+// sharing a file or a database
+func testStores(t *testing.T) map[string]func() Store {
+	path := filepath.Join(t.TempDir(), "state.json")
+	db := openTestDB(t)
+	memory := NewMemoryStore()
+	return map[string]func() Store{
+		"memory": func() Store { return memory },
+		"file":   func() Store { return NewFileStore(path) },
+		"sql":    func() Store { return NewSQLStore(db) },
+	}
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := NewSQLStore(db).Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestStoreSaveAndLoad(t *testing.T) {
+	ctx := context.Background()
+	for name, newStore := range testStores(t) {
+		store := newStore()
+		if _, ok, err := store.Load(ctx, "cleanup"); ok || err != nil {
+			t.Fatalf("%s: Load() of a new job = %v, %v", name, ok, err)
+		}
+
+		want := JobState{
+			Name:      "cleanup",
+			LastRun:   time.Now().Add(-time.Minute),
+			NextRun:   time.Now().Add(time.Minute),
+			LastError: "boom",
+			Runs:      3,
+		}
+		if err := store.Save(ctx, want); err != nil {
+			t.Fatalf("%s: Save(): %v", name, err)
+		}
+		want.LastError = ""
+		want.Runs = 4
+		if err := store.Save(ctx, want); err != nil {
+			t.Fatalf("%s: Save() again: %v", name, err)
+		}
+
+		got, ok, err := newStore().Load(ctx, "cleanup")
+		if err != nil || !ok {
+			t.Fatalf("%s: Load() = %v, %v", name, ok, err)
+		}
+		if got.Runs != want.Runs || got.LastError != want.LastError ||
+			got.LastRun.Sub(want.LastRun).Abs() > time.Microsecond || got.NextRun.Sub(want.NextRun).Abs() > time.Microsecond {
+			t.Errorf("%s: Load() = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestStoreClaim(t *testing.T) {
+	ctx := context.Background()
+	slot := time.Now().Add(time.Minute)
+	next := slot.Add(time.Minute)
+	for name, newStore := range testStores(t) {
+		first, second := newStore(), newStore()
+		if won, err := first.Claim(ctx, "cleanup", slot, next); won || err != nil {
+			t.Fatalf("%s: Claim() of a new job = %v, %v", name, won, err)
+		}
+		if err := first.Save(ctx, JobState{Name: "cleanup", NextRun: slot, Runs: 2}); err != nil {
+			t.Fatal(err)
+		}
+
+		// both loaded the slot, only one of them may fire it
+		if won, err := first.Claim(ctx, "cleanup", slot, next); !won || err != nil {
+			t.Fatalf("%s: first Claim() = %v, %v", name, won, err)
+		}
+		if won, err := second.Claim(ctx, "cleanup", slot, next); won || err != nil {
+			t.Fatalf("%s: second Claim() = %v, %v", name, won, err)
+		}
+
+		state, _, err := second.Load(ctx, "cleanup")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.NextRun.Sub(next).Abs() > time.Microsecond || state.Runs != 2 {
+			t.Errorf("%s: after Claim() state = %+v, want NextRun %v and the runs kept", name, state, next)
+		}
+	}
+}
+
+func TestSchedulersSharingStoreFireOnce(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	const duration = 210 * time.Millisecond
+
+	for name, newStore := range testStores(t) {
+		var mu sync.Mutex
+		runs := make(map[string]int)
+		ctx, cancel := context.WithTimeout(context.Background(), duration)
+		var wg sync.WaitGroup
+		for _, process := range []string{"a", "b"} {
+			s := newTestScheduler(newStore())
+			s.Add(Job{
+				Name:     "tick",
+				Schedule: Every(interval),
+				Run: func(ctx context.Context) error {
+					mu.Lock()
+					runs[process]++
+					mu.Unlock()
+					return nil
+				},
+			})
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.Run(ctx)
+			}()
+		}
+		wg.Wait()
+		cancel()
+
+		total := runs["a"] + runs["b"]
+		if slots := int(duration/interval) + 1; total > slots || total < slots/2 {
+			t.Errorf("%s: %d runs (%v) in %d slots, want each slot fired once", name, total, runs, slots)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// APIKeyAuthenticator accepts static API keys
+// Only the SHA-256 of the keys is kept, so lookups do not leak them through timing
+type APIKeyAuthenticator struct {
+	subjects map[[sha256.Size]byte]string
+}
+
+// NewAPIKeyAuthenticator returns an authenticator for keys, which maps each key to its subject
+func NewAPIKeyAuthenticator(keys map[string]string) *APIKeyAuthenticator {
+	subjects := make(map[[sha256.Size]byte]string, len(keys))
+	for key, subject := range keys {
+		subjects[sha256.Sum256([]byte(key))] = subject
+	}
+	return &APIKeyAuthenticator{subjects: subjects}
+}
+
+// LoadAPIKeys reads the keys of a JSON file: {"<key>": "<subject>", ...}
+func LoadAPIKeys(path string) (*APIKeyAuthenticator, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys map[string]string
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("cannot parse API keys %s: %v", path, err)
+	}
+	for key, subject := range keys {
+		if key == "" || subject == "" {
+			return nil, fmt.Errorf("API keys %s: empty key or subject", path)
+		}
+	}
+	return NewAPIKeyAuthenticator(keys), nil
+}
+
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	subject, ok := a.subjects[sha256.Sum256([]byte(token))]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return &Identity{Subject: subject, Method: "api-key"}, nil
+}
@@ -0,0 +1,201 @@
+// Package auth authenticates the callers of the gRPC servers
+//
+// Clients send a bearer token in the "authorization" metadata, or present a
+// client certificate over mutual TLS; the server interceptors check it and put
+// the caller's Identity in the context of the handler.
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrInvalidToken is returned by authenticators for a token they do not accept
+var ErrInvalidToken = errors.New("invalid token")
+
+// Identity is an authenticated caller
+type Identity struct {
+	// Subject names the caller, e.g. the "sub" claim of a JWT
+	Subject string
+	// Method is the authenticator that accepted the caller: "jwt", "api-key" or "client-cert"
+	Method string
+}
+
+// Authenticator turns a bearer token into the identity of its caller
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Identity, error)
+}
+
+// Chain returns an authenticator trying each of authenticators in turn
+// The first one accepting the token wins
+func Chain(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+type chain []Authenticator
+
+func (c chain) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	for _, a := range c {
+		id, err := a.Authenticate(ctx, token)
+		if err == nil {
+			return id, nil
+		}
+		if err != ErrInvalidToken {
+			return nil, err
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+type identityKey struct{}
+
+// NewContext returns a copy of ctx carrying id
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity of the caller, if it was authenticated
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// Interceptor authenticates every call but the ones to public methods
+type Interceptor struct {
+	authenticator Authenticator
+	clientCerts   *ClientCertAuthenticator
+	public        map[string]bool
+}
+
+// NewInterceptor returns an interceptor checking tokens with authenticator,
+// which may be nil to only accept client certificates, see WithClientCerts
+// publicMethods are full method names, e.g. "/blog.BlogService/ReadBlog",
+// that can be called without a token
+func NewInterceptor(authenticator Authenticator, publicMethods ...string) *Interceptor {
+	public := make(map[string]bool)
+	for _, method := range publicMethods {
+		public[method] = true
+	}
+	return &Interceptor{authenticator: authenticator, public: public}
+}
+
+// WithClientCerts also accepts the callers presenting a client certificate
+// A bearer token, when sent, still wins: e.g. a gateway with its own certificate
+// forwarding the tokens of its users.
+func (i *Interceptor) WithClientCerts(a *ClientCertAuthenticator) *Interceptor {
+	i.clientCerts = a
+	return i
+}
+
+// Unary returns the interceptor of unary calls
+func (i *Interceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := i.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream returns the interceptor of streaming calls
+func (i *Interceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := i.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (i *Interceptor) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if i.public[method] {
+		return ctx, nil
+	}
+
+	token, err := bearerToken(ctx)
+	if err != nil {
+		if i.clientCerts != nil && !hasAuthorization(ctx) {
+			return i.authenticateCert(ctx)
+		}
+		return nil, err
+	}
+	if i.authenticator == nil {
+		// only client certificates are accepted
+		return nil, status.Errorf(codes.Unauthenticated, "Invalid token")
+	}
+	id, err := i.authenticator.Authenticate(ctx, token)
+	if err == ErrInvalidToken {
+		return nil, status.Errorf(codes.Unauthenticated, "Invalid token")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Cannot authenticate: %v", err)
+	}
+	return NewContext(ctx, id), nil
+}
+
+func (i *Interceptor) authenticateCert(ctx context.Context) (context.Context, error) {
+	id, err := i.clientCerts.AuthenticatePeer(ctx)
+	if err == ErrInvalidToken {
+		return nil, status.Errorf(codes.Unauthenticated, "Missing authorization metadata or known client certificate")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Cannot authenticate: %v", err)
+	}
+	return NewContext(ctx, id), nil
+}
+
+func hasAuthorization(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return len(md.Get("authorization")) > 0
+}
+
+// bearerToken reads the token of the "authorization: Bearer <token>" metadata
+func bearerToken(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", status.Errorf(codes.Unauthenticated, "Missing authorization metadata")
+	}
+	const prefix = "bearer "
+	if len(values[0]) <= len(prefix) || !strings.EqualFold(values[0][:len(prefix)], prefix) {
+		return "", status.Errorf(codes.Unauthenticated, "Authorization is not a bearer token")
+	}
+	return strings.TrimSpace(values[0][len(prefix):]), nil
+}
+
+// serverStream hands the authenticated context to stream handlers
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// BearerToken returns call credentials sending token as the authorization metadata
+// Tokens are secrets, so they are only sent over TLS unless insecure is set
+func BearerToken(token string, insecure bool) grpc.DialOption {
+	return grpc.WithPerRPCCredentials(bearerCredentials{token: token, insecure: insecure})
+}
+
+type bearerCredentials struct {
+	token    string
+	insecure bool
+}
+
+func (c bearerCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + c.token}, nil
+}
+
+func (c bearerCredentials) RequireTransportSecurity() bool {
+	return !c.insecure
+}
@@ -0,0 +1,386 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var testNow = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+type testKeys struct {
+	rsa   *rsa.PrivateKey
+	ec    *ecdsa.PrivateKey
+	ec384 *ecdsa.PrivateKey
+	jwks  string
+}
+
+// newTestKeys writes a JWKS file with an RSA key "rsa", a P-256 key "ec" and a P-384 key "ec384"
+func newTestKeys(t *testing.T) *testKeys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N), "e": b64(big.NewInt(int64(rsaKey.E)))},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X), "y": b64(ecKey.Y)},
+		{"kty": "EC", "kid": "ec384", "crv": "P-384", "x": b64(ec384Key.X), "y": b64(ec384Key.Y)},
+		{"kty": "RSA", "kid": "encryption", "use": "enc", "n": b64(rsaKey.N), "e": "AQAB"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(path, jwks, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return &testKeys{rsa: rsaKey, ec: ecKey, ec384: ec384Key, jwks: path}
+}
+
+// sign returns a JWT of claims signed with the key kid
+// EC tokens are signed with the key kid whatever the curve alg names
+func (k *testKeys) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	var err error
+	switch alg {
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+	case "ES256", "ES384":
+		key, hash := k.ec, crypto.SHA256
+		if kid == "ec384" {
+			key = k.ec384
+		}
+		if alg == "ES384" {
+			hash = crypto.SHA384
+		}
+		h := hash.New()
+		h.Write([]byte(signed))
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, h.Sum(nil))
+		if err == nil {
+			size := (key.Curve.Params().BitSize + 7) / 8
+			signature = make([]byte, 2*size)
+			r.FillBytes(signature[:size])
+			s.FillBytes(signature[size:])
+		}
+	case "HS256":
+		// forges a token using the public key as the HMAC secret
+		mac := hmac.New(sha256.New, k.rsa.PublicKey.N.Bytes())
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func claims(overrides map[string]interface{}) map[string]interface{} {
+	c := map[string]interface{}{
+		"sub": "alice",
+		"iss": "https://issuer.example",
+		"aud": "blog",
+		"exp": testNow.Add(time.Hour).Unix(),
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	keys := newTestKeys(t)
+	a, err := LoadJWKS(keys.jwks, "https://issuer.example", "blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.now = func() time.Time { return testNow }
+	ctx := context.Background()
+
+	for _, alg := range []string{"RS256", "ES256", "ES384"} {
+		kid := map[string]string{"RS256": "rsa", "ES256": "ec", "ES384": "ec384"}[alg]
+		id, err := a.Authenticate(ctx, keys.sign(t, alg, kid, claims(nil)))
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if id.Subject != "alice" || id.Method != "jwt" {
+			t.Fatalf("%s: got %+v", alg, id)
+		}
+	}
+	if _, err := a.Authenticate(ctx, keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"aud": []string{"other", "blog"}}))); err != nil {
+		t.Fatalf("audience array: %v", err)
+	}
+
+	tampered := keys.sign(t, "RS256", "rsa", claims(nil))
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+
+	for name, token := range map[string]string{
+		"expired":          keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"exp": testNow.Unix()})),
+		"no expiry":        keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"exp": nil})),
+		"not yet valid":    keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"nbf": testNow.Add(time.Minute).Unix()})),
+		"other issuer":     keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"iss": "https://evil.example"})),
+		"other audience":   keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"aud": "calculator"})),
+		"no subject":       keys.sign(t, "RS256", "rsa", claims(map[string]interface{}{"sub": nil})),
+		"unknown key":      keys.sign(t, "RS256", "other", claims(nil)),
+		"encryption key":   keys.sign(t, "RS256", "encryption", claims(nil)),
+		"algorithm of ec":  keys.sign(t, "RS256", "ec", claims(nil)),
+		"curve of alg":     keys.sign(t, "ES256", "ec384", claims(nil)),
+		"alg of curve":     keys.sign(t, "ES384", "ec", claims(nil)),
+		"hmac with pubkey": keys.sign(t, "HS256", "rsa", claims(nil)),
+		"tampered":         tampered,
+		"not a jwt":        "abc",
+	} {
+		if _, err := a.Authenticate(ctx, token); err != ErrInvalidToken {
+			t.Errorf("%s: got %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestLoadJWKS_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, jwks := range map[string]string{
+		"not json":      `keys`,
+		"no keys":       `{"keys": []}`,
+		"no kid":        `{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`,
+		"off the curve": `{"keys": [{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`,
+		"symmetric key": `{"keys": [{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"}]}`,
+	} {
+		path := filepath.Join(dir, "jwks.json")
+		if err := ioutil.WriteFile(path, []byte(jwks), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadJWKS(path, "", ""); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := ioutil.WriteFile(path, []byte(`{"alice-key": "alice", "bob-key": "bob"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := LoadAPIKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := a.Authenticate(context.Background(), "bob-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Subject != "bob" || id.Method != "api-key" {
+		t.Fatalf("got %+v", id)
+	}
+	if _, err := a.Authenticate(context.Background(), "carol-key"); err != ErrInvalidToken {
+		t.Fatalf("unknown key: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestChain(t *testing.T) {
+	a := Chain(
+		NewAPIKeyAuthenticator(map[string]string{"first": "alice"}),
+		NewAPIKeyAuthenticator(map[string]string{"first": "bob", "second": "carol"}),
+	)
+	for token, subject := range map[string]string{"first": "alice", "second": "carol"} {
+		id, err := a.Authenticate(context.Background(), token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id.Subject != subject {
+			t.Fatalf("%s: got subject %q, want %q", token, id.Subject, subject)
+		}
+	}
+	if _, err := a.Authenticate(context.Background(), "third"); err != ErrInvalidToken {
+		t.Fatalf("got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	i := NewInterceptor(NewAPIKeyAuthenticator(map[string]string{"secret": "alice"}), "/test.Service/Public")
+	unary := i.Unary()
+
+	call := func(method string, md metadata.MD) (*Identity, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+		var id *Identity
+		_, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			id, _ = FromContext(ctx)
+			return nil, nil
+		})
+		return id, err
+	}
+
+	id, err := call("/test.Service/Private", metadata.Pairs("authorization", "Bearer secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == nil || id.Subject != "alice" {
+		t.Fatalf("got identity %+v", id)
+	}
+	if _, err := call("/test.Service/Private", metadata.Pairs("authorization", "bearer   secret")); err != nil {
+		t.Fatalf("lower-case scheme: %v", err)
+	}
+
+	for name, md := range map[string]metadata.MD{
+		"no metadata":   nil,
+		"basic auth":    metadata.Pairs("authorization", "Basic c2VjcmV0"),
+		"empty bearer":  metadata.Pairs("authorization", "Bearer "),
+		"invalid token": metadata.Pairs("authorization", "Bearer wrong"),
+	} {
+		_, err := call("/test.Service/Private", md)
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s: got %v, want Unauthenticated", name, err)
+		}
+	}
+
+	id, err = call("/test.Service/Public", nil)
+	if err != nil {
+		t.Fatalf("public method: %v", err)
+	}
+	if id != nil {
+		t.Fatalf("public method has identity %+v", id)
+	}
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestInterceptor_Stream(t *testing.T) {
+	stream := NewInterceptor(NewAPIKeyAuthenticator(map[string]string{"secret": "alice"})).Stream()
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Watch", IsServerStream: true}
+
+	ss := &testServerStream{ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))}
+	var id *Identity
+	err := stream(nil, ss, info, func(srv interface{}, ss grpc.ServerStream) error {
+		id, _ = FromContext(ss.Context())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == nil || id.Subject != "alice" {
+		t.Fatalf("got identity %+v", id)
+	}
+
+	ss = &testServerStream{ctx: context.Background()}
+	err = stream(nil, ss, info, func(srv interface{}, ss grpc.ServerStream) error {
+		t.Fatal("handler called without a token")
+		return nil
+	})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("got %v, want Unauthenticated", err)
+	}
+}
+
+// certContext returns the context of a call over mutual TLS, from a client certificate named commonName
+func certContext(md metadata.MD, commonName string) context.Context {
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	return peer.NewContext(ctx, &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}},
+	})
+}
+
+func TestInterceptor_ClientCerts(t *testing.T) {
+	call := func(i *Interceptor, ctx context.Context) (*Identity, error) {
+		var id *Identity
+		_, err := i.Unary()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Private"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			id, _ = FromContext(ctx)
+			return nil, nil
+		})
+		return id, err
+	}
+	i := NewInterceptor(NewAPIKeyAuthenticator(map[string]string{"secret": "alice"})).
+		WithClientCerts(NewClientCertAuthenticator(map[string]string{"bob-laptop": "bob"}))
+
+	id, err := call(i, certContext(nil, "bob-laptop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == nil || id.Subject != "bob" || id.Method != "client-cert" {
+		t.Fatalf("got identity %+v", id)
+	}
+	// the token of a user wins over the certificate of the client
+	id, err = call(i, certContext(metadata.Pairs("authorization", "Bearer secret"), "bob-laptop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == nil || id.Subject != "alice" {
+		t.Fatalf("got identity %+v, want the one of the token", id)
+	}
+
+	for name, ctx := range map[string]context.Context{
+		"unknown common name":    certContext(nil, "eve-laptop"),
+		"invalid token and cert": certContext(metadata.Pairs("authorization", "Bearer wrong"), "bob-laptop"),
+		"no certificate":         peer.NewContext(context.Background(), &peer.Peer{}),
+	} {
+		if _, err := call(i, ctx); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s: got %v, want Unauthenticated", name, err)
+		}
+	}
+
+	// without a mapping the common name is the subject, and without an
+	// authenticator tokens are refused
+	i = NewInterceptor(nil).WithClientCerts(NewClientCertAuthenticator(nil))
+	id, err = call(i, certContext(nil, "carol"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == nil || id.Subject != "carol" {
+		t.Fatalf("got identity %+v", id)
+	}
+	if _, err := call(i, certContext(metadata.Pairs("authorization", "Bearer secret"), "carol")); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("token without authenticator: got %v, want Unauthenticated", err)
+	}
+}
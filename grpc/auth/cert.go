@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// ClientCertAuthenticator identifies callers by the certificate they present
+// over mutual TLS, from its common name
+// The server checks the certificate during the handshake, so only its name is looked at here.
+type ClientCertAuthenticator struct {
+	subjects map[string]string
+}
+
+// NewClientCertAuthenticator maps each common name to its subject
+// With no subjects, the common name itself is the subject of every certificate.
+func NewClientCertAuthenticator(subjects map[string]string) *ClientCertAuthenticator {
+	return &ClientCertAuthenticator{subjects: subjects}
+}
+
+// LoadClientCerts reads the subjects of a JSON file: {"<common name>": "<subject>", ...}
+func LoadClientCerts(path string) (*ClientCertAuthenticator, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var subjects map[string]string
+	if err := json.Unmarshal(data, &subjects); err != nil {
+		return nil, fmt.Errorf("cannot parse client certificates %s: %v", path, err)
+	}
+	for name, subject := range subjects {
+		if name == "" || subject == "" {
+			return nil, fmt.Errorf("client certificates %s: empty common name or subject", path)
+		}
+	}
+	return NewClientCertAuthenticator(subjects), nil
+}
+
+// AuthenticatePeer returns the identity of the verified certificate of the caller,
+// ErrInvalidToken if there is none or its common name is unknown
+func (a *ClientCertAuthenticator) AuthenticatePeer(ctx context.Context) (*Identity, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil, ErrInvalidToken
+	}
+	name := info.State.VerifiedChains[0][0].Subject.CommonName
+	if name == "" {
+		return nil, ErrInvalidToken
+	}
+	subject := name
+	if a.subjects != nil {
+		if subject, ok = a.subjects[name]; !ok {
+			return nil, ErrInvalidToken
+		}
+	}
+	return &Identity{Subject: subject, Method: "client-cert"}, nil
+}
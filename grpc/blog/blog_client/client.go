@@ -2,20 +2,49 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"os"
+	"time"
 
-	"../blogpb"
-	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"grpc/auth"
+	"grpc/blog/blogpb"
+	"grpc/grpcclient"
 )
 
 func main() {
 	fmt.Println("Blog Client")
 
-	opts := grpc.WithInsecure()
+	token := flag.String("token", os.Getenv("BLOG_TOKEN"), "JWT or API key of the author (default $BLOG_TOKEN)")
+	watch := flag.Bool("watch", false, "print the changes to the blogs instead of running the demo")
+	watchAuthor := flag.String("watch-author", "", "only watch the blogs of this author")
+	watchTag := flag.String("watch-tag", "", "only watch the blogs with this tag")
+	cfg := grpcclient.RegisterFlags(grpcclient.Config{
+		Name:   "blog",
+		Target: "localhost:51051",
+		// the reads are retried; the writes are not, a retried CreateBlog
+		// could create the blog twice
+		Policies: []grpcclient.MethodPolicy{{
+			Service: "blog.BlogService",
+			Methods: []string{"ReadBlog", "ListBlog", "SearchBlogs", "ListTags", "ListRevisions", "ListComments"},
+			Retry:   grpcclient.DefaultRetryPolicy(),
+		}},
+	})
+	flag.Parse()
+	if *token == "" && cfg.CertFile == "" && !*watch {
+		log.Fatal("a token or a client certificate is needed to write blogs: set -token, BLOG_TOKEN or -tls-cert")
+	}
+
+	// without TLS the token is sent in the clear: local use only
+	if *token != "" {
+		cfg.DialOptions = append(cfg.DialOptions, auth.BearerToken(*token, cfg.CAFile == ""))
+	}
 
-	cc, err := grpc.Dial("localhost:51051", opts)
+	cc, err := grpcclient.Dial(*cfg)
 	if err != nil {
 		log.Fatalf("could not connect: %v", err)
 	}
@@ -23,12 +52,18 @@ func main() {
 
 	c := blogpb.NewBlogServiceClient(cc)
 
+	if *watch {
+		watchBlogs(c, &blogpb.WatchBlogsRequest{AuthorId: *watchAuthor, Tag: *watchTag})
+		return
+	}
+
 	// create Blog
 	fmt.Println("Creating the blog")
+	// the server sets the author from the token
 	blog := &blogpb.Blog{
-		AuthorId: "Tuyen",
-		Title:    "My First Blog",
-		Content:  "Content of the first blog",
+		Title:   "My First Blog",
+		Content: "Content of the first blog",
+		Tags:    []string{"first", "go"},
 	}
 	createBlogRes, err := c.CreateBlog(context.Background(), &blogpb.CreateBlogRequest{Blog: blog})
 	if err != nil {
@@ -55,10 +90,9 @@ func main() {
 
 	// update Blog
 	newBlog := &blogpb.Blog{
-		Id:       blogID,
-		AuthorId: "Changed Author",
-		Title:    "My First Blog (edited)",
-		Content:  "Content of the first blog, with some awesome additions!",
+		Id:      blogID,
+		Title:   "My First Blog (edited)",
+		Content: "Content of the first blog, with some awesome additions!",
 	}
 	updateRes, updateErr := c.UpdateBlog(context.Background(), &blogpb.UpdateBlogRequest{Blog: newBlog})
 	if updateErr != nil {
@@ -66,6 +100,37 @@ func main() {
 	}
 	fmt.Printf("Blog was updated: %v\n", updateRes)
 
+	// revisions of Blog: the update made revision 2, restoring revision 1 makes revision 3
+	revisionsRes, revisionsErr := c.ListRevisions(context.Background(), &blogpb.ListRevisionsRequest{BlogId: blogID})
+	if revisionsErr != nil {
+		fmt.Printf("Error happened while listing revisions: %v \n", revisionsErr)
+	}
+	fmt.Printf("Blog has revisions: %v \n", revisionsRes.GetRevisions())
+	restoreRes, restoreErr := c.RestoreRevision(context.Background(), &blogpb.RestoreRevisionRequest{BlogId: blogID, Revision: 1})
+	if restoreErr != nil {
+		fmt.Printf("Error happened while restoring: %v \n", restoreErr)
+	}
+	fmt.Printf("Blog was restored: %v \n", restoreRes)
+
+	// comment on Blog, and reply to the comment
+	commentRes, commentErr := c.AddComment(context.Background(), &blogpb.AddCommentRequest{
+		Comment: &blogpb.Comment{BlogId: blogID, Content: "Great first blog!"},
+	})
+	if commentErr != nil {
+		fmt.Printf("Error happened while commenting: %v \n", commentErr)
+	}
+	_, replyErr := c.AddComment(context.Background(), &blogpb.AddCommentRequest{
+		Comment: &blogpb.Comment{BlogId: blogID, ParentId: commentRes.GetComment().GetId(), Content: "Thanks!"},
+	})
+	if replyErr != nil {
+		fmt.Printf("Error happened while replying: %v \n", replyErr)
+	}
+	commentsRes, commentsErr := c.ListComments(context.Background(), &blogpb.ListCommentsRequest{BlogId: blogID})
+	if commentsErr != nil {
+		fmt.Printf("Error happened while listing comments: %v \n", commentsErr)
+	}
+	fmt.Printf("Blog has comments: %v \n", commentsRes.GetComments())
+
 	// delete Blog
 	deleteRes, deleteErr := c.DeleteBlog(context.Background(), &blogpb.DeleteBlogRequest{BlogId: blogID})
 
@@ -74,19 +139,77 @@ func main() {
 	}
 	fmt.Printf("Blog was deleted: %v \n", deleteRes)
 
-	// list Blogs
-	stream, err := c.ListBlog(context.Background(), &blogpb.ListBlogRequest{})
-	if err != nil {
-		log.Fatalf("error while calling ListBlog RPC: %v", err)
-	}
-	for {
-		res, err := stream.Recv()
-		if err == io.EOF {
+	// list Blogs, one page at a time
+	pageToken := ""
+	for page := 1; ; page++ {
+		fmt.Printf("Listing page %d\n", page)
+		stream, err := c.ListBlog(context.Background(), &blogpb.ListBlogRequest{
+			PageSize:  10,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			log.Fatalf("error while calling ListBlog RPC: %v", err)
+		}
+		pageToken = ""
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Fatalf("Something happened: %v", err)
+			}
+			fmt.Println(res.GetBlog())
+			pageToken = res.GetNextPageToken()
+		}
+		if pageToken == "" {
 			break
 		}
+	}
+
+	// tags in use
+	tagsRes, tagsErr := c.ListTags(context.Background(), &blogpb.ListTagsRequest{})
+	if tagsErr != nil {
+		fmt.Printf("Error happened while listing tags: %v \n", tagsErr)
+	}
+	fmt.Printf("Tags: %v \n", tagsRes.GetTags())
+
+	// search Blogs
+	searchRes, searchErr := c.SearchBlogs(context.Background(), &blogpb.SearchBlogsRequest{Query: "first blog"})
+	if searchErr != nil {
+		fmt.Printf("Error happened while searching: %v \n", searchErr)
+	}
+	fmt.Printf("Found %d blogs: %v \n", searchRes.GetTotalCount(), searchRes.GetBlogs())
+
+	fmt.Print(cc.Metrics)
+}
+
+// watchBlogs prints the changes to the blogs until the program is stopped
+// When the stream breaks it resumes after the last event, so no change is missed
+func watchBlogs(c blogpb.BlogServiceClient, req *blogpb.WatchBlogsRequest) {
+	fmt.Println("Watching the blogs")
+	for {
+		stream, err := c.WatchBlogs(context.Background(), req)
 		if err != nil {
-			log.Fatalf("Something happened: %v", err)
+			log.Fatalf("error while calling WatchBlogs RPC: %v", err)
+		}
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				switch status.Code(err) {
+				case codes.Unavailable:
+					fmt.Printf("Stream broke, resuming: %v\n", err)
+					time.Sleep(time.Second)
+				case codes.OutOfRange:
+					fmt.Printf("Missed some changes, watching from now: %v\n", err)
+					req.ResumeToken = ""
+				default:
+					log.Fatalf("Something happened: %v", err)
+				}
+				break
+			}
+			fmt.Printf("%v: %v\n", event.GetType(), event.GetBlog())
+			req.ResumeToken = event.GetResumeToken()
 		}
-		fmt.Println(res.GetBlog())
 	}
 }
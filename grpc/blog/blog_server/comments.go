@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"grpc/blog/blogpb"
+)
+
+func (s *server) AddComment(ctx context.Context, req *blogpb.AddCommentRequest) (*blogpb.AddCommentResponse, error) {
+	fmt.Println("Add comment request")
+	comment := req.GetComment()
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if comment.GetContent() == "" {
+		return nil, status.Errorf(
+			codes.InvalidArgument,
+			"Comment is empty",
+		)
+	}
+
+	created, err := s.repo.AddComment(ctx, &Comment{
+		BlogID:   comment.GetBlogId(),
+		ParentID: comment.GetParentId(),
+		AuthorID: caller,
+		Content:  comment.GetContent(),
+	})
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+
+	return &blogpb.AddCommentResponse{
+		Comment: dataToCommentPb(created),
+	}, nil
+}
+
+func (s *server) ListComments(ctx context.Context, req *blogpb.ListCommentsRequest) (*blogpb.ListCommentsResponse, error) {
+	fmt.Println("List comments request")
+
+	offset, limit, err := pageBounds(req.GetPageSize(), req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	comments, err := s.repo.ListComments(ctx, req.GetBlogId(), offset, limit+1)
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+
+	res := &blogpb.ListCommentsResponse{
+		NextPageToken: nextPageToken(offset, limit, len(comments)),
+	}
+	for i, comment := range comments {
+		if int64(i) == limit {
+			break
+		}
+		res.Comments = append(res.Comments, dataToCommentPb(comment))
+	}
+	return res, nil
+}
+
+func dataToCommentPb(data *Comment) *blogpb.Comment {
+	return &blogpb.Comment{
+		Id:        data.ID,
+		BlogId:    data.BlogID,
+		ParentId:  data.ParentID,
+		AuthorId:  data.AuthorID,
+		Content:   data.Content,
+		CreatedAt: timestamppb.New(data.CreatedAt),
+	}
+}
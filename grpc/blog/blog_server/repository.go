@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Blog is a stored blog, whatever the backend
+type Blog struct {
+	ID        string
+	AuthorID  string
+	Title     string
+	Content   string
+	Tags      []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// Revision is the number of the latest revision, 1 for a blog never updated
+	Revision int32
+}
+
+// Revision is an immutable copy of a blog, saved on each create and update
+type Revision struct {
+	BlogID    string
+	Revision  int32
+	AuthorID  string
+	Title     string
+	Content   string
+	Tags      []string
+	CreatedAt time.Time
+}
+
+// Comment is a comment on a blog, or a reply to another comment of the same blog
+type Comment struct {
+	ID        string
+	BlogID    string
+	ParentID  string // empty for a comment on the blog itself
+	AuthorID  string
+	Content   string
+	CreatedAt time.Time
+}
+
+// TagCount is a tag and the number of blogs having it
+type TagCount struct {
+	Tag   string
+	Count int64
+}
+
+var (
+	// ErrNotFound is returned for an ID that is well-formed but unknown
+	ErrNotFound = errors.New("blog not found")
+	// ErrInvalidID is returned for an ID that no backend could have issued
+	ErrInvalidID = errors.New("invalid blog ID")
+	// ErrInvalidParent is returned for a reply to a comment missing from the blog
+	ErrInvalidParent = errors.New("parent comment not found on the blog")
+)
+
+// BlogRepository stores the blogs of the server, their revisions and their comments
+//
+// All implementations pass the same conformance suite (repository_test.go):
+//   - IDs are ObjectID hex strings, so newer blogs and comments have greater IDs in every backend
+//   - timestamps are UTC and truncated to the millisecond, the precision of MongoDB
+//   - a blog matches a query when its title or content contains one of the query's
+//     words, ignoring case, even as part of a longer word; RELEVANCE ranks title
+//     matches above content matches
+type BlogRepository interface {
+	// Create stores a new blog and its first revision, and returns it with its ID and timestamps
+	Create(ctx context.Context, blog *Blog) (*Blog, error)
+	Get(ctx context.Context, id string) (*Blog, error)
+	// Update replaces the author, title, content and tags of an existing blog
+	// and saves them as its next revision
+	Update(ctx context.Context, blog *Blog) (*Blog, error)
+	// Delete removes a blog with its revisions and comments
+	Delete(ctx context.Context, id string) error
+	// List returns the blogs matching opts, sorted, skipping opts.offset and at most opts.limit
+	List(ctx context.Context, opts *listOptions) ([]*Blog, error)
+	// Count returns the number of blogs matching opts, ignoring offset and limit
+	Count(ctx context.Context, opts *listOptions) (int64, error)
+	// ListTags returns every tag in use, the most used first
+	ListTags(ctx context.Context) ([]TagCount, error)
+	// Watch calls fn with each change to the blogs after resumeToken, or from now if it is empty,
+	// until ctx is done or fn fails; it returns ErrInvalidToken or ErrTokenExpired for a token
+	// it cannot resume from
+	Watch(ctx context.Context, resumeToken string, fn func(*BlogEvent) error) error
+
+	// GetRevision returns one revision of a blog
+	GetRevision(ctx context.Context, blogID string, revision int32) (*Revision, error)
+	// ListRevisions returns the revisions of a blog, the latest first
+	ListRevisions(ctx context.Context, blogID string, offset, limit int64) ([]*Revision, error)
+
+	// AddComment stores a new comment and returns it with its ID and creation time
+	AddComment(ctx context.Context, comment *Comment) (*Comment, error)
+	// ListComments returns the comments of a blog, the oldest first
+	ListComments(ctx context.Context, blogID string, offset, limit int64) ([]*Comment, error)
+}
+
+// openRepository connects to the storage selected by the -store flag
+// The returned func closes it
+func openRepository(ctx context.Context, store, mongoURI, dsn string) (BlogRepository, func(), error) {
+	switch store {
+	case "mongo":
+		fmt.Println("Connecting to MongoDB")
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+		if err != nil {
+			return nil, nil, err
+		}
+		repo, err := newMongoRepository(ctx, client.Database("mydb"))
+		if err != nil {
+			client.Disconnect(context.Background())
+			return nil, nil, fmt.Errorf("failed to create indexes: %v", err)
+		}
+		return repo, func() { client.Disconnect(context.Background()) }, nil
+	case "memory":
+		return newMemoryRepository(), func() {}, nil
+	case "sqlite", "postgres":
+		driver := "postgres"
+		if store == "sqlite" {
+			driver = "sqlite3"
+		}
+		repo, err := newSQLRepository(ctx, driver, dsn)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, func() { repo.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown store %q", store)
+	}
+}
+
+// newBlogID issues the ID of a blog stored outside of MongoDB
+func newBlogID() string {
+	return primitive.NewObjectID().Hex()
+}
+
+// checkBlogID rejects IDs that are not ObjectID hex strings
+func checkBlogID(id string) error {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return ErrInvalidID
+	}
+	return nil
+}
+
+// now is the time of new blogs, revisions and comments, at the precision every backend keeps
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// copyBlog returns a copy of blog sharing nothing with it
+func copyBlog(blog *Blog) *Blog {
+	c := *blog
+	c.Tags = append([]string(nil), blog.Tags...)
+	return &c
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// revisionOf returns the revision saving blog as it is now
+func revisionOf(blog *Blog) *Revision {
+	return &Revision{
+		BlogID:    blog.ID,
+		Revision:  blog.Revision,
+		AuthorID:  blog.AuthorID,
+		Title:     blog.Title,
+		Content:   blog.Content,
+		Tags:      append([]string(nil), blog.Tags...),
+		CreatedAt: blog.UpdatedAt,
+	}
+}
+
+// sortTagCounts orders tags the most used first, then by name
+func sortTagCounts(tags []TagCount) {
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+}
+
+// searchTerms splits a query into the lower-case words a blog is matched against
+func searchTerms(query string) []string {
+	return strings.Fields(strings.ToLower(query))
+}
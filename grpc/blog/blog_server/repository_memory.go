@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"grpc/blog/blogpb"
+)
+
+// memoryRepository keeps blogs in process, for tests and local runs without MongoDB
+type memoryRepository struct {
+	mu    sync.RWMutex
+	blogs map[string]*Blog
+	// revisions of each blog, in order: revision n is at n-1
+	revisions map[string][]*Revision
+	// comments of each blog, the oldest first
+	comments map[string][]*Comment
+	events   *broadcaster
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		blogs:     make(map[string]*Blog),
+		revisions: make(map[string][]*Revision),
+		comments:  make(map[string][]*Comment),
+		events:    newBroadcaster(defaultBroadcastBacklog),
+	}
+}
+
+func (r *memoryRepository) Create(ctx context.Context, blog *Blog) (*Blog, error) {
+	created := copyBlog(blog)
+	created.ID = newBlogID()
+	created.CreatedAt = now()
+	created.UpdatedAt = created.CreatedAt
+	created.Revision = 1
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.blogs[created.ID] = created
+	r.revisions[created.ID] = []*Revision{revisionOf(created)}
+	r.events.publish(blogpb.BlogEventType_CREATED, created)
+	return copyBlog(created), nil
+}
+
+func (r *memoryRepository) Get(ctx context.Context, id string) (*Blog, error) {
+	if err := checkBlogID(id); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	blog, ok := r.blogs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyBlog(blog), nil
+}
+
+func (r *memoryRepository) Update(ctx context.Context, blog *Blog) (*Blog, error) {
+	if err := checkBlogID(blog.ID); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.blogs[blog.ID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	updated := copyBlog(blog)
+	updated.CreatedAt = current.CreatedAt
+	updated.UpdatedAt = now()
+	updated.Revision = current.Revision + 1
+	r.blogs[blog.ID] = updated
+	r.revisions[blog.ID] = append(r.revisions[blog.ID], revisionOf(updated))
+	r.events.publish(blogpb.BlogEventType_UPDATED, updated)
+	return copyBlog(updated), nil
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, id string) error {
+	if err := checkBlogID(id); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	blog, ok := r.blogs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(r.blogs, id)
+	delete(r.revisions, id)
+	delete(r.comments, id)
+	r.events.publish(blogpb.BlogEventType_DELETED, blog)
+	return nil
+}
+
+func (r *memoryRepository) List(ctx context.Context, opts *listOptions) ([]*Blog, error) {
+	matches := r.match(opts)
+
+	switch opts.sort {
+	case blogpb.SortOrder_OLDEST:
+		sort.Slice(matches, func(i, j int) bool { return matches[i].blog.ID < matches[j].blog.ID })
+	case blogpb.SortOrder_TITLE:
+		sort.Slice(matches, func(i, j int) bool {
+			if matches[i].blog.Title != matches[j].blog.Title {
+				return matches[i].blog.Title < matches[j].blog.Title
+			}
+			return matches[i].blog.ID < matches[j].blog.ID
+		})
+	case blogpb.SortOrder_RELEVANCE:
+		sort.Slice(matches, func(i, j int) bool {
+			if matches[i].score != matches[j].score {
+				return matches[i].score > matches[j].score
+			}
+			return matches[i].blog.ID > matches[j].blog.ID
+		})
+	default:
+		sort.Slice(matches, func(i, j int) bool { return matches[i].blog.ID > matches[j].blog.ID })
+	}
+
+	var blogs []*Blog
+	for i := opts.offset; i < int64(len(matches)) && int64(len(blogs)) < opts.limit; i++ {
+		blogs = append(blogs, matches[i].blog)
+	}
+	return blogs, nil
+}
+
+func (r *memoryRepository) Count(ctx context.Context, opts *listOptions) (int64, error) {
+	return int64(len(r.match(opts))), nil
+}
+
+func (r *memoryRepository) Watch(ctx context.Context, resumeToken string, fn func(*BlogEvent) error) error {
+	return r.events.watch(ctx, resumeToken, fn)
+}
+
+func (r *memoryRepository) ListTags(ctx context.Context) ([]TagCount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, blog := range r.blogs {
+		for _, tag := range blog.Tags {
+			counts[tag]++
+		}
+	}
+	var tags []TagCount
+	for tag, count := range counts {
+		tags = append(tags, TagCount{Tag: tag, Count: count})
+	}
+	sortTagCounts(tags)
+	return tags, nil
+}
+
+func (r *memoryRepository) GetRevision(ctx context.Context, blogID string, revision int32) (*Revision, error) {
+	if err := checkBlogID(blogID); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	revisions := r.revisions[blogID]
+	if revision < 1 || int(revision) > len(revisions) {
+		return nil, ErrNotFound
+	}
+	return copyRevision(revisions[revision-1]), nil
+}
+
+func (r *memoryRepository) ListRevisions(ctx context.Context, blogID string, offset, limit int64) ([]*Revision, error) {
+	if err := checkBlogID(blogID); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	revisions, ok := r.revisions[blogID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var page []*Revision
+	for i := int64(len(revisions)) - 1 - offset; i >= 0 && int64(len(page)) < limit; i-- {
+		page = append(page, copyRevision(revisions[i]))
+	}
+	return page, nil
+}
+
+func (r *memoryRepository) AddComment(ctx context.Context, comment *Comment) (*Comment, error) {
+	if err := checkBlogID(comment.BlogID); err != nil {
+		return nil, err
+	}
+	if comment.ParentID != "" && checkBlogID(comment.ParentID) != nil {
+		return nil, ErrInvalidParent
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.blogs[comment.BlogID]; !ok {
+		return nil, ErrNotFound
+	}
+	if comment.ParentID != "" && !hasComment(r.comments[comment.BlogID], comment.ParentID) {
+		return nil, ErrInvalidParent
+	}
+	created := *comment
+	created.ID = newBlogID()
+	created.CreatedAt = now()
+	r.comments[comment.BlogID] = append(r.comments[comment.BlogID], &created)
+	stored := created
+	return &stored, nil
+}
+
+func (r *memoryRepository) ListComments(ctx context.Context, blogID string, offset, limit int64) ([]*Comment, error) {
+	if err := checkBlogID(blogID); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if _, ok := r.blogs[blogID]; !ok {
+		return nil, ErrNotFound
+	}
+	comments := r.comments[blogID]
+	var page []*Comment
+	for i := offset; i < int64(len(comments)) && int64(len(page)) < limit; i++ {
+		found := *comments[i]
+		page = append(page, &found)
+	}
+	return page, nil
+}
+
+func hasComment(comments []*Comment, id string) bool {
+	for _, c := range comments {
+		if c.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func copyRevision(revision *Revision) *Revision {
+	c := *revision
+	c.Tags = append([]string(nil), revision.Tags...)
+	return &c
+}
+
+type memoryMatch struct {
+	blog  *Blog
+	score int
+}
+
+// match returns copies of the blogs matching the filters of opts, unsorted
+// The score weighs title matches above content matches like the other repositories do
+func (r *memoryRepository) match(opts *listOptions) []memoryMatch {
+	terms := searchTerms(opts.query)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matches []memoryMatch
+	for _, blog := range r.blogs {
+		if opts.authorID != "" && blog.AuthorID != opts.authorID {
+			continue
+		}
+		if opts.tag != "" && !hasTag(blog.Tags, opts.tag) {
+			continue
+		}
+		score := 0
+		if len(terms) > 0 {
+			title := strings.ToLower(blog.Title)
+			content := strings.ToLower(blog.Content)
+			for _, term := range terms {
+				if strings.Contains(title, term) {
+					score += 3
+				}
+				if strings.Contains(content, term) {
+					score++
+				}
+			}
+			if score == 0 {
+				continue
+			}
+		}
+		matches = append(matches, memoryMatch{blog: copyBlog(blog), score: score})
+	}
+	return matches
+}
@@ -0,0 +1,641 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"grpc/blog/blogpb"
+)
+
+// mongoRepository stores blogs in MongoDB
+// Revisions and comments have their own collections, keyed by blog_id
+// Changes are watched with the change stream of the blogs, which needs a replica set;
+// on a standalone server events holds the changes made by this process instead
+type mongoRepository struct {
+	blogs     *mongo.Collection
+	revisions *mongo.Collection
+	comments  *mongo.Collection
+	events    *broadcaster
+}
+
+type blogItem struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	AuthorID  string             `bson:"author_id"`
+	Content   string             `bson:"content"`
+	Title     string             `bson:"title"`
+	Tags      []string           `bson:"tags"`
+	CreatedAt time.Time          `bson:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at"`
+	Revision  int32              `bson:"revision"`
+}
+
+type revisionItem struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	BlogID    primitive.ObjectID `bson:"blog_id"`
+	Revision  int32              `bson:"revision"`
+	AuthorID  string             `bson:"author_id"`
+	Content   string             `bson:"content"`
+	Title     string             `bson:"title"`
+	Tags      []string           `bson:"tags"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+type commentItem struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty"`
+	BlogID    primitive.ObjectID  `bson:"blog_id"`
+	ParentID  *primitive.ObjectID `bson:"parent_id,omitempty"`
+	AuthorID  string              `bson:"author_id"`
+	Content   string              `bson:"content"`
+	CreatedAt time.Time           `bson:"created_at"`
+}
+
+// newMongoRepository returns a repository on db, creating its indexes
+func newMongoRepository(ctx context.Context, db *mongo.Database) (*mongoRepository, error) {
+	r := &mongoRepository{
+		blogs:     db.Collection("blog"),
+		revisions: db.Collection("blog_revisions"),
+		comments:  db.Collection("blog_comments"),
+	}
+	if err := r.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+	if err := r.checkChangeStreams(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *mongoRepository) Create(ctx context.Context, blog *Blog) (*Blog, error) {
+	createdAt := now()
+	data := blogItem{
+		AuthorID:  blog.AuthorID,
+		Title:     blog.Title,
+		Content:   blog.Content,
+		Tags:      bsonTags(blog.Tags),
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+		Revision:  1,
+	}
+	res, err := r.blogs.InsertOne(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	oid, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return nil, errors.New("cannot convert to OID")
+	}
+	data.ID = oid
+	if err := r.saveRevision(ctx, &data); err != nil {
+		return nil, err
+	}
+	created := dataToBlog(&data)
+	r.publish(blogpb.BlogEventType_CREATED, created)
+	return created, nil
+}
+
+func (r *mongoRepository) Get(ctx context.Context, id string) (*Blog, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	// create an empty struct
+	data := &blogItem{}
+	res := r.blogs.FindOne(ctx, bson.M{"_id": oid})
+	if err := res.Decode(data); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return dataToBlog(data), nil
+}
+
+func (r *mongoRepository) Update(ctx context.Context, blog *Blog) (*Blog, error) {
+	oid, err := primitive.ObjectIDFromHex(blog.ID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	// $inc hands out each revision number once, even to concurrent updates
+	update := bson.M{
+		"$set": bson.M{
+			"author_id":  blog.AuthorID,
+			"title":      blog.Title,
+			"content":    blog.Content,
+			"tags":       bsonTags(blog.Tags),
+			"updated_at": now(),
+		},
+		"$inc": bson.M{"revision": 1},
+	}
+	data := &blogItem{}
+	res := r.blogs.FindOneAndUpdate(ctx, bson.M{"_id": oid}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if err := res.Decode(data); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := r.saveRevision(ctx, data); err != nil {
+		return nil, err
+	}
+	updated := dataToBlog(data)
+	r.publish(blogpb.BlogEventType_UPDATED, updated)
+	return updated, nil
+}
+
+func (r *mongoRepository) saveRevision(ctx context.Context, data *blogItem) error {
+	_, err := r.revisions.InsertOne(ctx, revisionItem{
+		BlogID:    data.ID,
+		Revision:  data.Revision,
+		AuthorID:  data.AuthorID,
+		Title:     data.Title,
+		Content:   data.Content,
+		Tags:      data.Tags,
+		CreatedAt: data.UpdatedAt,
+	})
+	return err
+}
+
+func (r *mongoRepository) Delete(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	res, err := r.blogs.DeleteOne(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	r.publish(blogpb.BlogEventType_DELETED, &Blog{ID: id})
+	if _, err := r.revisions.DeleteMany(ctx, bson.M{"blog_id": oid}); err != nil {
+		return err
+	}
+	_, err = r.comments.DeleteMany(ctx, bson.M{"blog_id": oid})
+	return err
+}
+
+func (r *mongoRepository) List(ctx context.Context, opts *listOptions) ([]*Blog, error) {
+	var cur *mongo.Cursor
+	var err error
+	if opts.sort == blogpb.SortOrder_RELEVANCE {
+		cur, err = r.blogs.Aggregate(ctx, mongoRelevancePipeline(opts))
+	} else {
+		cur, err = r.blogs.Find(ctx, mongoFilter(opts), mongoFindOptions(opts))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var blogs []*Blog
+	for cur.Next(ctx) {
+		data := &blogItem{}
+		if err := cur.Decode(data); err != nil {
+			return nil, err
+		}
+		blogs = append(blogs, dataToBlog(data))
+	}
+	return blogs, cur.Err()
+}
+
+func (r *mongoRepository) Count(ctx context.Context, opts *listOptions) (int64, error) {
+	return r.blogs.CountDocuments(ctx, mongoFilter(opts))
+}
+
+func (r *mongoRepository) ListTags(ctx context.Context) ([]TagCount, error) {
+	cur, err := r.blogs.Aggregate(ctx, bson.A{
+		bson.M{"$unwind": "$tags"},
+		bson.M{"$group": bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var tags []TagCount
+	for cur.Next(ctx) {
+		var data struct {
+			Tag   string `bson:"_id"`
+			Count int64  `bson:"count"`
+		}
+		if err := cur.Decode(&data); err != nil {
+			return nil, err
+		}
+		tags = append(tags, TagCount{Tag: data.Tag, Count: data.Count})
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	sortTagCounts(tags)
+	return tags, nil
+}
+
+func (r *mongoRepository) GetRevision(ctx context.Context, blogID string, revision int32) (*Revision, error) {
+	oid, err := primitive.ObjectIDFromHex(blogID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	data := &revisionItem{}
+	res := r.revisions.FindOne(ctx, bson.M{"blog_id": oid, "revision": revision})
+	if err := res.Decode(data); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return dataToRevision(data), nil
+}
+
+func (r *mongoRepository) ListRevisions(ctx context.Context, blogID string, offset, limit int64) ([]*Revision, error) {
+	oid, err := primitive.ObjectIDFromHex(blogID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "revision", Value: -1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+	cur, err := r.revisions.Find(ctx, bson.M{"blog_id": oid}, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var revisions []*Revision
+	for cur.Next(ctx) {
+		data := &revisionItem{}
+		if err := cur.Decode(data); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, dataToRevision(data))
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, r.requireBlog(ctx, oid)
+	}
+	return revisions, nil
+}
+
+func (r *mongoRepository) AddComment(ctx context.Context, comment *Comment) (*Comment, error) {
+	blogID, err := primitive.ObjectIDFromHex(comment.BlogID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	data := commentItem{
+		BlogID:    blogID,
+		AuthorID:  comment.AuthorID,
+		Content:   comment.Content,
+		CreatedAt: now(),
+	}
+	if comment.ParentID != "" {
+		parentID, err := primitive.ObjectIDFromHex(comment.ParentID)
+		if err != nil {
+			return nil, ErrInvalidParent
+		}
+		data.ParentID = &parentID
+	}
+
+	if err := r.requireBlog(ctx, blogID); err != nil {
+		return nil, err
+	}
+	if data.ParentID != nil {
+		n, err := r.comments.CountDocuments(ctx, bson.M{"_id": *data.ParentID, "blog_id": blogID})
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, ErrInvalidParent
+		}
+	}
+
+	res, err := r.comments.InsertOne(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	oid, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return nil, errors.New("cannot convert to OID")
+	}
+	data.ID = oid
+	return dataToComment(&data), nil
+}
+
+func (r *mongoRepository) ListComments(ctx context.Context, blogID string, offset, limit int64) ([]*Comment, error) {
+	oid, err := primitive.ObjectIDFromHex(blogID)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	if err := r.requireBlog(ctx, oid); err != nil {
+		return nil, err
+	}
+
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+	cur, err := r.comments.Find(ctx, bson.M{"blog_id": oid}, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var comments []*Comment
+	for cur.Next(ctx) {
+		data := &commentItem{}
+		if err := cur.Decode(data); err != nil {
+			return nil, err
+		}
+		comments = append(comments, dataToComment(data))
+	}
+	return comments, cur.Err()
+}
+
+// Watch follows the change stream of the blogs, so it sees the changes of every server
+func (r *mongoRepository) Watch(ctx context.Context, resumeToken string, fn func(*BlogEvent) error) error {
+	if r.events != nil {
+		return r.events.watch(ctx, resumeToken, fn)
+	}
+
+	streamOpts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if resumeToken != "" {
+		kind, payload, err := decodeWatchToken(resumeToken)
+		if err != nil || kind != changeStreamToken || bson.Raw(payload).Validate() != nil {
+			return ErrInvalidToken
+		}
+		streamOpts.SetResumeAfter(bson.Raw(payload))
+	}
+	stream, err := r.blogs.Watch(ctx, changeStreamPipeline(), streamOpts)
+	if err != nil {
+		return changeStreamError(ctx, err)
+	}
+	defer stream.Close(ctx)
+
+	for stream.Next(ctx) {
+		var change struct {
+			// the _id of a change is its resume token
+			Token         bson.Raw `bson:"_id"`
+			OperationType string   `bson:"operationType"`
+			DocumentKey   struct {
+				ID primitive.ObjectID `bson:"_id"`
+			} `bson:"documentKey"`
+			FullDocument *blogItem `bson:"fullDocument"`
+		}
+		if err := stream.Decode(&change); err != nil {
+			return err
+		}
+
+		event := &BlogEvent{Token: encodeWatchToken(changeStreamToken, string(change.Token))}
+		switch {
+		case change.OperationType == "delete":
+			event.Type = blogpb.BlogEventType_DELETED
+			event.Blog = &Blog{ID: change.DocumentKey.ID.Hex()}
+		case change.FullDocument == nil:
+			// updated, then deleted before the update was looked up: its delete follows
+			continue
+		case change.OperationType == "insert":
+			event.Type = blogpb.BlogEventType_CREATED
+			event.Blog = dataToBlog(change.FullDocument)
+		default:
+			event.Type = blogpb.BlogEventType_UPDATED
+			event.Blog = dataToBlog(change.FullDocument)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return changeStreamError(ctx, err)
+	}
+	return ctx.Err()
+}
+
+func changeStreamPipeline() mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete"}},
+		}}},
+	}
+}
+
+// Server error codes of change streams
+const (
+	// the resume token is no longer in the oplog
+	codeChangeStreamFatalError  = 280
+	codeChangeStreamHistoryLost = 286
+	// change streams need a replica set or a sharded cluster
+	codeChangeStreamNotSupported = 40573
+)
+
+func changeStreamError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if cmdErr, ok := err.(mongo.CommandError); ok {
+		switch cmdErr.Code {
+		case codeChangeStreamFatalError, codeChangeStreamHistoryLost:
+			return ErrTokenExpired
+		}
+	}
+	return err
+}
+
+// checkChangeStreams falls back to watching the changes of this process
+// when the server has no change streams
+func (r *mongoRepository) checkChangeStreams(ctx context.Context) error {
+	stream, err := r.blogs.Watch(ctx, changeStreamPipeline())
+	if err != nil {
+		if cmdErr, ok := err.(mongo.CommandError); ok && cmdErr.Code == codeChangeStreamNotSupported {
+			log.Println("MongoDB has no change streams, only the changes of this server are watched: run a replica set to watch them all")
+			r.events = newBroadcaster(defaultBroadcastBacklog)
+			return nil
+		}
+		return err
+	}
+	return stream.Close(ctx)
+}
+
+// publish hands a change to the watchers of this process, without change streams
+// Concurrent changes may reach them in another order than they were made
+func (r *mongoRepository) publish(eventType blogpb.BlogEventType, blog *Blog) {
+	if r.events != nil {
+		r.events.publish(eventType, blog)
+	}
+}
+
+// requireBlog returns ErrNotFound unless the blog exists
+func (r *mongoRepository) requireBlog(ctx context.Context, oid primitive.ObjectID) error {
+	n, err := r.blogs.CountDocuments(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// bsonTags stores missing tags as an empty array rather than null
+func bsonTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// nilTags reads an empty array of tags back as nil, like the other backends
+func nilTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+func dataToBlog(data *blogItem) *Blog {
+	return &Blog{
+		ID:        data.ID.Hex(),
+		AuthorID:  data.AuthorID,
+		Title:     data.Title,
+		Content:   data.Content,
+		Tags:      nilTags(data.Tags),
+		CreatedAt: data.CreatedAt.UTC(),
+		UpdatedAt: data.UpdatedAt.UTC(),
+		Revision:  data.Revision,
+	}
+}
+
+func dataToRevision(data *revisionItem) *Revision {
+	return &Revision{
+		BlogID:    data.BlogID.Hex(),
+		Revision:  data.Revision,
+		AuthorID:  data.AuthorID,
+		Title:     data.Title,
+		Content:   data.Content,
+		Tags:      nilTags(data.Tags),
+		CreatedAt: data.CreatedAt.UTC(),
+	}
+}
+
+func dataToComment(data *commentItem) *Comment {
+	comment := &Comment{
+		ID:        data.ID.Hex(),
+		BlogID:    data.BlogID.Hex(),
+		AuthorID:  data.AuthorID,
+		Content:   data.Content,
+		CreatedAt: data.CreatedAt.UTC(),
+	}
+	if data.ParentID != nil {
+		comment.ParentID = data.ParentID.Hex()
+	}
+	return comment
+}
+
+func mongoFilter(opts *listOptions) bson.M {
+	filter := bson.M{}
+	if opts.authorID != "" {
+		filter["author_id"] = opts.authorID
+	}
+	if opts.tag != "" {
+		filter["tags"] = opts.tag
+	}
+	if terms := searchTerms(opts.query); len(terms) > 0 {
+		// substring matches like the other repositories, rather than the
+		// whole stemmed words of a text index
+		var matches bson.A
+		for _, term := range terms {
+			pattern := termPattern(term)
+			matches = append(matches, bson.M{"title": pattern}, bson.M{"content": pattern})
+		}
+		filter["$or"] = matches
+	}
+	return filter
+}
+
+// termPattern matches term anywhere, ignoring case, with regexp metacharacters in term taken literally
+func termPattern(term string) primitive.Regex {
+	return primitive.Regex{Pattern: regexp.QuoteMeta(term), Options: "i"}
+}
+
+// mongoRelevancePipeline lists the blogs matching opts by relevance
+// The score weighs title matches like the memory and SQL repositories do;
+// $regexMatch needs MongoDB 4.2
+func mongoRelevancePipeline(opts *listOptions) bson.A {
+	var score bson.A
+	for _, term := range searchTerms(opts.query) {
+		pattern := regexp.QuoteMeta(term)
+		score = append(score,
+			bson.M{"$cond": bson.A{bson.M{"$regexMatch": bson.M{"input": "$title", "regex": pattern, "options": "i"}}, 3, 0}},
+			bson.M{"$cond": bson.A{bson.M{"$regexMatch": bson.M{"input": "$content", "regex": pattern, "options": "i"}}, 1, 0}})
+	}
+	return bson.A{
+		bson.M{"$match": mongoFilter(opts)},
+		bson.M{"$addFields": bson.M{"score": bson.M{"$add": score}}},
+		bson.M{"$sort": bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: -1}}},
+		bson.M{"$skip": opts.offset},
+		bson.M{"$limit": opts.limit},
+	}
+}
+
+func mongoFindOptions(opts *listOptions) *options.FindOptions {
+	findOpts := options.Find().SetSkip(opts.offset).SetLimit(opts.limit)
+
+	// ObjectIDs grow with the creation time, so _id orders by age;
+	// it also breaks ties so pages never overlap
+	switch opts.sort {
+	case blogpb.SortOrder_OLDEST:
+		findOpts.SetSort(bson.D{{Key: "_id", Value: 1}})
+	case blogpb.SortOrder_TITLE:
+		findOpts.SetSort(bson.D{{Key: "title", Value: 1}, {Key: "_id", Value: 1}})
+	default:
+		findOpts.SetSort(bson.D{{Key: "_id", Value: -1}})
+	}
+	return findOpts
+}
+
+// ensureIndexes creates the indexes the queries rely on
+// Creating an index that already exists is a no-op, so it runs on every start
+func (r *mongoRepository) ensureIndexes(ctx context.Context) error {
+	_, err := r.blogs.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "author_id", Value: 1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetName("blog_author"),
+		},
+		{
+			Keys:    bson.D{{Key: "tags", Value: 1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetName("blog_tags"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// also guards against two revisions with the same number
+	_, err = r.revisions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "blog_id", Value: 1}, {Key: "revision", Value: -1}},
+		Options: options.Index().SetName("revision_blog").SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.comments.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "blog_id", Value: 1}, {Key: "_id", Value: 1}},
+		Options: options.Index().SetName("comment_blog"),
+	})
+	return err
+}
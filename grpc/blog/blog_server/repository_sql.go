@@ -0,0 +1,549 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"grpc/blog/blogpb"
+
+	// database/sql drivers of the SQL backend
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqlRepository stores blogs in SQLite or Postgres
+// The two dialects only differ in their placeholders
+// Changes are only watched in this process: several servers on one database
+// do not see each other's changes
+type sqlRepository struct {
+	db       *sql.DB
+	postgres bool
+	events   *broadcaster
+}
+
+// newSQLRepository opens driver ("sqlite3" or "postgres") and creates the blogs table
+func newSQLRepository(ctx context.Context, driver, dsn string) (*sqlRepository, error) {
+	if driver != "sqlite3" && driver != "postgres" {
+		return nil, fmt.Errorf("unsupported SQL driver %q", driver)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	r := &sqlRepository{db: db, postgres: driver == "postgres", events: newBroadcaster(defaultBroadcastBacklog)}
+	if err := r.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *sqlRepository) migrate(ctx context.Context) error {
+	for _, stmt := range []string{
+		// tags are kept as a JSON array with the blog, and in blog_tags for the tag queries;
+		// times are Unix milliseconds
+		`CREATE TABLE IF NOT EXISTS blogs (
+			id VARCHAR(24) PRIMARY KEY,
+			author_id TEXT NOT NULL,
+			title TEXT NOT NULL,
+			content TEXT NOT NULL,
+			tags TEXT NOT NULL,
+			created_at BIGINT NOT NULL,
+			updated_at BIGINT NOT NULL,
+			revision INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS blogs_author ON blogs (author_id, id)`,
+		`CREATE TABLE IF NOT EXISTS blog_tags (
+			blog_id VARCHAR(24) NOT NULL,
+			tag TEXT NOT NULL,
+			PRIMARY KEY (blog_id, tag)
+		)`,
+		`CREATE INDEX IF NOT EXISTS blog_tags_tag ON blog_tags (tag, blog_id)`,
+		`CREATE TABLE IF NOT EXISTS blog_revisions (
+			blog_id VARCHAR(24) NOT NULL,
+			revision INTEGER NOT NULL,
+			author_id TEXT NOT NULL,
+			title TEXT NOT NULL,
+			content TEXT NOT NULL,
+			tags TEXT NOT NULL,
+			created_at BIGINT NOT NULL,
+			PRIMARY KEY (blog_id, revision)
+		)`,
+		`CREATE TABLE IF NOT EXISTS blog_comments (
+			id VARCHAR(24) PRIMARY KEY,
+			blog_id VARCHAR(24) NOT NULL,
+			parent_id VARCHAR(24) NOT NULL,
+			author_id TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at BIGINT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS blog_comments_blog ON blog_comments (blog_id, id)`,
+	} {
+		if _, err := r.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *sqlRepository) Close() error {
+	return r.db.Close()
+}
+
+// inTx runs fn in a transaction, committed if fn succeeds
+func (r *sqlRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+const blogColumns = `id, author_id, title, content, tags, created_at, updated_at, revision`
+
+// scanner is a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBlog(row scanner) (*Blog, error) {
+	blog := &Blog{}
+	var tags string
+	var createdAt, updatedAt int64
+	err := row.Scan(&blog.ID, &blog.AuthorID, &blog.Title, &blog.Content, &tags, &createdAt, &updatedAt, &blog.Revision)
+	if err != nil {
+		return nil, err
+	}
+	if blog.Tags, err = decodeTags(tags); err != nil {
+		return nil, err
+	}
+	blog.CreatedAt = fromMillis(createdAt)
+	blog.UpdatedAt = fromMillis(updatedAt)
+	return blog, nil
+}
+
+func (r *sqlRepository) Create(ctx context.Context, blog *Blog) (*Blog, error) {
+	created := *blog
+	created.ID = newBlogID()
+	created.CreatedAt = now()
+	created.UpdatedAt = created.CreatedAt
+	created.Revision = 1
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			r.rebind(`INSERT INTO blogs (`+blogColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+			created.ID, created.AuthorID, created.Title, created.Content, encodeTags(created.Tags),
+			toMillis(created.CreatedAt), toMillis(created.UpdatedAt), created.Revision)
+		if err != nil {
+			return err
+		}
+		return r.saveRevision(ctx, tx, &created)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.events.publish(blogpb.BlogEventType_CREATED, &created)
+	return &created, nil
+}
+
+func (r *sqlRepository) Get(ctx context.Context, id string) (*Blog, error) {
+	if err := checkBlogID(id); err != nil {
+		return nil, err
+	}
+
+	blog, err := scanBlog(r.db.QueryRowContext(ctx,
+		r.rebind(`SELECT `+blogColumns+` FROM blogs WHERE id = ?`), id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return blog, nil
+}
+
+func (r *sqlRepository) Update(ctx context.Context, blog *Blog) (*Blog, error) {
+	if err := checkBlogID(blog.ID); err != nil {
+		return nil, err
+	}
+
+	var updated *Blog
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		// updating first locks the row, so concurrent updates get distinct revisions
+		res, err := tx.ExecContext(ctx,
+			r.rebind(`UPDATE blogs SET author_id = ?, title = ?, content = ?, tags = ?, updated_at = ?, revision = revision + 1 WHERE id = ?`),
+			blog.AuthorID, blog.Title, blog.Content, encodeTags(blog.Tags), toMillis(now()), blog.ID)
+		if err != nil {
+			return err
+		}
+		if err := requireRow(res); err != nil {
+			return err
+		}
+		updated, err = scanBlog(tx.QueryRowContext(ctx,
+			r.rebind(`SELECT `+blogColumns+` FROM blogs WHERE id = ?`), blog.ID))
+		if err != nil {
+			return err
+		}
+		return r.saveRevision(ctx, tx, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.events.publish(blogpb.BlogEventType_UPDATED, updated)
+	return updated, nil
+}
+
+// saveRevision saves blog as its current revision and indexes its tags
+func (r *sqlRepository) saveRevision(ctx context.Context, tx *sql.Tx, blog *Blog) error {
+	_, err := tx.ExecContext(ctx,
+		r.rebind(`INSERT INTO blog_revisions (blog_id, revision, author_id, title, content, tags, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		blog.ID, blog.Revision, blog.AuthorID, blog.Title, blog.Content, encodeTags(blog.Tags), toMillis(blog.UpdatedAt))
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, r.rebind(`DELETE FROM blog_tags WHERE blog_id = ?`), blog.ID); err != nil {
+		return err
+	}
+	for _, tag := range blog.Tags {
+		if _, err := tx.ExecContext(ctx, r.rebind(`INSERT INTO blog_tags (blog_id, tag) VALUES (?, ?)`), blog.ID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *sqlRepository) Delete(ctx context.Context, id string) error {
+	if err := checkBlogID(id); err != nil {
+		return err
+	}
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, r.rebind(`DELETE FROM blogs WHERE id = ?`), id)
+		if err != nil {
+			return err
+		}
+		if err := requireRow(res); err != nil {
+			return err
+		}
+		for _, table := range []string{"blog_tags", "blog_revisions", "blog_comments"} {
+			if _, err := tx.ExecContext(ctx, r.rebind(`DELETE FROM `+table+` WHERE blog_id = ?`), id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.events.publish(blogpb.BlogEventType_DELETED, &Blog{ID: id})
+	return nil
+}
+
+func (r *sqlRepository) List(ctx context.Context, opts *listOptions) ([]*Blog, error) {
+	where, args := sqlWhere(opts)
+	order := "id DESC"
+	switch opts.sort {
+	case blogpb.SortOrder_OLDEST:
+		order = "id"
+	case blogpb.SortOrder_TITLE:
+		order = "title, id"
+	case blogpb.SortOrder_RELEVANCE:
+		// the score weighs title matches above content matches like the other repositories do
+		var score []string
+		for _, term := range searchTerms(opts.query) {
+			score = append(score,
+				`CASE WHEN LOWER(title) LIKE ? ESCAPE '\' THEN 3 ELSE 0 END`,
+				`CASE WHEN LOWER(content) LIKE ? ESCAPE '\' THEN 1 ELSE 0 END`)
+			args = append(args, likePattern(term), likePattern(term))
+		}
+		order = "(" + strings.Join(score, " + ") + ") DESC, id DESC"
+	}
+
+	query := `SELECT ` + blogColumns + ` FROM blogs` + where + ` ORDER BY ` + order + ` LIMIT ? OFFSET ?`
+	args = append(args, opts.limit, opts.offset)
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blogs []*Blog
+	for rows.Next() {
+		blog, err := scanBlog(rows)
+		if err != nil {
+			return nil, err
+		}
+		blogs = append(blogs, blog)
+	}
+	return blogs, rows.Err()
+}
+
+func (r *sqlRepository) Count(ctx context.Context, opts *listOptions) (int64, error) {
+	where, args := sqlWhere(opts)
+	var count int64
+	err := r.db.QueryRowContext(ctx, r.rebind(`SELECT COUNT(*) FROM blogs`+where), args...).Scan(&count)
+	return count, err
+}
+
+func (r *sqlRepository) Watch(ctx context.Context, resumeToken string, fn func(*BlogEvent) error) error {
+	return r.events.watch(ctx, resumeToken, fn)
+}
+
+func (r *sqlRepository) ListTags(ctx context.Context) ([]TagCount, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT tag, COUNT(*) FROM blog_tags GROUP BY tag`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []TagCount
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortTagCounts(tags)
+	return tags, nil
+}
+
+const revisionColumns = `blog_id, revision, author_id, title, content, tags, created_at`
+
+func scanRevision(row scanner) (*Revision, error) {
+	revision := &Revision{}
+	var tags string
+	var createdAt int64
+	err := row.Scan(&revision.BlogID, &revision.Revision, &revision.AuthorID, &revision.Title, &revision.Content, &tags, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	if revision.Tags, err = decodeTags(tags); err != nil {
+		return nil, err
+	}
+	revision.CreatedAt = fromMillis(createdAt)
+	return revision, nil
+}
+
+func (r *sqlRepository) GetRevision(ctx context.Context, blogID string, revision int32) (*Revision, error) {
+	if err := checkBlogID(blogID); err != nil {
+		return nil, err
+	}
+
+	found, err := scanRevision(r.db.QueryRowContext(ctx,
+		r.rebind(`SELECT `+revisionColumns+` FROM blog_revisions WHERE blog_id = ? AND revision = ?`), blogID, revision))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+func (r *sqlRepository) ListRevisions(ctx context.Context, blogID string, offset, limit int64) ([]*Revision, error) {
+	if err := checkBlogID(blogID); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		r.rebind(`SELECT `+revisionColumns+` FROM blog_revisions WHERE blog_id = ? ORDER BY revision DESC LIMIT ? OFFSET ?`),
+		blogID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []*Revision
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, r.requireBlog(ctx, blogID)
+	}
+	return revisions, nil
+}
+
+func (r *sqlRepository) AddComment(ctx context.Context, comment *Comment) (*Comment, error) {
+	if err := checkBlogID(comment.BlogID); err != nil {
+		return nil, err
+	}
+	if comment.ParentID != "" && checkBlogID(comment.ParentID) != nil {
+		return nil, ErrInvalidParent
+	}
+	if err := r.requireBlog(ctx, comment.BlogID); err != nil {
+		return nil, err
+	}
+	if comment.ParentID != "" {
+		var n int64
+		err := r.db.QueryRowContext(ctx,
+			r.rebind(`SELECT COUNT(*) FROM blog_comments WHERE id = ? AND blog_id = ?`), comment.ParentID, comment.BlogID).
+			Scan(&n)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, ErrInvalidParent
+		}
+	}
+
+	created := *comment
+	created.ID = newBlogID()
+	created.CreatedAt = now()
+	_, err := r.db.ExecContext(ctx,
+		r.rebind(`INSERT INTO blog_comments (id, blog_id, parent_id, author_id, content, created_at) VALUES (?, ?, ?, ?, ?, ?)`),
+		created.ID, created.BlogID, created.ParentID, created.AuthorID, created.Content, toMillis(created.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (r *sqlRepository) ListComments(ctx context.Context, blogID string, offset, limit int64) ([]*Comment, error) {
+	if err := checkBlogID(blogID); err != nil {
+		return nil, err
+	}
+	if err := r.requireBlog(ctx, blogID); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		r.rebind(`SELECT id, blog_id, parent_id, author_id, content, created_at FROM blog_comments WHERE blog_id = ? ORDER BY id LIMIT ? OFFSET ?`),
+		blogID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []*Comment
+	for rows.Next() {
+		comment := &Comment{}
+		var createdAt int64
+		if err := rows.Scan(&comment.ID, &comment.BlogID, &comment.ParentID, &comment.AuthorID, &comment.Content, &createdAt); err != nil {
+			return nil, err
+		}
+		comment.CreatedAt = fromMillis(createdAt)
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+// requireBlog returns ErrNotFound unless the blog exists
+func (r *sqlRepository) requireBlog(ctx context.Context, id string) error {
+	var n int64
+	if err := r.db.QueryRowContext(ctx, r.rebind(`SELECT COUNT(*) FROM blogs WHERE id = ?`), id).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func encodeTags(tags []string) string {
+	if tags == nil {
+		tags = []string{}
+	}
+	data, _ := json.Marshal(tags)
+	return string(data)
+}
+
+// decodeTags reads tags back, nil if there are none
+func decodeTags(data string) ([]string, error) {
+	var tags []string
+	if err := json.Unmarshal([]byte(data), &tags); err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	return tags, nil
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC()
+}
+
+// sqlWhere returns the WHERE clause of the filters of opts, with ? placeholders
+func sqlWhere(opts *listOptions) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if opts.authorID != "" {
+		conds = append(conds, "author_id = ?")
+		args = append(args, opts.authorID)
+	}
+	if opts.tag != "" {
+		conds = append(conds, "id IN (SELECT blog_id FROM blog_tags WHERE tag = ?)")
+		args = append(args, opts.tag)
+	}
+	if terms := searchTerms(opts.query); len(terms) > 0 {
+		var matches []string
+		for _, term := range terms {
+			matches = append(matches, `LOWER(title) LIKE ? ESCAPE '\'`, `LOWER(content) LIKE ? ESCAPE '\'`)
+			args = append(args, likePattern(term), likePattern(term))
+		}
+		conds = append(conds, "("+strings.Join(matches, " OR ")+")")
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// likePattern matches term anywhere, with LIKE wildcards in term taken literally
+func likePattern(term string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+	return "%" + escaped + "%"
+}
+
+// rebind turns ? placeholders into $1, $2, ... for Postgres
+func (r *sqlRepository) rebind(query string) string {
+	if !r.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func requireRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"grpc/blog/blogpb"
+)
+
+// The conformance suite runs against every BlogRepository
+// MongoDB and Postgres only run when BLOG_TEST_MONGO_URI or BLOG_TEST_POSTGRES_DSN is set
+
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) BlogRepository {
+		return newMemoryRepository()
+	})
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	testRepository(t, func(t *testing.T) BlogRepository {
+		repo, err := newSQLRepository(context.Background(), "sqlite3", filepath.Join(t.TempDir(), "blog.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestPostgresRepository(t *testing.T) {
+	dsn := os.Getenv("BLOG_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("BLOG_TEST_POSTGRES_DSN is not set")
+	}
+	testRepository(t, func(t *testing.T) BlogRepository {
+		repo, err := newSQLRepository(context.Background(), "postgres", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, table := range []string{"blogs", "blog_tags", "blog_revisions", "blog_comments"} {
+			if _, err := repo.db.Exec(`DELETE FROM ` + table); err != nil {
+				t.Fatal(err)
+			}
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestMongoRepository(t *testing.T) {
+	uri := os.Getenv("BLOG_TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("BLOG_TEST_MONGO_URI is not set")
+	}
+	testRepository(t, func(t *testing.T) BlogRepository {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+		db := client.Database("blog_test")
+		if err := db.Drop(ctx); err != nil {
+			t.Fatal(err)
+		}
+		repo, err := newMongoRepository(ctx, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
+
+func testRepository(t *testing.T, newRepo func(t *testing.T) BlogRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+		before := time.Now().Add(-time.Second)
+		created, err := repo.Create(ctx, &Blog{AuthorID: "alice", Title: "First", Content: "Hello", Tags: []string{"go", "grpc"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created.ID == "" {
+			t.Fatal("created blog has no ID")
+		}
+		if created.Revision != 1 {
+			t.Fatalf("created blog has revision %d, want 1", created.Revision)
+		}
+		if created.CreatedAt.Before(before) || !created.UpdatedAt.Equal(created.CreatedAt) {
+			t.Fatalf("created blog has times %v and %v", created.CreatedAt, created.UpdatedAt)
+		}
+
+		got, err := repo.Get(ctx, created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireBlog(t, "get", got, created)
+	})
+
+	t.Run("UnknownAndInvalidIDs", func(t *testing.T) {
+		repo := newRepo(t)
+		unknown := newBlogID()
+
+		if _, err := repo.Get(ctx, unknown); err != ErrNotFound {
+			t.Errorf("Get unknown: got %v, want ErrNotFound", err)
+		}
+		if _, err := repo.Update(ctx, &Blog{ID: unknown}); err != ErrNotFound {
+			t.Errorf("Update unknown: got %v, want ErrNotFound", err)
+		}
+		if err := repo.Delete(ctx, unknown); err != ErrNotFound {
+			t.Errorf("Delete unknown: got %v, want ErrNotFound", err)
+		}
+		if _, err := repo.Get(ctx, "something_not_found"); err != ErrInvalidID {
+			t.Errorf("Get invalid: got %v, want ErrInvalidID", err)
+		}
+		if _, err := repo.Update(ctx, &Blog{ID: "something_not_found"}); err != ErrInvalidID {
+			t.Errorf("Update invalid: got %v, want ErrInvalidID", err)
+		}
+		if err := repo.Delete(ctx, "something_not_found"); err != ErrInvalidID {
+			t.Errorf("Delete invalid: got %v, want ErrInvalidID", err)
+		}
+	})
+
+	t.Run("UpdateAndDelete", func(t *testing.T) {
+		repo := newRepo(t)
+		created := mustCreate(t, repo, "alice", "First", "Hello")
+
+		changed := &Blog{ID: created.ID, AuthorID: "alice", Title: "First (edited)", Content: "Hello again", Tags: []string{"news"}}
+		updated, err := repo.Update(ctx, changed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := *changed
+		want.CreatedAt = created.CreatedAt
+		want.UpdatedAt = updated.UpdatedAt
+		want.Revision = 2
+		requireBlog(t, "update", updated, &want)
+		if updated.UpdatedAt.Before(created.UpdatedAt) {
+			t.Fatalf("updated at %v, before %v", updated.UpdatedAt, created.UpdatedAt)
+		}
+
+		// updating with the same values still finds the blog, and makes a revision
+		again, err := repo.Update(ctx, changed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.Revision != 3 {
+			t.Fatalf("second update has revision %d, want 3", again.Revision)
+		}
+		got, err := repo.Get(ctx, created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireBlog(t, "get after update", got, again)
+
+		if err := repo.Delete(ctx, created.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Get(ctx, created.ID); err != ErrNotFound {
+			t.Fatalf("Get after delete: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Revisions", func(t *testing.T) {
+		repo := newRepo(t)
+		created := mustCreate(t, repo, "alice", "First", "Hello")
+		updated, err := repo.Update(ctx, &Blog{ID: created.ID, AuthorID: "alice", Title: "Second", Content: "Hi", Tags: []string{"news"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		first := &Revision{BlogID: created.ID, Revision: 1, AuthorID: "alice", Title: "First", Content: "Hello", CreatedAt: created.CreatedAt}
+		second := &Revision{BlogID: created.ID, Revision: 2, AuthorID: "alice", Title: "Second", Content: "Hi", Tags: []string{"news"}, CreatedAt: updated.UpdatedAt}
+
+		got, err := repo.GetRevision(ctx, created.ID, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireRevisions(t, "get", []*Revision{got}, []*Revision{first})
+
+		revisions, err := repo.ListRevisions(ctx, created.ID, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireRevisions(t, "list", revisions, []*Revision{second, first})
+		revisions, err = repo.ListRevisions(ctx, created.ID, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireRevisions(t, "second page", revisions, []*Revision{first})
+
+		if _, err := repo.GetRevision(ctx, created.ID, 3); err != ErrNotFound {
+			t.Errorf("unknown revision: got %v, want ErrNotFound", err)
+		}
+		if _, err := repo.ListRevisions(ctx, newBlogID(), 0, 10); err != ErrNotFound {
+			t.Errorf("revisions of unknown blog: got %v, want ErrNotFound", err)
+		}
+
+		if err := repo.Delete(ctx, created.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetRevision(ctx, created.ID, 1); err != ErrNotFound {
+			t.Errorf("revision of deleted blog: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Comments", func(t *testing.T) {
+		repo := newRepo(t)
+		blog := mustCreate(t, repo, "alice", "First", "Hello")
+		other := mustCreate(t, repo, "alice", "Second", "Hi")
+
+		comment, err := repo.AddComment(ctx, &Comment{BlogID: blog.ID, AuthorID: "bob", Content: "Nice"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if comment.ID == "" || comment.CreatedAt.IsZero() {
+			t.Fatalf("added comment %+v has no ID or time", comment)
+		}
+		reply, err := repo.AddComment(ctx, &Comment{BlogID: blog.ID, ParentID: comment.ID, AuthorID: "alice", Content: "Thanks"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		comments, err := repo.ListComments(ctx, blog.ID, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireComments(t, "list", comments, []*Comment{comment, reply})
+		comments, err = repo.ListComments(ctx, blog.ID, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireComments(t, "second page", comments, []*Comment{reply})
+		comments, err = repo.ListComments(ctx, other.ID, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireComments(t, "no comments", comments, nil)
+
+		if _, err := repo.AddComment(ctx, &Comment{BlogID: other.ID, ParentID: comment.ID, Content: "Lost"}); err != ErrInvalidParent {
+			t.Errorf("reply to a comment of another blog: got %v, want ErrInvalidParent", err)
+		}
+		if _, err := repo.AddComment(ctx, &Comment{BlogID: blog.ID, ParentID: "nope", Content: "Lost"}); err != ErrInvalidParent {
+			t.Errorf("reply to an invalid ID: got %v, want ErrInvalidParent", err)
+		}
+		if _, err := repo.AddComment(ctx, &Comment{BlogID: newBlogID(), Content: "Lost"}); err != ErrNotFound {
+			t.Errorf("comment on unknown blog: got %v, want ErrNotFound", err)
+		}
+		if _, err := repo.ListComments(ctx, newBlogID(), 0, 10); err != ErrNotFound {
+			t.Errorf("comments of unknown blog: got %v, want ErrNotFound", err)
+		}
+
+		if err := repo.Delete(ctx, blog.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.AddComment(ctx, &Comment{BlogID: blog.ID, Content: "Late"}); err != ErrNotFound {
+			t.Errorf("comment on deleted blog: got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		repo := newRepo(t)
+		a, err := repo.Create(ctx, &Blog{AuthorID: "alice", Title: "A", Tags: []string{"go", "grpc"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := repo.Create(ctx, &Blog{AuthorID: "bob", Title: "B", Tags: []string{"go"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustCreate(t, repo, "alice", "C", "")
+
+		got, err := repo.List(ctx, &listOptions{tag: "go", limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireBlogs(t, "tag go", got, []*Blog{b, a})
+		count, err := repo.Count(ctx, &listOptions{tag: "grpc", authorID: "alice"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Fatalf("Count of tag and author: got %d, want 1", count)
+		}
+
+		tags, err := repo.ListTags(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []TagCount{{Tag: "go", Count: 2}, {Tag: "grpc", Count: 1}}
+		if !reflect.DeepEqual(tags, want) {
+			t.Fatalf("tags %v, want %v", tags, want)
+		}
+
+		// updates replace the tags
+		if _, err := repo.Update(ctx, &Blog{ID: a.ID, AuthorID: "alice", Title: "A", Tags: []string{"rest"}}); err != nil {
+			t.Fatal(err)
+		}
+		got, err = repo.List(ctx, &listOptions{tag: "grpc", limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireBlogs(t, "tag grpc after update", got, nil)
+		tags, err = repo.ListTags(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = []TagCount{{Tag: "go", Count: 1}, {Tag: "rest", Count: 1}}
+		if !reflect.DeepEqual(tags, want) {
+			t.Fatalf("tags after update %v, want %v", tags, want)
+		}
+	})
+
+	t.Run("ListSortsAndPages", func(t *testing.T) {
+		repo := newRepo(t)
+		a := mustCreate(t, repo, "alice", "Banana", "one")
+		b := mustCreate(t, repo, "bob", "Apple", "two")
+		c := mustCreate(t, repo, "alice", "Cherry", "three")
+
+		for _, tc := range []struct {
+			name string
+			opts listOptions
+			want []*Blog
+		}{
+			{"newest", listOptions{limit: 10}, []*Blog{c, b, a}},
+			{"oldest", listOptions{sort: blogpb.SortOrder_OLDEST, limit: 10}, []*Blog{a, b, c}},
+			{"title", listOptions{sort: blogpb.SortOrder_TITLE, limit: 10}, []*Blog{b, a, c}},
+			{"author", listOptions{authorID: "alice", limit: 10}, []*Blog{c, a}},
+			{"first page", listOptions{limit: 2}, []*Blog{c, b}},
+			{"second page", listOptions{offset: 2, limit: 2}, []*Blog{a}},
+			{"past the end", listOptions{offset: 3, limit: 2}, nil},
+		} {
+			got, err := repo.List(ctx, &tc.opts)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			requireBlogs(t, tc.name, got, tc.want)
+		}
+
+		count, err := repo.Count(ctx, &listOptions{authorID: "alice", offset: 1, limit: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 2 {
+			t.Fatalf("Count: got %d, want 2", count)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		repo := newRepo(t)
+		inContent := mustCreate(t, repo, "alice", "Weekend", "We went hiking in the mountains")
+		inTitle := mustCreate(t, repo, "bob", "Hiking guide", "Boots and water")
+		mustCreate(t, repo, "alice", "Cooking", "Pasta recipes")
+
+		got, err := repo.List(ctx, &listOptions{query: "HIKING", sort: blogpb.SortOrder_RELEVANCE, limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireBlogs(t, "relevance", got, []*Blog{inTitle, inContent})
+
+		got, err = repo.List(ctx, &listOptions{query: "hiking", authorID: "alice", limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireBlogs(t, "author and query", got, []*Blog{inContent})
+
+		count, err := repo.Count(ctx, &listOptions{query: "pasta mountains"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 2 {
+			t.Fatalf("Count of any word: got %d, want 2", count)
+		}
+
+		got, err = repo.List(ctx, &listOptions{query: "hik", sort: blogpb.SortOrder_RELEVANCE, limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireBlogs(t, "part of a word", got, []*Blog{inTitle, inContent})
+
+		count, err = repo.Count(ctx, &listOptions{query: "past.*"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Fatalf("Count of a pattern: got %d, want 0", count)
+		}
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		repo := newRepo(t)
+		token := watchToken(t, repo)
+
+		// b is kept: change streams look up an updated blog when its event is read
+		b := mustCreate(t, repo, "alice", "B", "")
+		c := mustCreate(t, repo, "bob", "C", "")
+		updated, err := repo.Update(ctx, &Blog{ID: b.ID, AuthorID: "alice", Title: "B2", Tags: []string{"go"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Delete(ctx, c.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		events := watchEvents(t, repo, token, 4)
+		requireEvent(t, "create", events[0], blogpb.BlogEventType_CREATED, b)
+		requireEvent(t, "create other", events[1], blogpb.BlogEventType_CREATED, c)
+		requireEvent(t, "update", events[2], blogpb.BlogEventType_UPDATED, updated)
+		requireEvent(t, "delete", events[3], blogpb.BlogEventType_DELETED, &Blog{ID: c.ID})
+
+		// resuming goes on right after the event of the token
+		resumed := watchEvents(t, repo, events[1].Token, 2)
+		requireEvent(t, "resumed update", resumed[0], blogpb.BlogEventType_UPDATED, updated)
+		requireEvent(t, "resumed delete", resumed[1], blogpb.BlogEventType_DELETED, &Blog{ID: c.ID})
+
+		if err := repo.Watch(ctx, "not a token", func(*BlogEvent) error { return nil }); err != ErrInvalidToken {
+			t.Fatalf("Watch with a bad token: got %v, want ErrInvalidToken", err)
+		}
+	})
+}
+
+// probeTitle is the title of the blogs watchToken creates
+const probeTitle = "watch probe"
+
+var errEnoughEvents = errors.New("enough events")
+
+// watchToken returns a token to watch the changes of repo from now
+// Watching from now starts some time after calling Watch, so it creates probe
+// blogs until one of them is seen
+func watchToken(t *testing.T, repo BlogRepository) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tokens := make(chan string, 1)
+	go repo.Watch(ctx, "", func(event *BlogEvent) error {
+		tokens <- event.Token
+		return errEnoughEvents
+	})
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		mustCreate(t, repo, "probe", probeTitle, "")
+		select {
+		case token := <-tokens:
+			return token
+		case <-ticker.C:
+		case <-ctx.Done():
+			t.Fatal("no event for the probe blogs")
+		}
+	}
+}
+
+// watchEvents returns the next n events after token, leaving out the probe blogs
+func watchEvents(t *testing.T, repo BlogRepository, token string, n int) []*BlogEvent {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var events []*BlogEvent
+	err := repo.Watch(ctx, token, func(event *BlogEvent) error {
+		if event.Blog.Title == probeTitle {
+			return nil
+		}
+		events = append(events, event)
+		if len(events) == n {
+			return errEnoughEvents
+		}
+		return nil
+	})
+	if err != errEnoughEvents {
+		t.Fatalf("watched %d of %d events: %v", len(events), n, err)
+	}
+	return events
+}
+
+func requireEvent(t *testing.T, name string, got *BlogEvent, eventType blogpb.BlogEventType, blog *Blog) {
+	t.Helper()
+	if got.Type != eventType || got.Token == "" {
+		t.Fatalf("%s: got a %v event with token %q, want a %v event", name, got.Type, got.Token, eventType)
+	}
+	requireBlog(t, name, got.Blog, blog)
+}
+
+func mustCreate(t *testing.T, repo BlogRepository, authorID, title, content string) *Blog {
+	t.Helper()
+	blog, err := repo.Create(context.Background(), &Blog{AuthorID: authorID, Title: title, Content: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blog
+}
+
+func requireBlog(t *testing.T, name string, got, want *Blog) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("%s: got %+v, want %+v", name, got, want)
+	}
+}
+
+func requireBlogs(t *testing.T, name string, got, want []*Blog) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d blogs, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Fatalf("%s: blog %d is %+v, want %+v", name, i, got[i], want[i])
+		}
+	}
+}
+
+func requireRevisions(t *testing.T, name string, got, want []*Revision) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d revisions, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Fatalf("%s: revision %d is %+v, want %+v", name, i, got[i], want[i])
+		}
+	}
+}
+
+func requireComments(t *testing.T, name string, got, want []*Comment) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d comments, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Fatalf("%s: comment %d is %+v, want %+v", name, i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"grpc/blog/blogpb"
+)
+
+func (s *server) ListRevisions(ctx context.Context, req *blogpb.ListRevisionsRequest) (*blogpb.ListRevisionsResponse, error) {
+	fmt.Println("List revisions request")
+
+	offset, limit, err := pageBounds(req.GetPageSize(), req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := s.repo.ListRevisions(ctx, req.GetBlogId(), offset, limit+1)
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+
+	res := &blogpb.ListRevisionsResponse{
+		NextPageToken: nextPageToken(offset, limit, len(revisions)),
+	}
+	for i, revision := range revisions {
+		if int64(i) == limit {
+			break
+		}
+		res.Revisions = append(res.Revisions, dataToRevisionPb(revision))
+	}
+	return res, nil
+}
+
+// RestoreRevision saves an old revision of a blog as its next one, so the history stays intact
+func (s *server) RestoreRevision(ctx context.Context, req *blogpb.RestoreRevisionRequest) (*blogpb.RestoreRevisionResponse, error) {
+	fmt.Println("Restore revision request")
+
+	caller, err := s.checkOwner(ctx, req.GetBlogId())
+	if err != nil {
+		return nil, err
+	}
+	revision, err := s.repo.GetRevision(ctx, req.GetBlogId(), req.GetRevision())
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+
+	restored, err := s.repo.Update(ctx, &Blog{
+		ID:       req.GetBlogId(),
+		AuthorID: caller,
+		Title:    revision.Title,
+		Content:  revision.Content,
+		Tags:     revision.Tags,
+	})
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+
+	return &blogpb.RestoreRevisionResponse{
+		Blog: dataToBlogPb(restored),
+	}, nil
+}
+
+func dataToRevisionPb(data *Revision) *blogpb.Revision {
+	return &blogpb.Revision{
+		BlogId:    data.BlogID,
+		Revision:  data.Revision,
+		AuthorId:  data.AuthorID,
+		Title:     data.Title,
+		Content:   data.Content,
+		Tags:      data.Tags,
+		CreatedAt: timestamppb.New(data.CreatedAt),
+	}
+}
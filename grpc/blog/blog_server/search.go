@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"grpc/blog/blogpb"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// listOptions is a validated ListBlog or SearchBlogs request
+type listOptions struct {
+	authorID string
+	tag      string
+	query    string
+	sort     blogpb.SortOrder
+	offset   int64
+	limit    int64
+}
+
+func newListOptions(authorID, tag, query string, sort blogpb.SortOrder, pageSize int32, pageToken string) (*listOptions, error) {
+	if _, ok := blogpb.SortOrder_name[int32(sort)]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Unknown sort order: %v", sort)
+	}
+	if sort == blogpb.SortOrder_RELEVANCE && query == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Sorting by relevance needs a query")
+	}
+	if tag != "" {
+		tags, err := normalizeTags([]string{tag})
+		if err != nil {
+			return nil, err
+		}
+		tag = tags[0]
+	}
+	offset, limit, err := pageBounds(pageSize, pageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &listOptions{
+		authorID: authorID,
+		tag:      tag,
+		query:    query,
+		sort:     sort,
+		offset:   offset,
+		limit:    limit,
+	}, nil
+}
+
+// pageBounds returns the offset and size of the page requested by pageSize and pageToken
+func pageBounds(pageSize int32, pageToken string) (int64, int64, error) {
+	if pageSize < 0 {
+		return 0, 0, status.Errorf(codes.InvalidArgument, "Negative page size: %v", pageSize)
+	}
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	offset, err := decodePageToken(pageToken)
+	if err != nil {
+		return 0, 0, status.Errorf(codes.InvalidArgument, "Invalid page token")
+	}
+	return offset, int64(pageSize), nil
+}
+
+// nextPageToken returns the token of the page after [offset, offset+limit),
+// given the number of items fetched for it with one extra
+func nextPageToken(offset, limit int64, fetched int) string {
+	if int64(fetched) <= limit {
+		return ""
+	}
+	return encodePageToken(offset + limit)
+}
+
+// listPage returns one page of blogs and the token of the next page, empty on the last page
+func listPage(ctx context.Context, repo BlogRepository, o *listOptions) ([]*Blog, string, error) {
+	// one more than the page tells whether another page follows
+	query := *o
+	query.limit++
+	blogs, err := repo.List(ctx, &query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := nextPageToken(o.offset, o.limit, len(blogs))
+	if next != "" {
+		blogs = blogs[:o.limit]
+	}
+	return blogs, next, nil
+}
+
+// page tokens are opaque to clients; they hold the offset of the next page
+func encodePageToken(offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(offset, 10)))
+}
+
+func decodePageToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid offset %q", data)
+	}
+	return offset, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"grpc/blog/blogpb"
+)
+
+func TestPageBounds(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		pageSize   int32
+		pageToken  string
+		wantOffset int64
+		wantLimit  int64
+	}{
+		{"default size", 0, "", 0, defaultPageSize},
+		{"given size", 10, "", 0, 10},
+		{"capped size", maxPageSize + 1, "", 0, maxPageSize},
+		{"next page", 10, encodePageToken(20), 20, 10},
+	} {
+		offset, limit, err := pageBounds(test.pageSize, test.pageToken)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if offset != test.wantOffset || limit != test.wantLimit {
+			t.Errorf("%s: got offset %d and limit %d, want %d and %d", test.name, offset, limit, test.wantOffset, test.wantLimit)
+		}
+	}
+
+	for name, token := range map[string]string{
+		"garbage":         "not a token",
+		"not a number":    encodeTestToken("ten"),
+		"negative offset": encodeTestToken("-10"),
+	} {
+		_, _, err := pageBounds(10, token)
+		requireCode(t, name, err, codes.InvalidArgument)
+	}
+	_, _, err := pageBounds(-1, "")
+	requireCode(t, "negative page size", err, codes.InvalidArgument)
+}
+
+func TestNextPageToken(t *testing.T) {
+	if token := nextPageToken(20, 10, 10); token != "" {
+		t.Fatalf("full last page has next page token %q", token)
+	}
+	if token := nextPageToken(20, 10, 5); token != "" {
+		t.Fatalf("short last page has next page token %q", token)
+	}
+	offset, err := decodePageToken(nextPageToken(20, 10, 11))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 30 {
+		t.Fatalf("next page starts at %d, want 30", offset)
+	}
+}
+
+func TestNewListOptions(t *testing.T) {
+	opts, err := newListOptions("alice", "Go", "hiking", blogpb.SortOrder_RELEVANCE, 5, encodePageToken(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := listOptions{authorID: "alice", tag: "go", query: "hiking", sort: blogpb.SortOrder_RELEVANCE, offset: 10, limit: 5}
+	if *opts != want {
+		t.Fatalf("got %+v, want %+v", *opts, want)
+	}
+
+	for _, test := range []struct {
+		name  string
+		tag   string
+		query string
+		sort  blogpb.SortOrder
+	}{
+		{"unknown sort", "", "", blogpb.SortOrder(42)},
+		{"relevance and no query", "", "", blogpb.SortOrder_RELEVANCE},
+		{"bad tag", "two words", "", blogpb.SortOrder_NEWEST},
+	} {
+		_, err := newListOptions("", test.tag, test.query, test.sort, 0, "")
+		requireCode(t, test.name, err, codes.InvalidArgument)
+	}
+}
+
+func encodeTestToken(offset string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(offset))
+}
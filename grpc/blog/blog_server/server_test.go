@@ -0,0 +1,647 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"grpc/auth"
+	"grpc/blog/blogpb"
+	"grpc/grpcserver"
+)
+
+// testAPIKeys authenticate the callers of the test server
+var testAPIKeys = map[string]string{"alice-key": "alice", "bob-key": "bob"}
+
+// newTestServer serves a blog server on the memory repository over an in-process listener
+func newTestServer(t *testing.T) *bufconn.Listener {
+	lis := bufconn.Listen(1024 * 1024)
+	interceptor := auth.NewInterceptor(auth.NewAPIKeyAuthenticator(testAPIKeys), publicMethods...)
+	s, err := grpcserver.New(grpcserver.Config{
+		Name:               "blog",
+		UnaryInterceptors:  []grpc.UnaryServerInterceptor{interceptor.Unary()},
+		StreamInterceptors: []grpc.StreamServerInterceptor{interceptor.Stream()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blogpb.RegisterBlogServiceServer(s.Server, newServer(newMemoryRepository()))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis
+}
+
+// dialTestServer returns a client sending apiKey, or no credentials if it is empty
+func dialTestServer(t *testing.T, lis *bufconn.Listener, apiKey string) blogpb.BlogServiceClient {
+	opts := []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithInsecure(),
+	}
+	if apiKey != "" {
+		opts = append(opts, auth.BearerToken(apiKey, true))
+	}
+	cc, err := grpc.Dial("bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return blogpb.NewBlogServiceClient(cc)
+}
+
+// newTestClient returns the client of alice on a new server
+func newTestClient(t *testing.T) blogpb.BlogServiceClient {
+	return dialTestServer(t, newTestServer(t), "alice-key")
+}
+
+func createTestBlog(t *testing.T, c blogpb.BlogServiceClient, title, content string) *blogpb.Blog {
+	t.Helper()
+	res, err := c.CreateBlog(context.Background(), &blogpb.CreateBlogRequest{
+		Blog: &blogpb.Blog{Title: title, Content: content},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.GetBlog()
+}
+
+func requireCode(t *testing.T, name string, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Fatalf("%s: got %v (%v), want %v", name, got, err, want)
+	}
+}
+
+func TestCreateAndReadBlog(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	created := createTestBlog(t, c, "First", "Hello")
+	if created.GetId() == "" {
+		t.Fatal("created blog has no ID")
+	}
+	if created.GetAuthorId() != "alice" {
+		t.Fatalf("created blog has author %q, want the caller", created.GetAuthorId())
+	}
+
+	res, err := c.ReadBlog(ctx, &blogpb.ReadBlogRequest{BlogId: created.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetBlog().String() != created.String() {
+		t.Fatalf("read %v, want %v", res.GetBlog(), created)
+	}
+
+	_, err = c.ReadBlog(ctx, &blogpb.ReadBlogRequest{BlogId: newBlogID()})
+	requireCode(t, "unknown ID", err, codes.NotFound)
+	_, err = c.ReadBlog(ctx, &blogpb.ReadBlogRequest{BlogId: "something_not_found"})
+	requireCode(t, "bad ID", err, codes.InvalidArgument)
+}
+
+func TestUpdateBlog(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	created := createTestBlog(t, c, "First", "Hello")
+
+	changed := &blogpb.Blog{Id: created.GetId(), Title: "First (edited)", Content: "Hello again", Tags: []string{"News", "go", "news"}}
+	res, err := c.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: changed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := res.GetBlog()
+	if updated.GetTitle() != changed.GetTitle() || updated.GetContent() != changed.GetContent() || updated.GetAuthorId() != "alice" {
+		t.Fatalf("updated %v, want %v", updated, changed)
+	}
+	requireTitles(t, "normalized tags", updated.GetTags(), "go", "news")
+	if updated.GetRevision() != 2 {
+		t.Fatalf("updated blog has revision %d, want 2", updated.GetRevision())
+	}
+	if !updated.GetCreatedAt().AsTime().Equal(created.GetCreatedAt().AsTime()) || updated.GetUpdatedAt().AsTime().Before(created.GetUpdatedAt().AsTime()) {
+		t.Fatalf("updated blog has times %v and %v", updated.GetCreatedAt(), updated.GetUpdatedAt())
+	}
+
+	_, err = c.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: created.GetId(), Tags: []string{"two words"}}})
+	requireCode(t, "bad tag", err, codes.InvalidArgument)
+
+	_, err = c.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: newBlogID()}})
+	requireCode(t, "unknown ID", err, codes.NotFound)
+	_, err = c.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: "something_not_found"}})
+	requireCode(t, "bad ID", err, codes.InvalidArgument)
+}
+
+func TestDeleteBlog(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	created := createTestBlog(t, c, "First", "Hello")
+
+	res, err := c.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: created.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetBlogId() != created.GetId() {
+		t.Fatalf("deleted %q, want %q", res.GetBlogId(), created.GetId())
+	}
+
+	_, err = c.ReadBlog(ctx, &blogpb.ReadBlogRequest{BlogId: created.GetId()})
+	requireCode(t, "read after delete", err, codes.NotFound)
+	_, err = c.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: created.GetId()})
+	requireCode(t, "delete twice", err, codes.NotFound)
+	_, err = c.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: "something_not_found"})
+	requireCode(t, "bad ID", err, codes.InvalidArgument)
+}
+
+// listBlogs drains one ListBlog page and returns its titles and next page token
+func listBlogs(c blogpb.BlogServiceClient, req *blogpb.ListBlogRequest) ([]string, string, error) {
+	stream, err := c.ListBlog(context.Background(), req)
+	if err != nil {
+		return nil, "", err
+	}
+	var titles []string
+	nextPageToken := ""
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return titles, nextPageToken, nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		titles = append(titles, res.GetBlog().GetTitle())
+		nextPageToken = res.GetNextPageToken()
+	}
+}
+
+func TestListBlog(t *testing.T) {
+	lis := newTestServer(t)
+	alice, bob := dialTestServer(t, lis, "alice-key"), dialTestServer(t, lis, "bob-key")
+	c := dialTestServer(t, lis, "")
+	createTestBlog(t, alice, "Banana", "one")
+	createTestBlog(t, bob, "Apple", "two")
+	createTestBlog(t, alice, "Cherry", "three")
+
+	titles, token, err := listBlogs(c, &blogpb.ListBlogRequest{PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireTitles(t, "first page", titles, "Cherry", "Apple")
+	if token == "" {
+		t.Fatal("first page has no next page token")
+	}
+	titles, token, err = listBlogs(c, &blogpb.ListBlogRequest{PageSize: 2, PageToken: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireTitles(t, "second page", titles, "Banana")
+	if token != "" {
+		t.Fatalf("last page has next page token %q", token)
+	}
+
+	titles, _, err = listBlogs(c, &blogpb.ListBlogRequest{AuthorId: "alice", Sort: blogpb.SortOrder_TITLE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireTitles(t, "by author and title", titles, "Banana", "Cherry")
+
+	for name, req := range map[string]*blogpb.ListBlogRequest{
+		"bad page token":         {PageToken: "not a token"},
+		"negative page size":     {PageSize: -1},
+		"unknown sort":           {Sort: blogpb.SortOrder(42)},
+		"relevance and no query": {Sort: blogpb.SortOrder_RELEVANCE},
+	} {
+		_, _, err := listBlogs(c, req)
+		requireCode(t, name, err, codes.InvalidArgument)
+	}
+}
+
+func TestListBlogSortsAndQuery(t *testing.T) {
+	c := newTestClient(t)
+	createTestBlog(t, c, "Weekend", "We went hiking in the mountains")
+	createTestBlog(t, c, "Hiking guide", "Boots and water")
+	createTestBlog(t, c, "Cooking", "Pasta recipes")
+
+	for _, test := range []struct {
+		name string
+		req  *blogpb.ListBlogRequest
+		want []string
+	}{
+		{"newest", &blogpb.ListBlogRequest{}, []string{"Cooking", "Hiking guide", "Weekend"}},
+		{"oldest", &blogpb.ListBlogRequest{Sort: blogpb.SortOrder_OLDEST}, []string{"Weekend", "Hiking guide", "Cooking"}},
+		{"title", &blogpb.ListBlogRequest{Sort: blogpb.SortOrder_TITLE}, []string{"Cooking", "Hiking guide", "Weekend"}},
+		{"query", &blogpb.ListBlogRequest{Query: "hiking"}, []string{"Hiking guide", "Weekend"}},
+		{"query by relevance", &blogpb.ListBlogRequest{Query: "weekend hiking", Sort: blogpb.SortOrder_RELEVANCE}, []string{"Weekend", "Hiking guide"}},
+		{"no match", &blogpb.ListBlogRequest{Query: "sailing"}, nil},
+	} {
+		titles, token, err := listBlogs(c, test.req)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		requireTitles(t, test.name, titles, test.want...)
+		if token != "" {
+			t.Fatalf("%s: single page has next page token %q", test.name, token)
+		}
+	}
+}
+
+func TestSearchBlogsFilters(t *testing.T) {
+	lis := newTestServer(t)
+	alice, bob := dialTestServer(t, lis, "alice-key"), dialTestServer(t, lis, "bob-key")
+	ctx := context.Background()
+	createTestBlog(t, alice, "Weekend", "We went hiking in the mountains")
+	createTestBlog(t, bob, "Hiking guide", "Boots and water")
+	if _, err := alice.CreateBlog(ctx, &blogpb.CreateBlogRequest{Blog: &blogpb.Blog{Title: "Alps", Content: "Hiking above the clouds", Tags: []string{"travel"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name string
+		req  *blogpb.SearchBlogsRequest
+		want []string
+	}{
+		{"newest is relevance", &blogpb.SearchBlogsRequest{Query: "hiking"}, []string{"Hiking guide", "Alps", "Weekend"}},
+		{"oldest", &blogpb.SearchBlogsRequest{Query: "hiking", Sort: blogpb.SortOrder_OLDEST}, []string{"Weekend", "Hiking guide", "Alps"}},
+		{"title", &blogpb.SearchBlogsRequest{Query: "hiking", Sort: blogpb.SortOrder_TITLE}, []string{"Alps", "Hiking guide", "Weekend"}},
+		{"author", &blogpb.SearchBlogsRequest{Query: "hiking", AuthorId: "alice"}, []string{"Alps", "Weekend"}},
+		{"tag", &blogpb.SearchBlogsRequest{Query: "hiking", Tag: "Travel"}, []string{"Alps"}},
+	} {
+		res, err := alice.SearchBlogs(ctx, test.req)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		requireTitles(t, test.name, blogTitles(res.GetBlogs()), test.want...)
+		if res.GetTotalCount() != int64(len(test.want)) {
+			t.Fatalf("%s: total count %d, want %d", test.name, res.GetTotalCount(), len(test.want))
+		}
+	}
+
+	_, err := alice.SearchBlogs(ctx, &blogpb.SearchBlogsRequest{Query: "hiking", PageSize: -1})
+	requireCode(t, "negative page size", err, codes.InvalidArgument)
+	_, err = alice.SearchBlogs(ctx, &blogpb.SearchBlogsRequest{Query: "hiking", Sort: blogpb.SortOrder(42)})
+	requireCode(t, "unknown sort", err, codes.InvalidArgument)
+}
+
+func TestSearchBlogs(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	createTestBlog(t, c, "Weekend", "We went hiking in the mountains")
+	createTestBlog(t, c, "Hiking guide", "Boots and water")
+	createTestBlog(t, c, "Cooking", "Pasta recipes")
+
+	res, err := c.SearchBlogs(ctx, &blogpb.SearchBlogsRequest{Query: "hiking", PageSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetTotalCount() != 2 {
+		t.Fatalf("total count %d, want 2", res.GetTotalCount())
+	}
+	requireTitles(t, "first page", blogTitles(res.GetBlogs()), "Hiking guide")
+
+	res, err = c.SearchBlogs(ctx, &blogpb.SearchBlogsRequest{Query: "hiking", PageSize: 1, PageToken: res.GetNextPageToken()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireTitles(t, "second page", blogTitles(res.GetBlogs()), "Weekend")
+	if res.GetNextPageToken() != "" {
+		t.Fatalf("last page has next page token %q", res.GetNextPageToken())
+	}
+
+	_, err = c.SearchBlogs(ctx, &blogpb.SearchBlogsRequest{})
+	requireCode(t, "empty query", err, codes.InvalidArgument)
+	_, err = c.SearchBlogs(ctx, &blogpb.SearchBlogsRequest{Query: "hiking", PageToken: "not a token"})
+	requireCode(t, "bad page token", err, codes.InvalidArgument)
+}
+
+func TestAuthorization(t *testing.T) {
+	lis := newTestServer(t)
+	alice, bob := dialTestServer(t, lis, "alice-key"), dialTestServer(t, lis, "bob-key")
+	anonymous, impostor := dialTestServer(t, lis, ""), dialTestServer(t, lis, "carol-key")
+	ctx := context.Background()
+
+	created, err := bob.CreateBlog(ctx, &blogpb.CreateBlogRequest{
+		Blog: &blogpb.Blog{AuthorId: "alice", Title: "Not by alice"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.GetBlog().GetAuthorId() != "bob" {
+		t.Fatalf("created blog has author %q, want the caller", created.GetBlog().GetAuthorId())
+	}
+	blog := createTestBlog(t, alice, "By alice", "Hello")
+
+	for name, c := range map[string]blogpb.BlogServiceClient{"anonymous": anonymous, "unknown key": impostor} {
+		_, err := c.CreateBlog(ctx, &blogpb.CreateBlogRequest{Blog: &blogpb.Blog{Title: "Spam"}})
+		requireCode(t, name+" create", err, codes.Unauthenticated)
+		_, err = c.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: blog})
+		requireCode(t, name+" update", err, codes.Unauthenticated)
+		_, err = c.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: blog.GetId()})
+		requireCode(t, name+" delete", err, codes.Unauthenticated)
+	}
+
+	_, err = bob.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: blog.GetId(), AuthorId: "bob", Title: "Taken"}})
+	requireCode(t, "update by another author", err, codes.PermissionDenied)
+	_, err = bob.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: blog.GetId()})
+	requireCode(t, "delete by another author", err, codes.PermissionDenied)
+
+	// reads stay public
+	res, err := anonymous.ReadBlog(ctx, &blogpb.ReadBlogRequest{BlogId: blog.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetBlog().String() != blog.String() {
+		t.Fatalf("read %v, want the unchanged %v", res.GetBlog(), blog)
+	}
+
+	// the author keeps the blog even when the update names another one
+	updated, err := alice.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: blog.GetId(), AuthorId: "bob", Title: "Edited"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.GetBlog().GetAuthorId() != "alice" {
+		t.Fatalf("updated blog has author %q, want alice", updated.GetBlog().GetAuthorId())
+	}
+	if _, err := alice.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: blog.GetId()}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTags(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	for _, blog := range []*blogpb.Blog{
+		{Title: "Banana", Tags: []string{"fruit", "yellow"}},
+		{Title: "Apple", Tags: []string{"Fruit"}},
+		{Title: "Sun", Tags: []string{"yellow", "star"}},
+		{Title: "Moon"},
+	} {
+		if _, err := c.CreateBlog(ctx, &blogpb.CreateBlogRequest{Blog: blog}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	titles, _, err := listBlogs(c, &blogpb.ListBlogRequest{Tag: "FRUIT", Sort: blogpb.SortOrder_TITLE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireTitles(t, "fruits", titles, "Apple", "Banana")
+
+	res, err := c.ListTags(ctx, &blogpb.ListTagsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tags []string
+	for _, tag := range res.GetTags() {
+		tags = append(tags, fmt.Sprintf("%s:%d", tag.GetTag(), tag.GetBlogCount()))
+	}
+	requireTitles(t, "tags", tags, "fruit:2", "yellow:2", "star:1")
+
+	_, _, err = listBlogs(c, &blogpb.ListBlogRequest{Tag: "two words"})
+	requireCode(t, "bad tag", err, codes.InvalidArgument)
+}
+
+func TestRevisions(t *testing.T) {
+	lis := newTestServer(t)
+	alice, bob := dialTestServer(t, lis, "alice-key"), dialTestServer(t, lis, "bob-key")
+	ctx := context.Background()
+	blog := createTestBlog(t, alice, "First", "Hello")
+	for _, title := range []string{"Second", "Third"} {
+		_, err := alice.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{Id: blog.GetId(), Title: title}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := bob.ListRevisions(ctx, &blogpb.ListRevisionsRequest{BlogId: blog.GetId(), PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireTitles(t, "first page", revisionTitles(res.GetRevisions()), "Third", "Second")
+	res, err = bob.ListRevisions(ctx, &blogpb.ListRevisionsRequest{BlogId: blog.GetId(), PageSize: 2, PageToken: res.GetNextPageToken()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireTitles(t, "second page", revisionTitles(res.GetRevisions()), "First")
+	if res.GetNextPageToken() != "" {
+		t.Fatalf("last page has next page token %q", res.GetNextPageToken())
+	}
+
+	_, err = bob.RestoreRevision(ctx, &blogpb.RestoreRevisionRequest{BlogId: blog.GetId(), Revision: 1})
+	requireCode(t, "restore by another author", err, codes.PermissionDenied)
+	_, err = alice.RestoreRevision(ctx, &blogpb.RestoreRevisionRequest{BlogId: blog.GetId(), Revision: 9})
+	requireCode(t, "unknown revision", err, codes.NotFound)
+	_, err = alice.RestoreRevision(ctx, &blogpb.RestoreRevisionRequest{BlogId: newBlogID(), Revision: 1})
+	requireCode(t, "unknown blog", err, codes.NotFound)
+	_, err = bob.ListRevisions(ctx, &blogpb.ListRevisionsRequest{BlogId: newBlogID()})
+	requireCode(t, "revisions of unknown blog", err, codes.NotFound)
+	_, err = bob.ListRevisions(ctx, &blogpb.ListRevisionsRequest{BlogId: "something_not_found"})
+	requireCode(t, "bad ID", err, codes.InvalidArgument)
+
+	restored, err := alice.RestoreRevision(ctx, &blogpb.RestoreRevisionRequest{BlogId: blog.GetId(), Revision: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.GetBlog().GetTitle() != "First" || restored.GetBlog().GetContent() != "Hello" || restored.GetBlog().GetRevision() != 4 {
+		t.Fatalf("restored %v, want revision 1 saved as 4", restored.GetBlog())
+	}
+	res, err = bob.ListRevisions(ctx, &blogpb.ListRevisionsRequest{BlogId: blog.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireTitles(t, "history", revisionTitles(res.GetRevisions()), "First", "Third", "Second", "First")
+}
+
+func TestComments(t *testing.T) {
+	lis := newTestServer(t)
+	alice, bob := dialTestServer(t, lis, "alice-key"), dialTestServer(t, lis, "bob-key")
+	anonymous := dialTestServer(t, lis, "")
+	ctx := context.Background()
+	blog := createTestBlog(t, alice, "First", "Hello")
+
+	added, err := bob.AddComment(ctx, &blogpb.AddCommentRequest{
+		Comment: &blogpb.Comment{BlogId: blog.GetId(), AuthorId: "alice", Content: "Nice"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment := added.GetComment()
+	if comment.GetAuthorId() != "bob" || comment.GetId() == "" || comment.GetCreatedAt() == nil {
+		t.Fatalf("added %v, want a comment by the caller", comment)
+	}
+	added, err = alice.AddComment(ctx, &blogpb.AddCommentRequest{
+		Comment: &blogpb.Comment{BlogId: blog.GetId(), ParentId: comment.GetId(), Content: "Thanks"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := added.GetComment()
+
+	res, err := anonymous.ListComments(ctx, &blogpb.ListCommentsRequest{BlogId: blog.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.GetComments()) != 2 || res.GetComments()[1].String() != reply.String() || res.GetComments()[1].GetParentId() != comment.GetId() {
+		t.Fatalf("listed %v, want the comment and its reply", res.GetComments())
+	}
+
+	_, err = anonymous.AddComment(ctx, &blogpb.AddCommentRequest{Comment: &blogpb.Comment{BlogId: blog.GetId(), Content: "Spam"}})
+	requireCode(t, "anonymous comment", err, codes.Unauthenticated)
+	_, err = bob.AddComment(ctx, &blogpb.AddCommentRequest{Comment: &blogpb.Comment{BlogId: blog.GetId()}})
+	requireCode(t, "empty comment", err, codes.InvalidArgument)
+	_, err = bob.AddComment(ctx, &blogpb.AddCommentRequest{Comment: &blogpb.Comment{BlogId: blog.GetId(), ParentId: newBlogID(), Content: "Lost"}})
+	requireCode(t, "unknown parent", err, codes.InvalidArgument)
+	_, err = bob.AddComment(ctx, &blogpb.AddCommentRequest{Comment: &blogpb.Comment{BlogId: newBlogID(), Content: "Lost"}})
+	requireCode(t, "unknown blog", err, codes.NotFound)
+	_, err = anonymous.ListComments(ctx, &blogpb.ListCommentsRequest{BlogId: newBlogID()})
+	requireCode(t, "comments of unknown blog", err, codes.NotFound)
+	_, err = anonymous.ListComments(ctx, &blogpb.ListCommentsRequest{BlogId: blog.GetId(), PageToken: "not a token"})
+	requireCode(t, "bad page token", err, codes.InvalidArgument)
+}
+
+func TestWatchBlogs(t *testing.T) {
+	lis := newTestServer(t)
+	alice, bob := dialTestServer(t, lis, "alice-key"), dialTestServer(t, lis, "bob-key")
+	anonymous := dialTestServer(t, lis, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	token := watchTestToken(t, alice)
+
+	gone := createTestBlog(t, bob, "Gone", "")
+	if _, err := bob.CreateBlog(ctx, &blogpb.CreateBlogRequest{Blog: &blogpb.Blog{Title: "Bob's", Tags: []string{"go"}}}); err != nil {
+		t.Fatal(err)
+	}
+	createTestBlog(t, alice, "Untagged", "")
+	created, err := alice.CreateBlog(ctx, &blogpb.CreateBlogRequest{Blog: &blogpb.Blog{Title: "Tagged", Tags: []string{"go"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blog := created.GetBlog()
+	blog.Title = "Tagged again"
+	if _, err := alice.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: blog}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bob.DeleteBlog(ctx, &blogpb.DeleteBlogRequest{BlogId: gone.GetId()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// bob's blog and the untagged one are filtered out, deletions reach everyone
+	events := watchTestEvents(t, anonymous, &blogpb.WatchBlogsRequest{AuthorId: "alice", Tag: "GO", ResumeToken: token}, 3)
+	requireTestEvent(t, "create", events[0], blogpb.BlogEventType_CREATED, "Tagged")
+	requireTestEvent(t, "update", events[1], blogpb.BlogEventType_UPDATED, "Tagged again")
+	requireTestEvent(t, "delete", events[2], blogpb.BlogEventType_DELETED, "")
+	if events[2].GetBlog().GetId() != gone.GetId() {
+		t.Fatalf("deleted %v, want %s", events[2].GetBlog(), gone.GetId())
+	}
+
+	resumed := watchTestEvents(t, anonymous, &blogpb.WatchBlogsRequest{Tag: "go", ResumeToken: events[0].GetResumeToken()}, 2)
+	requireTestEvent(t, "resumed update", resumed[0], blogpb.BlogEventType_UPDATED, "Tagged again")
+	requireTestEvent(t, "resumed delete", resumed[1], blogpb.BlogEventType_DELETED, "")
+
+	for _, test := range []struct {
+		name string
+		req  *blogpb.WatchBlogsRequest
+		want codes.Code
+	}{
+		{"bad token", &blogpb.WatchBlogsRequest{ResumeToken: "not a token"}, codes.InvalidArgument},
+		{"expired token", &blogpb.WatchBlogsRequest{ResumeToken: encodeWatchToken(broadcasterToken, "0.1")}, codes.OutOfRange},
+		{"bad tag", &blogpb.WatchBlogsRequest{Tag: "two words"}, codes.InvalidArgument},
+	} {
+		stream, err := anonymous.WatchBlogs(ctx, test.req)
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		requireCode(t, test.name, err, test.want)
+	}
+}
+
+// watchTestToken returns a token to watch from now, see watchToken
+func watchTestToken(t *testing.T, c blogpb.BlogServiceClient) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := c.WatchBlogs(ctx, &blogpb.WatchBlogsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := make(chan string, 1)
+	go func() {
+		if event, err := stream.Recv(); err == nil {
+			tokens <- event.GetResumeToken()
+		}
+	}()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		createTestBlog(t, c, probeTitle, "")
+		select {
+		case token := <-tokens:
+			return token
+		case <-ticker.C:
+		case <-ctx.Done():
+			t.Fatal("no event for the probe blogs")
+		}
+	}
+}
+
+// watchTestEvents returns the first n events of a watch, leaving out the probe blogs
+func watchTestEvents(t *testing.T, c blogpb.BlogServiceClient, req *blogpb.WatchBlogsRequest, n int) []*blogpb.BlogEvent {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := c.WatchBlogs(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []*blogpb.BlogEvent
+	for len(events) < n {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("watched %d of %d events: %v", len(events), n, err)
+		}
+		if event.GetBlog().GetTitle() != probeTitle {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func requireTestEvent(t *testing.T, name string, got *blogpb.BlogEvent, eventType blogpb.BlogEventType, title string) {
+	t.Helper()
+	if got.GetType() != eventType || got.GetBlog().GetTitle() != title || got.GetResumeToken() == "" {
+		t.Fatalf("%s: got %v, want a %v event of %q", name, got, eventType, title)
+	}
+}
+
+func revisionTitles(revisions []*blogpb.Revision) []string {
+	var titles []string
+	for _, revision := range revisions {
+		titles = append(titles, revision.GetTitle())
+	}
+	return titles
+}
+
+func blogTitles(blogs []*blogpb.Blog) []string {
+	var titles []string
+	for _, blog := range blogs {
+		titles = append(titles, blog.GetTitle())
+	}
+	return titles
+}
+
+func requireTitles(t *testing.T, name string, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %v, want %v", name, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"grpc/blog/blogpb"
+)
+
+const (
+	maxTags      = 20
+	maxTagLength = 32
+)
+
+// normalizeTags lower-cases tags, sorts them and drops duplicates
+// Tags must not be empty or hold spaces
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > maxTagLength || strings.IndexFunc(tag, unicode.IsSpace) >= 0 {
+			return nil, status.Errorf(
+				codes.InvalidArgument,
+				"Invalid tag %q: tags are 1 to %d characters without spaces", tag, maxTagLength,
+			)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxTags {
+		return nil, status.Errorf(
+			codes.InvalidArgument,
+			"Too many tags: at most %d", maxTags,
+		)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+func (s *server) ListTags(ctx context.Context, req *blogpb.ListTagsRequest) (*blogpb.ListTagsResponse, error) {
+	fmt.Println("List tags request")
+
+	tags, err := s.repo.ListTags(ctx)
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+
+	res := &blogpb.ListTagsResponse{}
+	for _, tag := range tags {
+		res.Tags = append(res.Tags, &blogpb.TagCount{Tag: tag.Tag, BlogCount: tag.Count})
+	}
+	return res, nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"grpc/blog/blogpb"
+)
+
+var (
+	// ErrInvalidToken is returned for a resume token no backend could have issued
+	ErrInvalidToken = errors.New("invalid resume token")
+	// ErrTokenExpired is returned for a resume token whose events are no longer kept
+	ErrTokenExpired = errors.New("resume token expired")
+)
+
+// BlogEvent is a change to a blog
+// DELETED events only carry the ID of the blog: the change streams of MongoDB
+// do not keep deleted documents
+type BlogEvent struct {
+	Type blogpb.BlogEventType
+	Blog *Blog
+	// Token resumes watching right after this event
+	Token string
+}
+
+// matches tells whether a watcher filtering by authorID and tag gets the event
+// Deletions reach every watcher since the deleted blog is unknown
+func (e *BlogEvent) matches(authorID, tag string) bool {
+	if e.Type == blogpb.BlogEventType_DELETED {
+		return true
+	}
+	if authorID != "" && e.Blog.AuthorID != authorID {
+		return false
+	}
+	return tag == "" || hasTag(e.Blog.Tags, tag)
+}
+
+func (s *server) WatchBlogs(req *blogpb.WatchBlogsRequest, stream blogpb.BlogService_WatchBlogsServer) error {
+	fmt.Println("Watch blogs request")
+
+	tag := ""
+	if req.GetTag() != "" {
+		tags, err := normalizeTags([]string{req.GetTag()})
+		if err != nil {
+			return err
+		}
+		tag = tags[0]
+	}
+
+	var sendErr error
+	err := s.repo.Watch(stream.Context(), req.GetResumeToken(), func(event *BlogEvent) error {
+		if !event.matches(req.GetAuthorId(), tag) {
+			return nil
+		}
+		res := &blogpb.BlogEvent{Type: event.Type, ResumeToken: event.Token}
+		if event.Type == blogpb.BlogEventType_DELETED {
+			res.Blog = &blogpb.Blog{Id: event.Blog.ID}
+		} else {
+			res.Blog = dataToBlogPb(event.Blog)
+		}
+		sendErr = stream.Send(res)
+		return sendErr
+	})
+	switch {
+	case sendErr != nil:
+		return sendErr
+	case stream.Context().Err() != nil:
+		// the client went away
+		return nil
+	default:
+		return repositoryError(err)
+	}
+}
+
+// Resume tokens name the source of their events, so a token of one source
+// is never taken for a token of another:
+//
+//	b:<epoch>.<sequence>  an event of the broadcaster of the process started at epoch
+//	m:<resume token>      an event of a MongoDB change stream, as raw BSON
+const (
+	broadcasterToken  = "b:"
+	changeStreamToken = "m:"
+)
+
+func encodeWatchToken(kind, payload string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(kind + payload))
+}
+
+// decodeWatchToken returns the kind and payload of a token, ErrInvalidToken if it has none
+func decodeWatchToken(token string) (string, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) < 2 {
+		return "", "", ErrInvalidToken
+	}
+	s := string(data)
+	return s[:2], s[2:], nil
+}
+
+// defaultBroadcastBacklog is the number of events a broadcaster keeps to resume watchers
+const defaultBroadcastBacklog = 4096
+
+// broadcaster hands the changes made by this process to its watchers
+// It keeps the latest events so that watchers can resume, and so that a slow
+// watcher never holds up writers: a watcher falling behind the backlog gets ErrTokenExpired.
+type broadcaster struct {
+	epoch string
+
+	mu sync.Mutex
+	// seq is the sequence number of the latest event, 0 before the first one
+	seq int64
+	// backlog holds the latest events, the oldest first; the last one has number seq
+	backlog []*BlogEvent
+	size    int
+	// notify is closed, and replaced, on each event
+	notify chan struct{}
+}
+
+func newBroadcaster(size int) *broadcaster {
+	return &broadcaster{
+		epoch:  strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:   size,
+		notify: make(chan struct{}),
+	}
+}
+
+// publish sends a change to the watchers
+// Callers publish in the order of their changes, e.g. while holding the lock of the repository
+func (b *broadcaster) publish(eventType blogpb.BlogEventType, blog *Blog) {
+	event := &BlogEvent{Type: eventType}
+	if eventType == blogpb.BlogEventType_DELETED {
+		event.Blog = &Blog{ID: blog.ID}
+	} else {
+		event.Blog = copyBlog(blog)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	event.Token = encodeWatchToken(broadcasterToken, b.epoch+"."+strconv.FormatInt(b.seq, 10))
+	b.backlog = append(b.backlog, event)
+	if len(b.backlog) > b.size {
+		b.backlog = b.backlog[len(b.backlog)-b.size:]
+	}
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// watch calls fn with each event after resumeToken, or from now if it is empty,
+// until ctx is done or fn fails
+func (b *broadcaster) watch(ctx context.Context, resumeToken string, fn func(*BlogEvent) error) error {
+	b.mu.Lock()
+	next := b.seq + 1
+	b.mu.Unlock()
+	if resumeToken != "" {
+		seq, err := b.parseToken(resumeToken)
+		if err != nil {
+			return err
+		}
+		next = seq + 1
+	}
+
+	for {
+		events, notify, err := b.since(next)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+		next += int64(len(events))
+		if len(events) > 0 {
+			continue
+		}
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// since returns the events from number next on, and the channel notifying the next one
+func (b *broadcaster) since(next int64) ([]*BlogEvent, <-chan struct{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if next > b.seq+1 {
+		return nil, nil, ErrInvalidToken
+	}
+	oldest := b.seq - int64(len(b.backlog)) + 1
+	if next < oldest {
+		return nil, nil, ErrTokenExpired
+	}
+	events := append([]*BlogEvent(nil), b.backlog[next-oldest:]...)
+	return events, b.notify, nil
+}
+
+// parseToken returns the sequence number of a token of this broadcaster
+// Tokens of an earlier process have expired with its events
+func (b *broadcaster) parseToken(token string) (int64, error) {
+	kind, payload, err := decodeWatchToken(token)
+	if err != nil || kind != broadcasterToken {
+		return 0, ErrInvalidToken
+	}
+	i := strings.IndexByte(payload, '.')
+	if i < 0 {
+		return 0, ErrInvalidToken
+	}
+	seq, err := strconv.ParseInt(payload[i+1:], 10, 64)
+	if err != nil || seq < 0 {
+		return 0, ErrInvalidToken
+	}
+	if payload[:i] != b.epoch {
+		return 0, ErrTokenExpired
+	}
+	return seq, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grpc/blog/blogpb"
+)
+
+func TestBroadcasterBacklog(t *testing.T) {
+	b := newBroadcaster(2)
+	var tokens []string
+	for _, title := range []string{"A", "B", "C"} {
+		b.publish(blogpb.BlogEventType_CREATED, &Blog{ID: title, Title: title})
+		tokens = append(tokens, b.backlog[len(b.backlog)-1].Token)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var titles []string
+	err := b.watch(ctx, tokens[0], func(event *BlogEvent) error {
+		titles = append(titles, event.Blog.Title)
+		if len(titles) == 2 {
+			return errEnoughEvents
+		}
+		return nil
+	})
+	if err != errEnoughEvents {
+		t.Fatal(err)
+	}
+	requireTitles(t, "after the oldest kept event", titles, "B", "C")
+
+	// the event after "A" was dropped from the backlog
+	if err := b.watch(ctx, encodeWatchToken(broadcasterToken, b.epoch+".0"), nil); err != ErrTokenExpired {
+		t.Fatalf("watch behind the backlog: got %v, want ErrTokenExpired", err)
+	}
+}
+
+func TestBroadcasterTokens(t *testing.T) {
+	b := newBroadcaster(defaultBroadcastBacklog)
+	b.publish(blogpb.BlogEventType_CREATED, &Blog{ID: "A"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, test := range []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"garbage", "not a token", ErrInvalidToken},
+		{"other kind", encodeWatchToken(changeStreamToken, "{}"), ErrInvalidToken},
+		{"no sequence", encodeWatchToken(broadcasterToken, b.epoch), ErrInvalidToken},
+		{"future", encodeWatchToken(broadcasterToken, b.epoch+".5"), ErrInvalidToken},
+		{"other process", encodeWatchToken(broadcasterToken, "0.1"), ErrTokenExpired},
+	} {
+		if err := b.watch(ctx, test.token, nil); err != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestBroadcasterWaits(t *testing.T) {
+	b := newBroadcaster(defaultBroadcastBacklog)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := make(chan *BlogEvent)
+	go b.watch(ctx, "", func(event *BlogEvent) error {
+		select {
+		case events <- event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	// publishes until the watcher has started, then checks it gets what follows
+	for started := false; !started; {
+		b.publish(blogpb.BlogEventType_CREATED, &Blog{ID: "probe"})
+		select {
+		case <-events:
+			started = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	b.publish(blogpb.BlogEventType_DELETED, &Blog{ID: "A", Title: "A"})
+
+	for {
+		select {
+		case event := <-events:
+			if event.Blog.ID == "probe" {
+				continue
+			}
+			if event.Type != blogpb.BlogEventType_DELETED || event.Blog.Title != "" {
+				t.Fatalf("got %+v, want a deletion carrying only the ID", event.Blog)
+			}
+			return
+		case <-ctx.Done():
+			t.Fatal("no event after waiting")
+		}
+	}
+}
+
+func TestBlogEventMatches(t *testing.T) {
+	blog := &Blog{ID: "A", AuthorID: "alice", Tags: []string{"go", "grpc"}}
+	for _, test := range []struct {
+		eventType blogpb.BlogEventType
+		authorID  string
+		tag       string
+		want      bool
+	}{
+		{blogpb.BlogEventType_CREATED, "", "", true},
+		{blogpb.BlogEventType_CREATED, "alice", "grpc", true},
+		{blogpb.BlogEventType_UPDATED, "bob", "", false},
+		{blogpb.BlogEventType_UPDATED, "alice", "rest", false},
+		{blogpb.BlogEventType_DELETED, "bob", "rest", true},
+	} {
+		event := &BlogEvent{Type: test.eventType, Blog: blog}
+		if got := event.matches(test.authorID, test.tag); got != test.want {
+			t.Errorf("%v by %q tagged %q: got %v, want %v", test.eventType, test.authorID, test.tag, got, test.want)
+		}
+	}
+}
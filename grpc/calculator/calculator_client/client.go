@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -11,13 +12,25 @@ import (
 
 	"google.golang.org/grpc/status"
 
-	"../calculatorpb"
-	"google.golang.org/grpc"
+	"grpc/calculator/calculatorpb"
+	"grpc/grpcclient"
 )
 
 func main() {
 	fmt.Println("[calculator] Hello! This is client")
-	cc, err := grpc.Dial("localhost:50051", grpc.WithInsecure())
+	cfg := grpcclient.RegisterFlags(grpcclient.Config{
+		Name:   "calculator",
+		Target: "localhost:50051",
+		// the calculations have no side effect: Sum and SquareRoot are hedged
+		// against a slow server, the stream is retried until it starts
+		Policies: []grpcclient.MethodPolicy{
+			{Service: "calculator.CalculatorService", Methods: []string{"Sum", "SquareRoot"}, Hedging: grpcclient.DefaultHedgingPolicy()},
+			{Service: "calculator.CalculatorService", Methods: []string{"PrimeNumberDecomposition"}, Retry: grpcclient.DefaultRetryPolicy()},
+		},
+	})
+	flag.Parse()
+
+	cc, err := grpcclient.Dial(*cfg)
 	if err != nil {
 		log.Fatalf("[calculator] Could not connect: %v\n", err)
 	}
@@ -33,6 +46,8 @@ func main() {
 	// doBiDirectionalStreaming(c)
 
 	doErrorUnary(c)
+
+	fmt.Print(cc.Metrics)
 }
 
 func doUnary(c calculatorpb.CalculatorServiceClient) {
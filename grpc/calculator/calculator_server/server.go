@@ -2,18 +2,18 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math"
-	"net"
 	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
-	"../calculatorpb"
-	"google.golang.org/grpc"
+	"grpc/calculator/calculatorpb"
+	"grpc/grpcserver"
 )
 
 type server struct{}
@@ -35,9 +35,12 @@ func (*server) PrimeNumberDecomposition(req *calculatorpb.PrimeNumberDecompositi
 	divisor := int64(2)
 	for number > 1 {
 		if number%divisor == 0 {
-			stream.Send(&calculatorpb.PrimeNumberDecompositionResponse{
+			sendErr := stream.Send(&calculatorpb.PrimeNumberDecompositionResponse{
 				PrimeFactor: divisor,
 			})
+			if sendErr != nil {
+				return sendErr
+			}
 			number = number / divisor
 		} else {
 			divisor++
@@ -61,7 +64,8 @@ func (*server) ComputeAverage(stream calculatorpb.CalculatorService_ComputeAvera
 			})
 		}
 		if err != nil {
-			log.Fatalf("[calculator] error while reading client stream: %v", err)
+			fmt.Printf("[calculator] error while reading client stream: %v\n", err)
+			return err
 		}
 		sum += req.GetNumber()
 		count++
@@ -78,7 +82,7 @@ func (*server) FindMaximum(stream calculatorpb.CalculatorService_FindMaximumServ
 			return nil
 		}
 		if err != nil {
-			log.Fatalf("[calculator] error while reading client stream: %v", err)
+			fmt.Printf("[calculator] error while reading client stream: %v\n", err)
 			return err
 		}
 		number := req.GetNumber()
@@ -88,8 +92,8 @@ func (*server) FindMaximum(stream calculatorpb.CalculatorService_FindMaximumServ
 				Max: max,
 			})
 			if sendErr != nil {
-				log.Fatalf("[calculator] error while sending data to client: %v", err)
-				return err
+				fmt.Printf("[calculator] error while sending data to client: %v\n", sendErr)
+				return sendErr
 			}
 		}
 	}
@@ -101,7 +105,7 @@ func (*server) SquareRoot(ctx context.Context, req *calculatorpb.SquareRootReque
 	if number < 0 {
 		return nil, status.Errorf(
 			codes.InvalidArgument,
-			fmt.Sprintf("[calculator] Received a negative number: %v", number),
+			"[calculator] Received a negative number: %v", number,
 		)
 	}
 	return &calculatorpb.SquareRootResponse{
@@ -111,13 +115,15 @@ func (*server) SquareRoot(ctx context.Context, req *calculatorpb.SquareRootReque
 
 func main() {
 	fmt.Println("[calculator] Hello! This is server")
-	lis, err := net.Listen("tcp", "0.0.0.0:50051")
+	cfg := grpcserver.RegisterFlags(grpcserver.Config{Name: "calculator", Addr: "0.0.0.0:50051"})
+	flag.Parse()
+
+	s, err := grpcserver.New(*cfg)
 	if err != nil {
-		log.Fatalf("[calculator] Failed to listen: %v\n", err)
+		log.Fatalf("[calculator] Failed to create the server: %v\n", err)
 	}
-	s := grpc.NewServer()
-	calculatorpb.RegisterCalculatorServiceServer(s, &server{})
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("[calculator] Failed to serve: %v\n", err)
+	calculatorpb.RegisterCalculatorServiceServer(s.Server, &server{})
+	if err := s.Run(); err != nil {
+		log.Fatalf("[calculator] %v\n", err)
 	}
 }
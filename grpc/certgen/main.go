@@ -0,0 +1,90 @@
+// Certgen makes the certificates of the gRPC servers and clients
+//
+// It signs a server certificate, and a client certificate per client, with the
+// CA of the output directory, creating the CA first if there is none:
+//
+//	go run certgen/main.go -hosts localhost,127.0.0.1 -clients alice,gateway
+//
+// writes in ssl/:
+//
+//	ca.crt, ca.key                       the CA: everyone trusts ca.crt, ca.key stays private
+//	server.crt, server.key               the certificate of the servers, valid for -hosts
+//	client-<name>.crt, client-<name>.key a certificate with the common name <name>
+//
+// Running it again rotates the certificates; servers and clients reload them
+// without a restart. -new-ca replaces the CA too.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"grpc/certs"
+)
+
+func main() {
+	out := flag.String("out", "ssl", "Output directory")
+	hosts := flag.String("hosts", "localhost,127.0.0.1", "Comma-separated DNS names and IP addresses of the servers")
+	clients := flag.String("clients", "", "Comma-separated common names of the client certificates, e.g. author ids")
+	validFor := flag.Duration("valid-for", 90*24*time.Hour, "Validity of the server and client certificates")
+	caValidFor := flag.Duration("ca-valid-for", 10*365*24*time.Hour, "Validity of a new CA")
+	newCA := flag.Bool("new-ca", false, "Replace the CA of the output directory")
+	flag.Parse()
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		log.Fatal(err)
+	}
+	caCert, caKey := filepath.Join(*out, "ca.crt"), filepath.Join(*out, "ca.key")
+	ca, err := certs.LoadCA(caCert, caKey)
+	switch {
+	case err == nil && !*newCA:
+		fmt.Printf("Using the CA of %s\n", caCert)
+	case err == nil || os.IsNotExist(err):
+		if ca, err = certs.NewCA("gRPC course CA", *caValidFor); err != nil {
+			log.Fatal(err)
+		}
+		if err := ca.Write(caCert, caKey); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Created the CA %s\n", caCert)
+	default:
+		log.Fatalf("Cannot load the CA: %v", err)
+	}
+
+	server, err := ca.IssueServer(split(*hosts), *validFor)
+	if err != nil {
+		log.Fatal(err)
+	}
+	write(server, *out, "server")
+
+	for _, name := range split(*clients) {
+		client, err := ca.IssueClient(name, *validFor)
+		if err != nil {
+			log.Fatal(err)
+		}
+		write(client, *out, "client-"+name)
+	}
+}
+
+func write(pair *certs.KeyPair, dir, name string) {
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := pair.Write(certFile, keyFile); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Wrote %s and %s\n", certFile, keyFile)
+}
+
+func split(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,214 @@
+package certs
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testFiles struct {
+	dir string
+	ca  *CA
+}
+
+// newTestFiles writes a CA, a server certificate for localhost and a client certificate for alice
+func newTestFiles(t *testing.T) *testFiles {
+	f := &testFiles{dir: t.TempDir()}
+	ca, err := NewCA("test CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.ca = ca
+	if err := ca.Write(f.path("ca.crt"), f.path("ca.key")); err != nil {
+		t.Fatal(err)
+	}
+	f.issueServer(t, ca)
+	f.issueClient(t, ca, "alice")
+	return f
+}
+
+func (f *testFiles) path(name string) string {
+	return filepath.Join(f.dir, name)
+}
+
+func (f *testFiles) issueServer(t *testing.T, ca *CA) {
+	pair, err := ca.IssueServer([]string{"localhost", "127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pair.Write(f.path("server.crt"), f.path("server.key")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *testFiles) issueClient(t *testing.T, ca *CA, name string) {
+	pair, err := ca.IssueClient(name, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pair.Write(f.path("client.crt"), f.path("client.key")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *testFiles) serverConfig(t *testing.T, clientCA string) *tls.Config {
+	config, err := ServerConfig(f.path("server.crt"), f.path("server.key"), clientCA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func (f *testFiles) clientConfig(t *testing.T, certFile, keyFile string) *tls.Config {
+	config, err := ClientConfig(f.path("ca.crt"), certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ServerName = "localhost"
+	return config
+}
+
+// handshake connects a client to a server, returning what the server saw
+// of the client and what the client saw of the server
+func handshake(server, client *tls.Config) (tls.ConnectionState, tls.ConnectionState, error) {
+	// over TCP rather than a net.Pipe, whose writes block until read: the
+	// alert of a server refusing the client would never be sent
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	if err != nil {
+		return tls.ConnectionState{}, tls.ConnectionState{}, err
+	}
+	defer lis.Close()
+
+	type result struct {
+		state tls.ConnectionState
+		err   error
+	}
+	accepted := make(chan result, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			accepted <- result{err: err}
+			return
+		}
+		defer conn.Close()
+		s := conn.(*tls.Conn)
+		err = s.Handshake()
+		accepted <- result{state: s.ConnectionState(), err: err}
+	}()
+
+	c, err := tls.Dial("tcp", lis.Addr().String(), client)
+	if err != nil {
+		<-accepted
+		return tls.ConnectionState{}, tls.ConnectionState{}, err
+	}
+	defer c.Close()
+	// with TLS 1.3 the server checks the certificate of the client after the
+	// client is done, so the server is waited for too
+	r := <-accepted
+	if r.err != nil {
+		return tls.ConnectionState{}, tls.ConnectionState{}, r.err
+	}
+	return r.state, c.ConnectionState(), nil
+}
+
+func TestMutualTLS(t *testing.T) {
+	f := newTestFiles(t)
+	server := f.serverConfig(t, f.path("ca.crt"))
+
+	serverState, clientState, err := handshake(server, f.clientConfig(t, f.path("client.crt"), f.path("client.key")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := serverState.VerifiedChains[0][0].Subject.CommonName; got != "alice" {
+		t.Fatalf("server saw client %q, want alice", got)
+	}
+	if clientState.NegotiatedProtocol != "h2" {
+		t.Fatalf("negotiated %q, want h2 as gRPC needs", clientState.NegotiatedProtocol)
+	}
+
+	if _, _, err := handshake(server, f.clientConfig(t, "", "")); err == nil {
+		t.Fatal("server accepted a client without certificate")
+	}
+
+	client := f.clientConfig(t, "", "")
+	client.ServerName = "example.com"
+	if _, _, err := handshake(f.serverConfig(t, ""), client); err == nil {
+		t.Fatal("client accepted a certificate for another host")
+	}
+
+	// a client certificate of another CA is refused
+	other := newTestFiles(t)
+	client = f.clientConfig(t, other.path("client.crt"), other.path("client.key"))
+	if _, _, err := handshake(server, client); err == nil {
+		t.Fatal("server accepted a client certificate of another CA")
+	}
+}
+
+func TestReload(t *testing.T) {
+	f := newTestFiles(t)
+	server := f.serverConfig(t, f.path("ca.crt"))
+	client := f.clientConfig(t, f.path("client.crt"), f.path("client.key"))
+
+	serverState, clientState, err := handshake(server, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverSerial := clientState.PeerCertificates[0].SerialNumber
+
+	// new certificates of the same CA
+	f.issueServer(t, f.ca)
+	f.issueClient(t, f.ca, "bob")
+	serverState, clientState, err = handshake(server, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientState.PeerCertificates[0].SerialNumber.Cmp(serverSerial) == 0 {
+		t.Fatal("server certificate not reloaded")
+	}
+	if got := serverState.VerifiedChains[0][0].Subject.CommonName; got != "bob" {
+		t.Fatalf("server saw client %q after rotation, want bob", got)
+	}
+
+	// a new CA, trusted by both sides before it signs the certificates
+	ca, err := NewCA("new test CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.Write(f.path("ca.crt"), f.path("ca.key")); err != nil {
+		t.Fatal(err)
+	}
+	f.issueServer(t, ca)
+	f.issueClient(t, ca, "carol")
+	if _, _, err := handshake(server, client); err != nil {
+		t.Fatalf("handshake after a new CA: %v", err)
+	}
+
+	// a broken file keeps the certificate loaded before
+	if err := ioutil.WriteFile(f.path("server.crt"), []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := handshake(server, client); err != nil {
+		t.Fatalf("handshake with a broken certificate file: %v", err)
+	}
+}
+
+func TestLoadCA(t *testing.T) {
+	f := newTestFiles(t)
+	ca, err := LoadCA(f.path("ca.crt"), f.path("ca.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.issueServer(t, ca)
+	if _, _, err := handshake(f.serverConfig(t, ""), f.clientConfig(t, "", "")); err != nil {
+		t.Fatalf("certificate of the loaded CA: %v", err)
+	}
+
+	if _, err := LoadCA(f.path("server.crt"), f.path("server.key")); err == nil {
+		t.Fatal("LoadCA accepted a server certificate")
+	}
+	if _, err := ServerConfig(f.path("missing.crt"), f.path("server.key"), ""); err == nil {
+		t.Fatal("ServerConfig accepted a missing certificate")
+	}
+}
@@ -0,0 +1,185 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// KeyPair is a certificate and its private key, PEM-encoded
+type KeyPair struct {
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// Write saves the pair to certFile and keyFile
+// Each file is replaced at once, so a reloading server never reads half a file.
+func (p *KeyPair) Write(certFile, keyFile string) error {
+	if err := writeFile(keyFile, p.KeyPEM, 0600); err != nil {
+		return err
+	}
+	return writeFile(certFile, p.CertPEM, 0644)
+}
+
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// CA is a certificate authority signing server and client certificates
+type CA struct {
+	KeyPair
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// NewCA returns a new self-signed CA
+func NewCA(commonName string, validFor time.Duration) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template, err := newTemplate(commonName, validFor)
+	if err != nil {
+		return nil, err
+	}
+	template.IsCA = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	template.BasicConstraintsValid = true
+	template.MaxPathLenZero = true
+
+	pair, err := sign(template, template, key, key)
+	if err != nil {
+		return nil, err
+	}
+	return newCA(pair)
+}
+
+// LoadCA reads a CA written by Write, to sign more certificates with it
+func LoadCA(certFile, keyFile string) (*CA, error) {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return newCA(&KeyPair{CertPEM: certPEM, KeyPEM: keyPEM})
+}
+
+func newCA(pair *KeyPair) (*CA, error) {
+	pemCert, err := tls.X509KeyPair(pair.CertPEM, pair.KeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(pemCert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	if !cert.IsCA {
+		return nil, errors.New("certs: not a CA certificate")
+	}
+	key, ok := pemCert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("certs: the CA key cannot sign")
+	}
+	return &CA{KeyPair: *pair, cert: cert, key: key}, nil
+}
+
+// IssueServer returns a server certificate for hosts, DNS names or IP addresses
+// The first host is also the common name.
+func (ca *CA) IssueServer(hosts []string, validFor time.Duration) (*KeyPair, error) {
+	if len(hosts) == 0 {
+		return nil, errors.New("certs: a server certificate needs a host")
+	}
+	template, err := newTemplate(hosts[0], validFor)
+	if err != nil {
+		return nil, err
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	return ca.issue(template)
+}
+
+// IssueClient returns a client certificate whose common name identifies the client
+func (ca *CA) IssueClient(commonName string, validFor time.Duration) (*KeyPair, error) {
+	template, err := newTemplate(commonName, validFor)
+	if err != nil {
+		return nil, err
+	}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	return ca.issue(template)
+}
+
+func (ca *CA) issue(template *x509.Certificate) (*KeyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	return sign(template, ca.cert, key, ca.key)
+}
+
+func newTemplate(commonName string, validFor time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		// tolerates clocks running a little late
+		NotBefore: now.Add(-5 * time.Minute),
+		NotAfter:  now.Add(validFor),
+	}, nil
+}
+
+func sign(template, parent *x509.Certificate, key *ecdsa.PrivateKey, parentKey crypto.Signer) (*KeyPair, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, fmt.Errorf("certs: signing %s: %v", template.Subject.CommonName, err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
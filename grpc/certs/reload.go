@@ -0,0 +1,200 @@
+// Package certs provides the TLS configs of the gRPC servers and clients, and
+// the certificates they use
+//
+// Certificates and CAs are read again when their files change, so rotating
+// them needs no restart: the handshakes after the change use the new files,
+// while the connections already open keep going.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+)
+
+// ServerConfig returns the TLS config of a server presenting certFile and keyFile
+// With clientCAFile, clients must present a certificate signed by one of its CAs: mutual TLS.
+func ServerConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := newKeyPairReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return cert.certificate(), nil
+		},
+	}
+	if clientCAFile == "" {
+		return config, nil
+	}
+
+	clientCAs, err := newCAReloader(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	// each handshake gets a config with the current CAs
+	base := config.Clone()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := base.Clone()
+		c.ClientCAs = clientCAs.pool()
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+		return c, nil
+	}
+	return config, nil
+}
+
+// ClientConfig returns the TLS config of a client trusting the CAs of caFile,
+// or the CAs of the system if it is empty
+// With certFile and keyFile, the client presents that certificate: mutual TLS.
+func ClientConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2"},
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := newKeyPairReloader(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert.certificate(), nil
+		}
+	}
+	if caFile == "" {
+		return config, nil
+	}
+
+	roots, err := newCAReloader(caFile)
+	if err != nil {
+		return nil, err
+	}
+	// RootCAs cannot change once set, so the chain is verified here against the
+	// current CAs instead; the checks are the ones of the default verification
+	config.InsecureSkipVerify = true
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("certs: the server presented no certificate")
+		}
+		opts := x509.VerifyOptions{
+			Roots:         roots.pool(),
+			DNSName:       cs.ServerName,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, cert := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		_, err := cs.PeerCertificates[0].Verify(opts)
+		return err
+	}
+	return config, nil
+}
+
+// reloader holds the value loaded from files, loading it again when one of
+// them changes; they are checked on each use, i.e. each handshake
+// A failed load, e.g. of files caught halfway through a rotation, keeps the
+// previous value and is tried again on the next use.
+type reloader struct {
+	files []string
+	load  func() (interface{}, error)
+
+	mu    sync.Mutex
+	value interface{}
+	stamp string
+}
+
+func newReloader(load func() (interface{}, error), files ...string) (*reloader, error) {
+	r := &reloader{files: files, load: load}
+	stamp, err := r.stampFiles()
+	if err != nil {
+		return nil, err
+	}
+	if r.value, err = load(); err != nil {
+		return nil, err
+	}
+	r.stamp = stamp
+	return r, nil
+}
+
+// stampFiles sums up the size and modification time of the files
+func (r *reloader) stampFiles() (string, error) {
+	stamp := ""
+	for _, file := range r.files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		stamp += fmt.Sprintf("%d.%d;", info.Size(), info.ModTime().UnixNano())
+	}
+	return stamp, nil
+}
+
+func (r *reloader) get() interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stamp, err := r.stampFiles()
+	if err != nil || stamp == r.stamp {
+		return r.value
+	}
+	value, err := r.load()
+	if err != nil {
+		log.Printf("certs: keeping the loaded %v: %v", r.files, err)
+		return r.value
+	}
+	r.value, r.stamp = value, stamp
+	return value
+}
+
+type keyPairReloader struct {
+	*reloader
+}
+
+func newKeyPairReloader(certFile, keyFile string) (*keyPairReloader, error) {
+	r, err := newReloader(func() (interface{}, error) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("certs: loading %s: %v", certFile, err)
+		}
+		return &cert, nil
+	}, certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &keyPairReloader{r}, nil
+}
+
+func (r *keyPairReloader) certificate() *tls.Certificate {
+	return r.get().(*tls.Certificate)
+}
+
+type caReloader struct {
+	*reloader
+}
+
+func newCAReloader(caFile string) (*caReloader, error) {
+	r, err := newReloader(func() (interface{}, error) {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("certs: no certificate in %s", caFile)
+		}
+		return pool, nil
+	}, caFile)
+	if err != nil {
+		return nil, err
+	}
+	return &caReloader{r}, nil
+}
+
+func (r *caReloader) pool() *x509.CertPool {
+	return r.get().(*x509.CertPool)
+}
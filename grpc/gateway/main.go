@@ -0,0 +1,113 @@
+// The gateway serves the blog and calculator services as REST/JSON
+//
+// Routes come from the google.api.http annotations of the protos; the OpenAPI
+// document generated by buf (see buf.gen.yaml) is served at /openapi.json.
+// Server-streaming RPCs come as newline-delimited JSON, one {"result": ...}
+// object per message, or {"error": ...} if the stream fails.
+//
+//	curl localhost:8080/v1/blogs?page_size=10
+//	curl -X POST -H "Authorization: Bearer $BLOG_TOKEN" -d '{"title": "Hi"}' localhost:8080/v1/blogs
+//	curl -X POST -d '{"first_number": 3, "second_number": 10}' localhost:8080/v1/calculator/sum
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/encoding/protojson"
+	"grpc/blog/blogpb"
+	"grpc/calculator/calculatorpb"
+	"grpc/certs"
+)
+
+func main() {
+	// if we crash the go code, we get the file name and line number
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	addr := flag.String("addr", "0.0.0.0:8080", "HTTP address of the gateway")
+	blogEndpoint := flag.String("blog-endpoint", "localhost:51051", "gRPC address of the blog server")
+	calculatorEndpoint := flag.String("calculator-endpoint", "localhost:50051", "gRPC address of the calculator server")
+	openAPI := flag.String("openapi", "openapi/api.swagger.json", "OpenAPI document served at /openapi.json")
+	caFile := flag.String("tls-ca", "", "CA certificate of the gRPC servers, enables TLS")
+	certFile := flag.String("tls-cert", "", "Client certificate of the gateway, for servers asking for one (mutual TLS)")
+	keyFile := flag.String("tls-key", "", "Private key of the client certificate")
+	flag.Parse()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mux := runtime.NewServeMux(
+		// field names as in the protos, and zero values rather than missing fields
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				UseProtoNames:   true,
+				EmitUnpopulated: true,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
+		}),
+	)
+
+	// the "Authorization" header is forwarded as the "authorization" metadata
+	// the blog server checks; it wins over the certificate of the gateway
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if *caFile != "" {
+		tlsConfig, err := certs.ClientConfig(*caFile, *certFile, *keyFile)
+		if err != nil {
+			log.Fatalf("Failed to load the certificates: %v", err)
+		}
+		opts[0] = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+	if err := blogpb.RegisterBlogServiceHandlerFromEndpoint(ctx, mux, *blogEndpoint, opts); err != nil {
+		log.Fatalf("Failed to register the blog service: %v", err)
+	}
+	if err := calculatorpb.RegisterCalculatorServiceHandlerFromEndpoint(ctx, mux, *calculatorEndpoint, opts); err != nil {
+		log.Fatalf("Failed to register the calculator service: %v", err)
+	}
+
+	spec, err := ioutil.ReadFile(*openAPI)
+	if err != nil {
+		log.Fatalf("Failed to read the OpenAPI document, run buf generate first: %v", err)
+	}
+	err = mux.HandlePath("GET", "/openapi.json", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: mux}
+	go func() {
+		fmt.Printf("Starting Gateway on %s...\n", *addr)
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatalf("Failed to serve: %v", err)
+		}
+	}()
+
+	// Wait for Ctrl + C to exit
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt)
+
+	// Block until a signal is received
+	<-ch
+
+	fmt.Println("Stopping Gateway...")
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to stop: %v", err)
+	}
+	fmt.Println("End of program")
+}
@@ -2,36 +2,35 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"time"
 
-	"google.golang.org/grpc/credentials"
-
 	"google.golang.org/grpc/codes"
 
 	"google.golang.org/grpc/status"
 
-	"../greetpb"
-	"google.golang.org/grpc"
+	"grpc/greet/greetpb"
+	"grpc/grpcclient"
 )
 
 func main() {
 	fmt.Println("Hello World! This is Client")
 
-	tls := true
-	opts := grpc.WithInsecure()
-	if tls {
-		certFile := "ssl/ca.crt" // Certificate Authority Trust certificate
-		creds, sslErr := credentials.NewClientTLSFromFile(certFile, "")
-		if sslErr != nil {
-			log.Fatalf("Error while loading CA trust certificate: %v", sslErr)
-		}
-		opts = grpc.WithTransportCredentials(creds)
-	}
+	cfg := grpcclient.RegisterFlags(grpcclient.Config{
+		Name:   "greet",
+		Target: "localhost:50051",
+		CAFile: "ssl/ca.crt",
+		// Greet only builds a string: sending it again is harmless
+		Policies: []grpcclient.MethodPolicy{
+			{Service: "greet.GreetService", Methods: []string{"Greet"}, Retry: grpcclient.DefaultRetryPolicy()},
+		},
+	})
+	flag.Parse()
 
-	cc, err := grpc.Dial("localhost:50051", opts)
+	cc, err := grpcclient.Dial(*cfg)
 	if err != nil {
 		log.Fatalf("Could not connect: %v", err)
 	}
@@ -49,6 +48,8 @@ func main() {
 
 	// doUnaryWithDeadline(c, 5*time.Second) // should complete
 	// doUnaryWithDeadline(c, 1*time.Second) // should timeout
+
+	fmt.Print(cc.Metrics)
 }
 
 func doUnary(c greetpb.GreetServiceClient) {
@@ -2,20 +2,18 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"io"
 	"log"
-	"net"
 	"time"
 
-	"google.golang.org/grpc/credentials"
-
 	"google.golang.org/grpc/status"
 
 	"google.golang.org/grpc/codes"
 
-	"../greetpb"
-	"google.golang.org/grpc"
+	"grpc/greet/greetpb"
+	"grpc/grpcserver"
 )
 
 type server struct{}
@@ -40,7 +38,9 @@ func (*server) GreetManyTimes(req *greetpb.GreetManyTimesRequest, stream greetpb
 		res := &greetpb.GreetManyTimesResponse{
 			Result: fmt.Sprintf("Times [%d]: %s", (i + 1), result),
 		}
-		stream.Send(res)
+		if err := stream.Send(res); err != nil {
+			return err
+		}
 		time.Sleep(1000 * time.Millisecond)
 	}
 	return nil
@@ -58,7 +58,8 @@ func (*server) LongGreet(stream greetpb.GreetService_LongGreetServer) error {
 			})
 		}
 		if err != nil {
-			log.Fatalf("error while reading client stream: %v", err)
+			fmt.Printf("error while reading client stream: %v\n", err)
+			return err
 		}
 		firstName := req.GetGreeting().GetFirstName()
 		lastName := req.GetGreeting().GetLastName()
@@ -74,7 +75,7 @@ func (*server) GreetEveryone(stream greetpb.GreetService_GreetEveryoneServer) er
 			return nil
 		}
 		if err != nil {
-			log.Fatalf("error while reading client stream: %v", err)
+			fmt.Printf("error while reading client stream: %v\n", err)
 			return err
 		}
 		firstName := req.GetGreeting().GetFirstName()
@@ -84,7 +85,7 @@ func (*server) GreetEveryone(stream greetpb.GreetService_GreetEveryoneServer) er
 			Result: result,
 		})
 		if sendErr != nil {
-			log.Fatalf("error while sending data to client: %v", sendErr)
+			fmt.Printf("error while sending data to client: %v\n", sendErr)
 			return sendErr
 		}
 	}
@@ -111,27 +112,22 @@ func (*server) GreetWithDeadline(ctx context.Context, req *greetpb.GreetWithDead
 
 func main() {
 	fmt.Println("Hello World! This is Server")
-	lis, err := net.Listen("tcp", "0.0.0.0:50051")
-	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
-	}
+	// TLS by default, as the client expects it: make the certificates with
+	// go run certgen/main.go
+	cfg := grpcserver.RegisterFlags(grpcserver.Config{
+		Name:     "greet",
+		Addr:     "0.0.0.0:50051",
+		CertFile: "ssl/server.crt",
+		KeyFile:  "ssl/server.key",
+	})
+	flag.Parse()
 
-	opts := []grpc.ServerOption{}
-	tls := true
-	if tls {
-		certFile := "ssl/server.crt"
-		keyFile := "ssl/server.pem"
-		creds, ssLErr := credentials.NewServerTLSFromFile(certFile, keyFile)
-		if ssLErr != nil {
-			log.Fatalf("Failed loading certificates: %v", err)
-			return
-		}
-		opts = append(opts, grpc.Creds(creds))
+	s, err := grpcserver.New(*cfg)
+	if err != nil {
+		log.Fatalf("Failed to create the server: %v", err)
 	}
-
-	s := grpc.NewServer(opts...)
-	greetpb.RegisterGreetServiceServer(s, &server{})
-	if err := s.Serve(lis); err != nil {
-		log.Fatalf("Failed to serve: %v", err)
+	greetpb.RegisterGreetServiceServer(s.Server, &server{})
+	if err := s.Run(); err != nil {
+		log.Fatal(err)
 	}
 }
@@ -0,0 +1,144 @@
+// Package grpcclient dials the gRPC servers
+//
+// A Conn balances its calls round-robin over the addresses of its target,
+// retries or hedges the idempotent methods its policies name, gives unary
+// calls a default deadline and counts every call in its Metrics.
+//
+//	cfg := grpcclient.RegisterFlags(grpcclient.Config{Name: "calculator", Target: "localhost:50051"})
+//	flag.Parse()
+//	cc, err := grpcclient.Dial(*cfg)
+//	c := calculatorpb.NewCalculatorServiceClient(cc)
+package grpcclient
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"grpc/certs"
+)
+
+// Config configures a Conn
+type Config struct {
+	// Name prefixes the logs and metrics of the client
+	Name string
+	// Target is an address, a comma-separated list of addresses, or a gRPC
+	// target resolving to several addresses, e.g. "dns:///blog.internal:51051"
+	Target string
+
+	// CAFile enables TLS, trusting the servers certified by this CA
+	// With CertFile and KeyFile the client presents a certificate: mutual TLS.
+	// The files are reloaded when they change: see package certs.
+	CAFile   string
+	CertFile string
+	KeyFile  string
+
+	// Timeout is the deadline of the unary calls made without one
+	// Streams may run for long, e.g. WatchBlogs, so they get none.
+	Timeout time.Duration
+
+	// Policies retry or hedge the calls of idempotent methods
+	Policies []MethodPolicy
+
+	// DialOptions are added to the ones of the Config
+	DialOptions []grpc.DialOption
+}
+
+// DefaultTimeout is the default of Config.Timeout
+const DefaultTimeout = 10 * time.Second
+
+// RegisterFlags defines the flags of the client on the command line, taking
+// their defaults from cfg, and returns the Config flag.Parse fills in
+func RegisterFlags(cfg Config) *Config {
+	c := &cfg
+	flag.StringVar(&c.Target, "target", cfg.Target, "Server address, comma-separated addresses to balance over, or a target like dns:///host:port")
+	flag.StringVar(&c.CAFile, "tls-ca", cfg.CAFile, "CA certificate of the servers, enables TLS")
+	flag.StringVar(&c.CertFile, "tls-cert", cfg.CertFile, "Client certificate, for servers asking for one (mutual TLS)")
+	flag.StringVar(&c.KeyFile, "tls-key", cfg.KeyFile, "Private key of the client certificate")
+	flag.DurationVar(&c.Timeout, "timeout", cfg.Timeout, "Deadline of the unary calls (default 10s)")
+	return c
+}
+
+// Conn is a client connection with its metrics
+type Conn struct {
+	*grpc.ClientConn
+	Metrics *Metrics
+}
+
+// Dial returns a connection to the servers of cfg.Target
+// Like grpc.Dial it does not wait for the servers: calls wait for one to be ready.
+func Dial(cfg Config) (*Conn, error) {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	serviceConfig, err := ServiceConfig(cfg.Policies)
+	if err != nil {
+		return nil, err
+	}
+	hedging, err := newHedging(cfg.Policies)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := newMetrics()
+	opts := []grpc.DialOption{
+		grpc.WithDefaultServiceConfig(serviceConfig),
+		grpc.WithStatsHandler(metrics),
+		// hedging is within the deadline of the call, and counts as one call
+		grpc.WithChainUnaryInterceptor(metrics.unary, deadline(cfg.Timeout), hedging.unary),
+		grpc.WithChainStreamInterceptor(metrics.stream),
+	}
+
+	if cfg.CAFile != "" {
+		tlsConfig, err := certs.ClientConfig(cfg.CAFile, cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else if cfg.CertFile != "" {
+		return nil, errors.New("mutual TLS needs the CA of the servers")
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	target := cfg.Target
+	if !strings.Contains(target, "://") {
+		// a list of addresses, resolved by a resolver of this connection only
+		addrs := splitAddrs(target)
+		if len(addrs) == 0 {
+			return nil, errors.New("no server address")
+		}
+		r := manual.NewBuilderWithScheme("static")
+		state := resolver.State{}
+		for _, addr := range addrs {
+			state.Addresses = append(state.Addresses, resolver.Address{Addr: addr})
+		}
+		r.InitialState(state)
+		// servers are checked against the name of the first one
+		opts = append(opts, grpc.WithResolvers(r), grpc.WithAuthority(addrs[0]))
+		target = "static:///" + addrs[0]
+	}
+
+	opts = append(opts, cfg.DialOptions...)
+	cc, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("dialing %s: %v", cfg.Target, err)
+	}
+	return &Conn{ClientConn: cc, Metrics: metrics}, nil
+}
+
+func splitAddrs(list string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
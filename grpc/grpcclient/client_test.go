@@ -0,0 +1,419 @@
+package grpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// backend serves test.Echo, answering with its name, after the fault injected
+// into the call if any
+type backend struct {
+	name string
+
+	mu    sync.Mutex
+	calls int
+	// fault returns the delay and error of the call n of the backend, from 1
+	fault func(n int) (time.Duration, error)
+}
+
+func (b *backend) answer(ctx context.Context) (*wrapperspb.StringValue, error) {
+	b.mu.Lock()
+	b.calls++
+	n, fault := b.calls, b.fault
+	b.mu.Unlock()
+	if fault != nil {
+		delay, err := fault(n)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return wrapperspb.String(b.name), nil
+}
+
+func (b *backend) callCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls
+}
+
+func unaryHandler(name string) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			if err := dec(&emptypb.Empty{}); err != nil {
+				return nil, err
+			}
+			return srv.(*backend).answer(ctx)
+		},
+	}
+}
+
+// testService has two unary methods, Get and Put, and List streaming a
+// single answer
+var testService = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*interface{})(nil),
+	Methods:     []grpc.MethodDesc{unaryHandler("Get"), unaryHandler("Put")},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "List",
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			if err := stream.RecvMsg(&emptypb.Empty{}); err != nil {
+				return err
+			}
+			answer, err := srv.(*backend).answer(stream.Context())
+			if err != nil {
+				return err
+			}
+			return stream.SendMsg(answer)
+		},
+	}},
+}
+
+// startBackends serves a backend per fault over in-process listeners, and
+// returns the backends with the Config of a client of them all
+func startBackends(t *testing.T, faults ...func(n int) (time.Duration, error)) ([]*backend, Config) {
+	listeners := make(map[string]*bufconn.Listener)
+	var backends []*backend
+	var addrs []string
+	for i, fault := range faults {
+		b := &backend{name: fmt.Sprintf("backend-%d", i), fault: fault}
+		lis := bufconn.Listen(1024 * 1024)
+		s := grpc.NewServer()
+		s.RegisterService(&testService, b)
+		go s.Serve(lis)
+		t.Cleanup(s.Stop)
+
+		backends = append(backends, b)
+		listeners[b.name] = lis
+		addrs = append(addrs, b.name)
+	}
+
+	return backends, Config{
+		Name:   "test",
+		Target: strings.Join(addrs, ","),
+		DialOptions: []grpc.DialOption{
+			grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+				lis, ok := listeners[addr]
+				if !ok {
+					return nil, fmt.Errorf("no backend %s", addr)
+				}
+				return lis.DialContext(ctx)
+			}),
+		},
+	}
+}
+
+func dial(t *testing.T, cfg Config) *Conn {
+	cc, err := Dial(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return cc
+}
+
+// warmUp calls Get until every backend answered, as the balancer only picks
+// the backends already connected: the first calls may all go to the first one
+// ready. It then resets the call counts of the backends.
+func warmUp(t *testing.T, cc *Conn, backends []*backend) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100 && len(seen) < len(backends); i++ {
+		name, err := call(context.Background(), cc, "Get")
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[name] = true
+	}
+	if len(seen) != len(backends) {
+		t.Fatalf("calls answered by %v, want all of %d backends", seen, len(backends))
+	}
+	for _, b := range backends {
+		b.mu.Lock()
+		b.calls = 0
+		b.mu.Unlock()
+	}
+}
+
+func call(ctx context.Context, cc *Conn, method string) (string, error) {
+	out := &wrapperspb.StringValue{}
+	err := cc.Invoke(ctx, "/test.Echo/"+method, &emptypb.Empty{}, out)
+	return out.GetValue(), err
+}
+
+// failing fails the first calls of a backend with code
+func failing(calls int, code codes.Code) func(n int) (time.Duration, error) {
+	return func(n int) (time.Duration, error) {
+		if n <= calls {
+			return 0, status.Errorf(code, "fault %d", n)
+		}
+		return 0, nil
+	}
+}
+
+// slow delays every call of a backend
+func slow(delay time.Duration) func(n int) (time.Duration, error) {
+	return func(int) (time.Duration, error) { return delay, nil }
+}
+
+func TestRoundRobin(t *testing.T) {
+	backends, cfg := startBackends(t, nil, nil, nil)
+	cc := dial(t, cfg)
+
+	// once all are ready, calls alternate between them
+	warmUp(t, cc, backends)
+	for i := 0; i < 3*len(backends); i++ {
+		if _, err := call(context.Background(), cc, "Get"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, b := range backends {
+		if n := b.callCount(); n != 3 {
+			t.Errorf("%s got %d calls, want 3", b.name, n)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	backends, cfg := startBackends(t, failing(1, codes.Unavailable), failing(1, codes.Unavailable))
+	cfg.Policies = []MethodPolicy{{Service: "test.Echo", Methods: []string{"Get"}, Retry: &RetryPolicy{
+		MaxAttempts:       4,
+		InitialBackoff:    time.Millisecond,
+		MaxBackoff:        10 * time.Millisecond,
+		BackoffMultiplier: 2,
+		RetryableCodes:    []codes.Code{codes.Unavailable},
+	}}}
+	cc := dial(t, cfg)
+
+	for i := 0; i < 5; i++ {
+		if _, err := call(context.Background(), cc, "Get"); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	stats := cc.Metrics.Snapshot()["/test.Echo/Get"]
+	if stats.Calls != 5 || stats.Codes[codes.OK] != 5 {
+		t.Errorf("got %d calls, %d OK, want 5 OK", stats.Calls, stats.Codes[codes.OK])
+	}
+	failed := 0
+	for _, b := range backends {
+		if b.callCount() > 0 {
+			failed++
+		}
+	}
+	if stats.Attempts != 5+int64(failed) {
+		t.Errorf("got %d attempts, want %d with the %d failed ones", stats.Attempts, 5+failed, failed)
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	cases := []struct {
+		name   string
+		fault  codes.Code
+		method string
+	}{
+		{name: "code not retryable", fault: codes.InvalidArgument, method: "Get"},
+		{name: "method without policy", fault: codes.Unavailable, method: "Put"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, cfg := startBackends(t, failing(1, c.fault))
+			cfg.Policies = []MethodPolicy{{Service: "test.Echo", Methods: []string{"Get"}, Retry: DefaultRetryPolicy()}}
+			cc := dial(t, cfg)
+
+			if _, err := call(context.Background(), cc, c.method); status.Code(err) != c.fault {
+				t.Fatalf("got %v, want %v", err, c.fault)
+			}
+			stats := cc.Metrics.Snapshot()["/test.Echo/"+c.method]
+			if stats.Calls != 1 || stats.Attempts != 1 || stats.Codes[c.fault] != 1 {
+				t.Errorf("got %+v, want a single failed attempt", stats)
+			}
+		})
+	}
+}
+
+func TestHedging(t *testing.T) {
+	backends, cfg := startBackends(t, nil, nil)
+	cfg.Policies = []MethodPolicy{{Service: "test.Echo", Hedging: &HedgingPolicy{
+		MaxAttempts:   2,
+		HedgingDelay:  20 * time.Millisecond,
+		NonFatalCodes: []codes.Code{codes.Unavailable},
+	}}}
+	cc := dial(t, cfg)
+	// the hedged attempts must find the fast backend ready
+	warmUp(t, cc, backends)
+	backends[0].mu.Lock()
+	backends[0].fault = slow(time.Minute)
+	backends[0].mu.Unlock()
+	before := cc.Metrics.Snapshot()["/test.Echo/Get"]
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		name, err := call(context.Background(), cc, "Get")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != backends[1].name {
+			t.Errorf("call %d answered by %s, want the fast %s", i, name, backends[1].name)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("calls took %v, the slow backend was waited for", elapsed)
+	}
+
+	stats := cc.Metrics.Snapshot()["/test.Echo/Get"]
+	calls, attempts := stats.Calls-before.Calls, stats.Attempts-before.Attempts
+	if calls != 4 || stats.Codes[codes.OK] != before.Codes[codes.OK]+4 {
+		t.Errorf("got %d calls, %d OK, want 4 more OK", calls, stats.Codes[codes.OK])
+	}
+	if attempts <= calls {
+		t.Errorf("got %d attempts for %d calls, want hedged calls", attempts, calls)
+	}
+}
+
+func TestHedgingFatalCode(t *testing.T) {
+	_, cfg := startBackends(t, failing(10, codes.NotFound))
+	cfg.Policies = []MethodPolicy{{Service: "test.Echo", Hedging: DefaultHedgingPolicy()}}
+	cc := dial(t, cfg)
+
+	if _, err := call(context.Background(), cc, "Get"); status.Code(err) != codes.NotFound {
+		t.Fatalf("got %v, want NotFound", err)
+	}
+	if stats := cc.Metrics.Snapshot()["/test.Echo/Get"]; stats.Attempts != 1 {
+		t.Errorf("got %d attempts, want 1: NotFound is fatal", stats.Attempts)
+	}
+}
+
+func TestHedgingNonFatalCode(t *testing.T) {
+	_, cfg := startBackends(t, failing(1, codes.Unavailable))
+	cfg.Policies = []MethodPolicy{{Service: "test.Echo", Hedging: &HedgingPolicy{
+		MaxAttempts:   3,
+		HedgingDelay:  time.Minute,
+		NonFatalCodes: []codes.Code{codes.Unavailable},
+	}}}
+	cc := dial(t, cfg)
+
+	// the failure starts the next attempt without waiting for the delay
+	if _, err := call(context.Background(), cc, "Get"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := cc.Metrics.Snapshot()["/test.Echo/Get"]; stats.Attempts != 2 {
+		t.Errorf("got %d attempts, want 2", stats.Attempts)
+	}
+}
+
+func TestDefaultDeadline(t *testing.T) {
+	_, cfg := startBackends(t, slow(time.Minute))
+	cfg.Timeout = 50 * time.Millisecond
+	cc := dial(t, cfg)
+
+	if _, err := call(context.Background(), cc, "Get"); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("got %v, want DeadlineExceeded", err)
+	}
+
+	// a deadline of the caller is kept
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, cfg = startBackends(t, slow(200*time.Millisecond))
+	cfg.Timeout = 50 * time.Millisecond
+	cc = dial(t, cfg)
+	if _, err := call(ctx, cc, "Get"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamMetrics(t *testing.T) {
+	_, cfg := startBackends(t, nil)
+	cc := dial(t, cfg)
+
+	desc := &grpc.StreamDesc{StreamName: "List", ServerStreams: true}
+	stream, err := cc.NewStream(context.Background(), desc, "/test.Echo/List")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&emptypb.Empty{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if err := stream.RecvMsg(&wrapperspb.StringValue{}); err != nil {
+			break
+		}
+	}
+
+	stats := cc.Metrics.Snapshot()["/test.Echo/List"]
+	if stats.Calls != 1 || stats.Attempts != 1 || stats.Codes[codes.OK] != 1 {
+		t.Errorf("got %+v, want a single OK call", stats)
+	}
+	if !strings.Contains(cc.Metrics.String(), "/test.Echo/List calls=1 attempts=1") {
+		t.Errorf("metrics %q do not list the stream", cc.Metrics.String())
+	}
+}
+
+func TestServiceConfig(t *testing.T) {
+	sc, err := ServiceConfig([]MethodPolicy{
+		{Service: "test.Echo", Methods: []string{"Get"}, Retry: &RetryPolicy{
+			MaxAttempts:       3,
+			InitialBackoff:    100 * time.Millisecond,
+			MaxBackoff:        time.Second,
+			BackoffMultiplier: 2,
+			RetryableCodes:    []codes.Code{codes.Unavailable, codes.DeadlineExceeded},
+		}},
+		{Service: "test.Other", Hedging: DefaultHedgingPolicy()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		LoadBalancingConfig []map[string]interface{}
+		MethodConfig        []struct {
+			Name        []map[string]string
+			RetryPolicy map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal([]byte(sc), &config); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.LoadBalancingConfig) != 1 || config.LoadBalancingConfig[0]["round_robin"] == nil {
+		t.Errorf("got balancing %v, want round_robin", config.LoadBalancingConfig)
+	}
+	if len(config.MethodConfig) != 1 {
+		t.Fatalf("got %d method configs, want the retried one only", len(config.MethodConfig))
+	}
+	mc := config.MethodConfig[0]
+	if got := mc.Name[0]; got["service"] != "test.Echo" || got["method"] != "Get" {
+		t.Errorf("got name %v", got)
+	}
+	if got := mc.RetryPolicy["initialBackoff"]; got != "0.100s" {
+		t.Errorf("got initial backoff %v, want 0.100s", got)
+	}
+	if got := fmt.Sprint(mc.RetryPolicy["retryableStatusCodes"]); got != "[UNAVAILABLE DEADLINE_EXCEEDED]" {
+		t.Errorf("got codes %s", got)
+	}
+
+	if _, err := ServiceConfig([]MethodPolicy{{Service: "test.Echo", Retry: DefaultRetryPolicy(), Hedging: DefaultHedgingPolicy()}}); err == nil {
+		t.Error("retry and hedging of the same method should fail")
+	}
+	if _, err := ServiceConfig([]MethodPolicy{{Service: "test.Echo", Retry: &RetryPolicy{MaxAttempts: 1}}}); err == nil {
+		t.Error("a retry policy without retries should fail")
+	}
+}
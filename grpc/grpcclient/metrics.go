@@ -0,0 +1,180 @@
+package grpcclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// MethodStats are the metrics of the calls of a method
+type MethodStats struct {
+	// Calls are the calls made by the application
+	Calls int64
+	// Attempts are the calls sent to a server: more than Calls when some were
+	// retried or hedged
+	Attempts int64
+	// Codes counts the calls by the code they ended with
+	Codes map[codes.Code]int64
+	// Latency is the total duration of the calls; streams count until their end
+	Latency    time.Duration
+	MaxLatency time.Duration
+}
+
+// MeanLatency is the mean duration of the calls
+func (s MethodStats) MeanLatency() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.Latency / time.Duration(s.Calls)
+}
+
+// Metrics counts the calls of a Conn by method
+type Metrics struct {
+	mu      sync.Mutex
+	methods map[string]*MethodStats // by full method name, e.g. "/blog.BlogService/ReadBlog"
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{methods: make(map[string]*MethodStats)}
+}
+
+// Snapshot returns a copy of the metrics by full method name
+func (m *Metrics) Snapshot() map[string]MethodStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshot := make(map[string]MethodStats, len(m.methods))
+	for method, s := range m.methods {
+		c := *s
+		c.Codes = make(map[codes.Code]int64, len(s.Codes))
+		for code, n := range s.Codes {
+			c.Codes[code] = n
+		}
+		snapshot[method] = c
+	}
+	return snapshot
+}
+
+// String lists the metrics, a method per line
+func (m *Metrics) String() string {
+	snapshot := m.Snapshot()
+	methods := make([]string, 0, len(snapshot))
+	for method := range snapshot {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	var b strings.Builder
+	for _, method := range methods {
+		s := snapshot[method]
+		fmt.Fprintf(&b, "%s calls=%d attempts=%d mean=%v max=%v", method, s.Calls, s.Attempts, s.MeanLatency(), s.MaxLatency)
+		codeList := make([]codes.Code, 0, len(s.Codes))
+		for code := range s.Codes {
+			codeList = append(codeList, code)
+		}
+		sort.Slice(codeList, func(i, j int) bool { return codeList[i] < codeList[j] })
+		for _, code := range codeList {
+			fmt.Fprintf(&b, " %s=%d", code, s.Codes[code])
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// method returns the stats of method, to update with m.mu held
+func (m *Metrics) method(method string) *MethodStats {
+	s, ok := m.methods[method]
+	if !ok {
+		s = &MethodStats{Codes: make(map[codes.Code]int64)}
+		m.methods[method] = s
+	}
+	return s
+}
+
+func (m *Metrics) record(method string, err error, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.method(method)
+	s.Calls++
+	s.Codes[status.Code(err)]++
+	s.Latency += latency
+	if latency > s.MaxLatency {
+		s.MaxLatency = latency
+	}
+}
+
+func (m *Metrics) unary(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	m.record(method, err, time.Since(start))
+	return err
+}
+
+func (m *Metrics) stream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	start := time.Now()
+	s, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		m.record(method, err, time.Since(start))
+		return nil, err
+	}
+	return &meteredStream{ClientStream: s, metrics: m, method: method, start: start}, nil
+}
+
+// meteredStream records its call when RecvMsg ends it
+// A stream the application abandons without reading its end is not counted.
+type meteredStream struct {
+	grpc.ClientStream
+	metrics *Metrics
+	method  string
+	start   time.Time
+	once    sync.Once
+}
+
+func (s *meteredStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.once.Do(func() {
+			if err == io.EOF {
+				s.metrics.record(s.method, nil, time.Since(s.start))
+			} else {
+				s.metrics.record(s.method, err, time.Since(s.start))
+			}
+		})
+	}
+	return err
+}
+
+type methodKey struct{}
+
+// TagRPC implements stats.Handler, keeping the method of the attempt
+func (m *Metrics) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, methodKey{}, info.FullMethodName)
+}
+
+// HandleRPC implements stats.Handler, counting the attempts
+func (m *Metrics) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	begin, ok := s.(*stats.Begin)
+	if !ok || !begin.IsClient() {
+		return
+	}
+	method, _ := ctx.Value(methodKey{}).(string)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.method(method).Attempts++
+}
+
+// TagConn implements stats.Handler
+func (m *Metrics) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn implements stats.Handler
+func (m *Metrics) HandleConn(context.Context, stats.ConnStats) {}
@@ -0,0 +1,280 @@
+package grpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// MethodPolicy retries or hedges the calls of some methods of a service
+// Only idempotent methods should have one: a call may reach several servers.
+type MethodPolicy struct {
+	// Service is the full name of the service, e.g. "blog.BlogService"
+	Service string
+	// Methods are the names of the methods, e.g. "ReadBlog"; all the methods
+	// of the service if empty
+	Methods []string
+
+	// Retry resends a call failing with a retryable code, after a backoff
+	Retry *RetryPolicy
+	// Hedging sends a call again when the previous attempts are slow to answer,
+	// to the next server, and takes the first answer
+	// It only applies to unary calls, and cannot be combined with Retry.
+	Hedging *HedgingPolicy
+}
+
+// RetryPolicy is the retry policy of the gRPC service config, see
+// https://github.com/grpc/proposal/blob/master/A6-client-retries.md
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt, at most 5
+	MaxAttempts       int
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration
+	BackoffMultiplier float64
+	RetryableCodes    []codes.Code
+}
+
+// HedgingPolicy sends up to MaxAttempts attempts of a call, HedgingDelay apart
+// An attempt failing with a non-fatal code starts the next one at once; any
+// other failure ends the call.
+type HedgingPolicy struct {
+	MaxAttempts   int
+	HedgingDelay  time.Duration
+	NonFatalCodes []codes.Code
+}
+
+// DefaultRetryPolicy retries calls failing because no server could take them
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:       4,
+		InitialBackoff:    100 * time.Millisecond,
+		MaxBackoff:        time.Second,
+		BackoffMultiplier: 2,
+		RetryableCodes:    []codes.Code{codes.Unavailable},
+	}
+}
+
+// DefaultHedgingPolicy sends a second attempt after 100ms without an answer,
+// and a third one after 200ms
+func DefaultHedgingPolicy() *HedgingPolicy {
+	return &HedgingPolicy{
+		MaxAttempts:   3,
+		HedgingDelay:  100 * time.Millisecond,
+		NonFatalCodes: []codes.Code{codes.Unavailable},
+	}
+}
+
+// ServiceConfig returns the gRPC service config of policies: round-robin
+// balancing, the retry policies, and a throttling of the retries when most
+// calls fail, so the retries of many clients do not bring down a recovering server
+// Hedging policies are left out, as grpc-go does not hedge: the interceptor of Dial does.
+func ServiceConfig(policies []MethodPolicy) (string, error) {
+	type name struct {
+		Service string `json:"service"`
+		Method  string `json:"method,omitempty"`
+	}
+	type retryPolicy struct {
+		MaxAttempts          int      `json:"maxAttempts"`
+		InitialBackoff       string   `json:"initialBackoff"`
+		MaxBackoff           string   `json:"maxBackoff"`
+		BackoffMultiplier    float64  `json:"backoffMultiplier"`
+		RetryableStatusCodes []string `json:"retryableStatusCodes"`
+	}
+	type methodConfig struct {
+		Name        []name       `json:"name"`
+		RetryPolicy *retryPolicy `json:"retryPolicy"`
+	}
+	config := struct {
+		LoadBalancingConfig []map[string]struct{} `json:"loadBalancingConfig"`
+		MethodConfig        []methodConfig        `json:"methodConfig,omitempty"`
+		RetryThrottling     map[string]float64    `json:"retryThrottling"`
+	}{
+		LoadBalancingConfig: []map[string]struct{}{{"round_robin": {}}},
+		RetryThrottling:     map[string]float64{"maxTokens": 10, "tokenRatio": 0.1},
+	}
+
+	for _, p := range policies {
+		if err := p.validate(); err != nil {
+			return "", err
+		}
+		if p.Retry == nil {
+			continue
+		}
+		mc := methodConfig{RetryPolicy: &retryPolicy{
+			MaxAttempts:       p.Retry.MaxAttempts,
+			InitialBackoff:    duration(p.Retry.InitialBackoff),
+			MaxBackoff:        duration(p.Retry.MaxBackoff),
+			BackoffMultiplier: p.Retry.BackoffMultiplier,
+		}}
+		for _, code := range p.Retry.RetryableCodes {
+			mc.RetryPolicy.RetryableStatusCodes = append(mc.RetryPolicy.RetryableStatusCodes, codeName(code))
+		}
+		if len(p.Methods) == 0 {
+			mc.Name = []name{{Service: p.Service}}
+		}
+		for _, method := range p.Methods {
+			mc.Name = append(mc.Name, name{Service: p.Service, Method: method})
+		}
+		config.MethodConfig = append(config.MethodConfig, mc)
+	}
+
+	data, err := json.Marshal(config)
+	return string(data), err
+}
+
+func (p *MethodPolicy) validate() error {
+	switch {
+	case p.Service == "":
+		return errors.New("policy without a service")
+	case p.Retry != nil && p.Hedging != nil:
+		return fmt.Errorf("policy of %s: a method is either retried or hedged", p.Service)
+	case p.Retry != nil && (p.Retry.MaxAttempts < 2 || p.Retry.InitialBackoff <= 0 ||
+		p.Retry.MaxBackoff <= 0 || p.Retry.BackoffMultiplier <= 0 || len(p.Retry.RetryableCodes) == 0):
+		return fmt.Errorf("retry policy of %s: needs at least 2 attempts, backoffs, a multiplier and retryable codes", p.Service)
+	case p.Hedging != nil && p.Hedging.MaxAttempts < 2:
+		return fmt.Errorf("hedging policy of %s: needs at least 2 attempts", p.Service)
+	}
+	return nil
+}
+
+// duration formats d as in JSON protos, e.g. "0.100s"
+func duration(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+// codeName returns the name of code in service configs, e.g. "DEADLINE_EXCEEDED"
+func codeName(code codes.Code) string {
+	if code == codes.OK {
+		return "OK"
+	}
+	var b strings.Builder
+	for i, r := range code.String() {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// deadline gives timeout to the unary calls made without a deadline
+func deadline(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// hedging applies the hedging policies to unary calls
+type hedging struct {
+	// policies by full method name, or "/<service>/" for all the methods of a service
+	policies map[string]*HedgingPolicy
+}
+
+func newHedging(policies []MethodPolicy) (*hedging, error) {
+	h := &hedging{policies: make(map[string]*HedgingPolicy)}
+	for _, p := range policies {
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+		if p.Hedging == nil {
+			continue
+		}
+		if len(p.Methods) == 0 {
+			h.policies["/"+p.Service+"/"] = p.Hedging
+		}
+		for _, method := range p.Methods {
+			h.policies["/"+p.Service+"/"+method] = p.Hedging
+		}
+	}
+	return h, nil
+}
+
+func (h *hedging) policy(method string) *HedgingPolicy {
+	if p, ok := h.policies[method]; ok {
+		return p
+	}
+	return h.policies[method[:strings.LastIndexByte(method, '/')+1]]
+}
+
+// unary sends the attempts of a hedged call, each with its own reply, and
+// copies the first successful reply into reply
+// The attempts share the call options: options writing back to the caller,
+// like grpc.Header, may see any attempt.
+func (h *hedging) unary(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	policy := h.policy(method)
+	msg, ok := reply.(proto.Message)
+	if policy == nil || !ok {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	// ends the attempts still running once the call is decided
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		reply proto.Message
+		err   error
+	}
+	results := make(chan result, policy.MaxAttempts)
+	started, done := 0, 0
+	start := func() {
+		started++
+		attemptReply := msg.ProtoReflect().New().Interface()
+		go func() {
+			err := invoker(ctx, method, req, attemptReply, cc, opts...)
+			results <- result{reply: attemptReply, err: err}
+		}()
+	}
+
+	start()
+	timer := time.NewTimer(policy.HedgingDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if started < policy.MaxAttempts {
+				start()
+				timer.Reset(policy.HedgingDelay)
+			}
+		case r := <-results:
+			done++
+			if r.err == nil {
+				proto.Reset(msg)
+				proto.Merge(msg, r.reply)
+				return nil
+			}
+			if !hasCode(policy.NonFatalCodes, status.Code(r.err)) {
+				return r.err
+			}
+			if started < policy.MaxAttempts {
+				start()
+				timer.Reset(policy.HedgingDelay)
+			} else if done == started {
+				return r.err
+			}
+		}
+	}
+}
+
+func hasCode(list []codes.Code, code codes.Code) bool {
+	for _, c := range list {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package grpcserver
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RecoveryUnary turns a panic of a handler into an INTERNAL error, so one bad
+// call does not bring the server down
+func RecoveryUnary(name string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(name, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStream is RecoveryUnary for streaming calls
+func RecoveryStream(name string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(name, info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// recovered logs a panic with its stack; the caller only learns that something failed
+func recovered(name, method string, r interface{}) error {
+	log.Printf("[%s] %s panicked: %v\n%s", name, method, r, debug.Stack())
+	return status.Errorf(codes.Internal, "internal error")
+}
+
+// LoggingUnary logs each call with its outcome and duration
+// Health checks are left out, as load balancers make them all the time.
+func LoggingUnary(name string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+		logCall(ctx, name, info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+// LoggingStream is LoggingUnary for streaming calls, logged when they end
+func LoggingStream(name string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(ss.Context(), name, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logCall(ctx context.Context, name, method string, start time.Time, err error) {
+	if strings.HasPrefix(method, "/grpc.health.v1.Health/") {
+		return
+	}
+	from := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		from = p.Addr.String()
+	}
+	if err != nil {
+		log.Printf("[%s] %s from %s: %v in %v: %v", name, method, from, status.Code(err), time.Since(start), err)
+		return
+	}
+	log.Printf("[%s] %s from %s: OK in %v", name, method, from, time.Since(start))
+}
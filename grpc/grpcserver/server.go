@@ -0,0 +1,228 @@
+// Package grpcserver bootstraps the gRPC servers
+//
+// A Server comes with the health service, reflection, keepalive enforcement,
+// message size limits, optional TLS or mutual TLS, and interceptors logging
+// each call and turning panics into INTERNAL errors. Run serves until SIGINT
+// or SIGTERM, then drains the calls in flight before stopping.
+//
+//	s, err := grpcserver.New(grpcserver.RegisterFlags(grpcserver.Config{Name: "greet", Addr: "0.0.0.0:50051"}))
+//	greetpb.RegisterGreetServiceServer(s.Server, &server{})
+//	err = s.Run()
+package grpcserver
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
+	"grpc/certs"
+)
+
+// Config configures a Server; zero values take the defaults below
+type Config struct {
+	// Name prefixes the logs of the server
+	Name string
+	// Addr is the address Run listens on
+	Addr string
+
+	// CertFile and KeyFile enable TLS when set
+	// They are reloaded when they change, like ClientCAFile: see package certs.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mutual TLS: clients need a certificate signed by this CA
+	ClientCAFile string
+
+	MaxRecvMsgSize int
+	MaxSendMsgSize int
+
+	// MinPingInterval is the shortest interval allowed between the keepalive
+	// pings of a client; clients pinging more often are disconnected
+	MinPingInterval time.Duration
+	// PingInterval is the time after which the server pings an idle connection,
+	// and PingTimeout how long it waits for the answer before closing it
+	PingInterval time.Duration
+	PingTimeout  time.Duration
+	// MaxConnectionIdle closes the connections without calls for that long
+	MaxConnectionIdle time.Duration
+
+	// ShutdownTimeout bounds the wait for the calls in flight, e.g. endless
+	// streams, before they are cancelled
+	ShutdownTimeout time.Duration
+
+	// UnaryInterceptors and StreamInterceptors run after logging and recovery,
+	// in order, e.g. to authenticate the callers
+	UnaryInterceptors  []grpc.UnaryServerInterceptor
+	StreamInterceptors []grpc.StreamServerInterceptor
+}
+
+// Defaults of Config
+const (
+	DefaultMaxMsgSize        = 4 << 20
+	DefaultMinPingInterval   = 10 * time.Second
+	DefaultPingInterval      = 2 * time.Hour
+	DefaultPingTimeout       = 20 * time.Second
+	DefaultMaxConnectionIdle = 15 * time.Minute
+	DefaultShutdownTimeout   = 10 * time.Second
+)
+
+func (cfg Config) withDefaults() Config {
+	if cfg.MaxRecvMsgSize == 0 {
+		cfg.MaxRecvMsgSize = DefaultMaxMsgSize
+	}
+	if cfg.MaxSendMsgSize == 0 {
+		cfg.MaxSendMsgSize = DefaultMaxMsgSize
+	}
+	if cfg.MinPingInterval == 0 {
+		cfg.MinPingInterval = DefaultMinPingInterval
+	}
+	if cfg.PingInterval == 0 {
+		cfg.PingInterval = DefaultPingInterval
+	}
+	if cfg.PingTimeout == 0 {
+		cfg.PingTimeout = DefaultPingTimeout
+	}
+	if cfg.MaxConnectionIdle == 0 {
+		cfg.MaxConnectionIdle = DefaultMaxConnectionIdle
+	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	return cfg
+}
+
+// RegisterFlags defines the flags of the bootstrap on the command line, taking
+// their defaults from cfg, and returns the Config flag.Parse fills in
+func RegisterFlags(cfg Config) *Config {
+	c := &cfg
+	flag.StringVar(&c.Addr, "addr", cfg.Addr, "Address to listen on")
+	flag.StringVar(&c.CertFile, "tls-cert", cfg.CertFile, "PEM certificate of the server, enables TLS with -tls-key")
+	flag.StringVar(&c.KeyFile, "tls-key", cfg.KeyFile, "PEM private key of the server")
+	flag.StringVar(&c.ClientCAFile, "tls-client-ca", cfg.ClientCAFile, "PEM CA certificate clients must present a certificate of (mutual TLS)")
+	flag.IntVar(&c.MaxRecvMsgSize, "max-recv-msg-size", cfg.MaxRecvMsgSize, "Largest message received, in bytes (default 4 MiB)")
+	flag.IntVar(&c.MaxSendMsgSize, "max-send-msg-size", cfg.MaxSendMsgSize, "Largest message sent, in bytes (default 4 MiB)")
+	flag.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "Longest wait for the calls in flight on shutdown (default 10s)")
+	return c
+}
+
+// Server is a gRPC server with its health service
+// Services register on the embedded grpc.Server before Serve or Run.
+type Server struct {
+	*grpc.Server
+	Health *health.Server
+	cfg    Config
+}
+
+// New returns a server configured by cfg
+func New(cfg Config) (*Server, error) {
+	cfg = cfg.withDefaults()
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize),
+		grpc.MaxSendMsgSize(cfg.MaxSendMsgSize),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime: cfg.MinPingInterval,
+			// long streams like WatchBlogs may go quiet for a while
+			PermitWithoutStream: true,
+		}),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionIdle: cfg.MaxConnectionIdle,
+			Time:              cfg.PingInterval,
+			Timeout:           cfg.PingTimeout,
+		}),
+		grpc.ChainUnaryInterceptor(append([]grpc.UnaryServerInterceptor{
+			LoggingUnary(cfg.Name),
+			RecoveryUnary(cfg.Name),
+		}, cfg.UnaryInterceptors...)...),
+		grpc.ChainStreamInterceptor(append([]grpc.StreamServerInterceptor{
+			LoggingStream(cfg.Name),
+			RecoveryStream(cfg.Name),
+		}, cfg.StreamInterceptors...)...),
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		tlsConfig, err := certs.ServerConfig(cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	} else if cfg.ClientCAFile != "" {
+		return nil, errors.New("mutual TLS needs the certificate and key of the server")
+	}
+
+	s := &Server{
+		Server: grpc.NewServer(opts...),
+		Health: health.NewServer(),
+		cfg:    cfg,
+	}
+	healthpb.RegisterHealthServer(s.Server, s.Health)
+	// cli: evans -p 50051 -r
+	reflection.Register(s.Server)
+	return s, nil
+}
+
+// Serve marks the registered services as serving, then serves lis until Shutdown
+func (s *Server) Serve(lis net.Listener) error {
+	s.Health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	for name := range s.GetServiceInfo() {
+		s.Health.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+	return s.Server.Serve(lis)
+}
+
+// Shutdown marks the services as not serving, so load balancers stop sending
+// calls, and waits for the calls in flight up to ShutdownTimeout before
+// cancelling the rest
+func (s *Server) Shutdown() {
+	s.Health.Shutdown()
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(s.cfg.ShutdownTimeout):
+		log.Printf("[%s] Calls still running after %v, cancelling them", s.cfg.Name, s.cfg.ShutdownTimeout)
+		s.Stop()
+		<-done
+	}
+}
+
+// Run serves on Addr until SIGINT or SIGTERM, then shuts down
+func (s *Server) Run() error {
+	lis, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %v", err)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		fmt.Printf("[%s] Serving on %s...\n", s.cfg.Name, lis.Addr())
+		errc <- s.Serve(lis)
+	}()
+
+	// Wait for Ctrl + C, or the stop of a container, to exit
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(ch)
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("failed to serve: %v", err)
+	case sig := <-ch:
+		fmt.Printf("[%s] Received %v, stopping...\n", s.cfg.Name, sig)
+	}
+	s.Shutdown()
+	return nil
+}
@@ -0,0 +1,279 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"grpc/certs"
+)
+
+// testService has a unary and a streaming method, both panicking when asked to
+// The stream otherwise waits for the end of the call.
+var testService = grpc.ServiceDesc{
+	ServiceName: "test.Test",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Panic",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := &emptypb.Empty{}
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/test.Test/Panic"}
+			return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				panic("boom")
+			})
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Wait",
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			if err := stream.RecvMsg(&emptypb.Empty{}); err != nil {
+				return err
+			}
+			if doPanic, _ := stream.Context().Value(panicKey{}).(bool); doPanic {
+				panic("boom")
+			}
+			if err := stream.SendHeader(metadata.MD{}); err != nil {
+				return err
+			}
+			<-stream.Context().Done()
+			return stream.Context().Err()
+		},
+	}},
+}
+
+type panicKey struct{}
+
+// startTestServer serves s with the test service over an in-process listener
+func startTestServer(t *testing.T, s *Server) *grpc.ClientConn {
+	lis := bufconn.Listen(1024 * 1024)
+	s.RegisterService(&testService, struct{}{})
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(lis) }()
+	t.Cleanup(func() {
+		s.Stop()
+		<-served
+	})
+
+	cc, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return cc
+}
+
+func TestRecovery(t *testing.T) {
+	s, err := New(Config{
+		Name: "test",
+		// marks the stream to panic, as the client cannot pass Go values
+		StreamInterceptors: []grpc.StreamServerInterceptor{
+			func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				return handler(srv, &panicStream{ss})
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := startTestServer(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = cc.Invoke(ctx, "/test.Test/Panic", &emptypb.Empty{}, &emptypb.Empty{})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("unary panic: got %v, want INTERNAL", err)
+	}
+
+	stream, err := cc.NewStream(ctx, &testService.Streams[0], "/test.Test/Wait")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&emptypb.Empty{}); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+	if err := stream.RecvMsg(&emptypb.Empty{}); status.Code(err) != codes.Internal {
+		t.Fatalf("stream panic: got %v, want INTERNAL", err)
+	}
+
+	// the server survived
+	res, err := healthpb.NewHealthClient(cc).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("health after panics: %v", res.GetStatus())
+	}
+}
+
+type panicStream struct {
+	grpc.ServerStream
+}
+
+func (s *panicStream) Context() context.Context {
+	return context.WithValue(s.ServerStream.Context(), panicKey{}, true)
+}
+
+func TestHealth(t *testing.T) {
+	s, err := New(Config{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := startTestServer(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := healthpb.NewHealthClient(cc)
+	for _, service := range []string{"", "test.Test"} {
+		res, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("health of %q: %v, want SERVING", service, res.GetStatus())
+		}
+	}
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown.Service"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("health of an unknown service: got %v, want NOT_FOUND", err)
+	}
+
+	s.Shutdown()
+	res, err := s.Health.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("health after shutdown: %v, want NOT_SERVING", res.GetStatus())
+	}
+}
+
+func TestShutdownCancelsLongCalls(t *testing.T) {
+	s, err := New(Config{Name: "test", ShutdownTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := startTestServer(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := cc.NewStream(ctx, &testService.Streams[0], "/test.Test/Wait")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&emptypb.Empty{}); err != nil {
+		t.Fatal(err)
+	}
+	// the stream is running once its headers come
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	s.Shutdown()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("shutdown took %v despite its timeout", elapsed)
+	}
+	if err := stream.RecvMsg(&emptypb.Empty{}); status.Code(err) != codes.Unavailable && status.Code(err) != codes.Canceled {
+		t.Fatalf("stream after shutdown: got %v, want UNAVAILABLE or CANCELED", err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := certs.NewCA("test CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.Write(filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")); err != nil {
+		t.Fatal(err)
+	}
+	for name, issue := range map[string]func() (*certs.KeyPair, error){
+		"server": func() (*certs.KeyPair, error) { return ca.IssueServer([]string{"localhost"}, time.Hour) },
+		"client": func() (*certs.KeyPair, error) { return ca.IssueClient("alice", time.Hour) },
+	} {
+		pair, err := issue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pair.Write(filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the identity of the client reaches the handlers
+	commonNames := make(chan string, 1)
+	s, err := New(Config{
+		Name:         "test",
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+		UnaryInterceptors: []grpc.UnaryServerInterceptor{
+			func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				p, _ := peer.FromContext(ctx)
+				commonNames <- p.AuthInfo.(credentials.TLSInfo).State.VerifiedChains[0][0].Subject.CommonName
+				return handler(ctx, req)
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	tlsConfig, err := certs.ClientConfig(filepath.Join(dir, "ca.crt"), filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc, err := grpc.Dial(lis.Addr().String(),
+		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+		grpc.WithAuthority("localhost"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := healthpb.NewHealthClient(cc).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-commonNames; got != "alice" {
+		t.Fatalf("handler saw client %q, want alice", got)
+	}
+}
+
+func TestNewNeedsCertificateForMutualTLS(t *testing.T) {
+	if _, err := New(Config{ClientCAFile: "ca.crt"}); err == nil {
+		t.Fatal("New without a server certificate accepted a client CA")
+	}
+	if _, err := New(Config{CertFile: "missing.crt", KeyFile: "missing.key"}); err == nil {
+		t.Fatal("New accepted missing certificate files")
+	}
+}
@@ -0,0 +1,149 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"temporal/models"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// ApprovalStore keeps approval requests so reviewers can find and decide them
+type ApprovalStore interface {
+	Save(ctx context.Context, req models.ApprovalRequest) error
+	Get(ctx context.Context, orderID string) (models.ApprovalRequest, bool, error)
+	ListPending(ctx context.Context) ([]models.ApprovalRequest, error)
+}
+
+// MemoryApprovalStore is an in-process ApprovalStore
+// It is shared by the activities and the reviewer HTTP endpoint of the same worker,
+// so a multi-worker deployment needs a shared implementation instead
+type MemoryApprovalStore struct {
+	mu       sync.RWMutex
+	requests map[string]models.ApprovalRequest
+}
+
+func NewMemoryApprovalStore() *MemoryApprovalStore {
+	return &MemoryApprovalStore{requests: make(map[string]models.ApprovalRequest)}
+}
+
+func (s *MemoryApprovalStore) Save(_ context.Context, req models.ApprovalRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[req.OrderID] = req
+	return nil
+}
+
+func (s *MemoryApprovalStore) Get(_ context.Context, orderID string) (models.ApprovalRequest, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	req, ok := s.requests[orderID]
+	return req, ok, nil
+}
+
+func (s *MemoryApprovalStore) ListPending(_ context.Context) ([]models.ApprovalRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var pending []models.ApprovalRequest
+	for _, req := range s.requests {
+		if req.Status == "pending" || req.Status == "escalated" {
+			pending = append(pending, req)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].RequestedAt.Before(pending[j].RequestedAt)
+	})
+	return pending, nil
+}
+
+// ApprovalActivities records approval requests for high-value orders
+// The decision itself arrives as a signal on the order workflow
+type ApprovalActivities struct {
+	Store ApprovalStore
+}
+
+// RequestApproval registers a pending approval for the calling workflow
+// Idempotent: a retry keeps the original request time
+func (a *ApprovalActivities) RequestApproval(ctx context.Context, order models.Order) error {
+	logger := activity.GetLogger(ctx)
+	info := activity.GetInfo(ctx)
+
+	existing, ok, err := a.Store.Get(ctx, order.OrderID)
+	if err != nil {
+		return fmt.Errorf("approval store error: %w", err)
+	}
+	if ok && existing.WorkflowID == info.WorkflowExecution.ID && existing.Status != "approved" && existing.Status != "rejected" {
+		logger.Info("Approval already requested", "OrderID", order.OrderID)
+		return nil
+	}
+
+	req := models.ApprovalRequest{
+		OrderID:     order.OrderID,
+		CustomerID:  order.CustomerID,
+		WorkflowID:  info.WorkflowExecution.ID,
+		Amount:      order.TotalAmount,
+		Status:      "pending",
+		RequestedAt: time.Now(),
+	}
+	if err := a.Store.Save(ctx, req); err != nil {
+		return fmt.Errorf("approval store error: %w", err)
+	}
+
+	logger.Info("Approval requested", "OrderID", order.OrderID, "Amount", order.TotalAmount)
+	return nil
+}
+
+// EscalateApproval marks a pending approval as escalated
+// The workflow sends the escalation notification separately
+func (a *ApprovalActivities) EscalateApproval(ctx context.Context, orderID string) error {
+	logger := activity.GetLogger(ctx)
+
+	req, ok, err := a.Store.Get(ctx, orderID)
+	if err != nil {
+		return fmt.Errorf("approval store error: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("no approval request for order %s", orderID)
+	}
+	if req.Status != "pending" {
+		return nil
+	}
+
+	req.Status = "escalated"
+	if err := a.Store.Save(ctx, req); err != nil {
+		return fmt.Errorf("approval store error: %w", err)
+	}
+
+	logger.Warn("Approval escalated", "OrderID", orderID)
+	return nil
+}
+
+// ResolveApproval records the final decision (including automatic rejections)
+func (a *ApprovalActivities) ResolveApproval(ctx context.Context, orderID string, decision models.ApprovalDecision) error {
+	logger := activity.GetLogger(ctx)
+
+	req, ok, err := a.Store.Get(ctx, orderID)
+	if err != nil {
+		return fmt.Errorf("approval store error: %w", err)
+	}
+	if !ok {
+		req = models.ApprovalRequest{OrderID: orderID}
+	}
+
+	req.Status = "rejected"
+	if decision.Approved {
+		req.Status = "approved"
+	}
+	req.Decision = &decision
+	req.ResolvedAt = time.Now()
+	if err := a.Store.Save(ctx, req); err != nil {
+		return fmt.Errorf("approval store error: %w", err)
+	}
+
+	logger.Info("Approval resolved", "OrderID", orderID, "Status", req.Status, "Reviewer", decision.Reviewer)
+	return nil
+}
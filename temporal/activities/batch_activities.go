@@ -0,0 +1,364 @@
+package activities
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"temporal/models"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+/*
+BATCH FILES:
+
+A batch is a file of orders that the workers can read (a shared volume, or
+the local disk when the client and worker run on one machine):
+
+- JSON: an array of models.Order
+- CSV: one row per order item with the header
+    order_id,customer_id,product_id,quantity,price,street,city,postal_code,country
+  Consecutive rows with the same order_id form one order; its total is the
+  sum of the items
+
+Only a page of orders travels through the workflow history at a time, so a
+batch of tens of thousands of orders stays far below the payload limits.
+Each page carries the byte offset of the next order, so a page is read from
+there and loading a batch reads the file twice in all, not once per page.
+*/
+
+// batchCSVColumns are the required columns of a CSV batch file
+var batchCSVColumns = []string{"order_id", "customer_id", "product_id", "quantity", "price", "street", "city", "postal_code", "country"}
+
+// BatchActivities reads batch files and writes batch reports
+type BatchActivities struct{}
+
+// LoadOrderPage returns up to limit orders of the batch file, starting at offset
+// cursor is the byte offset of that order in the file, the Next of the previous
+// page, so each page is read from there instead of parsing the file from the start.
+// Only the first page (cursor 0) reads the whole file, to count and validate it
+func (a *BatchActivities) LoadOrderPage(ctx context.Context, source string, offset, limit int, cursor int64) (*models.BatchPage, error) {
+	page, err := loadOrderPage(source, offset, limit, cursor)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrInvalidBatchFile) {
+			return nil, temporal.NewNonRetryableApplicationError(err.Error(), "INVALID_BATCH_FILE", err)
+		}
+		return nil, fmt.Errorf("read batch file: %w", err)
+	}
+	activity.GetLogger(ctx).Info("Loaded batch page", "Source", source, "Offset", offset, "Orders", len(page.Orders), "Total", page.Total)
+	return page, nil
+}
+
+func loadOrderPage(source string, offset, limit int, cursor int64) (*models.BatchPage, error) {
+	scanner, err := openOrders(source, cursor)
+	if err != nil {
+		return nil, err
+	}
+	defer scanner.Close()
+
+	// A batch that continued as new before pages carried a cursor skips the
+	// orders it already processed
+	skip := 0
+	if cursor == 0 {
+		skip = offset
+	}
+	counting := cursor == 0
+
+	page := &models.BatchPage{Next: cursor}
+	n := 0
+	for ; ; n++ {
+		order, err := scanner.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n < skip {
+			continue
+		}
+		if len(page.Orders) < limit {
+			page.Orders = append(page.Orders, order)
+			page.Next = scanner.Pos()
+		} else if !counting {
+			break
+		}
+	}
+	if counting {
+		page.Total = n
+	}
+	return page, nil
+}
+
+// AppendBatchResults appends per-order results to the CSV report of a batch
+// A retried attempt may append a page twice; readers keep the last row per order
+func (a *BatchActivities) AppendBatchResults(ctx context.Context, reportPath string, results []models.BatchOrderResult) error {
+	f, err := os.OpenFile(reportPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open batch report: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat batch report: %w", err)
+	}
+
+	w := csv.NewWriter(f)
+	if info.Size() == 0 {
+		w.Write([]string{"order_id", "workflow_id", "status", "error"})
+	}
+	for _, r := range results {
+		w.Write([]string{r.OrderID, r.WorkflowID, r.Status, r.Error})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write batch report: %w", err)
+	}
+
+	activity.GetLogger(ctx).Info("Batch results written", "Report", reportPath, "Results", len(results))
+	return nil
+}
+
+// ErrInvalidBatchFile is returned for batch files that cannot be parsed
+var ErrInvalidBatchFile = errors.New("invalid batch file")
+
+// ReadOrders parses a CSV or JSON batch file, chosen by its extension
+func ReadOrders(path string) ([]models.Order, error) {
+	scanner, err := openOrders(path, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer scanner.Close()
+
+	var orders []models.Order
+	for {
+		order, err := scanner.Next()
+		if err == io.EOF {
+			return orders, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+}
+
+// orderScanner reads the orders of a batch file one at a time
+type orderScanner interface {
+	// Next returns the next order, or io.EOF after the last one
+	Next() (models.Order, error)
+	// Pos is the byte offset right after the last order returned; openOrders resumes there
+	Pos() int64
+	Close() error
+}
+
+// openOrders opens a batch file at the byte offset cursor, 0 for its first order
+func openOrders(path string, cursor int64) (orderScanner, error) {
+	var open func(*os.File, int64) (orderScanner, error)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		open = openJSONOrders
+	case ".csv":
+		open = openCSVOrders
+	default:
+		return nil, fmt.Errorf("%w: %s is neither .csv nor .json", ErrInvalidBatchFile, path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	scanner, err := open(f, cursor)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return scanner, nil
+}
+
+// jsonOrders decodes the elements of a JSON array of orders one at a time
+type jsonOrders struct {
+	*os.File
+	dec  *json.Decoder
+	base int64 // file offset of the decoder's first byte
+	done bool
+}
+
+func openJSONOrders(f *os.File, cursor int64) (orderScanner, error) {
+	if cursor == 0 {
+		dec := json.NewDecoder(f)
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+			return nil, fmt.Errorf("%w: not a JSON array of orders", ErrInvalidBatchFile)
+		}
+		return &jsonOrders{File: f, dec: dec}, nil
+	}
+
+	// The cursor is right after an order: step over the comma and decode the
+	// rest of the file as an array that starts there
+	if _, err := f.Seek(cursor, io.SeekStart); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(f)
+	for pos := cursor; ; pos++ {
+		c, err := r.ReadByte()
+		switch {
+		case err != nil:
+			return nil, fmt.Errorf("%w: unexpected end of the JSON array", ErrInvalidBatchFile)
+		case c == ']':
+			return &jsonOrders{File: f, done: true}, nil
+		case c == ',':
+			dec := json.NewDecoder(io.MultiReader(strings.NewReader("["), r))
+			dec.Token()
+			return &jsonOrders{File: f, dec: dec, base: pos}, nil
+		case !strings.ContainsRune(" \t\r\n", rune(c)):
+			return nil, fmt.Errorf("%w: unexpected %q at byte %d", ErrInvalidBatchFile, c, pos)
+		}
+	}
+}
+
+func (s *jsonOrders) Next() (models.Order, error) {
+	if s.done {
+		return models.Order{}, io.EOF
+	}
+	if !s.dec.More() {
+		s.done = true
+		if tok, err := s.dec.Token(); err != nil || tok != json.Delim(']') {
+			return models.Order{}, fmt.Errorf("%w: unexpected end of the JSON array", ErrInvalidBatchFile)
+		}
+		return models.Order{}, io.EOF
+	}
+
+	var order models.Order
+	if err := s.dec.Decode(&order); err != nil {
+		return order, fmt.Errorf("%w: %v", ErrInvalidBatchFile, err)
+	}
+	if order.OrderID == "" {
+		return order, fmt.Errorf("%w: order ending at byte %d has no OrderID", ErrInvalidBatchFile, s.Pos())
+	}
+	return order, nil
+}
+
+func (s *jsonOrders) Pos() int64 {
+	return s.base + s.dec.InputOffset()
+}
+
+// csvOrders reads the rows of a CSV batch file and joins the consecutive rows
+// of each order
+type csvOrders struct {
+	*os.File
+	r    *csv.Reader
+	col  map[string]int
+	base int64 // file offset of the reader's first byte
+
+	next []string        // first row of the next order, already read
+	pos  int64           // file offset of next
+	seen map[string]bool // orders read so far
+}
+
+func openCSVOrders(f *os.File, cursor int64) (orderScanner, error) {
+	s := &csvOrders{File: f, r: newCSVReader(f), seen: make(map[string]bool)}
+	header, err := s.r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header: %v", ErrInvalidBatchFile, err)
+	}
+	s.col = make(map[string]int, len(header))
+	for i, name := range header {
+		s.col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range batchCSVColumns {
+		if _, ok := s.col[name]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidBatchFile, name)
+		}
+	}
+
+	if cursor > 0 {
+		if _, err := f.Seek(cursor, io.SeekStart); err != nil {
+			return nil, err
+		}
+		s.r, s.base = newCSVReader(f), cursor
+	}
+	return s, s.read()
+}
+
+func newCSVReader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	return cr
+}
+
+// read reads the row after the current one into next
+func (s *csvOrders) read() error {
+	s.pos = s.base + s.r.InputOffset()
+	record, err := s.r.Read()
+	switch {
+	case err == io.EOF:
+		s.next = nil
+		return nil
+	case err != nil:
+		return fmt.Errorf("%w: %v", ErrInvalidBatchFile, err)
+	}
+	s.next = record
+	return nil
+}
+
+func (s *csvOrders) Next() (models.Order, error) {
+	if s.next == nil {
+		return models.Order{}, io.EOF
+	}
+	field := func(name string) string { return strings.TrimSpace(s.next[s.col[name]]) }
+
+	order := models.Order{
+		OrderID:    field("order_id"),
+		CustomerID: field("customer_id"),
+		Status:     "pending",
+		ShippingAddress: models.ShippingAddress{
+			Street:     field("street"),
+			City:       field("city"),
+			PostalCode: field("postal_code"),
+			Country:    field("country"),
+		},
+	}
+	if order.OrderID == "" {
+		line, _ := s.r.FieldPos(0)
+		return order, fmt.Errorf("%w: line %d: missing order_id", ErrInvalidBatchFile, line)
+	}
+	// Pages end between two orders, so the rows of an order must be consecutive
+	if s.seen[order.OrderID] {
+		line, _ := s.r.FieldPos(0)
+		return order, fmt.Errorf("%w: line %d: the rows of order %s are not consecutive", ErrInvalidBatchFile, line, order.OrderID)
+	}
+	s.seen[order.OrderID] = true
+
+	for s.next != nil && field("order_id") == order.OrderID {
+		line, _ := s.r.FieldPos(0)
+		quantity, err := strconv.Atoi(field("quantity"))
+		if err != nil {
+			return order, fmt.Errorf("%w: line %d: invalid quantity %q", ErrInvalidBatchFile, line, field("quantity"))
+		}
+		price, err := strconv.ParseFloat(field("price"), 64)
+		if err != nil {
+			return order, fmt.Errorf("%w: line %d: invalid price %q", ErrInvalidBatchFile, line, field("price"))
+		}
+		order.Items = append(order.Items, models.OrderItem{ProductID: field("product_id"), Quantity: quantity, Price: price})
+		order.TotalAmount += float64(quantity) * price
+
+		if err := s.read(); err != nil {
+			return order, err
+		}
+	}
+	return order, nil
+}
+
+func (s *csvOrders) Pos() int64 {
+	return s.pos
+}
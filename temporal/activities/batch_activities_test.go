@@ -0,0 +1,138 @@
+package activities
+
+import (
+	"os"
+	"path/filepath"
+	"temporal/models"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func writeBatchFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestReadOrders_CSVGroupsItems(t *testing.T) {
+	path := writeBatchFile(t, "orders.csv", `order_id,customer_id,product_id,quantity,price,street,city,postal_code,country
+o-1,c-1,prod-001,2,10,1 Main St,Springfield,12345,US
+o-1,c-1,prod-002,1,5.5,1 Main St,Springfield,12345,US
+o-2,c-2,prod-001,1,10,2 Oak Ave,Shelbyville,12346,US
+`)
+
+	orders, err := ReadOrders(path)
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	require.Equal(t, "o-1", orders[0].OrderID)
+	require.Len(t, orders[0].Items, 2)
+	require.InDelta(t, 25.5, orders[0].TotalAmount, 0.001)
+	require.Equal(t, "Springfield", orders[0].ShippingAddress.City)
+	require.Equal(t, "o-2", orders[1].OrderID)
+}
+
+func TestReadOrders_JSON(t *testing.T) {
+	path := writeBatchFile(t, "orders.json", `[{"OrderID":"o-1","CustomerID":"c-1","TotalAmount":10}]`)
+
+	orders, err := ReadOrders(path)
+	require.NoError(t, err)
+	require.Equal(t, []models.Order{{OrderID: "o-1", CustomerID: "c-1", TotalAmount: 10}}, orders)
+}
+
+func TestReadOrders_Invalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"missing_column.csv": "order_id,customer_id\no-1,c-1\n",
+		"bad_quantity.csv":   "order_id,customer_id,product_id,quantity,price,street,city,postal_code,country\no-1,c-1,p,two,1,s,c,p,US\n",
+		"split_order.csv":    "order_id,customer_id,product_id,quantity,price,street,city,postal_code,country\no-1,c-1,p,1,1,s,c,p,US\no-2,c-1,p,1,1,s,c,p,US\no-1,c-1,p,1,1,s,c,p,US\n",
+		"no_id.json":         `[{"CustomerID":"c-1"}]`,
+		"orders.txt":         "o-1",
+	} {
+		_, err := ReadOrders(writeBatchFile(t, name, content))
+		require.ErrorIs(t, err, ErrInvalidBatchFile, name)
+	}
+}
+
+func TestLoadOrderPage(t *testing.T) {
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestActivityEnvironment()
+	env.RegisterActivity(&BatchActivities{})
+	path := writeBatchFile(t, "orders.json", `[{"OrderID":"o-1"},{"OrderID":"o-2"},{"OrderID":"o-3"}]`)
+
+	value, err := env.ExecuteActivity("LoadOrderPage", path, 2, 2)
+	require.NoError(t, err)
+	var page models.BatchPage
+	require.NoError(t, value.Get(&page))
+	require.Equal(t, 3, page.Total)
+	require.Equal(t, []models.Order{{OrderID: "o-3"}}, page.Orders)
+
+	_, err = env.ExecuteActivity("LoadOrderPage", filepath.Join(t.TempDir(), "missing.csv"), 0, 2)
+	require.ErrorContains(t, err, "INVALID_BATCH_FILE")
+}
+
+func TestLoadOrderPage_FollowsCursor(t *testing.T) {
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestActivityEnvironment()
+	env.RegisterActivity(&BatchActivities{})
+
+	files := map[string]string{
+		"orders.json": `[
+  {"OrderID": "o-1"}, {"OrderID": "o-2"},
+  {"OrderID": "o-3"} ,{"OrderID": "o-4"},
+  {"OrderID": "o-5"}
+]`,
+		"orders.csv": `order_id,customer_id,product_id,quantity,price,street,city,postal_code,country
+o-1,c-1,prod-001,1,10,s,c,p,US
+o-2,c-1,prod-001,1,10,s,c,p,US
+o-2,c-1,prod-002,1,10,s,c,p,US
+o-3,c-1,prod-001,1,10,s,c,p,US
+o-4,c-1,prod-001,1,10,s,c,p,US
+o-5,c-1,prod-001,1,10,s,c,p,US
+o-5,c-1,prod-002,1,10,s,c,p,US
+`,
+	}
+	for name, content := range files {
+		path := writeBatchFile(t, name, content)
+
+		var ids []string
+		var cursor int64
+		for offset := 0; ; {
+			value, err := env.ExecuteActivity("LoadOrderPage", path, offset, 2, cursor)
+			require.NoError(t, err, name)
+			var page models.BatchPage
+			require.NoError(t, value.Get(&page))
+			if offset == 0 {
+				require.Equal(t, 5, page.Total, name)
+			} else {
+				require.Zero(t, page.Total, "%s: only the first page counts the file", name)
+			}
+			if len(page.Orders) == 0 {
+				break
+			}
+			for _, order := range page.Orders {
+				ids = append(ids, order.OrderID)
+			}
+			offset += len(page.Orders)
+			cursor = page.Next
+		}
+		require.Equal(t, []string{"o-1", "o-2", "o-3", "o-4", "o-5"}, ids, name)
+	}
+}
+
+func TestAppendBatchResults(t *testing.T) {
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestActivityEnvironment()
+	env.RegisterActivity(&BatchActivities{})
+	path := filepath.Join(t.TempDir(), "report.csv")
+
+	for _, id := range []string{"o-1", "o-2"} {
+		_, err := env.ExecuteActivity("AppendBatchResults", path, []models.BatchOrderResult{{OrderID: id, Status: "completed"}})
+		require.NoError(t, err)
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "order_id,workflow_id,status,error\no-1,,completed,\no-2,,completed,\n", string(data))
+}
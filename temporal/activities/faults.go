@@ -0,0 +1,130 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+/*
+FAULT INJECTION:
+
+Activities call FaultModel.Inject before doing their work. The model decides,
+per activity type, whether the attempt is slowed down or fails:
+- Rate: random transient failures (the demo default, e.g. 20% for ValidateOrder)
+- FailAttempts: the first N attempts fail, deterministic for tests
+- NonRetryable: the failure is permanent and skips the retry policy
+
+A nil *FaultModel injects nothing, so tests get deterministic activities by default.
+*/
+
+// Fault describes the failures injected into one activity type
+type Fault struct {
+	Rate         float64       // probability that an attempt fails
+	FailAttempts int32         // the first N attempts always fail
+	Latency      time.Duration // simulated processing time of every attempt
+	NonRetryable bool
+	Type         string // error type of non-retryable failures, defaults to "INJECTED_FAULT"
+	Message      string
+}
+
+// FaultModel holds the faults of all activity types
+type FaultModel struct {
+	mu     sync.Mutex
+	faults map[string]Fault
+	rnd    *rand.Rand
+}
+
+func NewFaultModel(seed int64) *FaultModel {
+	return &FaultModel{
+		faults: make(map[string]Fault),
+		rnd:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Set configures the fault of an activity type, replacing any previous one
+func (m *FaultModel) Set(activityType string, f Fault) *FaultModel {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.faults[activityType] = f
+	return m
+}
+
+// Inject applies the fault configured for the running activity
+func (m *FaultModel) Inject(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	info := activity.GetInfo(ctx)
+
+	m.mu.Lock()
+	f, ok := m.faults[info.ActivityType.Name]
+	fail := ok && (info.Attempt <= f.FailAttempts || (f.Rate > 0 && m.rnd.Float64() < f.Rate))
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if f.Latency > 0 {
+		if err := Sleep(ctx, f.Latency, nil); err != nil {
+			return err
+		}
+	}
+	if !fail {
+		return nil
+	}
+
+	msg := f.Message
+	if msg == "" {
+		msg = fmt.Sprintf("injected fault in %s (attempt %d)", info.ActivityType.Name, info.Attempt)
+	}
+	activity.GetLogger(ctx).Warn("Injecting fault", "Activity", info.ActivityType.Name, "Attempt", info.Attempt)
+	if f.NonRetryable {
+		errType := f.Type
+		if errType == "" {
+			errType = "INJECTED_FAULT"
+		}
+		return temporal.NewNonRetryableApplicationError(msg, errType, nil)
+	}
+	return fmt.Errorf("%s: transient error", msg)
+}
+
+// DemoFaults is the behavior the sample worker runs with: a few random
+// transient failures and some latency, so retries show up in the Web UI
+const DemoFaults = "ValidateOrder=0.2/500ms,SendNotification=0.1/200ms"
+
+// ParseFaults parses a comma-separated list of activity=rate[/latency],
+// e.g. "ValidateOrder=0.2/500ms,SendNotification=0.1"
+func ParseFaults(spec string, seed int64) (*FaultModel, error) {
+	m := NewFaultModel(seed)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid fault %q: expected activity=rate[/latency]", entry)
+		}
+		rateStr, latencyStr, hasLatency := strings.Cut(value, "/")
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid fault rate %q for %s", rateStr, name)
+		}
+		f := Fault{Rate: rate}
+		if hasLatency {
+			if f.Latency, err = time.ParseDuration(latencyStr); err != nil {
+				return nil, fmt.Errorf("invalid fault latency %q for %s", latencyStr, name)
+			}
+		}
+		m.Set(strings.TrimSpace(name), f)
+	}
+	return m, nil
+}
@@ -0,0 +1,24 @@
+package activities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFaults(t *testing.T) {
+	m, err := ParseFaults(DemoFaults, 1)
+	require.NoError(t, err)
+	require.Equal(t, Fault{Rate: 0.2, Latency: 500 * time.Millisecond}, m.faults["ValidateOrder"])
+	require.Equal(t, Fault{Rate: 0.1, Latency: 200 * time.Millisecond}, m.faults["SendNotification"])
+
+	m, err = ParseFaults("", 1)
+	require.NoError(t, err)
+	require.Empty(t, m.faults)
+
+	for _, spec := range []string{"ValidateOrder", "ValidateOrder=2", "ValidateOrder=0.1/soon"} {
+		_, err := ParseFaults(spec, 1)
+		require.Error(t, err, spec)
+	}
+}
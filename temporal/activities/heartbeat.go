@@ -0,0 +1,94 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+/*
+LONG-RUNNING ACTIVITIES:
+
+An activity that runs for minutes (a bulk sync, a large export) must:
+
+1. Heartbeat
+   - The workflow sets HeartbeatTimeout; an attempt that stops heartbeating
+     (crashed worker, hung call) times out and is retried long before its
+     StartToCloseTimeout
+   - Cancellation only reaches an activity through heartbeats: once the workflow
+     cancels it, the next heartbeat closes ctx.Done()
+
+2. Record its progress in the heartbeat details
+   - The next attempt reads them with LastHeartbeat and resumes where the last
+     heartbeat that reached the server left off
+   - Work after that heartbeat is done again, so every unit of work must be idempotent
+
+3. Stop promptly
+   - Heartbeat returns ctx.Err() when the activity is cancelled or timed out,
+     and ErrWorkerStopping when its worker shuts down, so the retry resumes on
+     another worker instead of waiting out the shutdown timeout
+   - Never time.Sleep in an activity; Sleep waits, heartbeats and stops early
+*/
+
+// ErrWorkerStopping is returned by Heartbeat when the worker shuts down
+// It is retryable: the next attempt resumes from the last heartbeat on another worker
+var ErrWorkerStopping = errors.New("worker is stopping")
+
+// LastHeartbeat returns the details recorded by the last heartbeat of a previous attempt
+func LastHeartbeat[T any](ctx context.Context) (T, bool) {
+	var details T
+	if !activity.HasHeartbeatDetails(ctx) {
+		return details, false
+	}
+	if err := activity.GetHeartbeatDetails(ctx, &details); err != nil {
+		activity.GetLogger(ctx).Warn("Ignoring unreadable heartbeat details", "Error", err)
+		var zero T
+		return zero, false
+	}
+	return details, true
+}
+
+// Heartbeat records progress and reports whether the activity must stop
+// The SDK throttles the calls to the server, so calling it after every item is fine
+func Heartbeat(ctx context.Context, details interface{}) error {
+	activity.RecordHeartbeat(ctx, details)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-activity.GetWorkerStopChannel(ctx):
+		return ErrWorkerStopping
+	default:
+		return nil
+	}
+}
+
+// Sleep waits for d, heartbeating details meanwhile
+// It returns early with ctx.Err() when the activity is cancelled or times out
+func Sleep(ctx context.Context, d time.Duration, details interface{}) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	ticker := time.NewTicker(heartbeatInterval(ctx))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			return nil
+		case <-ticker.C:
+			activity.RecordHeartbeat(ctx, details)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// heartbeatInterval heartbeats well within the activity's HeartbeatTimeout
+func heartbeatInterval(ctx context.Context) time.Duration {
+	timeout := activity.GetInfo(ctx).HeartbeatTimeout
+	if timeout <= 0 {
+		return 5 * time.Second
+	}
+	return max(timeout/3, 100*time.Millisecond)
+}
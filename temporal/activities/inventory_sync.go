@@ -0,0 +1,140 @@
+package activities
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"temporal/inventory"
+	"temporal/models"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+const defaultSyncBatchSize = 100
+
+// StockCount is one row of a stock count file
+type StockCount struct {
+	ProductID string
+	OnHand    int
+}
+
+// InventorySyncActivities bring the inventory in line with a warehouse stock count
+type InventorySyncActivities struct {
+	Inventory inventory.Store
+	Faults    *FaultModel
+}
+
+// SyncInventory sets the stock of every product of a count file
+// It is the reference long-running activity: it heartbeats its progress after
+// every batch, resumes from the last heartbeat when retried, and stops as soon
+// as it is cancelled. SetOnHand is idempotent, so rows applied again after a
+// crash do no harm
+func (a *InventorySyncActivities) SyncInventory(ctx context.Context, req models.InventorySyncRequest) (*models.InventorySyncResult, error) {
+	logger := activity.GetLogger(ctx)
+	batchSize := req.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultSyncBatchSize
+	}
+
+	counts, err := ReadStockCounts(req.Source)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrInvalidStockFile) {
+			return nil, temporal.NewNonRetryableApplicationError(err.Error(), "INVALID_STOCK_FILE", err)
+		}
+		return nil, err
+	}
+
+	// Resume where the last heartbeat of a previous attempt left off
+	progress, resumed := LastHeartbeat[models.InventorySyncProgress](ctx)
+	result := &models.InventorySyncResult{Source: req.Source, Total: len(counts), Attempts: activity.GetInfo(ctx).Attempt}
+	if resumed {
+		result.ResumedFrom = progress.Processed
+		logger.Info("Resuming inventory sync", "Processed", progress.Processed, "Total", len(counts))
+	} else {
+		logger.Info("Starting inventory sync", "Source", req.Source, "Total", len(counts))
+	}
+
+	for progress.Processed < len(counts) {
+		end := min(progress.Processed+batchSize, len(counts))
+		for _, count := range counts[progress.Processed:end] {
+			before, err := a.Inventory.Stock(ctx, count.ProductID)
+			if err != nil && !errors.Is(err, inventory.ErrUnknownProduct) {
+				return nil, fmt.Errorf("inventory error: %w", err)
+			}
+			if err := a.Inventory.SetOnHand(ctx, count.ProductID, count.OnHand); err != nil {
+				return nil, fmt.Errorf("inventory error: %w", err)
+			}
+			if before.OnHand != count.OnHand {
+				progress.Changed++
+			}
+		}
+		progress.Processed = end
+		if err := Heartbeat(ctx, progress); err != nil {
+			logger.Info("Inventory sync interrupted", "Processed", progress.Processed, "Reason", err)
+			return nil, err
+		}
+
+		// Slow warehouse API, or a transient failure mid-way
+		if err := a.Faults.Inject(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Processed = progress.Processed
+	result.Changed = progress.Changed
+	logger.Info("Inventory sync completed", "Total", result.Total, "Changed", result.Changed)
+	return result, nil
+}
+
+// ErrInvalidStockFile is returned for stock count files that cannot be parsed
+var ErrInvalidStockFile = errors.New("invalid stock count file")
+
+// ReadStockCounts parses a CSV stock count with a product_id,on_hand header
+func ReadStockCounts(path string) ([]StockCount, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header: %v", ErrInvalidStockFile, err)
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"product_id", "on_hand"} {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidStockFile, name)
+		}
+	}
+
+	var counts []StockCount
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidStockFile, err)
+		}
+		line, _ := cr.FieldPos(0)
+		productID := strings.TrimSpace(record[col["product_id"]])
+		onHand, err := strconv.Atoi(strings.TrimSpace(record[col["on_hand"]]))
+		if productID == "" || err != nil || onHand < 0 {
+			return nil, fmt.Errorf("%w: line %d: want a product ID and a non-negative count", ErrInvalidStockFile, line)
+		}
+		counts = append(counts, StockCount{ProductID: productID, OnHand: onHand})
+	}
+	return counts, nil
+}
@@ -0,0 +1,139 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"temporal/inventory"
+	"temporal/models"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func writeStockFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stock.csv")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+const stockFile = `product_id,on_hand
+prod-001,10
+prod-002,5
+prod-003,7
+prod-004,0
+prod-005,3
+`
+
+func newSyncEnv(t *testing.T) (*testsuite.TestActivityEnvironment, *inventory.MemoryStore, *[]models.InventorySyncProgress) {
+	t.Helper()
+	var suite testsuite.WorkflowTestSuite
+	store := inventory.NewMemoryStore()
+	require.NoError(t, store.Restock(context.Background(), "prod-002", 5))
+
+	var heartbeats []models.InventorySyncProgress
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(&InventorySyncActivities{Inventory: store})
+	env.SetOnActivityHeartbeatListener(func(_ *activity.Info, details converter.EncodedValues) {
+		var progress models.InventorySyncProgress
+		require.NoError(t, details.Get(&progress))
+		heartbeats = append(heartbeats, progress)
+	})
+	return env, store, &heartbeats
+}
+
+func syncResult(t *testing.T, env *testsuite.TestActivityEnvironment, req models.InventorySyncRequest) (*models.InventorySyncResult, error) {
+	t.Helper()
+	value, err := env.ExecuteActivity("SyncInventory", req)
+	if err != nil {
+		return nil, err
+	}
+	var result models.InventorySyncResult
+	require.NoError(t, value.Get(&result))
+	return &result, nil
+}
+
+func TestSyncInventory_HeartbeatsEveryBatch(t *testing.T) {
+	env, store, heartbeats := newSyncEnv(t)
+
+	result, err := syncResult(t, env, models.InventorySyncRequest{Source: writeStockFile(t, stockFile), BatchSize: 2})
+
+	require.NoError(t, err)
+	require.Equal(t, 5, result.Processed)
+	require.Equal(t, 3, result.Changed) // prod-002 and prod-004 already match
+	require.Zero(t, result.ResumedFrom)
+	level, err := store.Stock(context.Background(), "prod-003")
+	require.NoError(t, err)
+	require.Equal(t, 7, level.OnHand)
+
+	// The SDK throttles heartbeats; the first one goes out right away
+	require.NotEmpty(t, *heartbeats)
+	require.Equal(t, models.InventorySyncProgress{Processed: 2, Changed: 1}, (*heartbeats)[0])
+}
+
+func TestSyncInventory_ResumesFromLastHeartbeat(t *testing.T) {
+	env, store, _ := newSyncEnv(t)
+	env.SetHeartbeatDetails(models.InventorySyncProgress{Processed: 2, Changed: 1})
+
+	result, err := syncResult(t, env, models.InventorySyncRequest{Source: writeStockFile(t, stockFile), BatchSize: 2})
+
+	require.NoError(t, err)
+	require.Equal(t, 2, result.ResumedFrom)
+	require.Equal(t, 5, result.Processed)
+	// prod-001 was applied by the previous attempt, which the fresh store doesn't know
+	_, err = store.Stock(context.Background(), "prod-001")
+	require.ErrorIs(t, err, inventory.ErrUnknownProduct)
+	level, err := store.Stock(context.Background(), "prod-005")
+	require.NoError(t, err)
+	require.Equal(t, 3, level.OnHand)
+}
+
+func TestSyncInventory_StopsWhenWorkerStops(t *testing.T) {
+	env, _, heartbeats := newSyncEnv(t)
+	stop := make(chan struct{})
+	close(stop)
+	env.SetWorkerStopChannel(stop)
+
+	_, err := syncResult(t, env, models.InventorySyncRequest{Source: writeStockFile(t, stockFile), BatchSize: 2})
+
+	require.ErrorContains(t, err, ErrWorkerStopping.Error())
+	require.Len(t, *heartbeats, 1)
+	require.Equal(t, 2, (*heartbeats)[0].Processed)
+}
+
+func TestSyncInventory_InvalidFile(t *testing.T) {
+	env, _, _ := newSyncEnv(t)
+
+	_, err := syncResult(t, env, models.InventorySyncRequest{Source: writeStockFile(t, "product_id,on_hand\nprod-001,-1\n")})
+
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(err, &appErr))
+	require.Equal(t, "INVALID_STOCK_FILE", appErr.Type())
+}
+
+// sleepForAnHour is cancelled after 50ms, like an activity whose workflow cancelled it
+func sleepForAnHour(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	return Sleep(ctx, time.Hour, nil)
+}
+
+func TestSleep_StopsOnCancellation(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(sleepForAnHour)
+
+	start := time.Now()
+	_, err := env.ExecuteActivity(sleepForAnHour)
+
+	require.ErrorContains(t, err, context.DeadlineExceeded.Error())
+	require.Less(t, time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,196 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+	"temporal/models"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// DeadLetterStore keeps the notifications no channel could deliver, for follow-up by support
+type DeadLetterStore interface {
+	Save(ctx context.Context, letter models.NotificationDeadLetter) error
+}
+
+// MemoryDeadLetterStore is an in-process DeadLetterStore for local runs and tests
+type MemoryDeadLetterStore struct {
+	mu      sync.Mutex
+	letters map[string]models.NotificationDeadLetter // by workflow ID
+}
+
+func NewMemoryDeadLetterStore() *MemoryDeadLetterStore {
+	return &MemoryDeadLetterStore{letters: make(map[string]models.NotificationDeadLetter)}
+}
+
+func (s *MemoryDeadLetterStore) Save(_ context.Context, letter models.NotificationDeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.letters[letter.WorkflowID] = letter
+	return nil
+}
+
+// List returns the recorded dead letters
+func (s *MemoryDeadLetterStore) List() []models.NotificationDeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	letters := make([]models.NotificationDeadLetter, 0, len(s.letters))
+	for _, letter := range s.letters {
+		letters = append(letters, letter)
+	}
+	return letters
+}
+
+// FileDeadLetterStore appends dead letters to a file as JSON lines
+// A retried save can write a line twice; readers deduplicate by WorkflowID
+type FileDeadLetterStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (s *FileDeadLetterStore) Save(_ context.Context, letter models.NotificationDeadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// RecipientDirectory looks up where customers receive notifications
+type RecipientDirectory interface {
+	// Recipients returns the customer's address per channel: email address,
+	// phone number or webhook URL; an unknown customer has none
+	Recipients(ctx context.Context, customerID string) (map[string]string, error)
+}
+
+// MemoryRecipientDirectory is an in-process RecipientDirectory for local runs and tests
+type MemoryRecipientDirectory struct {
+	mu         sync.Mutex
+	recipients map[string]map[string]string // by customer ID
+}
+
+func NewMemoryRecipientDirectory() *MemoryRecipientDirectory {
+	return &MemoryRecipientDirectory{recipients: make(map[string]map[string]string)}
+}
+
+// Set replaces the addresses of a customer
+func (d *MemoryRecipientDirectory) Set(customerID string, recipients map[string]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.recipients[customerID] = maps.Clone(recipients)
+}
+
+func (d *MemoryRecipientDirectory) Recipients(_ context.Context, customerID string) (map[string]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return maps.Clone(d.recipients[customerID]), nil
+}
+
+// FileRecipientDirectory reads the addresses from a JSON file on every lookup:
+// {"<customer ID>": {"email": "...", "sms": "...", "webhook": "..."}}
+type FileRecipientDirectory struct {
+	Path string
+}
+
+func (d *FileRecipientDirectory) Recipients(_ context.Context, customerID string) (map[string]string, error) {
+	data, err := os.ReadFile(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	var recipients map[string]map[string]string
+	if err := json.Unmarshal(data, &recipients); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", d.Path, err)
+	}
+	return recipients[customerID], nil
+}
+
+// NotificationActivities deliver notifications through pluggable channels
+// The worker registers them once per channel task queue, so the rate limit of
+// each queue applies to one channel only
+type NotificationActivities struct {
+	Channels    map[string]NotificationChannel
+	DeadLetters DeadLetterStore
+	Recipients  RecipientDirectory
+}
+
+// ResolveRecipients returns the addresses of a customer, channel => address,
+// for the NotificationWorkflow of one of their orders
+func (a *NotificationActivities) ResolveRecipients(ctx context.Context, customerID string) (map[string]string, error) {
+	if a.Recipients == nil {
+		return nil, nil
+	}
+	recipients, err := a.Recipients.Recipients(ctx, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("recipient directory error: %w", err)
+	}
+	if len(recipients) == 0 {
+		activity.GetLogger(ctx).Warn("Customer has no notification recipients", "CustomerID", customerID)
+	}
+	return recipients, nil
+}
+
+// DeliverNotification sends one notification through one channel
+// Permanent refusals are non-retryable (NOTIFICATION_REJECTED), so the workflow
+// falls back to the next channel right away; throttling is retried after the
+// provider's Retry-After hint
+func (a *NotificationActivities) DeliverNotification(ctx context.Context, msg models.NotificationMessage) error {
+	logger := activity.GetLogger(ctx)
+
+	channel, ok := a.Channels[msg.Channel]
+	if !ok {
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("notification channel %q is not configured", msg.Channel), "NOTIFICATION_CHANNEL_UNKNOWN", nil)
+	}
+
+	logger.Info("Delivering notification", "OrderID", msg.Notification.OrderID, "Channel", msg.Channel, "Attempt", activity.GetInfo(ctx).Attempt)
+	err := channel.Send(ctx, IdempotencyKey(ctx), msg.Recipient, msg.Notification)
+
+	var rejected *RejectedError
+	var limited *RateLimitedError
+	switch {
+	case err == nil:
+		logger.Info("Notification delivered", "OrderID", msg.Notification.OrderID, "Channel", msg.Channel)
+		return nil
+	case errors.As(err, &rejected):
+		return temporal.NewNonRetryableApplicationError(rejected.Error(), "NOTIFICATION_REJECTED", err)
+	case errors.As(err, &limited):
+		logger.Warn("Notification channel rate limited", "Channel", msg.Channel, "RetryAfter", limited.RetryAfter)
+		return temporal.NewApplicationErrorWithOptions(limited.Error(), "NOTIFICATION_RATE_LIMITED", temporal.ApplicationErrorOptions{
+			NextRetryDelay: limited.RetryAfter,
+			Cause:          err,
+		})
+	default:
+		logger.Warn("Notification delivery failed", "Channel", msg.Channel, "Error", err)
+		return err
+	}
+}
+
+// RecordDeadLetter stores a notification that every channel failed to deliver
+func (a *NotificationActivities) RecordDeadLetter(ctx context.Context, letter models.NotificationDeadLetter) error {
+	if err := a.DeadLetters.Save(ctx, letter); err != nil {
+		return fmt.Errorf("dead letter store error: %w", err)
+	}
+	activity.GetLogger(ctx).Error("Notification dead-lettered",
+		"OrderID", letter.Notification.OrderID,
+		"Type", letter.Notification.MessageType,
+		"Attempts", len(letter.Attempts))
+	return nil
+}
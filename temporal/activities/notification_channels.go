@@ -0,0 +1,231 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"temporal/models"
+	"time"
+)
+
+// Notification channel names
+const (
+	ChannelEmail   = "email"
+	ChannelSMS     = "sms"
+	ChannelWebhook = "webhook"
+)
+
+// NotificationChannel delivers notifications through one medium
+//
+// Every call carries an idempotency key shared by all attempts of a delivery.
+// Channels that can deduplicate (webhooks, most SMS and email APIs) should pass
+// it on, so a retry after a lost response does not notify the customer twice
+type NotificationChannel interface {
+	Name() string
+	Send(ctx context.Context, idempotencyKey string, recipient string, notif models.NotificationRequest) error
+}
+
+// ErrChannelUnavailable is a transient channel failure; the delivery is retried
+var ErrChannelUnavailable = errors.New("notification channel unavailable")
+
+// RateLimitedError is returned when the provider throttles us
+// RetryAfter is the provider's hint, zero if it gave none
+type RateLimitedError struct {
+	Channel    string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("%s rate limited (retry after %s)", e.Channel, e.RetryAfter)
+}
+
+// RejectedError is a permanent refusal, e.g. an invalid address
+// Retrying the same channel won't help; the workflow falls back to the next one
+type RejectedError struct {
+	Channel string
+	Reason  string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("%s rejected the notification: %s", e.Channel, e.Reason)
+}
+
+// SMTPChannel sends notifications as plain text email
+type SMTPChannel struct {
+	Addr string // host:port of the SMTP server
+	From string
+	Auth smtp.Auth
+}
+
+func (c *SMTPChannel) Name() string {
+	return ChannelEmail
+}
+
+func (c *SMTPChannel) Send(_ context.Context, idempotencyKey string, recipient string, notif models.NotificationRequest) error {
+	if recipient == "" || !strings.Contains(recipient, "@") {
+		return &RejectedError{Channel: c.Name(), Reason: fmt.Sprintf("invalid email address %q", recipient)}
+	}
+
+	// The Message-ID lets mail clients collapse the duplicates of a retried delivery
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(&msg, "Subject: Order %s: %s\r\n", notif.OrderID, strings.ReplaceAll(notif.MessageType, "_", " "))
+	fmt.Fprintf(&msg, "Message-ID: <%s@orders>\r\n", strings.ReplaceAll(idempotencyKey, "/", "."))
+	fmt.Fprintf(&msg, "\r\n%s\r\n", notif.Message)
+
+	if err := smtp.SendMail(c.Addr, c.Auth, c.From, []string{recipient}, msg.Bytes()); err != nil {
+		return fmt.Errorf("%w: %v", ErrChannelUnavailable, err)
+	}
+	return nil
+}
+
+// HTTPChannel posts notifications as JSON to an HTTP endpoint
+// As "webhook" the recipient is the URL to call, unless URL is fixed; as "sms"
+// it is the phone number, posted to the SMS provider at URL
+type HTTPChannel struct {
+	ChannelName      string
+	URL              string // fixed endpoint, or empty to post to the recipient
+	RequireRecipient bool
+	Client           *http.Client
+}
+
+// NewWebhookChannel posts notifications to url, or to the webhook URL of each
+// recipient if url is empty
+func NewWebhookChannel(url string) *HTTPChannel {
+	return &HTTPChannel{ChannelName: ChannelWebhook, URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// NewSMSChannel posts notifications to an SMS provider's HTTP API
+func NewSMSChannel(url string) *HTTPChannel {
+	return &HTTPChannel{ChannelName: ChannelSMS, URL: url, RequireRecipient: true, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type httpNotification struct {
+	To          string `json:"to"`
+	OrderID     string `json:"order_id"`
+	CustomerID  string `json:"customer_id"`
+	MessageType string `json:"message_type"`
+	Message     string `json:"message"`
+}
+
+func (c *HTTPChannel) Name() string {
+	return c.ChannelName
+}
+
+func (c *HTTPChannel) Send(ctx context.Context, idempotencyKey string, recipient string, notif models.NotificationRequest) error {
+	endpoint := c.URL
+	if endpoint == "" {
+		endpoint = recipient
+	}
+	if endpoint == "" || (c.RequireRecipient && recipient == "") {
+		return &RejectedError{Channel: c.Name(), Reason: "no recipient"}
+	}
+
+	data, err := json.Marshal(httpNotification{
+		To:          recipient,
+		OrderID:     notif.OrderID,
+		CustomerID:  notif.CustomerID,
+		MessageType: notif.MessageType,
+		Message:     notif.Message,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return &RejectedError{Channel: c.Name(), Reason: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrChannelUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests:
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &RateLimitedError{Channel: c.Name(), RetryAfter: time.Duration(seconds) * time.Second}
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("%w: %s status %d", ErrChannelUnavailable, c.Name(), resp.StatusCode)
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &RejectedError{Channel: c.Name(), Reason: fmt.Sprintf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))}
+	}
+}
+
+// FakeChannel is an in-memory NotificationChannel for local runs and tests
+// It delivers everything unless told to fail with FailNext or Reject
+type FakeChannel struct {
+	ChannelName string
+
+	mu       sync.Mutex
+	sent     map[string]models.NotificationRequest // by idempotency key
+	order    []string
+	failures int
+	rejected string
+}
+
+func NewFakeChannel(name string) *FakeChannel {
+	return &FakeChannel{ChannelName: name, sent: make(map[string]models.NotificationRequest)}
+}
+
+// FailNext makes the next n sends fail with ErrChannelUnavailable
+func (c *FakeChannel) FailNext(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = n
+}
+
+// Reject makes every send fail permanently with reason; an empty reason accepts again
+func (c *FakeChannel) Reject(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rejected = reason
+}
+
+// Sent returns the delivered notifications in order, duplicates of a key excluded
+func (c *FakeChannel) Sent() []models.NotificationRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sent := make([]models.NotificationRequest, 0, len(c.order))
+	for _, key := range c.order {
+		sent = append(sent, c.sent[key])
+	}
+	return sent
+}
+
+func (c *FakeChannel) Name() string {
+	return c.ChannelName
+}
+
+func (c *FakeChannel) Send(_ context.Context, idempotencyKey string, _ string, notif models.NotificationRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.rejected != "" {
+		return &RejectedError{Channel: c.ChannelName, Reason: c.rejected}
+	}
+	if c.failures > 0 {
+		c.failures--
+		return fmt.Errorf("%w: %s (fake)", ErrChannelUnavailable, c.ChannelName)
+	}
+	if _, ok := c.sent[idempotencyKey]; !ok {
+		c.sent[idempotencyKey] = notif
+		c.order = append(c.order, idempotencyKey)
+	}
+	return nil
+}
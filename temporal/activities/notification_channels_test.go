@@ -0,0 +1,127 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"temporal/models"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func testNotification() models.NotificationRequest {
+	return models.NotificationRequest{OrderID: "order-1", CustomerID: "customer-1", MessageType: "order_confirmation", Message: "confirmed"}
+}
+
+func TestHTTPChannel_Webhook(t *testing.T) {
+	var got httpNotification
+	var key string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key = r.Header.Get("Idempotency-Key")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+
+	err := NewWebhookChannel("").Send(context.Background(), "wf/run/DeliverNotification/1", server.URL, testNotification())
+
+	require.NoError(t, err)
+	require.Equal(t, "wf/run/DeliverNotification/1", key)
+	require.Equal(t, "order-1", got.OrderID)
+	require.Equal(t, server.URL, got.To)
+}
+
+func TestHTTPChannel_Errors(t *testing.T) {
+	status := http.StatusTooManyRequests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	sms := NewSMSChannel(server.URL)
+	send := func() error {
+		return sms.Send(context.Background(), "key", "+15550100", testNotification())
+	}
+
+	var limited *RateLimitedError
+	require.True(t, errors.As(send(), &limited))
+	require.Equal(t, 30*time.Second, limited.RetryAfter)
+
+	status = http.StatusServiceUnavailable
+	require.ErrorIs(t, send(), ErrChannelUnavailable)
+
+	status = http.StatusBadRequest
+	var rejected *RejectedError
+	require.True(t, errors.As(send(), &rejected))
+
+	err := sms.Send(context.Background(), "key", "", testNotification())
+	require.True(t, errors.As(err, &rejected))
+}
+
+func TestSMTPChannel_InvalidAddress(t *testing.T) {
+	channel := &SMTPChannel{Addr: "localhost:0", From: "orders@example.com"}
+
+	err := channel.Send(context.Background(), "key", "not-an-address", testNotification())
+
+	var rejected *RejectedError
+	require.True(t, errors.As(err, &rejected))
+}
+
+func TestFakeChannel_DeduplicatesByKey(t *testing.T) {
+	fake := NewFakeChannel(ChannelEmail)
+	fake.FailNext(1)
+
+	require.ErrorIs(t, fake.Send(context.Background(), "key", "", testNotification()), ErrChannelUnavailable)
+	require.NoError(t, fake.Send(context.Background(), "key", "", testNotification()))
+	require.NoError(t, fake.Send(context.Background(), "key", "", testNotification()))
+	require.Len(t, fake.Sent(), 1)
+}
+
+func TestDeliverNotification_ErrorTypes(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	email := NewFakeChannel(ChannelEmail)
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(&NotificationActivities{Channels: map[string]NotificationChannel{ChannelEmail: email}})
+	deliver := func(channel string) error {
+		_, err := env.ExecuteActivity("DeliverNotification", models.NotificationMessage{Channel: channel, Notification: testNotification()})
+		return err
+	}
+
+	require.NoError(t, deliver(ChannelEmail))
+
+	email.Reject("bounced")
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(deliver(ChannelEmail), &appErr))
+	require.Equal(t, "NOTIFICATION_REJECTED", appErr.Type())
+	require.True(t, appErr.NonRetryable())
+
+	require.True(t, errors.As(deliver("pigeon"), &appErr))
+	require.Equal(t, "NOTIFICATION_CHANNEL_UNKNOWN", appErr.Type())
+}
+
+func TestResolveRecipients_File(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	path := filepath.Join(t.TempDir(), "recipients.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"customer-1": {"email": "jo@example.com", "sms": "+15551234567"}}`), 0o644))
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(&NotificationActivities{Recipients: &FileRecipientDirectory{Path: path}})
+	resolve := func(customerID string) map[string]string {
+		value, err := env.ExecuteActivity("ResolveRecipients", customerID)
+		require.NoError(t, err)
+		var recipients map[string]string
+		require.NoError(t, value.Get(&recipients))
+		return recipients
+	}
+
+	require.Equal(t, map[string]string{ChannelEmail: "jo@example.com", ChannelSMS: "+15551234567"}, resolve("customer-1"))
+	require.Empty(t, resolve("customer-2"))
+}
@@ -2,51 +2,57 @@ package activities
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math/rand"
+	"temporal/inventory"
 	"temporal/models"
 	"time"
 
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 )
 
+// DefaultReservationTTL is how long reserved stock is held before it is released
+const DefaultReservationTTL = 30 * time.Minute
+
 // OrderActivities contains order-related activities
 // Activities are where ALL side effects and I/O operations should happen
 // They are non-deterministic and can be retried independently
-type OrderActivities struct{}
+type OrderActivities struct {
+	Inventory      inventory.Store
+	ReservationTTL time.Duration
+	Faults         *FaultModel
+}
 
 // ValidateOrder checks if the order is valid
 // This activity demonstrates:
 // - Simple validation logic
 // - Activity retry on transient failures
-// - Activity heartbeats for long-running operations
+// - Activity heartbeats while waiting on a slow validation service (FaultModel latency uses Sleep)
 func (a *OrderActivities) ValidateOrder(ctx context.Context, order models.Order) error {
 	logger := activity.GetLogger(ctx)
 	logger.Info("Validating order", "OrderID", order.OrderID)
 
-	// Simulate validation checks
+	// Invalid orders stay invalid: don't retry them
 	if order.CustomerID == "" {
-		return fmt.Errorf("invalid order: customer ID is required")
+		return temporal.NewNonRetryableApplicationError("invalid order: customer ID is required", "INVALID_ORDER", nil)
 	}
 
 	if len(order.Items) == 0 {
-		return fmt.Errorf("invalid order: no items in order")
+		return temporal.NewNonRetryableApplicationError("invalid order: no items in order", "INVALID_ORDER", nil)
 	}
 
 	if order.TotalAmount <= 0 {
-		return fmt.Errorf("invalid order: invalid total amount")
+		return temporal.NewNonRetryableApplicationError("invalid order: invalid total amount", "INVALID_ORDER", nil)
 	}
 
-	// Simulate potential transient failure (network call to validation service)
+	// Potential transient failure (network call to validation service)
 	// This will be retried by the activity retry policy
-	if rand.Float32() < 0.2 { // 20% failure rate for demo
-		logger.Warn("Validation service temporarily unavailable")
-		return fmt.Errorf("validation service unavailable: transient error")
+	if err := a.Faults.Inject(ctx); err != nil {
+		logger.Warn("Validation service temporarily unavailable", "Error", err)
+		return err
 	}
 
-	// Simulate processing time
-	time.Sleep(500 * time.Millisecond)
-
 	logger.Info("Order validated successfully", "OrderID", order.OrderID)
 	return nil
 }
@@ -56,40 +62,65 @@ func (a *OrderActivities) ValidateOrder(ctx context.Context, order models.Order)
 // - Idempotent operations (can be safely retried)
 // - Activity-level retry for transient failures
 // - Using activity info for correlation
+//
+// The reservation ID is derived from the order ID, so a retry after a timeout
+// returns the reservation made by the lost attempt instead of reserving twice
 func (a *OrderActivities) ReserveInventory(ctx context.Context, order models.Order) (*models.InventoryReservation, error) {
 	logger := activity.GetLogger(ctx)
 	activityInfo := activity.GetInfo(ctx)
 
 	logger.Info("Reserving inventory", "OrderID", order.OrderID, "AttemptCount", activityInfo.Attempt)
 
-	// Simulate inventory check
-	for _, item := range order.Items {
-		logger.Info("Checking inventory", "ProductID", item.ProductID, "Quantity", item.Quantity)
+	if err := a.Faults.Inject(ctx); err != nil {
+		return nil, err
+	}
 
-		// Simulate out of stock scenario occasionally
-		if item.ProductID == "prod-unavailable" {
-			return nil, fmt.Errorf("product %s is out of stock", item.ProductID)
-		}
+	ttl := a.ReservationTTL
+	if ttl <= 0 {
+		ttl = DefaultReservationTTL
 	}
 
-	// Simulate transient database failure
-	if rand.Float32() < 0.15 { // 15% failure rate
-		logger.Warn("Database temporarily unavailable during inventory reservation")
-		return nil, fmt.Errorf("database error: connection timeout")
+	reservation, err := a.Inventory.Reserve(ctx, order.OrderID, order.Items, ttl)
+	if err != nil {
+		var stockErr *inventory.InsufficientStockError
+		if errors.As(err, &stockErr) {
+			// Retrying won't bring stock back; let the workflow compensate right away
+			return nil, temporal.NewNonRetryableApplicationError(stockErr.Error(), "OUT_OF_STOCK", err)
+		}
+		// Database errors are transient and retried by the activity retry policy
+		logger.Warn("Inventory service error during reservation", "Error", err)
+		return nil, fmt.Errorf("inventory error: %w", err)
 	}
 
-	// Simulate processing time
-	time.Sleep(300 * time.Millisecond)
+	logger.Info("Inventory reserved successfully", "ReservationID", reservation.ReservationID, "ExpiresAt", reservation.ExpiresAt)
+	return toModelReservation(reservation), nil
+}
+
+// ConfirmInventory commits a reservation once the order is handed to shipping
+// The units leave the stock and the reservation no longer expires
+func (a *OrderActivities) ConfirmInventory(ctx context.Context, reservation *models.InventoryReservation) (*models.InventoryReservation, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Confirming inventory reservation", "ReservationID", reservation.ReservationID)
+
+	if err := a.Faults.Inject(ctx); err != nil {
+		return nil, err
+	}
 
-	reservation := &models.InventoryReservation{
-		ReservationID: fmt.Sprintf("res-%d", time.Now().Unix()),
-		OrderID:       order.OrderID,
-		Items:         order.Items,
-		Status:        "reserved",
+	confirmed, err := a.Inventory.Confirm(ctx, reservation.ReservationID)
+	if err != nil {
+		var stateErr *inventory.StateError
+		if errors.As(err, &stateErr) {
+			// The reservation expired (or was released) while the order was waiting
+			return nil, temporal.NewNonRetryableApplicationError(stateErr.Error(), "RESERVATION_EXPIRED", err)
+		}
+		if errors.Is(err, inventory.ErrReservationNotFound) {
+			return nil, temporal.NewNonRetryableApplicationError(err.Error(), "RESERVATION_NOT_FOUND", err)
+		}
+		return nil, fmt.Errorf("inventory error: %w", err)
 	}
 
-	logger.Info("Inventory reserved successfully", "ReservationID", reservation.ReservationID)
-	return reservation, nil
+	logger.Info("Inventory reservation confirmed", "ReservationID", confirmed.ReservationID)
+	return toModelReservation(confirmed), nil
 }
 
 // SendNotification sends a notification to the customer
@@ -101,15 +132,12 @@ func (a *OrderActivities) SendNotification(ctx context.Context, notif models.Not
 	logger := activity.GetLogger(ctx)
 	logger.Info("Sending notification", "OrderID", notif.OrderID, "Type", notif.MessageType)
 
-	// Simulate notification service call
-	if rand.Float32() < 0.1 { // 10% failure rate
-		logger.Warn("Notification service temporarily unavailable")
-		return fmt.Errorf("notification service error: rate limit exceeded")
+	// Notification service call (email/SMS)
+	if err := a.Faults.Inject(ctx); err != nil {
+		logger.Warn("Notification service temporarily unavailable", "Error", err)
+		return err
 	}
 
-	// Simulate sending email/SMS
-	time.Sleep(200 * time.Millisecond)
-
 	logger.Info("Notification sent successfully",
 		"OrderID", notif.OrderID,
 		"CustomerID", notif.CustomerID,
@@ -120,6 +148,7 @@ func (a *OrderActivities) SendNotification(ctx context.Context, notif models.Not
 
 // CompensateInventory releases reserved inventory (compensation logic)
 // This is called when the workflow fails and we need to rollback
+// Releasing twice is a no-op, and releasing a confirmed reservation puts the units back in stock
 func (a *OrderActivities) CompensateInventory(ctx context.Context, reservation *models.InventoryReservation) error {
 	logger := activity.GetLogger(ctx)
 
@@ -130,9 +159,29 @@ func (a *OrderActivities) CompensateInventory(ctx context.Context, reservation *
 
 	logger.Info("Compensating inventory reservation", "ReservationID", reservation.ReservationID)
 
-	// Simulate releasing inventory
-	time.Sleep(100 * time.Millisecond)
+	if err := a.Faults.Inject(ctx); err != nil {
+		return err
+	}
 
-	logger.Info("Inventory reservation released", "ReservationID", reservation.ReservationID)
+	released, err := a.Inventory.Release(ctx, reservation.ReservationID)
+	if errors.Is(err, inventory.ErrReservationNotFound) {
+		logger.Info("Inventory reservation not found, nothing to release", "ReservationID", reservation.ReservationID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("inventory error: %w", err)
+	}
+
+	logger.Info("Inventory reservation released", "ReservationID", released.ReservationID, "Status", released.Status)
 	return nil
 }
+
+func toModelReservation(r inventory.Reservation) *models.InventoryReservation {
+	return &models.InventoryReservation{
+		ReservationID: r.ReservationID,
+		OrderID:       r.OrderID,
+		Items:         r.Items,
+		Status:        r.Status,
+		ExpiresAt:     r.ExpiresAt,
+	}
+}
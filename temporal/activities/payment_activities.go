@@ -2,24 +2,31 @@ package activities
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math/rand"
 	"temporal/models"
-	"time"
 
 	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/temporal"
 )
 
 // PaymentActivities contains payment-related activities
-type PaymentActivities struct{}
+// The gateway is injected so the same activities run against a sandbox,
+// the local stub server or an in-memory fake
+//
+// Activities never mutate their argument: the workflow only sees what an
+// activity returns, so each one returns the updated payment info
+type PaymentActivities struct {
+	Gateway PaymentGateway
+	Faults  *FaultModel
+}
 
 // AuthorizePayment authorizes the payment (holds the amount)
 // This activity demonstrates:
 // - Critical operation with strict retry policy
 // - Idempotent operation design
 // - Different failure modes
-func (a *PaymentActivities) AuthorizePayment(ctx context.Context, paymentInfo *models.PaymentInfo) error {
+func (a *PaymentActivities) AuthorizePayment(ctx context.Context, paymentInfo *models.PaymentInfo) (*models.PaymentInfo, error) {
 	logger := activity.GetLogger(ctx)
 	activityInfo := activity.GetInfo(ctx)
 
@@ -28,36 +35,30 @@ func (a *PaymentActivities) AuthorizePayment(ctx context.Context, paymentInfo *m
 		"Amount", paymentInfo.Amount,
 		"Attempt", activityInfo.Attempt)
 
-	// Simulate different failure scenarios
-	failureRate := rand.Float32()
-
-	// Permanent failure: insufficient funds (should NOT retry)
-	if paymentInfo.Amount > 10000 && failureRate < 0.05 {
-		logger.Error("Payment authorization failed: insufficient funds")
-		return temporal.NewApplicationError(
-			"insufficient funds",
-			"INSUFFICIENT_FUNDS", // Error type for conditional retry logic
-			nil,
-		)
+	if err := a.Faults.Inject(ctx); err != nil {
+		return nil, err
 	}
 
-	// Transient failure: payment gateway timeout (SHOULD retry)
-	if failureRate < 0.2 { // 20% transient failure rate
-		logger.Warn("Payment gateway timeout")
-		return fmt.Errorf("payment gateway timeout: transient error")
+	result, err := a.Gateway.Authorize(ctx, IdempotencyKey(ctx), AuthorizeRequest{
+		PaymentID:     paymentInfo.PaymentID,
+		OrderID:       paymentInfo.OrderID,
+		Amount:        paymentInfo.Amount,
+		PaymentMethod: paymentInfo.PaymentMethod,
+	})
+	if err != nil {
+		logger.Error("Payment authorization failed", "Error", err)
+		return nil, gatewayError(err)
 	}
 
-	// Simulate payment gateway call
-	time.Sleep(400 * time.Millisecond)
-
-	// Update payment info with authorization ID
-	paymentInfo.AuthorizationID = fmt.Sprintf("auth-%d", time.Now().Unix())
-	paymentInfo.Status = "authorized"
+	// Return payment info with the authorization ID
+	authorized := *paymentInfo
+	authorized.AuthorizationID = result.ID
+	authorized.Status = "authorized"
 
 	logger.Info("Payment authorized successfully",
-		"AuthorizationID", paymentInfo.AuthorizationID)
+		"AuthorizationID", authorized.AuthorizationID)
 
-	return nil
+	return &authorized, nil
 }
 
 // CapturePayment captures the previously authorized payment
@@ -65,7 +66,7 @@ func (a *PaymentActivities) AuthorizePayment(ctx context.Context, paymentInfo *m
 // - Two-phase commit pattern
 // - Dependency on previous activity result
 // - Compensation-aware design
-func (a *PaymentActivities) CapturePayment(ctx context.Context, paymentInfo *models.PaymentInfo) error {
+func (a *PaymentActivities) CapturePayment(ctx context.Context, paymentInfo *models.PaymentInfo) (*models.PaymentInfo, error) {
 	logger := activity.GetLogger(ctx)
 
 	logger.Info("Capturing payment",
@@ -74,70 +75,104 @@ func (a *PaymentActivities) CapturePayment(ctx context.Context, paymentInfo *mod
 
 	// Verify authorization exists
 	if paymentInfo.AuthorizationID == "" {
-		return fmt.Errorf("cannot capture payment: no authorization ID")
+		return nil, temporal.NewNonRetryableApplicationError("cannot capture payment: no authorization ID", "INVALID_PAYMENT_STATE", nil)
 	}
 
-	// Simulate transient failure
-	if rand.Float32() < 0.15 { // 15% failure rate
-		logger.Warn("Payment capture temporarily failed")
-		return fmt.Errorf("payment capture error: gateway unavailable")
+	if err := a.Faults.Inject(ctx); err != nil {
+		return nil, err
 	}
 
-	// Simulate payment capture
-	time.Sleep(300 * time.Millisecond)
+	result, err := a.Gateway.Capture(ctx, IdempotencyKey(ctx), paymentInfo.AuthorizationID, paymentInfo.Amount)
+	if err != nil {
+		logger.Warn("Payment capture failed", "Error", err)
+		return nil, gatewayError(err)
+	}
 
-	// Update payment info
-	paymentInfo.CaptureID = fmt.Sprintf("cap-%d", time.Now().Unix())
-	paymentInfo.Status = "captured"
+	captured := *paymentInfo
+	captured.CaptureID = result.ID
+	captured.Status = "captured"
 
 	logger.Info("Payment captured successfully",
-		"CaptureID", paymentInfo.CaptureID)
+		"CaptureID", captured.CaptureID)
 
-	return nil
+	return &captured, nil
 }
 
 // RefundPayment refunds a captured payment (compensation logic)
 // This is called when we need to rollback a successful payment
-func (a *PaymentActivities) RefundPayment(ctx context.Context, paymentInfo *models.PaymentInfo) error {
+func (a *PaymentActivities) RefundPayment(ctx context.Context, paymentInfo *models.PaymentInfo) (*models.PaymentInfo, error) {
 	logger := activity.GetLogger(ctx)
 
 	if paymentInfo.CaptureID == "" {
 		logger.Info("No captured payment to refund")
-		return nil
+		return paymentInfo, nil
 	}
 
 	logger.Info("Refunding payment",
 		"PaymentID", paymentInfo.PaymentID,
 		"CaptureID", paymentInfo.CaptureID)
 
-	// Simulate refund processing
-	time.Sleep(200 * time.Millisecond)
+	if err := a.Faults.Inject(ctx); err != nil {
+		return nil, err
+	}
+
+	result, err := a.Gateway.Refund(ctx, IdempotencyKey(ctx), paymentInfo.CaptureID, paymentInfo.Amount)
+	if err != nil {
+		logger.Error("Payment refund failed", "Error", err)
+		return nil, gatewayError(err)
+	}
 
-	paymentInfo.Status = "refunded"
+	refunded := *paymentInfo
+	refunded.RefundID = result.ID
+	refunded.Status = "refunded"
 
-	logger.Info("Payment refunded successfully")
-	return nil
+	logger.Info("Payment refunded successfully", "RefundID", refunded.RefundID)
+	return &refunded, nil
 }
 
 // VoidAuthorization voids an authorization (releases the hold)
 // This is called when authorization succeeded but we didn't capture
-func (a *PaymentActivities) VoidAuthorization(ctx context.Context, paymentInfo *models.PaymentInfo) error {
+func (a *PaymentActivities) VoidAuthorization(ctx context.Context, paymentInfo *models.PaymentInfo) (*models.PaymentInfo, error) {
 	logger := activity.GetLogger(ctx)
 
 	if paymentInfo.AuthorizationID == "" {
 		logger.Info("No authorization to void")
-		return nil
+		return paymentInfo, nil
 	}
 
 	logger.Info("Voiding authorization",
 		"PaymentID", paymentInfo.PaymentID,
 		"AuthorizationID", paymentInfo.AuthorizationID)
 
-	// Simulate void processing
-	time.Sleep(150 * time.Millisecond)
+	if err := a.Faults.Inject(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err := a.Gateway.Void(ctx, IdempotencyKey(ctx), paymentInfo.AuthorizationID); err != nil {
+		logger.Error("Authorization void failed", "Error", err)
+		return nil, gatewayError(err)
+	}
 
-	paymentInfo.Status = "voided"
+	voided := *paymentInfo
+	voided.Status = "voided"
 
 	logger.Info("Authorization voided successfully")
-	return nil
+	return &voided, nil
+}
+
+// gatewayError maps gateway errors to activity errors
+// Declines are permanent and must not be retried; everything else is
+func gatewayError(err error) error {
+	var declined *DeclinedError
+	if !errors.As(err, &declined) {
+		return fmt.Errorf("payment gateway error: %w", err)
+	}
+	switch declined.Code {
+	case DeclineInsufficientFunds:
+		return temporal.NewNonRetryableApplicationError(declined.Error(), "INSUFFICIENT_FUNDS", err)
+	case DeclineInvalidState:
+		return temporal.NewNonRetryableApplicationError(declined.Error(), "INVALID_PAYMENT_STATE", err)
+	default:
+		return temporal.NewNonRetryableApplicationError(declined.Error(), "PAYMENT_DECLINED", err)
+	}
 }
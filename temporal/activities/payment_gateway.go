@@ -0,0 +1,108 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// PaymentGateway is the payment provider behind PaymentActivities
+//
+// Every call carries an idempotency key. A gateway that sees the same key twice
+// must return the result of the first call instead of charging again, so a
+// retried activity whose previous attempt timed out after the charge went through
+// never double-charges
+type PaymentGateway interface {
+	Authorize(ctx context.Context, idempotencyKey string, req AuthorizeRequest) (GatewayResult, error)
+	Capture(ctx context.Context, idempotencyKey string, authorizationID string, amount float64) (GatewayResult, error)
+	Void(ctx context.Context, idempotencyKey string, authorizationID string) (GatewayResult, error)
+	Refund(ctx context.Context, idempotencyKey string, captureID string, amount float64) (GatewayResult, error)
+
+	// Payments lists the gateway's payment records; it is read-only and needs no key
+	Payments(ctx context.Context, query PaymentQuery) ([]Payment, error)
+}
+
+type AuthorizeRequest struct {
+	PaymentID     string  `json:"payment_id"`
+	OrderID       string  `json:"order_id"`
+	Amount        float64 `json:"amount"`
+	PaymentMethod string  `json:"payment_method"`
+}
+
+// GatewayResult is the gateway's view of one operation
+// ID is the authorization, capture or refund ID depending on the call
+type GatewayResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// Payment statuses reported by the gateway
+// A partially refunded payment stays captured
+const (
+	PaymentAuthorized = "authorized"
+	PaymentCaptured   = "captured"
+	PaymentVoided     = "voided"
+	PaymentRefunded   = "refunded"
+)
+
+// Payment is the gateway's record of one authorization and what happened to it since
+type Payment struct {
+	AuthorizationID string    `json:"authorization_id"`
+	PaymentID       string    `json:"payment_id"`
+	OrderID         string    `json:"order_id"`
+	Amount          float64   `json:"amount"`
+	Status          string    `json:"status"`
+	CaptureID       string    `json:"capture_id,omitempty"`
+	Captured        float64   `json:"captured"`
+	Refunded        float64   `json:"refunded"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// PaymentQuery selects the payments of one order, or those last updated in
+// [UpdatedAfter, UpdatedBefore); zero fields don't filter
+type PaymentQuery struct {
+	OrderID       string
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+}
+
+func (q PaymentQuery) matches(p Payment) bool {
+	return (q.OrderID == "" || p.OrderID == q.OrderID) &&
+		(q.UpdatedAfter.IsZero() || !p.UpdatedAt.Before(q.UpdatedAfter)) &&
+		(q.UpdatedBefore.IsZero() || p.UpdatedAt.Before(q.UpdatedBefore))
+}
+
+// Gateway decline codes
+const (
+	DeclineInsufficientFunds = "insufficient_funds"
+	DeclineCardDeclined      = "card_declined"
+	DeclineInvalidState      = "invalid_state"
+)
+
+// DeclinedError is a permanent refusal by the gateway; retrying won't help
+type DeclinedError struct {
+	Code    string
+	Message string
+}
+
+func (e *DeclinedError) Error() string {
+	return fmt.Sprintf("payment declined (%s): %s", e.Code, e.Message)
+}
+
+// ErrGatewayUnavailable is a transient gateway failure; the activity is retried
+var ErrGatewayUnavailable = errors.New("payment gateway unavailable")
+
+// IdempotencyKey derives the gateway idempotency key of the running activity
+// Retries of an activity keep their activity ID, so all attempts share one key,
+// while two different payment steps never do. The run ID is left out: a retried
+// PaymentWorkflow (payment-<OrderID>) is a new run, and its steps have fixed
+// activity IDs, so it replays the first run's authorization and capture instead
+// of charging again. Workflows that continue as new and pay for different orders
+// in each run must set an ActivityID naming the order
+func IdempotencyKey(ctx context.Context) string {
+	info := activity.GetInfo(ctx)
+	return fmt.Sprintf("%s/%s/%s", info.WorkflowExecution.ID, info.ActivityType.Name, info.ActivityID)
+}
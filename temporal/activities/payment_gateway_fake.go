@@ -0,0 +1,216 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FakeGateway is an in-memory PaymentGateway for local runs and tests
+// Authorizations above DeclineAbove are declined for insufficient funds
+type FakeGateway struct {
+	DeclineAbove float64
+
+	mu       sync.Mutex
+	seq      int
+	results  map[string]GatewayResult // by idempotency key
+	payments map[string]*fakePayment  // by authorization ID
+	captures map[string]string        // capture ID => authorization ID
+	failures map[string]int           // operation => remaining transient failures
+	lost     map[string]int           // operation => responses to drop after processing
+	calls    map[string]int           // operation => calls that reached the gateway
+	now      func() time.Time
+}
+
+type fakePayment struct {
+	paymentID string
+	orderID   string
+	amount    float64
+	status    string
+	captureID string
+	captured  float64
+	refunded  float64
+	updatedAt time.Time
+}
+
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		results:  make(map[string]GatewayResult),
+		payments: make(map[string]*fakePayment),
+		captures: make(map[string]string),
+		failures: make(map[string]int),
+		lost:     make(map[string]int),
+		calls:    make(map[string]int),
+		now:      time.Now,
+	}
+}
+
+// FailNext makes the next n calls of op ("authorize", "capture", "void", "refund")
+// fail with ErrGatewayUnavailable
+func (g *FakeGateway) FailNext(op string, n int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures[op] = n
+}
+
+// LoseNextResponse processes the next n calls of op but reports ErrGatewayUnavailable,
+// like a timeout after the gateway already charged
+func (g *FakeGateway) LoseNextResponse(op string, n int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.lost[op] = n
+}
+
+// Calls reports how many calls of op were processed, replays of a known key excluded
+func (g *FakeGateway) Calls(op string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.calls[op]
+}
+
+func (g *FakeGateway) Authorize(_ context.Context, key string, req AuthorizeRequest) (GatewayResult, error) {
+	return g.do("authorize", key, func() (GatewayResult, error) {
+		if g.DeclineAbove > 0 && req.Amount > g.DeclineAbove {
+			return GatewayResult{}, &DeclinedError{Code: DeclineInsufficientFunds, Message: "insufficient funds"}
+		}
+		id := g.nextID("auth")
+		g.payments[id] = &fakePayment{
+			paymentID: req.PaymentID,
+			orderID:   req.OrderID,
+			amount:    req.Amount,
+			status:    PaymentAuthorized,
+			updatedAt: g.now(),
+		}
+		return GatewayResult{ID: id, Status: PaymentAuthorized}, nil
+	})
+}
+
+func (g *FakeGateway) Capture(_ context.Context, key string, authorizationID string, amount float64) (GatewayResult, error) {
+	return g.do("capture", key, func() (GatewayResult, error) {
+		payment, ok := g.payments[authorizationID]
+		if !ok || payment.status != PaymentAuthorized {
+			return GatewayResult{}, invalidState("capture", authorizationID)
+		}
+		if amount > payment.amount {
+			return GatewayResult{}, &DeclinedError{Code: DeclineInvalidState, Message: "capture exceeds authorized amount"}
+		}
+		id := g.nextID("cap")
+		payment.status = PaymentCaptured
+		payment.captureID = id
+		payment.captured = amount
+		payment.updatedAt = g.now()
+		g.captures[id] = authorizationID
+		return GatewayResult{ID: id, Status: PaymentCaptured}, nil
+	})
+}
+
+func (g *FakeGateway) Void(_ context.Context, key string, authorizationID string) (GatewayResult, error) {
+	return g.do("void", key, func() (GatewayResult, error) {
+		payment, ok := g.payments[authorizationID]
+		if !ok || payment.status == PaymentCaptured || payment.status == PaymentRefunded {
+			return GatewayResult{}, invalidState("void", authorizationID)
+		}
+		payment.status = PaymentVoided
+		payment.updatedAt = g.now()
+		return GatewayResult{ID: authorizationID, Status: PaymentVoided}, nil
+	})
+}
+
+func (g *FakeGateway) Refund(_ context.Context, key string, captureID string, amount float64) (GatewayResult, error) {
+	return g.do("refund", key, func() (GatewayResult, error) {
+		authorizationID, ok := g.captures[captureID]
+		if !ok {
+			return GatewayResult{}, invalidState("refund", captureID)
+		}
+		payment := g.payments[authorizationID]
+		if payment.refunded+amount > payment.captured {
+			return GatewayResult{}, &DeclinedError{Code: DeclineInvalidState, Message: "refund exceeds captured amount"}
+		}
+		payment.refunded += amount
+		if payment.refunded == payment.captured {
+			payment.status = PaymentRefunded
+		}
+		payment.updatedAt = g.now()
+		return GatewayResult{ID: g.nextID("ref"), Status: PaymentRefunded}, nil
+	})
+}
+
+// Status returns the state of an authorization, "" if unknown
+func (g *FakeGateway) Status(authorizationID string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if payment, ok := g.payments[authorizationID]; ok {
+		return payment.status
+	}
+	return ""
+}
+
+func (g *FakeGateway) Payments(_ context.Context, query PaymentQuery) ([]Payment, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var payments []Payment
+	for id, p := range g.payments {
+		payment := Payment{
+			AuthorizationID: id,
+			PaymentID:       p.paymentID,
+			OrderID:         p.orderID,
+			Amount:          p.amount,
+			Status:          p.status,
+			CaptureID:       p.captureID,
+			Captured:        p.captured,
+			Refunded:        p.refunded,
+			UpdatedAt:       p.updatedAt,
+		}
+		if query.matches(payment) {
+			payments = append(payments, payment)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].AuthorizationID < payments[j].AuthorizationID })
+	return payments, nil
+}
+
+// do replays the stored result of a known key, otherwise runs fn
+// Declines are stored too: the same request is declined the same way
+func (g *FakeGateway) do(op, key string, fn func() (GatewayResult, error)) (GatewayResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.failures[op] > 0 {
+		g.failures[op]--
+		return GatewayResult{}, ErrGatewayUnavailable
+	}
+	if result, ok := g.results[key]; ok {
+		if result.Status == "declined" {
+			return GatewayResult{}, &DeclinedError{Code: result.ID, Message: "declined (replayed)"}
+		}
+		return result, nil
+	}
+
+	g.calls[op]++
+	result, err := fn()
+	if declined, ok := err.(*DeclinedError); ok {
+		g.results[key] = GatewayResult{ID: declined.Code, Status: "declined"}
+		return GatewayResult{}, err
+	}
+	if err != nil {
+		return GatewayResult{}, err
+	}
+	g.results[key] = result
+	if g.lost[op] > 0 {
+		g.lost[op]--
+		return GatewayResult{}, ErrGatewayUnavailable
+	}
+	return result, nil
+}
+
+func (g *FakeGateway) nextID(prefix string) string {
+	g.seq++
+	return fmt.Sprintf("%s-%06d", prefix, g.seq)
+}
+
+func invalidState(op, id string) *DeclinedError {
+	return &DeclinedError{Code: DeclineInvalidState, Message: fmt.Sprintf("cannot %s %s", op, id)}
+}
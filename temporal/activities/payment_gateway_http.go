@@ -0,0 +1,239 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/*
+PAYMENT GATEWAY HTTP API:
+
+HTTPGateway speaks a small REST API, served by PaymentStubHandler for local runs
+or by a sandbox adapter in front of a real provider:
+  POST /authorizations                  body: AuthorizeRequest
+  POST /authorizations/{id}/capture     body: {"amount": 10.5}
+  POST /authorizations/{id}/void
+  POST /captures/{id}/refunds           body: {"amount": 10.5}
+  GET  /payments?order_id=&updated_after=&updated_before=   (RFC 3339 times)
+
+Every POST sends an Idempotency-Key header. Responses are GatewayResult JSON;
+402 carries a decline ({"code": "...", "message": "..."}), 5xx is transient.
+*/
+
+// HTTPGateway is a PaymentGateway backed by the HTTP API above
+type HTTPGateway struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewHTTPGateway(baseURL string) *HTTPGateway {
+	return &HTTPGateway{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type amountBody struct {
+	Amount float64 `json:"amount"`
+}
+
+type declineBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (g *HTTPGateway) Authorize(ctx context.Context, key string, req AuthorizeRequest) (GatewayResult, error) {
+	return g.post(ctx, key, "/authorizations", req)
+}
+
+func (g *HTTPGateway) Capture(ctx context.Context, key string, authorizationID string, amount float64) (GatewayResult, error) {
+	return g.post(ctx, key, "/authorizations/"+url.PathEscape(authorizationID)+"/capture", amountBody{Amount: amount})
+}
+
+func (g *HTTPGateway) Void(ctx context.Context, key string, authorizationID string) (GatewayResult, error) {
+	return g.post(ctx, key, "/authorizations/"+url.PathEscape(authorizationID)+"/void", nil)
+}
+
+func (g *HTTPGateway) Refund(ctx context.Context, key string, captureID string, amount float64) (GatewayResult, error) {
+	return g.post(ctx, key, "/captures/"+url.PathEscape(captureID)+"/refunds", amountBody{Amount: amount})
+}
+
+func (g *HTTPGateway) Payments(ctx context.Context, query PaymentQuery) ([]Payment, error) {
+	params := url.Values{}
+	if query.OrderID != "" {
+		params.Set("order_id", query.OrderID)
+	}
+	if !query.UpdatedAfter.IsZero() {
+		params.Set("updated_after", query.UpdatedAfter.UTC().Format(time.RFC3339Nano))
+	}
+	if !query.UpdatedBefore.IsZero() {
+		params.Set("updated_before", query.UpdatedBefore.UTC().Format(time.RFC3339Nano))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.BaseURL+"/payments?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGatewayUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%w: status %d: %s", ErrGatewayUnavailable, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var payments []Payment
+	if err := json.NewDecoder(resp.Body).Decode(&payments); err != nil {
+		return nil, fmt.Errorf("decode gateway payments: %w", err)
+	}
+	return payments, nil
+}
+
+func (g *HTTPGateway) post(ctx context.Context, key, path string, body interface{}) (GatewayResult, error) {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return GatewayResult{}, err
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.BaseURL+path, payload)
+	if err != nil {
+		return GatewayResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		// Network errors are transient; the idempotency key makes the retry safe
+		return GatewayResult{}, fmt.Errorf("%w: %v", ErrGatewayUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated:
+		var result GatewayResult
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return GatewayResult{}, fmt.Errorf("decode gateway response: %w", err)
+		}
+		return result, nil
+	case resp.StatusCode == http.StatusPaymentRequired:
+		var decline declineBody
+		if err := json.NewDecoder(resp.Body).Decode(&decline); err != nil {
+			return GatewayResult{}, fmt.Errorf("decode gateway decline: %w", err)
+		}
+		return GatewayResult{}, &DeclinedError{Code: decline.Code, Message: decline.Message}
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return GatewayResult{}, fmt.Errorf("%w: status %d", ErrGatewayUnavailable, resp.StatusCode)
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return GatewayResult{}, &DeclinedError{Code: "bad_request", Message: strings.TrimSpace(string(msg))}
+	}
+}
+
+// PaymentStubHandler serves the gateway HTTP API on top of any PaymentGateway,
+// typically a FakeGateway, so HTTPGateway can be exercised end to end
+func PaymentStubHandler(gw PaymentGateway) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /authorizations", func(w http.ResponseWriter, r *http.Request) {
+		var req AuthorizeRequest
+		if !decodeStubBody(w, r, &req) {
+			return
+		}
+		result, err := gw.Authorize(r.Context(), r.Header.Get("Idempotency-Key"), req)
+		writeStubResult(w, result, err)
+	})
+	mux.HandleFunc("POST /authorizations/{id}/capture", func(w http.ResponseWriter, r *http.Request) {
+		var body amountBody
+		if !decodeStubBody(w, r, &body) {
+			return
+		}
+		result, err := gw.Capture(r.Context(), r.Header.Get("Idempotency-Key"), r.PathValue("id"), body.Amount)
+		writeStubResult(w, result, err)
+	})
+	mux.HandleFunc("POST /authorizations/{id}/void", func(w http.ResponseWriter, r *http.Request) {
+		result, err := gw.Void(r.Context(), r.Header.Get("Idempotency-Key"), r.PathValue("id"))
+		writeStubResult(w, result, err)
+	})
+	mux.HandleFunc("POST /captures/{id}/refunds", func(w http.ResponseWriter, r *http.Request) {
+		var body amountBody
+		if !decodeStubBody(w, r, &body) {
+			return
+		}
+		result, err := gw.Refund(r.Context(), r.Header.Get("Idempotency-Key"), r.PathValue("id"), body.Amount)
+		writeStubResult(w, result, err)
+	})
+	mux.HandleFunc("GET /payments", func(w http.ResponseWriter, r *http.Request) {
+		query := PaymentQuery{OrderID: r.URL.Query().Get("order_id")}
+		for param, t := range map[string]*time.Time{"updated_after": &query.UpdatedAfter, "updated_before": &query.UpdatedBefore} {
+			if v := r.URL.Query().Get(param); v != "" {
+				parsed, err := time.Parse(time.RFC3339Nano, v)
+				if err != nil {
+					http.Error(w, "invalid "+param, http.StatusBadRequest)
+					return
+				}
+				*t = parsed
+			}
+		}
+		payments, err := gw.Payments(r.Context(), query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if payments == nil {
+			payments = []Payment{}
+		}
+		writeStubJSON(w, http.StatusOK, payments)
+	})
+
+	// Reject changes without a key: without it retries could double-charge
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Header.Get("Idempotency-Key") == "" {
+			http.Error(w, "Idempotency-Key header is required", http.StatusBadRequest)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func decodeStubBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeStubResult(w http.ResponseWriter, result GatewayResult, err error) {
+	var declined *DeclinedError
+	switch {
+	case err == nil:
+		writeStubJSON(w, http.StatusOK, result)
+	case errors.As(err, &declined):
+		writeStubJSON(w, http.StatusPaymentRequired, declineBody{Code: declined.Code, Message: declined.Message})
+	default:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	}
+}
+
+func writeStubJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Unable to encode response", err)
+	}
+}
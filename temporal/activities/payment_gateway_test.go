@@ -0,0 +1,109 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newStubGateway(t *testing.T) (*HTTPGateway, *FakeGateway) {
+	t.Helper()
+	fake := NewFakeGateway()
+	fake.DeclineAbove = 1000
+	server := httptest.NewServer(PaymentStubHandler(fake))
+	t.Cleanup(server.Close)
+	return NewHTTPGateway(server.URL), fake
+}
+
+func TestHTTPGateway_AuthorizeCaptureRefund(t *testing.T) {
+	ctx := context.Background()
+	gw, fake := newStubGateway(t)
+
+	auth, err := gw.Authorize(ctx, "wf/AuthorizePayment/1", AuthorizeRequest{PaymentID: "pay-1", OrderID: "order-1", Amount: 50})
+	require.NoError(t, err)
+	require.Equal(t, "authorized", auth.Status)
+
+	capture, err := gw.Capture(ctx, "wf/CapturePayment/2", auth.ID, 50)
+	require.NoError(t, err)
+	require.Equal(t, "captured", fake.Status(auth.ID))
+
+	_, err = gw.Refund(ctx, "wf/RefundPayment/3", capture.ID, 50)
+	require.NoError(t, err)
+	require.Equal(t, "refunded", fake.Status(auth.ID))
+}
+
+func TestHTTPGateway_SameKeyChargesOnce(t *testing.T) {
+	ctx := context.Background()
+	gw, fake := newStubGateway(t)
+	fake.LoseNextResponse("authorize", 1)
+
+	req := AuthorizeRequest{PaymentID: "pay-1", OrderID: "order-1", Amount: 50}
+	_, err := gw.Authorize(ctx, "wf/AuthorizePayment/1", req)
+	require.ErrorIs(t, err, ErrGatewayUnavailable)
+
+	retried, err := gw.Authorize(ctx, "wf/AuthorizePayment/1", req)
+	require.NoError(t, err)
+	require.Equal(t, "authorized", fake.Status(retried.ID))
+	require.Equal(t, 1, fake.Calls("authorize"))
+}
+
+func TestHTTPGateway_Declined(t *testing.T) {
+	gw, _ := newStubGateway(t)
+
+	_, err := gw.Authorize(context.Background(), "wf/AuthorizePayment/1", AuthorizeRequest{PaymentID: "pay-1", Amount: 5000})
+
+	var declined *DeclinedError
+	require.True(t, errors.As(err, &declined))
+	require.Equal(t, DeclineInsufficientFunds, declined.Code)
+}
+
+func TestHTTPGateway_Payments(t *testing.T) {
+	ctx := context.Background()
+	gw, fake := newStubGateway(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fake.now = func() time.Time { return start }
+
+	first, err := gw.Authorize(ctx, "wf/AuthorizePayment/1", AuthorizeRequest{PaymentID: "pay-1", OrderID: "order-1", Amount: 50})
+	require.NoError(t, err)
+	fake.now = func() time.Time { return start.Add(time.Hour) }
+	_, err = gw.Authorize(ctx, "wf/AuthorizePayment/2", AuthorizeRequest{PaymentID: "pay-2", OrderID: "order-2", Amount: 20})
+	require.NoError(t, err)
+	capture, err := gw.Capture(ctx, "wf/CapturePayment/3", first.ID, 50)
+	require.NoError(t, err)
+
+	payments, err := gw.Payments(ctx, PaymentQuery{OrderID: "order-1"})
+	require.NoError(t, err)
+	require.Equal(t, []Payment{{
+		AuthorizationID: first.ID,
+		PaymentID:       "pay-1",
+		OrderID:         "order-1",
+		Amount:          50,
+		Status:          PaymentCaptured,
+		CaptureID:       capture.ID,
+		Captured:        50,
+		UpdatedAt:       start.Add(time.Hour),
+	}}, payments)
+
+	payments, err = gw.Payments(ctx, PaymentQuery{UpdatedAfter: start, UpdatedBefore: start.Add(time.Hour)})
+	require.NoError(t, err)
+	require.Empty(t, payments)
+
+	payments, err = gw.Payments(ctx, PaymentQuery{UpdatedAfter: start.Add(time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, payments, 2)
+}
+
+func TestPaymentStubHandler_RequiresIdempotencyKey(t *testing.T) {
+	server := httptest.NewServer(PaymentStubHandler(NewFakeGateway()))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/authorizations", "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,327 @@
+package activities
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"temporal/inventory"
+	"temporal/models"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// Reconciliation issues, the Issue of a models.ReconciliationCase
+const (
+	// An authorization was neither captured nor voided, e.g. the void of a failed capture failed too
+	IssueAuthorizationNotVoided = "authorization_not_voided"
+	// A payment was captured but the order holds no stock: its reservation was released, expired or never made
+	IssueCapturedWithoutReservation = "captured_without_reservation"
+	// The payment was voided or refunded but the stock is still reserved
+	IssueReservedWithoutPayment = "reserved_without_payment"
+	// Stock was shipped without a captured payment
+	IssueShippedWithoutPayment = "shipped_without_payment"
+	// The order was charged more than once
+	IssueMultipleCaptures = "multiple_captures"
+)
+
+// ReviewStore keeps the manual reviews opened by reconciliation, for follow-up by support
+type ReviewStore interface {
+	// Open records a review and returns it; a review already open under the same ID is returned as is
+	Open(ctx context.Context, review models.ManualReview) (models.ManualReview, error)
+}
+
+// MemoryReviewStore is an in-process ReviewStore for local runs and tests
+type MemoryReviewStore struct {
+	mu      sync.Mutex
+	reviews map[string]models.ManualReview // by review ID
+}
+
+func NewMemoryReviewStore() *MemoryReviewStore {
+	return &MemoryReviewStore{reviews: make(map[string]models.ManualReview)}
+}
+
+func (s *MemoryReviewStore) Open(_ context.Context, review models.ManualReview) (models.ManualReview, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.reviews[review.ReviewID]; ok {
+		return existing, nil
+	}
+	s.reviews[review.ReviewID] = review
+	return review, nil
+}
+
+// List returns the open reviews, oldest first
+func (s *MemoryReviewStore) List() []models.ManualReview {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reviews := make([]models.ManualReview, 0, len(s.reviews))
+	for _, review := range s.reviews {
+		reviews = append(reviews, review)
+	}
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].OpenedAt.Before(reviews[j].OpenedAt) })
+	return reviews
+}
+
+// FileReviewStore appends reviews to a file as JSON lines
+// Reviews already in the file are not written again
+type FileReviewStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (s *FileReviewStore) Open(_ context.Context, review models.ManualReview) (models.ManualReview, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, found, err := s.find(review.ReviewID)
+	if err != nil || found {
+		return existing, err
+	}
+
+	data, err := json.Marshal(review)
+	if err != nil {
+		return models.ManualReview{}, err
+	}
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return models.ManualReview{}, err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return models.ManualReview{}, err
+	}
+	return review, f.Close()
+}
+
+func (s *FileReviewStore) find(reviewID string) (models.ManualReview, bool, error) {
+	f, err := os.Open(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return models.ManualReview{}, false, nil
+	}
+	if err != nil {
+		return models.ManualReview{}, false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var review models.ManualReview
+		if err := json.Unmarshal(scanner.Bytes(), &review); err != nil {
+			return models.ManualReview{}, false, fmt.Errorf("%s: %w", s.Path, err)
+		}
+		if review.ReviewID == reviewID {
+			return review, true, nil
+		}
+	}
+	return models.ManualReview{}, false, scanner.Err()
+}
+
+// ReconciliationActivities compare what the payment gateway and the inventory
+// know about each order; the repairs run through the payment and inventory
+// activities the orders use
+type ReconciliationActivities struct {
+	Gateway   PaymentGateway
+	Inventory inventory.Store
+	Reviews   ReviewStore
+}
+
+// FindReconciliationCases looks at every order with a payment or reservation
+// updated in the window and returns those whose states disagree
+// Orders with a record updated after the window may still be running and are
+// left for a later pass
+func (a *ReconciliationActivities) FindReconciliationCases(ctx context.Context, window models.ReconciliationWindow) (*models.ReconciliationScan, error) {
+	logger := activity.GetLogger(ctx)
+
+	payments, err := a.Gateway.Payments(ctx, PaymentQuery{UpdatedAfter: window.From, UpdatedBefore: window.To})
+	if err != nil {
+		return nil, fmt.Errorf("list payments: %w", err)
+	}
+	reservations, err := a.Inventory.ListReservations(ctx, window.From, window.To)
+	if err != nil {
+		return nil, fmt.Errorf("list reservations: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var orderIDs []string
+	for _, p := range payments {
+		if p.OrderID != "" && !seen[p.OrderID] {
+			seen[p.OrderID] = true
+			orderIDs = append(orderIDs, p.OrderID)
+		}
+	}
+	for _, r := range reservations {
+		if !seen[r.OrderID] {
+			seen[r.OrderID] = true
+			orderIDs = append(orderIDs, r.OrderID)
+		}
+	}
+	sort.Strings(orderIDs)
+
+	scan := &models.ReconciliationScan{}
+	for i, orderID := range orderIDs {
+		if err := Heartbeat(ctx, i); err != nil {
+			return nil, err
+		}
+
+		orderPayments, err := a.Gateway.Payments(ctx, PaymentQuery{OrderID: orderID})
+		if err != nil {
+			return nil, fmt.Errorf("payments of order %s: %w", orderID, err)
+		}
+		var reservation *inventory.Reservation
+		r, err := a.Inventory.GetReservationByOrder(ctx, orderID)
+		switch {
+		case err == nil:
+			reservation = &r
+		case !errors.Is(err, inventory.ErrReservationNotFound):
+			return nil, fmt.Errorf("reservation of order %s: %w", orderID, err)
+		}
+
+		if updatedSince(orderPayments, reservation, window.To) {
+			continue
+		}
+		scan.Checked++
+		scan.Cases = append(scan.Cases, reconcileOrder(orderID, orderPayments, reservation)...)
+	}
+
+	logger.Info("Reconciliation scan completed",
+		"From", window.From, "To", window.To, "Checked", scan.Checked, "Cases", len(scan.Cases))
+	return scan, nil
+}
+
+// OpenManualReview records a case reconciliation could not repair
+func (a *ReconciliationActivities) OpenManualReview(ctx context.Context, review models.ManualReview) (*models.ManualReview, error) {
+	if review.ReviewID == "" {
+		review.ReviewID = review.OrderID + "/" + review.Issue
+	}
+	if review.OpenedAt.IsZero() {
+		review.OpenedAt = time.Now()
+	}
+
+	opened, err := a.Reviews.Open(ctx, review)
+	if err != nil {
+		return nil, fmt.Errorf("review store error: %w", err)
+	}
+	activity.GetLogger(ctx).Error("Manual review opened",
+		"ReviewID", opened.ReviewID,
+		"OrderID", opened.OrderID,
+		"Issue", opened.Issue,
+		"Detail", opened.Detail)
+	return &opened, nil
+}
+
+// updatedSince reports whether a payment or the reservation changed at or after t
+func updatedSince(payments []Payment, reservation *inventory.Reservation, t time.Time) bool {
+	for _, p := range payments {
+		if !p.UpdatedAt.Before(t) {
+			return true
+		}
+	}
+	return reservation != nil && !reservation.UpdatedAt.Before(t)
+}
+
+// reconcileOrder compares the payments of an order with its reservation
+//
+//	payments                 reservation              repair
+//	captured twice           any                      manual review
+//	captured                 released/expired/none    refund
+//	none captured            confirmed (shipped)      manual review
+//	none captured            reserved                 release
+//	authorized               any but shipped          void
+func reconcileOrder(orderID string, payments []Payment, reservation *inventory.Reservation) []models.ReconciliationCase {
+	var authorized, captured []Payment
+	for _, p := range payments {
+		switch p.Status {
+		case PaymentAuthorized:
+			authorized = append(authorized, p)
+		case PaymentCaptured:
+			captured = append(captured, p)
+		}
+	}
+
+	reservationStatus := "missing"
+	var modelReservation *models.InventoryReservation
+	if reservation != nil {
+		reservationStatus = reservation.Status
+		modelReservation = toModelReservation(*reservation)
+	}
+	shipped := reservationStatus == inventory.StatusConfirmed
+	held := reservationStatus == inventory.StatusReserved
+
+	var cases []models.ReconciliationCase
+	switch {
+	case len(captured) > 1:
+		ids := make([]string, len(captured))
+		for i, p := range captured {
+			ids[i] = p.CaptureID
+		}
+		cases = append(cases, models.ReconciliationCase{
+			OrderID:     orderID,
+			Issue:       IssueMultipleCaptures,
+			Action:      models.RepairManualReview,
+			Detail:      fmt.Sprintf("captures %s, reservation %s", strings.Join(ids, ", "), reservationStatus),
+			Reservation: modelReservation,
+		})
+	case len(captured) == 1 && !shipped && !held:
+		p := captured[0]
+		refund := paymentInfo(p)
+		refund.Amount = p.Captured - p.Refunded
+		cases = append(cases, models.ReconciliationCase{
+			OrderID: orderID,
+			Issue:   IssueCapturedWithoutReservation,
+			Action:  models.RepairRefundPayment,
+			Detail:  fmt.Sprintf("capture %s of %.2f, reservation %s", p.CaptureID, refund.Amount, reservationStatus),
+			Payment: refund,
+		})
+	case len(captured) == 0 && shipped:
+		cases = append(cases, models.ReconciliationCase{
+			OrderID:     orderID,
+			Issue:       IssueShippedWithoutPayment,
+			Action:      models.RepairManualReview,
+			Detail:      fmt.Sprintf("reservation %s confirmed, %d open authorizations", reservation.ReservationID, len(authorized)),
+			Reservation: modelReservation,
+		})
+	case len(captured) == 0 && held && len(payments) > 0:
+		cases = append(cases, models.ReconciliationCase{
+			OrderID:     orderID,
+			Issue:       IssueReservedWithoutPayment,
+			Action:      models.RepairReleaseInventory,
+			Detail:      fmt.Sprintf("reservation %s still reserved, no captured payment", reservation.ReservationID),
+			Reservation: modelReservation,
+		})
+	}
+
+	// A shipped order without a capture keeps its authorization: support may still capture it
+	if shipped && len(captured) == 0 {
+		return cases
+	}
+	for _, p := range authorized {
+		cases = append(cases, models.ReconciliationCase{
+			OrderID: orderID,
+			Issue:   IssueAuthorizationNotVoided,
+			Action:  models.RepairVoidAuthorization,
+			Detail:  fmt.Sprintf("authorization %s of %.2f neither captured nor voided", p.AuthorizationID, p.Amount),
+			Payment: paymentInfo(p),
+		})
+	}
+	return cases
+}
+
+func paymentInfo(p Payment) *models.PaymentInfo {
+	return &models.PaymentInfo{
+		PaymentID:       p.PaymentID,
+		OrderID:         p.OrderID,
+		Amount:          p.Amount,
+		AuthorizationID: p.AuthorizationID,
+		CaptureID:       p.CaptureID,
+		Status:          p.Status,
+	}
+}
@@ -0,0 +1,164 @@
+package activities
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"temporal/inventory"
+	"temporal/models"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+)
+
+type reconciliationFixture struct {
+	t       *testing.T
+	gateway *FakeGateway
+	store   *inventory.MemoryStore
+	acts    *ReconciliationActivities
+}
+
+func newReconciliationFixture(t *testing.T) *reconciliationFixture {
+	t.Helper()
+	store := inventory.NewMemoryStore()
+	require.NoError(t, store.Restock(context.Background(), "prod-001", 100))
+	gateway := NewFakeGateway()
+	return &reconciliationFixture{
+		t:       t,
+		gateway: gateway,
+		store:   store,
+		acts:    &ReconciliationActivities{Gateway: gateway, Inventory: store, Reviews: NewMemoryReviewStore()},
+	}
+}
+
+// order creates the gateway and inventory state of an order: payment is
+// authorized, captured, voided or ""; reservation is reserved, confirmed, released or ""
+func (f *reconciliationFixture) order(orderID, payment, reservation string) {
+	ctx := context.Background()
+	if payment != "" {
+		auth, err := f.gateway.Authorize(ctx, orderID+"/authorize", AuthorizeRequest{PaymentID: "pay-" + orderID, OrderID: orderID, Amount: 30})
+		require.NoError(f.t, err)
+		switch payment {
+		case PaymentCaptured:
+			_, err = f.gateway.Capture(ctx, orderID+"/capture", auth.ID, 30)
+		case PaymentVoided:
+			_, err = f.gateway.Void(ctx, orderID+"/void", auth.ID)
+		}
+		require.NoError(f.t, err)
+	}
+	if reservation != "" {
+		r, err := f.store.Reserve(ctx, orderID, []models.OrderItem{{ProductID: "prod-001", Quantity: 1}}, time.Hour)
+		require.NoError(f.t, err)
+		switch reservation {
+		case inventory.StatusConfirmed:
+			_, err = f.store.Confirm(ctx, r.ReservationID)
+		case inventory.StatusReleased:
+			_, err = f.store.Release(ctx, r.ReservationID)
+		}
+		require.NoError(f.t, err)
+	}
+}
+
+func (f *reconciliationFixture) scan(window models.ReconciliationWindow) *models.ReconciliationScan {
+	f.t.Helper()
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(f.acts)
+	value, err := env.ExecuteActivity(f.acts.FindReconciliationCases, window)
+	require.NoError(f.t, err)
+	var scan models.ReconciliationScan
+	require.NoError(f.t, value.Get(&scan))
+	return &scan
+}
+
+func lastHour() models.ReconciliationWindow {
+	now := time.Now()
+	return models.ReconciliationWindow{From: now.Add(-time.Hour), To: now.Add(time.Minute)}
+}
+
+func TestFindReconciliationCases(t *testing.T) {
+	f := newReconciliationFixture(t)
+	f.order("order-ok", PaymentCaptured, inventory.StatusConfirmed)
+	f.order("order-in-flight", PaymentCaptured, inventory.StatusReserved)
+	f.order("order-declined", "", inventory.StatusReleased)
+	f.order("order-not-voided", PaymentAuthorized, inventory.StatusReleased)
+	f.order("order-no-stock", PaymentCaptured, inventory.StatusReleased)
+	f.order("order-voided", PaymentVoided, inventory.StatusReserved)
+	f.order("order-shipped", PaymentAuthorized, inventory.StatusConfirmed)
+
+	scan := f.scan(lastHour())
+
+	require.Equal(t, 7, scan.Checked)
+	var got [][3]string
+	for _, c := range scan.Cases {
+		got = append(got, [3]string{c.OrderID, c.Issue, c.Action})
+	}
+	require.Equal(t, [][3]string{
+		{"order-no-stock", IssueCapturedWithoutReservation, models.RepairRefundPayment},
+		{"order-not-voided", IssueAuthorizationNotVoided, models.RepairVoidAuthorization},
+		{"order-shipped", IssueShippedWithoutPayment, models.RepairManualReview},
+		{"order-voided", IssueReservedWithoutPayment, models.RepairReleaseInventory},
+	}, got)
+
+	refund := scan.Cases[0].Payment
+	require.NotEmpty(t, refund.CaptureID)
+	require.Equal(t, 30.0, refund.Amount)
+	require.NotEmpty(t, scan.Cases[1].Payment.AuthorizationID)
+	require.Equal(t, inventory.ReservationIDForOrder("order-voided"), scan.Cases[3].Reservation.ReservationID)
+}
+
+func TestFindReconciliationCases_RefundsWhatIsLeft(t *testing.T) {
+	f := newReconciliationFixture(t)
+	f.order("order-1", PaymentCaptured, inventory.StatusReleased)
+	payments, err := f.gateway.Payments(context.Background(), PaymentQuery{OrderID: "order-1"})
+	require.NoError(t, err)
+	_, err = f.gateway.Refund(context.Background(), "order-1/refund", payments[0].CaptureID, 10)
+	require.NoError(t, err)
+
+	scan := f.scan(lastHour())
+
+	require.Len(t, scan.Cases, 1)
+	require.Equal(t, 20.0, scan.Cases[0].Payment.Amount)
+}
+
+func TestFindReconciliationCases_SkipsOrdersUpdatedAfterWindow(t *testing.T) {
+	f := newReconciliationFixture(t)
+	f.order("order-1", "", inventory.StatusReserved)
+	// the payment is still being processed
+	f.gateway.now = func() time.Time { return time.Now().Add(time.Hour) }
+	f.order("order-1", PaymentVoided, "")
+
+	scan := f.scan(lastHour())
+
+	require.Zero(t, scan.Checked)
+	require.Empty(t, scan.Cases)
+}
+
+func TestOpenManualReview_OncePerOrderAndIssue(t *testing.T) {
+	store := &FileReviewStore{Path: filepath.Join(t.TempDir(), "reviews.jsonl")}
+	acts := &ReconciliationActivities{Reviews: store}
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(acts)
+
+	open := func(detail string) models.ManualReview {
+		value, err := env.ExecuteActivity(acts.OpenManualReview, models.ManualReview{
+			OrderID: "order-1",
+			Issue:   IssueShippedWithoutPayment,
+			Detail:  detail,
+		})
+		require.NoError(t, err)
+		var review models.ManualReview
+		require.NoError(t, value.Get(&review))
+		return review
+	}
+
+	first := open("first pass")
+	again := open("second pass")
+
+	require.Equal(t, "order-1/"+IssueShippedWithoutPayment, first.ReviewID)
+	require.False(t, first.OpenedAt.IsZero())
+	require.Equal(t, "first pass", again.Detail)
+}
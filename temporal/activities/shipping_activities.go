@@ -0,0 +1,171 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"temporal/models"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// Carrier is the shipping provider behind ShippingActivities
+// Implementations must make CreateShipment idempotent per reference (the order ID),
+// because activities are retried
+type Carrier interface {
+	Name() string
+	CreateShipment(ctx context.Context, reference string, address models.ShippingAddress, items []models.OrderItem) (models.Shipment, error)
+	GetTracking(ctx context.Context, trackingNumber string) (models.TrackingUpdate, error)
+	CancelShipment(ctx context.Context, trackingNumber string) error
+}
+
+// ShippingActivities contains shipment-related activities
+type ShippingActivities struct {
+	Carrier Carrier
+}
+
+// CreateShipment books a shipment with the carrier
+func (a *ShippingActivities) CreateShipment(ctx context.Context, order models.Order) (*models.Shipment, error) {
+	logger := activity.GetLogger(ctx)
+	logger.Info("Creating shipment", "OrderID", order.OrderID, "Carrier", a.Carrier.Name())
+
+	if order.ShippingAddress == (models.ShippingAddress{}) {
+		return nil, temporal.NewNonRetryableApplicationError("order has no shipping address", "INVALID_ADDRESS", nil)
+	}
+
+	shipment, err := a.Carrier.CreateShipment(ctx, order.OrderID, order.ShippingAddress, order.Items)
+	if err != nil {
+		return nil, fmt.Errorf("carrier error: %w", err)
+	}
+
+	logger.Info("Shipment created", "OrderID", order.OrderID, "TrackingNumber", shipment.TrackingNumber)
+	return &shipment, nil
+}
+
+// GetTrackingStatus polls the carrier for carriers (or outages) without push updates
+func (a *ShippingActivities) GetTrackingStatus(ctx context.Context, trackingNumber string) (*models.TrackingUpdate, error) {
+	update, err := a.Carrier.GetTracking(ctx, trackingNumber)
+	if err != nil {
+		return nil, fmt.Errorf("carrier error: %w", err)
+	}
+	activity.GetLogger(ctx).Info("Tracking status", "TrackingNumber", trackingNumber, "Status", update.Status)
+	return &update, nil
+}
+
+// CancelShipment cancels a shipment (compensation logic)
+// Cancelling an unknown or already cancelled shipment succeeds
+func (a *ShippingActivities) CancelShipment(ctx context.Context, shipment *models.Shipment) error {
+	logger := activity.GetLogger(ctx)
+
+	if shipment == nil || shipment.TrackingNumber == "" {
+		logger.Info("No shipment to cancel")
+		return nil
+	}
+
+	logger.Info("Cancelling shipment", "TrackingNumber", shipment.TrackingNumber)
+	if err := a.Carrier.CancelShipment(ctx, shipment.TrackingNumber); err != nil {
+		return fmt.Errorf("carrier error: %w", err)
+	}
+	return nil
+}
+
+// FakeCarrier is an in-memory Carrier for local runs and tests
+// Every GetTracking call advances the parcel one step along Route,
+// unless an outcome was forced with SetOutcome
+type FakeCarrier struct {
+	Route []string
+
+	mu        sync.Mutex
+	shipments map[string]*fakeParcel // by tracking number
+	byRef     map[string]string      // reference => tracking number
+}
+
+type fakeParcel struct {
+	shipment models.Shipment
+	step     int
+	outcome  string
+}
+
+func NewFakeCarrier() *FakeCarrier {
+	return &FakeCarrier{
+		Route:     []string{"label_created", "in_transit", "out_for_delivery", "delivered"},
+		shipments: make(map[string]*fakeParcel),
+		byRef:     make(map[string]string),
+	}
+}
+
+func (c *FakeCarrier) Name() string {
+	return "fake-carrier"
+}
+
+func (c *FakeCarrier) CreateShipment(_ context.Context, reference string, address models.ShippingAddress, _ []models.OrderItem) (models.Shipment, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tn, ok := c.byRef[reference]; ok {
+		return c.shipments[tn].shipment, nil
+	}
+
+	tn := fmt.Sprintf("FAKE-%s", reference)
+	shipment := models.Shipment{
+		ShipmentID:     fmt.Sprintf("shp-%s", reference),
+		OrderID:        reference,
+		Carrier:        c.Name(),
+		TrackingNumber: tn,
+		Address:        address,
+		Status:         c.Route[0],
+		CreatedAt:      time.Now(),
+	}
+	c.shipments[tn] = &fakeParcel{shipment: shipment}
+	c.byRef[reference] = tn
+	return shipment, nil
+}
+
+func (c *FakeCarrier) GetTracking(_ context.Context, trackingNumber string) (models.TrackingUpdate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	parcel, ok := c.shipments[trackingNumber]
+	if !ok {
+		return models.TrackingUpdate{}, fmt.Errorf("unknown tracking number %s", trackingNumber)
+	}
+
+	status := parcel.outcome
+	if status == "" {
+		if parcel.step < len(c.Route)-1 {
+			parcel.step++
+		}
+		status = c.Route[parcel.step]
+	}
+	parcel.shipment.Status = status
+
+	return models.TrackingUpdate{
+		TrackingNumber: trackingNumber,
+		Status:         status,
+		Location:       "fake hub",
+		Time:           time.Now(),
+	}, nil
+}
+
+func (c *FakeCarrier) CancelShipment(_ context.Context, trackingNumber string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if parcel, ok := c.shipments[trackingNumber]; ok {
+		parcel.outcome = "cancelled"
+		parcel.shipment.Status = "cancelled"
+	}
+	return nil
+}
+
+// SetOutcome forces the next tracking status of a parcel, e.g. "lost" or "returned"
+func (c *FakeCarrier) SetOutcome(trackingNumber, status string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if parcel, ok := c.shipments[trackingNumber]; ok {
+		parcel.outcome = status
+	}
+}
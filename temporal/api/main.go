@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"temporal/orders"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// The orders HTTP API: starts orders and looks them up by order ID
+//
+//	go run ./api -addr :8080
+//	curl -X POST localhost:8080/orders -d '{"CustomerID":"customer-123","TotalAmount":29.99,...}'
+//	curl localhost:8080/orders/order-1234
+func main() {
+	addr := flag.String("addr", ":8080", "Listen address")
+	hostPort := flag.String("address", "localhost:7233", "Temporal server address")
+	namespace := flag.String("namespace", "default", "Temporal namespace")
+	taskQueue := flag.String("task-queue", orders.DefaultTaskQueue, "Task queue of the order workers")
+	flag.Parse()
+
+	c, err := client.Dial(client.Options{HostPort: *hostPort, Namespace: *namespace})
+	if err != nil {
+		log.Fatalln("Unable to create Temporal client", err)
+	}
+	defer c.Close()
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           newOrderHandler(c, *taskQueue),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		log.Println("Orders API listening on", *addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalln("Orders API error", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	log.Println("Orders API stopped")
+}
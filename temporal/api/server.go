@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"temporal/models"
+	"temporal/orders"
+
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+)
+
+/*
+ORDERS HTTP API:
+
+  POST /orders                    body: models.Order (OrderID is generated when empty)
+                                  409 when the order is running or completed
+  GET  /orders                    ?customer_id=&status=&min_total=&running=true&limit=
+  GET  /orders/{orderID}          status and step history of an order
+  POST /orders/{orderID}/cancel   body: {"reason": "..."}
+  POST /orders/{orderID}/retry    run a failed order again
+
+Orders are addressed by order ID; listing uses the CustomerID, OrderTotal
+and Status search attributes.
+*/
+
+type orderServer struct {
+	client    client.Client
+	taskQueue string // where new and retried orders run
+}
+
+type startResponse struct {
+	OrderID    string `json:"order_id"`
+	WorkflowID string `json:"workflow_id"`
+	RunID      string `json:"run_id"`
+}
+
+type cancelBody struct {
+	Reason string `json:"reason"`
+}
+
+func newOrderHandler(c client.Client, taskQueue string) http.Handler {
+	s := &orderServer{client: c, taskQueue: taskQueue}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /orders", s.handleStart)
+	mux.HandleFunc("GET /orders", s.handleList)
+	mux.HandleFunc("GET /orders/{orderID}", s.handleGet)
+	mux.HandleFunc("POST /orders/{orderID}/cancel", s.handleCancel)
+	mux.HandleFunc("POST /orders/{orderID}/retry", s.handleRetry)
+	return mux
+}
+
+func (s *orderServer) handleStart(w http.ResponseWriter, r *http.Request) {
+	var order models.Order
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if order.OrderID == "" {
+		order.OrderID = fmt.Sprintf("order-%s", uuid.New().String()[:8])
+	}
+
+	run, err := orders.Start(r.Context(), s.client, s.taskQueue, order)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, startResponse{OrderID: order.OrderID, WorkflowID: run.GetID(), RunID: run.GetRunID()})
+}
+
+func (s *orderServer) handleList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := orders.Filter{
+		CustomerID: q.Get("customer_id"),
+		Status:     q.Get("status"),
+		Running:    q.Get("running") == "true",
+	}
+	var err error
+	if v := q.Get("min_total"); v != "" {
+		if filter.MinTotal, err = strconv.ParseFloat(v, 64); err != nil {
+			http.Error(w, "invalid min_total", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	summaries, err := orders.List(r.Context(), s.client, filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if summaries == nil {
+		summaries = []orders.Summary{}
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+func (s *orderServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	details, err := orders.Get(r.Context(), s.client, r.PathValue("orderID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, details)
+}
+
+func (s *orderServer) handleCancel(w http.ResponseWriter, r *http.Request) {
+	var body cancelBody
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	orderID := r.PathValue("orderID")
+	if err := orders.Cancel(r.Context(), s.client, orderID, body.Reason); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"order_id": orderID, "status": "cancel_requested"})
+}
+
+func (s *orderServer) handleRetry(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("orderID")
+	run, err := orders.Retry(r.Context(), s.client, s.taskQueue, orderID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, startResponse{OrderID: orderID, WorkflowID: run.GetID(), RunID: run.GetRunID()})
+}
+
+// writeError maps order errors to HTTP status codes
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, orders.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, orders.ErrNotRetryable), errors.Is(err, orders.ErrAlreadyStarted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Println("Order request failed", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Unable to encode response", err)
+	}
+}
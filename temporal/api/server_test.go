@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"temporal/models"
+	"temporal/workflows"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/mocks"
+)
+
+func serve(c client.Client, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	newOrderHandler(c, "orders-test").ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestStartOrder(t *testing.T) {
+	c := &mocks.Client{}
+	run := &mocks.WorkflowRun{}
+	run.On("GetID").Return("order-workflow-order-1")
+	run.On("GetRunID").Return("run-1")
+	c.On("ExecuteWorkflow", mock.Anything, mock.MatchedBy(func(o client.StartWorkflowOptions) bool {
+		customerID, _ := o.TypedSearchAttributes.GetKeyword(workflows.SearchAttributeCustomerID)
+		return o.ID == "order-workflow-order-1" && o.TaskQueue == "orders-test" && customerID == "customer-1"
+	}), mock.Anything, mock.MatchedBy(func(o models.Order) bool { return o.OrderID == "order-1" })).Return(run, nil)
+
+	rec := serve(c, http.MethodPost, "/orders", `{"OrderID":"order-1","CustomerID":"customer-1","TotalAmount":10}`)
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.JSONEq(t, `{"order_id":"order-1","workflow_id":"order-workflow-order-1","run_id":"run-1"}`, rec.Body.String())
+	c.AssertExpectations(t)
+}
+
+func TestStartOrder_AlreadyStarted(t *testing.T) {
+	c := &mocks.Client{}
+	c.On("ExecuteWorkflow", mock.Anything, mock.MatchedBy(func(o client.StartWorkflowOptions) bool {
+		return o.WorkflowExecutionErrorWhenAlreadyStarted
+	}), mock.Anything, mock.Anything).
+		Return(nil, serviceerror.NewWorkflowExecutionAlreadyStarted("workflow already started", "", "run-1"))
+
+	rec := serve(c, http.MethodPost, "/orders", `{"OrderID":"order-1","CustomerID":"customer-1","TotalAmount":10}`)
+
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "order-1")
+}
+
+func TestGetOrder_NotFound(t *testing.T) {
+	c := &mocks.Client{}
+	c.On("DescribeWorkflowExecution", mock.Anything, "order-workflow-missing", "").
+		Return(nil, serviceerror.NewNotFound("workflow not found"))
+
+	rec := serve(c, http.MethodGet, "/orders/missing", "")
+
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRetryOrder_NotFailed(t *testing.T) {
+	c := &mocks.Client{}
+	c.On("DescribeWorkflowExecution", mock.Anything, "order-workflow-order-1", "").
+		Return(&workflowservice.DescribeWorkflowExecutionResponse{
+			WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{Status: enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED},
+		}, nil)
+
+	rec := serve(c, http.MethodPost, "/orders/order-1/retry", "")
+
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "Completed")
+}
+
+func TestListOrders_InvalidFilter(t *testing.T) {
+	rec := serve(&mocks.Client{}, http.MethodGet, "/orders?min_total=lots", "")
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"temporal/activities"
+	"temporal/models"
+	"temporal/workflows"
+	"time"
+
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+)
+
+// batchCmd validates a batch file and starts a BatchOrderWorkflow for it
+// The workers read the file themselves, so it must be on a path they can reach
+func batchCmd(ctx context.Context, c client.Client, args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	concurrency := fs.Int("concurrency", 10, "Orders processed at the same time")
+	reportPath := fs.String("report", "", "CSV report of per-order results (default: <file>.report.csv)")
+	values, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	source, err := filepath.Abs(values[0])
+	if err != nil {
+		return err
+	}
+	batch, err := activities.ReadOrders(source)
+	if err != nil {
+		return fmt.Errorf("read batch file: %w", err)
+	}
+	report := *reportPath
+	if report == "" {
+		report = strings.TrimSuffix(source, filepath.Ext(source)) + ".report.csv"
+	}
+	if report, err = filepath.Abs(report); err != nil {
+		return err
+	}
+
+	batchID := fmt.Sprintf("batch-%s", uuid.New().String()[:8])
+	run, err := c.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:        batchID,
+		TaskQueue: taskQueue,
+	}, workflows.BatchOrderWorkflow, models.BatchRequest{
+		BatchID:     batchID,
+		Source:      source,
+		ReportPath:  report,
+		Concurrency: *concurrency,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to start batch: %w", err)
+	}
+
+	fmt.Printf("Started batch %s with %d orders (concurrency %d)\n", batchID, len(batch), *concurrency)
+	fmt.Printf("  Run ID: %s\n", run.GetRunID())
+	fmt.Printf("  Report: %s\n", report)
+	fmt.Printf("Follow it with: orders progress %s\n", batchID)
+	return nil
+}
+
+// progressCmd queries the get-batch-progress handler of a batch workflow
+// The query follows continue-as-new to the latest run of the batch
+func progressCmd(ctx context.Context, c client.Client, args []string) error {
+	fs := flag.NewFlagSet("progress", flag.ExitOnError)
+	values, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	value, err := c.QueryWorkflow(ctx, values[0], "", workflows.QueryBatchProgress)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	var report models.BatchReport
+	if err := value.Get(&report); err != nil {
+		return fmt.Errorf("decode query result: %w", err)
+	}
+
+	state := "running"
+	if report.Done {
+		state = "done in " + report.FinishedAt.Sub(report.StartedAt).Round(time.Second).String()
+	}
+	fmt.Printf("Batch: %s (%s, run %d)\n", report.BatchID, state, report.Runs)
+	fmt.Printf("Processed: %d/%d  in flight: %d\n", report.Processed, report.Total, report.InFlight)
+	fmt.Printf("Succeeded: %d  failed: %d  duplicates: %d\n", report.Succeeded, report.Failed, report.Duplicates)
+	if len(report.Failures) > 0 {
+		fmt.Println("Failures:")
+		for _, f := range report.Failures {
+			fmt.Printf("  %-20s %s\n", f.OrderID, f.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"temporal/activities"
+	"temporal/models"
+	"temporal/workflows"
+
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+)
+
+// syncInventoryCmd starts an InventorySyncWorkflow for a stock count file
+// Like batch files, the file must be on a path the workers can reach
+func syncInventoryCmd(ctx context.Context, c client.Client, args []string) error {
+	fs := flag.NewFlagSet("sync-inventory", flag.ExitOnError)
+	batchSize := fs.Int("batch", 100, "Products applied between heartbeats")
+	wait := fs.Bool("wait", false, "Wait for the sync to complete")
+	values, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	source, err := filepath.Abs(values[0])
+	if err != nil {
+		return err
+	}
+	counts, err := activities.ReadStockCounts(source)
+	if err != nil {
+		return fmt.Errorf("read stock file: %w", err)
+	}
+
+	run, err := c.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("inventory-sync-%s", uuid.New().String()[:8]),
+		TaskQueue: taskQueue,
+	}, workflows.InventorySyncWorkflow, models.InventorySyncRequest{Source: source, BatchSize: *batchSize})
+	if err != nil {
+		return fmt.Errorf("unable to start inventory sync: %w", err)
+	}
+	fmt.Printf("Syncing %d products from %s\n", len(counts), source)
+	printRun(run)
+	if !*wait {
+		return nil
+	}
+
+	var result models.InventorySyncResult
+	if err := run.Get(ctx, &result); err != nil {
+		return fmt.Errorf("inventory sync failed: %w", err)
+	}
+	fmt.Printf("Synced %d products, %d changed (%d attempts", result.Processed, result.Changed, result.Attempts)
+	if result.ResumedFrom > 0 {
+		fmt.Printf(", resumed from row %d", result.ResumedFrom)
+	}
+	fmt.Println(")")
+	return nil
+}
@@ -2,15 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"temporal/models"
-	"temporal/workflows"
-	"time"
+	"os"
+	"temporal/orders"
 
-	"github.com/google/uuid"
 	"go.temporal.io/sdk/client"
-	"go.temporal.io/sdk/temporal"
 )
 
 /*
@@ -23,169 +22,137 @@ The client is responsible for:
 4. Canceling workflows
 
 This is typically where your application code interacts with Temporal.
+Orders are addressed by order ID; the workflow ID is derived from it
+(workflows.OrderWorkflowID), so callers never need to remember run IDs.
+
+Usage (go build -o orders ./client, or go run ./client):
+  orders start                                     # start a sample order
+  orders start -order order.json -wait             # start an order from a JSON file and wait for it
+  orders status ORDER_ID                           # query get-status
+  orders cancel ORDER_ID -reason "changed my mind"
+  orders list -customer customer-123 -status payment_failed
+  orders retry ORDER_ID                            # run a failed order again
+  orders ship-to ORDER_ID "221B Baker St,London,NW1 6XE,UK"
+  orders approve ORDER_ID -reviewer alice -comment "known customer"
+  orders reject ORDER_ID -reviewer alice -comment "suspicious"
+  orders track ORDER_ID delivered -location "Front door"
+  orders batch client/sample_batch.csv -concurrency 5
+  orders progress BATCH_ID
+  orders subscribe -customer customer-123 -schedule "0 9 * * MON"
+  orders subscription SUB_ID                       # query get-subscription
+  orders subscription SUB_ID skip                  # or pause, resume, cancel
+  orders sync-inventory client/sample_stock.csv -wait
 */
 
+// taskQueue is the task queue of the workflows the commands start, the -task-queue of the workers
+var taskQueue string
+
+// command is one subcommand of the orders CLI
+type command struct {
+	usage string
+	run   func(ctx context.Context, c client.Client, args []string) error
+}
+
+var commands = map[string]command{
+	"start":    {"start [-order FILE] [-customer ID] [-wait]", startCmd},
+	"status":   {"status ORDER_ID", statusCmd},
+	"cancel":   {"cancel ORDER_ID [-reason TEXT]", cancelCmd},
+	"list":     {"list [-customer ID] [-status STATUS] [-min-total N] [-running] [-limit N]", listCmd},
+	"retry":    {"retry ORDER_ID", retryCmd},
+	"ship-to":  {`ship-to ORDER_ID "street,city,postal code,country"`, shipToCmd},
+	"approve":  {"approve ORDER_ID -reviewer NAME [-comment TEXT]", approvalCmd(true)},
+	"reject":   {"reject ORDER_ID -reviewer NAME [-comment TEXT]", approvalCmd(false)},
+	"track":    {"track ORDER_ID STATUS [-location TEXT]", trackCmd},
+	"batch":    {"batch FILE [-concurrency N] [-report FILE]", batchCmd},
+	"progress": {"progress BATCH_ID", progressCmd},
+
+	"subscribe":    {"subscribe [-id SUB_ID] [-customer ID] [-schedule CRON] [-max-payment-failures N]", subscribeCmd},
+	"subscription": {"subscription SUB_ID [pause|resume|skip|cancel] [-reason TEXT]", subscriptionCmd},
+
+	"sync-inventory": {"sync-inventory FILE [-batch N] [-wait]", syncInventoryCmd},
+	"reconcile":      {"reconcile [-interval D] [-grace D] [-lookback D] [-status]", reconcileCmd},
+}
+
+// commandOrder is the order of the commands in the usage text
+var commandOrder = []string{"start", "status", "cancel", "list", "retry", "ship-to", "approve", "reject", "track", "batch", "progress", "subscribe", "subscription", "sync-inventory", "reconcile"}
+
 func main() {
+	hostPort := flag.String("address", envOr("TEMPORAL_ADDRESS", "localhost:7233"), "Temporal server address")
+	namespace := flag.String("namespace", envOr("TEMPORAL_NAMESPACE", "default"), "Temporal namespace")
+	flag.StringVar(&taskQueue, "task-queue", envOr("TEMPORAL_TASK_QUEUE", orders.DefaultTaskQueue), "Task queue of the order workers")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
 	// Create Temporal client
 	c, err := client.Dial(client.Options{
-		HostPort:  "localhost:7233",
-		Namespace: "default",
+		HostPort:  *hostPort,
+		Namespace: *namespace,
 	})
 	if err != nil {
 		log.Fatalln("Unable to create Temporal client", err)
 	}
 	defer c.Close()
 
-	// Create a sample order
-	order := models.Order{
-		OrderID:    fmt.Sprintf("order-%s", uuid.New().String()[:8]),
-		CustomerID: "customer-123",
-		Items: []models.OrderItem{
-			{
-				ProductID: "prod-001",
-				Quantity:  2,
-				Price:     29.99,
-			},
-			{
-				ProductID: "prod-002",
-				Quantity:  1,
-				Price:     49.99,
-			},
-		},
-		TotalAmount: 109.97,
-		Status:      "pending",
-	}
-
-	fmt.Println("=== Starting Order Processing Workflow ===")
-	fmt.Printf("Order ID: %s\n", order.OrderID)
-	fmt.Printf("Customer ID: %s\n", order.CustomerID)
-	fmt.Printf("Total Amount: $%.2f\n", order.TotalAmount)
-	fmt.Println()
-
-	// Configure workflow options
-	workflowOptions := client.StartWorkflowOptions{
-		// Unique ID for this workflow execution
-		// If a workflow with this ID is already running, it will fail
-		ID: fmt.Sprintf("order-workflow-%s", order.OrderID),
-
-		// Task queue where the workflow will be executed
-		// Must match the task queue that workers are polling
-		TaskQueue: "order-processing-queue",
-
-		// WORKFLOW-LEVEL RETRY POLICY
-		// This is different from activity retry!
-		// Applied when the ENTIRE workflow fails and needs to restart from beginning
-		RetryPolicy: &temporal.RetryPolicy{
-			InitialInterval:    2 * time.Second,
-			BackoffCoefficient: 2.0,
-			MaximumInterval:    1 * time.Minute,
-			MaximumAttempts:    3, // Retry the entire workflow up to 3 times
-		},
-
-		// Workflow execution timeout: Maximum time for workflow to complete
-		WorkflowExecutionTimeout: 10 * time.Minute,
-
-		// Workflow run timeout: Maximum time for a single workflow run
-		// (useful if workflow continues-as-new)
-		WorkflowRunTimeout: 10 * time.Minute,
-
-		// Workflow task timeout: Maximum time to process a single workflow task
-		WorkflowTaskTimeout: 10 * time.Second,
-
-		// Memo: Static metadata about the workflow (searchable)
-		// Memo: map[string]interface{}{
-		//     "customerID": order.CustomerID,
-		//     "orderTotal": order.TotalAmount,
-		// },
-
-		// SearchAttributes: Custom indexed fields for querying
-		// SearchAttributes: map[string]interface{}{
-		//     "CustomKeywordField": "order",
-		// },
+	err = cmd.run(context.Background(), c, flag.Args()[1:])
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, "usage: orders "+cmd.usage)
+		c.Close()
+		os.Exit(2)
 	}
-
-	// Start the workflow
-	// This is asynchronous - it returns immediately
-	workflowRun, err := c.ExecuteWorkflow(
-		context.Background(),
-		workflowOptions,
-		workflows.OrderWorkflow, // Workflow function
-		order,                   // Workflow arguments
-	)
 	if err != nil {
-		log.Fatalln("Unable to execute workflow", err)
+		c.Close()
+		log.Fatalln(err)
 	}
+}
 
-	fmt.Printf("Started workflow\n")
-	fmt.Printf("  Workflow ID: %s\n", workflowRun.GetID())
-	fmt.Printf("  Run ID: %s\n", workflowRun.GetRunID())
-	fmt.Println()
-	fmt.Println("View workflow execution in Temporal UI:")
-	fmt.Printf("  http://localhost:8233/namespaces/default/workflows/%s/%s\n",
-		workflowRun.GetID(), workflowRun.GetRunID())
-	fmt.Println()
-
-	// Option 1: Wait for workflow to complete (blocking)
-	fmt.Println("Waiting for workflow to complete...")
-	var result string
-	err = workflowRun.Get(context.Background(), &result)
-	if err != nil {
-		log.Printf("Workflow execution failed: %v\n", err)
-		fmt.Println()
-		fmt.Println("=== Workflow Failed ===")
-		fmt.Printf("Error: %v\n", err)
-		fmt.Println()
-		fmt.Println("Check the Temporal UI for details:")
-		fmt.Printf("  http://localhost:8233/namespaces/default/workflows/%s/%s\n",
-			workflowRun.GetID(), workflowRun.GetRunID())
-		return
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: orders [-address HOST:PORT] [-namespace NS] [-task-queue QUEUE] <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, name := range commandOrder {
+		fmt.Fprintln(os.Stderr, "  "+commands[name].usage)
 	}
+}
 
-	fmt.Println()
-	fmt.Println("=== Workflow Completed Successfully ===")
-	fmt.Printf("Final Status: %s\n", result)
-	fmt.Println()
-
-	// Option 2: Query workflow state (non-blocking)
-	// This would be used to check workflow state without waiting for completion
-	/*
-		var queryResult string
-		queryValue, err := c.QueryWorkflow(
-			context.Background(),
-			workflowRun.GetID(),
-			workflowRun.GetRunID(),
-			"getOrderStatus", // Query handler name
-		)
-		if err != nil {
-			log.Println("Query failed", err)
-		} else {
-			err = queryValue.Get(&queryResult)
-			log.Printf("Current order status: %s", queryResult)
+// errUsage makes main print the usage of the command
+var errUsage = errors.New("wrong number of arguments")
+
+// parseArgs parses flags anywhere between the positional arguments,
+// so both "cancel -reason x ORDER_ID" and "cancel ORDER_ID -reason x" work,
+// and checks the number of positional arguments
+func parseArgs(fs *flag.FlagSet, args []string, positional int) ([]string, error) {
+	var values []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
 		}
-	*/
-
-	// Option 3: Signal workflow (to change behavior)
-	// This would be used to send data to a running workflow
-	/*
-		err = c.SignalWorkflow(
-			context.Background(),
-			workflowRun.GetID(),
-			workflowRun.GetRunID(),
-			"updateOrderSignal", // Signal name
-			updateData,          // Signal payload
-		)
-	*/
-
-	// Option 4: Cancel workflow
-	/*
-		err = c.CancelWorkflow(
-			context.Background(),
-			workflowRun.GetID(),
-			workflowRun.GetRunID(),
-		)
-	*/
-
-	fmt.Println("View complete workflow history in Temporal UI:")
-	fmt.Printf("  http://localhost:8233/namespaces/default/workflows/%s/%s\n",
-		workflowRun.GetID(), workflowRun.GetRunID())
+		values = append(values, args[0])
+		args = args[1:]
+	}
+	if len(values) != positional {
+		return nil, errUsage
+	}
+	return values, nil
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"temporal/models"
+	"temporal/orders"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+)
+
+// sampleOrder is the order started by "orders start" without -order
+func sampleOrder(customerID string) models.Order {
+	return models.Order{
+		OrderID:    fmt.Sprintf("order-%s", uuid.New().String()[:8]),
+		CustomerID: customerID,
+		Items: []models.OrderItem{
+			{
+				ProductID: "prod-001",
+				Quantity:  2,
+				Price:     29.99,
+			},
+			{
+				ProductID: "prod-002",
+				Quantity:  1,
+				Price:     49.99,
+			},
+		},
+		TotalAmount: 109.97,
+		Status:      "pending",
+		ShippingAddress: models.ShippingAddress{
+			Street:     "1 Main St",
+			City:       "Springfield",
+			PostalCode: "12345",
+			Country:    "US",
+		},
+	}
+}
+
+// startCmd starts an order workflow, optionally waiting for its result
+func startCmd(ctx context.Context, c client.Client, args []string) error {
+	fs := flag.NewFlagSet("start", flag.ExitOnError)
+	orderFile := fs.String("order", "", "JSON file with the order to start (default: a sample order)")
+	customerID := fs.String("customer", "customer-123", "Customer of the sample order")
+	wait := fs.Bool("wait", false, "Wait for the order workflow to complete")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	order := sampleOrder(*customerID)
+	if *orderFile != "" {
+		data, err := os.ReadFile(*orderFile)
+		if err != nil {
+			return err
+		}
+		order = models.Order{}
+		if err := json.Unmarshal(data, &order); err != nil {
+			return fmt.Errorf("decode %s: %w", *orderFile, err)
+		}
+	}
+
+	fmt.Println("=== Starting Order Processing Workflow ===")
+	fmt.Printf("Order ID: %s\n", order.OrderID)
+	fmt.Printf("Customer ID: %s\n", order.CustomerID)
+	fmt.Printf("Total Amount: $%.2f\n", order.TotalAmount)
+	fmt.Println()
+
+	// Start the workflow
+	// This is asynchronous - it returns immediately
+	run, err := orders.Start(ctx, c, taskQueue, order)
+	if errors.Is(err, orders.ErrAlreadyStarted) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("%w (are the CustomerID, OrderTotal and Status search attributes registered?)", err)
+	}
+	printRun(run)
+
+	if !*wait {
+		fmt.Printf("Follow it with: orders status %s\n", order.OrderID)
+		return nil
+	}
+
+	// Wait for workflow to complete (blocking)
+	fmt.Println("Waiting for workflow to complete...")
+	var result string
+	if err := run.Get(ctx, &result); err != nil {
+		fmt.Println()
+		fmt.Println("=== Workflow Failed ===")
+		fmt.Printf("Error: %v\n", err)
+		fmt.Printf("Retry it with: orders retry %s\n", order.OrderID)
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Println("=== Workflow Completed Successfully ===")
+	fmt.Printf("Final Status: %s\n", result)
+	return nil
+}
+
+// statusCmd looks up an order by order ID and prints its step history
+func statusCmd(ctx context.Context, c client.Client, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	values, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	details, err := orders.Get(ctx, c, values[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Order ID: %s\n", details.OrderID)
+	fmt.Printf("Status: %s (workflow %s)\n", details.Status, details.ExecutionStatus)
+	fmt.Printf("Workflow: %s/%s\n", details.WorkflowID, details.RunID)
+	fmt.Println("Steps:")
+	for _, step := range details.Steps {
+		fmt.Printf("  %s  %-16s %-10s %s\n", step.Time.Format(time.RFC3339), step.Step, step.Status, step.Message)
+	}
+	return nil
+}
+
+// cancelCmd sends the cancel-order signal, which triggers saga compensation
+func cancelCmd(ctx context.Context, c client.Client, args []string) error {
+	fs := flag.NewFlagSet("cancel", flag.ExitOnError)
+	reason := fs.String("reason", "", "Cancellation reason")
+	values, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if err := orders.Cancel(ctx, c, values[0], *reason); err != nil {
+		return fmt.Errorf("cancel signal failed: %w", err)
+	}
+	fmt.Printf("Cancel requested for %s\n", values[0])
+	return nil
+}
+
+// listCmd lists orders through their search attributes
+func listCmd(ctx context.Context, c client.Client, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	var filter orders.Filter
+	fs.StringVar(&filter.CustomerID, "customer", "", "Only orders of this customer")
+	fs.StringVar(&filter.Status, "status", "", "Only orders with this status (completed, payment_failed, ...)")
+	fs.Float64Var(&filter.MinTotal, "min-total", 0, "Only orders of at least this amount")
+	fs.BoolVar(&filter.Running, "running", false, "Only orders still in progress")
+	fs.IntVar(&filter.Limit, "limit", 20, "Maximum number of orders")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	summaries, err := orders.List(ctx, c, filter)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ORDER ID\tCUSTOMER\tTOTAL\tSTATUS\tWORKFLOW\tSTARTED")
+	for _, s := range summaries {
+		fmt.Fprintf(tw, "%s\t%s\t%.2f\t%s\t%s\t%s\n",
+			s.OrderID, s.CustomerID, s.OrderTotal, s.Status, s.ExecutionStatus, s.StartTime.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+// retryCmd runs a failed order again with its original input
+func retryCmd(ctx context.Context, c client.Client, args []string) error {
+	fs := flag.NewFlagSet("retry", flag.ExitOnError)
+	values, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	run, err := orders.Retry(ctx, c, taskQueue, values[0])
+	if errors.Is(err, orders.ErrNotRetryable) {
+		return fmt.Errorf("%w; only failed, timed out or terminated orders can be retried", err)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Retrying order %s\n", values[0])
+	printRun(run)
+	return nil
+}
+
+func printRun(run client.WorkflowRun) {
+	fmt.Printf("Started workflow\n")
+	fmt.Printf("  Workflow ID: %s\n", run.GetID())
+	fmt.Printf("  Run ID: %s\n", run.GetRunID())
+	fmt.Println("View workflow execution in Temporal UI:")
+	fmt.Printf("  http://localhost:8233/namespaces/default/workflows/%s/%s\n", run.GetID(), run.GetRunID())
+	fmt.Println()
+}
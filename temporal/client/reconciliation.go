@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"temporal/models"
+	"temporal/workflows"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// reconcileCmd starts the ReconciliationWorkflow, or shows it with -status
+// There is one reconciliation per namespace; starting it again returns the running one
+func reconcileCmd(ctx context.Context, c client.Client, args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	interval := fs.Duration("interval", 15*time.Minute, "Time between reconciliation passes")
+	grace := fs.Duration("grace", time.Hour, "Leave orders alone for this long after their last payment or inventory change")
+	lookback := fs.Duration("lookback", 24*time.Hour, "How far back the first pass looks")
+	status := fs.Bool("status", false, "Show the running reconciliation instead of starting it")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *status {
+		return reconciliationStatus(ctx, c)
+	}
+
+	run, err := c.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:        workflows.ReconciliationWorkflowID,
+		TaskQueue: taskQueue,
+	}, workflows.ReconciliationWorkflow, models.ReconciliationRequest{
+		Interval: *interval,
+		Grace:    *grace,
+		Lookback: *lookback,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to start reconciliation: %w", err)
+	}
+	fmt.Printf("Reconciling payments and inventory every %s\n", *interval)
+	printRun(run)
+	fmt.Println("Follow it with: orders reconcile -status")
+	return nil
+}
+
+func reconciliationStatus(ctx context.Context, c client.Client) error {
+	value, err := c.QueryWorkflow(ctx, workflows.ReconciliationWorkflowID, "", workflows.QueryGetReconciliation)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	var status models.ReconciliationStatus
+	if err := value.Get(&status); err != nil {
+		return fmt.Errorf("decode query result: %w", err)
+	}
+
+	fmt.Printf("Reconciled up to: %s\n", status.Checkpoint.Format(time.RFC3339))
+	fmt.Printf("Passes: %d  repaired: %d  manual reviews: %d\n", status.Passes, status.Repaired, status.Reviews)
+	if !status.NextRunAt.IsZero() {
+		fmt.Printf("Next pass: %s\n", status.NextRunAt.Format(time.RFC3339))
+	}
+	if status.LastError != "" {
+		fmt.Printf("Last pass failed: %s\n", status.LastError)
+	}
+	if pass := status.LastPass; pass != nil {
+		fmt.Printf("Last pass: %d orders checked, %d repaired, %d manual reviews\n", pass.Checked, pass.Repaired, pass.Reviews)
+		for _, o := range pass.Outcomes {
+			fmt.Printf("  %-20s %-32s %-14s %s\n", o.Case.OrderID, o.Case.Issue, o.Result, o.Case.Detail)
+		}
+	}
+	return nil
+}
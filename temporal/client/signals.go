@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"temporal/models"
+	"temporal/workflows"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// shipToCmd sends the update-shipping signal
+// The workflow rejects it once fulfillment has started; check the status afterwards
+func shipToCmd(ctx context.Context, c client.Client, args []string) error {
+	fs := flag.NewFlagSet("ship-to", flag.ExitOnError)
+	values, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	orderID, shipTo := values[0], values[1]
+
+	parts := strings.Split(shipTo, ",")
+	if len(parts) != 4 {
+		return fmt.Errorf("address must be \"street,city,postal code,country\"")
+	}
+	address := models.ShippingAddress{
+		Street:     strings.TrimSpace(parts[0]),
+		City:       strings.TrimSpace(parts[1]),
+		PostalCode: strings.TrimSpace(parts[2]),
+		Country:    strings.TrimSpace(parts[3]),
+	}
+
+	err = c.SignalWorkflow(ctx, workflows.OrderWorkflowID(orderID), "", workflows.SignalUpdateShipping, models.UpdateShippingRequest{Address: address})
+	if err != nil {
+		return fmt.Errorf("update-shipping signal failed: %w", err)
+	}
+	fmt.Printf("Shipping address update sent to %s\n", orderID)
+	return nil
+}
+
+// approvalCmd sends a reviewer's approval-decision signal to an order waiting for approval
+func approvalCmd(approved bool) func(ctx context.Context, c client.Client, args []string) error {
+	name := "reject"
+	if approved {
+		name = "approve"
+	}
+	return func(ctx context.Context, c client.Client, args []string) error {
+		fs := flag.NewFlagSet(name, flag.ExitOnError)
+		reviewer := fs.String("reviewer", "", "Reviewer name")
+		comment := fs.String("comment", "", "Reviewer comment")
+		values, err := parseArgs(fs, args, 1)
+		if err != nil {
+			return err
+		}
+		if *reviewer == "" {
+			return fmt.Errorf("-reviewer is required")
+		}
+
+		decision := models.ApprovalDecision{Approved: approved, Reviewer: *reviewer, Comment: *comment}
+		err = c.SignalWorkflow(ctx, workflows.OrderWorkflowID(values[0]), "", workflows.SignalApprovalDecision, decision)
+		if err != nil {
+			return fmt.Errorf("approval signal failed: %w", err)
+		}
+		fmt.Printf("Approval decision (approved=%t) sent to %s\n", approved, values[0])
+		return nil
+	}
+}
+
+// trackCmd simulates a carrier webhook by signalling the fulfillment child workflow
+func trackCmd(ctx context.Context, c client.Client, args []string) error {
+	fs := flag.NewFlagSet("track", flag.ExitOnError)
+	location := fs.String("location", "", "Parcel location")
+	values, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	orderID, status := values[0], values[1]
+
+	update := models.TrackingUpdate{Status: status, Location: *location, Time: time.Now()}
+	err = c.SignalWorkflow(ctx, fmt.Sprintf("fulfillment-%s", orderID), "", workflows.SignalTrackingUpdate, update)
+	if err != nil {
+		return fmt.Errorf("tracking signal failed: %w", err)
+	}
+	fmt.Printf("Tracking update %q sent to the shipment of %s\n", status, orderID)
+	return nil
+}
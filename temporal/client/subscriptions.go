@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"temporal/models"
+	"temporal/workflows"
+	"time"
+
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+)
+
+// subscriptionSignals maps the actions of "orders subscription" to their signals
+var subscriptionSignals = map[string]string{
+	"pause":  workflows.SignalPauseSubscription,
+	"resume": workflows.SignalResumeSubscription,
+	"skip":   workflows.SignalSkipNextOrder,
+	"cancel": workflows.SignalCancelSubscription,
+}
+
+// subscribeCmd starts a SubscriptionWorkflow placing the sample order on a schedule
+func subscribeCmd(ctx context.Context, c client.Client, args []string) error {
+	fs := flag.NewFlagSet("subscribe", flag.ExitOnError)
+	subscriptionID := fs.String("id", "", "Subscription ID (default: generated)")
+	customerID := fs.String("customer", "customer-123", "Customer of the subscription")
+	schedule := fs.String("schedule", "@monthly", `Cron schedule ("0 9 * * MON", "@weekly", "@every 24h")`)
+	maxFailures := fs.Int("max-payment-failures", 3, "Payment failures in a row before the subscription stops")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *subscriptionID == "" {
+		*subscriptionID = fmt.Sprintf("sub-%s", uuid.New().String()[:8])
+	}
+
+	order := sampleOrder(*customerID)
+	run, err := c.ExecuteWorkflow(ctx, client.StartWorkflowOptions{
+		ID:        workflows.SubscriptionWorkflowID(*subscriptionID),
+		TaskQueue: taskQueue,
+	}, workflows.SubscriptionWorkflow, models.SubscriptionRequest{
+		SubscriptionID:     *subscriptionID,
+		CustomerID:         *customerID,
+		Items:              order.Items,
+		ShippingAddress:    order.ShippingAddress,
+		Schedule:           *schedule,
+		MaxPaymentFailures: *maxFailures,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to start subscription: %w", err)
+	}
+
+	fmt.Printf("Started subscription %s for %s (%s)\n", *subscriptionID, *customerID, *schedule)
+	printRun(run)
+	fmt.Printf("Follow it with: orders subscription %s\n", *subscriptionID)
+	return nil
+}
+
+// subscriptionCmd shows a subscription, or pauses, resumes, skips or cancels it
+func subscriptionCmd(ctx context.Context, c client.Client, args []string) error {
+	fs := flag.NewFlagSet("subscription", flag.ExitOnError)
+	reason := fs.String("reason", "", "Reason for the change")
+	values, err := parseArgs(fs, args, 1)
+	if err != nil {
+		values, err = parseArgs(fs, args, 2)
+	}
+	if err != nil {
+		return err
+	}
+	workflowID := workflows.SubscriptionWorkflowID(values[0])
+
+	if len(values) == 2 {
+		signal, ok := subscriptionSignals[values[1]]
+		if !ok {
+			return fmt.Errorf("unknown action %q; use pause, resume, skip or cancel", values[1])
+		}
+		err := c.SignalWorkflow(ctx, workflowID, "", signal, models.SubscriptionChange{Reason: *reason})
+		if err != nil {
+			return fmt.Errorf("%s signal failed: %w", signal, err)
+		}
+		fmt.Printf("%s sent to subscription %s\n", signal, values[0])
+		return nil
+	}
+
+	value, err := c.QueryWorkflow(ctx, workflowID, "", workflows.QueryGetSubscription)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	var status models.SubscriptionStatus
+	if err := value.Get(&status); err != nil {
+		return fmt.Errorf("decode query result: %w", err)
+	}
+
+	fmt.Printf("Subscription: %s (customer %s, %s)\n", status.SubscriptionID, status.CustomerID, status.Schedule)
+	fmt.Printf("Status: %s %s\n", status.Status, status.Reason)
+	if !status.NextRunAt.IsZero() {
+		next := status.NextRunAt.Format(time.RFC3339)
+		if status.SkipNext {
+			next += " (skipped)"
+		}
+		fmt.Printf("Next order: %s\n", next)
+	}
+	fmt.Printf("Orders placed: %d  payment failures in a row: %d\n", status.OrdersCreated, status.PaymentFailures)
+	for _, o := range status.Orders {
+		fmt.Printf("  %s  %-20s %-14s %s\n", o.ScheduledAt.Format(time.RFC3339), o.OrderID, o.Status, o.Error)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/api/temporalproto"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal/workflows"
+)
+
+/*
+WORKFLOW HISTORIES:
+
+Records the event history of a finished workflow as JSON, so it can be
+replayed against new workflow code by the replay test in workflows/:
+
+  go run ./history record -workflow-id order-1234
+  go run ./history record -workflow-id order-1234 -out workflows/testdata/histories/order_1234.json
+
+Record one history per path through the workflow you want to protect
+(completed, payment failed, cancelled, ...).
+
+Replays recorded histories against the current workflow code and fails on
+any non-deterministic change; CI runs it on every push:
+
+  go run ./history replay
+  go run ./history replay -dir path/to/histories
+  go run ./history replay -workflow-id order-1234   (straight from the server)
+*/
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "record":
+		record(os.Args[2:])
+	case "replay":
+		replay(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: history record -workflow-id ID [-run-id ID] [-out FILE]")
+	fmt.Fprintln(os.Stderr, "       history replay [-dir DIR] [-workflow-id ID [-run-id ID]]")
+	os.Exit(2)
+}
+
+func record(args []string) {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	hostPort := fs.String("address", "localhost:7233", "Temporal server address")
+	namespace := fs.String("namespace", "default", "Temporal namespace")
+	workflowID := fs.String("workflow-id", "", "Workflow ID to record")
+	runID := fs.String("run-id", "", "Run ID (default: latest run)")
+	out := fs.String("out", "", "Output file (default: workflows/testdata/histories/<workflow-id>.json)")
+	fs.Parse(args)
+
+	if *workflowID == "" {
+		usage()
+	}
+	if *out == "" {
+		*out = filepath.Join("workflows", "testdata", "histories", *workflowID+".json")
+	}
+
+	c, err := client.Dial(client.Options{HostPort: *hostPort, Namespace: *namespace})
+	if err != nil {
+		log.Fatalln("Unable to create Temporal client", err)
+	}
+	defer c.Close()
+
+	history := &historypb.History{}
+	iter := c.GetWorkflowHistory(context.Background(), *workflowID, *runID, false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			log.Fatalln("Unable to read workflow history", err)
+		}
+		history.Events = append(history.Events, event)
+	}
+
+	data, err := temporalproto.CustomJSONMarshalOptions{Indent: "  "}.Marshal(history)
+	if err != nil {
+		log.Fatalln("Unable to encode workflow history", err)
+	}
+	if err := os.WriteFile(*out, append(data, '\n'), 0o644); err != nil {
+		log.Fatalln("Unable to write workflow history", err)
+	}
+
+	log.Printf("Recorded %d events of %s to %s", len(history.Events), *workflowID, *out)
+}
+
+func replay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	dir := fs.String("dir", filepath.Join("workflows", "testdata", "histories"), "Directory of recorded histories")
+	hostPort := fs.String("address", "localhost:7233", "Temporal server address")
+	namespace := fs.String("namespace", "default", "Temporal namespace")
+	workflowID := fs.String("workflow-id", "", "Replay this workflow from the server instead of -dir")
+	runID := fs.String("run-id", "", "Run ID (default: latest run)")
+	fs.Parse(args)
+
+	replayer := worker.NewWorkflowReplayer()
+	workflows.RegisterWorkflows(replayer)
+
+	// Replay a single execution straight from the server
+	if *workflowID != "" {
+		c, err := client.Dial(client.Options{HostPort: *hostPort, Namespace: *namespace})
+		if err != nil {
+			log.Fatalln("Unable to create Temporal client", err)
+		}
+		defer c.Close()
+
+		execution := workflow.Execution{ID: *workflowID, RunID: *runID}
+		if err := replayer.ReplayWorkflowExecution(context.Background(), c.WorkflowService(), nil, *namespace, execution); err != nil {
+			log.Fatalf("FAIL %s: %v", *workflowID, err)
+		}
+		log.Printf("ok   %s", *workflowID)
+		return
+	}
+
+	files, err := filepath.Glob(filepath.Join(*dir, "*.json"))
+	if err != nil {
+		log.Fatalln("Unable to list histories", err)
+	}
+	if len(files) == 0 {
+		log.Fatalln("No histories found in", *dir)
+	}
+
+	failed := 0
+	for _, file := range files {
+		if err := replayer.ReplayWorkflowHistoryFromJSONFile(nil, file); err != nil {
+			failed++
+			fmt.Printf("FAIL %s\n     %v\n", file, err)
+			continue
+		}
+		fmt.Printf("ok   %s\n", file)
+	}
+
+	if failed > 0 {
+		fmt.Printf("%d of %d histories failed to replay\n", failed, len(files))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,144 @@
+// Package inventory keeps per-product stock, time-limited reservations and
+// a ledger of every stock movement. It backs the ReserveInventory and
+// CompensateInventory activities.
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"temporal/models"
+	"time"
+)
+
+// Reservation statuses
+const (
+	StatusReserved  = "reserved"
+	StatusConfirmed = "confirmed"
+	StatusReleased  = "released"
+	StatusExpired   = "expired"
+)
+
+// Ledger operations
+const (
+	OpRestock = "restock"
+	OpReserve = "reserve"
+	OpConfirm = "confirm"
+	OpRelease = "release"
+	OpReturn  = "return"
+	OpExpire  = "expire"
+	OpCount   = "count"
+)
+
+var (
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrUnknownProduct      = errors.New("unknown product")
+)
+
+// InsufficientStockError is returned when a product can't cover a reservation
+type InsufficientStockError struct {
+	ProductID string
+	Requested int
+	Available int
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("product %s is out of stock: requested %d, available %d", e.ProductID, e.Requested, e.Available)
+}
+
+// StateError is returned when a reservation can't move to the requested state,
+// e.g. confirming a reservation that already expired
+type StateError struct {
+	ReservationID string
+	Status        string
+	Operation     string
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("cannot %s reservation %s in status %s", e.Operation, e.ReservationID, e.Status)
+}
+
+// StockLevel is the stock of one product
+// OnHand counts physical units; Reserved are held for orders but not shipped yet
+type StockLevel struct {
+	ProductID string
+	OnHand    int
+	Reserved  int
+}
+
+func (s StockLevel) Available() int {
+	return s.OnHand - s.Reserved
+}
+
+// Reservation holds stock for one order until it is confirmed, released or expires
+type Reservation struct {
+	ReservationID string
+	OrderID       string
+	Items         []models.OrderItem
+	Status        string
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// LedgerEntry records one stock movement
+type LedgerEntry struct {
+	ID            int64
+	ProductID     string
+	OrderID       string
+	ReservationID string
+	Operation     string
+	Quantity      int
+	CreatedAt     time.Time
+}
+
+// Store is the inventory service
+//
+// Every operation is idempotent so it can be called from retried activities:
+//   - Reserve returns the existing active reservation for an order instead of reserving twice
+//   - Confirm and Release on a reservation already in the target state are no-ops
+type Store interface {
+	// Restock adds units to a product, creating it if needed
+	Restock(ctx context.Context, productID string, quantity int) error
+	// SetOnHand sets the physical units of a product to a counted value, creating it if needed
+	// The ledger records the difference; setting the same count again changes nothing
+	SetOnHand(ctx context.Context, productID string, onHand int) error
+	Stock(ctx context.Context, productID string) (StockLevel, error)
+
+	// Reserve holds stock for all items of an order, or none of them
+	Reserve(ctx context.Context, orderID string, items []models.OrderItem, ttl time.Duration) (Reservation, error)
+	// Confirm turns a reservation into a shipment: the units leave OnHand
+	Confirm(ctx context.Context, reservationID string) (Reservation, error)
+	// Release returns reserved units, or restocks confirmed units (a returned parcel)
+	Release(ctx context.Context, reservationID string) (Reservation, error)
+	GetReservation(ctx context.Context, reservationID string) (Reservation, error)
+	GetReservationByOrder(ctx context.Context, orderID string) (Reservation, error)
+	// ListReservations returns the reservations last updated in [after, before), oldest first
+	ListReservations(ctx context.Context, after, before time.Time) ([]Reservation, error)
+
+	// ExpireReservations releases reservations that were not confirmed before their expiry
+	ExpireReservations(ctx context.Context, now time.Time) (int, error)
+
+	Ledger(ctx context.Context, productID string) ([]LedgerEntry, error)
+}
+
+// ReservationIDForOrder is the deterministic reservation ID of an order
+func ReservationIDForOrder(orderID string) string {
+	return "res-" + orderID
+}
+
+// mergeItems sums quantities per product so an order listing a product twice reserves once
+func mergeItems(items []models.OrderItem) ([]string, map[string]int, error) {
+	var products []string
+	quantities := make(map[string]int)
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, nil, fmt.Errorf("invalid quantity %d for product %s", item.Quantity, item.ProductID)
+		}
+		if _, ok := quantities[item.ProductID]; !ok {
+			products = append(products, item.ProductID)
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+	return products, quantities, nil
+}
@@ -0,0 +1,259 @@
+package inventory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"temporal/models"
+	"time"
+)
+
+// MemoryStore is an in-memory Store for local runs and tests
+type MemoryStore struct {
+	mu           sync.Mutex
+	stock        map[string]*StockLevel
+	reservations map[string]*Reservation // by reservation ID
+	ledger       []LedgerEntry
+	now          func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		stock:        make(map[string]*StockLevel),
+		reservations: make(map[string]*Reservation),
+		now:          time.Now,
+	}
+}
+
+func (s *MemoryStore) Restock(_ context.Context, productID string, quantity int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	level, ok := s.stock[productID]
+	if !ok {
+		level = &StockLevel{ProductID: productID}
+		s.stock[productID] = level
+	}
+	level.OnHand += quantity
+	s.record(productID, "", "", OpRestock, quantity)
+	return nil
+}
+
+func (s *MemoryStore) SetOnHand(_ context.Context, productID string, onHand int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	level, ok := s.stock[productID]
+	if !ok {
+		level = &StockLevel{ProductID: productID}
+		s.stock[productID] = level
+	}
+	if delta := onHand - level.OnHand; delta != 0 {
+		level.OnHand = onHand
+		s.record(productID, "", "", OpCount, delta)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Stock(_ context.Context, productID string) (StockLevel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	level, ok := s.stock[productID]
+	if !ok {
+		return StockLevel{}, ErrUnknownProduct
+	}
+	return *level, nil
+}
+
+func (s *MemoryStore) Reserve(_ context.Context, orderID string, items []models.OrderItem, ttl time.Duration) (Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := ReservationIDForOrder(orderID)
+	existing, ok := s.reservations[id]
+	if ok && (existing.Status == StatusReserved || existing.Status == StatusConfirmed) {
+		return copyReservation(existing), nil
+	}
+
+	products, quantities, err := mergeItems(items)
+	if err != nil {
+		return Reservation{}, err
+	}
+	for _, productID := range products {
+		level, ok := s.stock[productID]
+		if !ok {
+			return Reservation{}, &InsufficientStockError{ProductID: productID, Requested: quantities[productID]}
+		}
+		if level.Available() < quantities[productID] {
+			return Reservation{}, &InsufficientStockError{ProductID: productID, Requested: quantities[productID], Available: level.Available()}
+		}
+	}
+
+	now := s.now()
+	for _, productID := range products {
+		s.stock[productID].Reserved += quantities[productID]
+		s.record(productID, orderID, id, OpReserve, quantities[productID])
+	}
+
+	reservation := &Reservation{
+		ReservationID: id,
+		OrderID:       orderID,
+		Items:         append([]models.OrderItem(nil), items...),
+		Status:        StatusReserved,
+		ExpiresAt:     now.Add(ttl),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if ok {
+		reservation.CreatedAt = existing.CreatedAt
+	}
+	s.reservations[id] = reservation
+	return copyReservation(reservation), nil
+}
+
+func (s *MemoryStore) Confirm(_ context.Context, reservationID string) (Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reservation, ok := s.reservations[reservationID]
+	if !ok {
+		return Reservation{}, ErrReservationNotFound
+	}
+	switch reservation.Status {
+	case StatusConfirmed:
+		return copyReservation(reservation), nil
+	case StatusReserved:
+	default:
+		return Reservation{}, &StateError{ReservationID: reservationID, Status: reservation.Status, Operation: OpConfirm}
+	}
+
+	_, quantities, _ := mergeItems(reservation.Items)
+	for productID, quantity := range quantities {
+		level := s.stock[productID]
+		level.Reserved -= quantity
+		level.OnHand -= quantity
+		s.record(productID, reservation.OrderID, reservationID, OpConfirm, quantity)
+	}
+	reservation.Status = StatusConfirmed
+	reservation.UpdatedAt = s.now()
+	return copyReservation(reservation), nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, reservationID string) (Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reservation, ok := s.reservations[reservationID]
+	if !ok {
+		return Reservation{}, ErrReservationNotFound
+	}
+	s.release(reservation, OpRelease)
+	return copyReservation(reservation), nil
+}
+
+// release must be called with s.mu held
+func (s *MemoryStore) release(reservation *Reservation, op string) {
+	_, quantities, _ := mergeItems(reservation.Items)
+	switch reservation.Status {
+	case StatusReserved:
+		for productID, quantity := range quantities {
+			s.stock[productID].Reserved -= quantity
+			s.record(productID, reservation.OrderID, reservation.ReservationID, op, quantity)
+		}
+	case StatusConfirmed:
+		for productID, quantity := range quantities {
+			s.stock[productID].OnHand += quantity
+			s.record(productID, reservation.OrderID, reservation.ReservationID, OpReturn, quantity)
+		}
+	default:
+		return
+	}
+
+	reservation.Status = StatusReleased
+	if op == OpExpire {
+		reservation.Status = StatusExpired
+	}
+	reservation.UpdatedAt = s.now()
+}
+
+func (s *MemoryStore) GetReservation(_ context.Context, reservationID string) (Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reservation, ok := s.reservations[reservationID]
+	if !ok {
+		return Reservation{}, ErrReservationNotFound
+	}
+	return copyReservation(reservation), nil
+}
+
+func (s *MemoryStore) GetReservationByOrder(ctx context.Context, orderID string) (Reservation, error) {
+	return s.GetReservation(ctx, ReservationIDForOrder(orderID))
+}
+
+func (s *MemoryStore) ListReservations(_ context.Context, after, before time.Time) ([]Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var reservations []Reservation
+	for _, reservation := range s.reservations {
+		if !reservation.UpdatedAt.Before(after) && reservation.UpdatedAt.Before(before) {
+			reservations = append(reservations, copyReservation(reservation))
+		}
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		if !reservations[i].UpdatedAt.Equal(reservations[j].UpdatedAt) {
+			return reservations[i].UpdatedAt.Before(reservations[j].UpdatedAt)
+		}
+		return reservations[i].ReservationID < reservations[j].ReservationID
+	})
+	return reservations, nil
+}
+
+func (s *MemoryStore) ExpireReservations(_ context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := 0
+	for _, reservation := range s.reservations {
+		if reservation.Status == StatusReserved && !reservation.ExpiresAt.After(now) {
+			s.release(reservation, OpExpire)
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+func (s *MemoryStore) Ledger(_ context.Context, productID string) ([]LedgerEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []LedgerEntry
+	for _, entry := range s.ledger {
+		if entry.ProductID == productID {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+// record must be called with s.mu held
+func (s *MemoryStore) record(productID, orderID, reservationID, op string, quantity int) {
+	s.ledger = append(s.ledger, LedgerEntry{
+		ID:            int64(len(s.ledger) + 1),
+		ProductID:     productID,
+		OrderID:       orderID,
+		ReservationID: reservationID,
+		Operation:     op,
+		Quantity:      quantity,
+		CreatedAt:     s.now(),
+	})
+}
+
+func copyReservation(r *Reservation) Reservation {
+	c := *r
+	c.Items = append([]models.OrderItem(nil), r.Items...)
+	return c
+}
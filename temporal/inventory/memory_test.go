@@ -0,0 +1,14 @@
+package inventory
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T, now func() time.Time) Store {
+		store := NewMemoryStore()
+		store.now = now
+		return store
+	})
+}
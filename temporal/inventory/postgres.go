@@ -0,0 +1,471 @@
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"temporal/models"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// PostgresStore keeps stock, reservations and the ledger in Postgres
+// Every change runs in one transaction that locks the touched stock rows
+// (in product order, so concurrent reservations can't deadlock)
+type PostgresStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// statusClaimed marks a reservation row inserted by Reserve before the stock
+// is reserved; it never outlives the transaction that inserted it
+const statusClaimed = "claimed"
+
+// OpenPostgres connects with the pgx driver, e.g.
+// "host=localhost user=temporal password=temporal dbname=inventory sslmode=disable"
+func OpenPostgres(dsn string) (*PostgresStore, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("inventory: open %w", err)
+	}
+	return NewPostgresStore(db), nil
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db, now: time.Now}
+}
+
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+// Migrate creates the inventory tables if they do not exist
+func (s *PostgresStore) Migrate(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS inventory_stock (
+		product_id VARCHAR(255) PRIMARY KEY,
+		on_hand INTEGER NOT NULL DEFAULT 0,
+		reserved INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0)
+	);
+
+	CREATE TABLE IF NOT EXISTS inventory_reservations (
+		reservation_id VARCHAR(255) PRIMARY KEY,
+		order_id VARCHAR(255) NOT NULL UNIQUE,
+		items JSONB NOT NULL,
+		status VARCHAR(32) NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS inventory_reservations_expiry
+		ON inventory_reservations (expires_at) WHERE status = 'reserved';
+
+	CREATE INDEX IF NOT EXISTS inventory_reservations_updated
+		ON inventory_reservations (updated_at);
+
+	CREATE TABLE IF NOT EXISTS inventory_ledger (
+		id BIGSERIAL PRIMARY KEY,
+		product_id VARCHAR(255) NOT NULL,
+		order_id VARCHAR(255) NOT NULL DEFAULT '',
+		reservation_id VARCHAR(255) NOT NULL DEFAULT '',
+		operation VARCHAR(32) NOT NULL,
+		quantity INTEGER NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS inventory_ledger_product ON inventory_ledger (product_id, id);
+	`
+	_, err := s.db.ExecContext(ctx, query)
+	return err
+}
+
+func (s *PostgresStore) Restock(ctx context.Context, productID string, quantity int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		query := `
+		INSERT INTO inventory_stock (product_id, on_hand)
+		VALUES ($1, $2)
+		ON CONFLICT (product_id) DO UPDATE
+		SET on_hand = inventory_stock.on_hand + EXCLUDED.on_hand
+		`
+		if _, err := tx.ExecContext(ctx, query, productID, quantity); err != nil {
+			return err
+		}
+		return recordTx(ctx, tx, productID, "", "", OpRestock, quantity)
+	})
+}
+
+func (s *PostgresStore) SetOnHand(ctx context.Context, productID string, onHand int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		var current int
+		err := tx.QueryRowContext(ctx, `SELECT on_hand FROM inventory_stock WHERE product_id = $1 FOR UPDATE`, productID).Scan(&current)
+		switch {
+		case err == sql.ErrNoRows:
+			_, err = tx.ExecContext(ctx, `INSERT INTO inventory_stock (product_id, on_hand) VALUES ($1, $2)`, productID, onHand)
+		case err == nil && current != onHand:
+			_, err = tx.ExecContext(ctx, `UPDATE inventory_stock SET on_hand = $2 WHERE product_id = $1`, productID, onHand)
+		}
+		if err != nil || current == onHand {
+			return err
+		}
+		return recordTx(ctx, tx, productID, "", "", OpCount, onHand-current)
+	})
+}
+
+func (s *PostgresStore) Stock(ctx context.Context, productID string) (StockLevel, error) {
+	query := `SELECT product_id, on_hand, reserved FROM inventory_stock WHERE product_id = $1`
+	level := StockLevel{}
+	err := s.db.QueryRowContext(ctx, query, productID).Scan(&level.ProductID, &level.OnHand, &level.Reserved)
+	if err == sql.ErrNoRows {
+		return StockLevel{}, ErrUnknownProduct
+	}
+	return level, err
+}
+
+func (s *PostgresStore) Reserve(ctx context.Context, orderID string, items []models.OrderItem, ttl time.Duration) (Reservation, error) {
+	var reservation Reservation
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		id := ReservationIDForOrder(orderID)
+		now := s.now().UTC()
+
+		// Claim the reservation row before touching stock: a concurrent first
+		// reservation of the same order waits on the primary key until this
+		// transaction ends, then finds the reservation made here. The claim is
+		// only ever committed together with the reserved stock.
+		claim := `
+		INSERT INTO inventory_reservations (reservation_id, order_id, items, status, expires_at, created_at, updated_at)
+		VALUES ($1, $2, '[]', $3, $4, $4, $4)
+		ON CONFLICT DO NOTHING
+		`
+		if _, err := tx.ExecContext(ctx, claim, id, orderID, statusClaimed, now); err != nil {
+			return err
+		}
+		existing, err := getReservationTx(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+		if existing.Status == StatusReserved || existing.Status == StatusConfirmed {
+			reservation = existing
+			return nil
+		}
+
+		products, quantities, err := mergeItems(items)
+		if err != nil {
+			return err
+		}
+		levels, err := lockStockTx(ctx, tx, products)
+		if err != nil {
+			return err
+		}
+		for _, productID := range products {
+			level, ok := levels[productID]
+			if !ok {
+				return &InsufficientStockError{ProductID: productID, Requested: quantities[productID]}
+			}
+			if level.Available() < quantities[productID] {
+				return &InsufficientStockError{ProductID: productID, Requested: quantities[productID], Available: level.Available()}
+			}
+		}
+
+		for _, productID := range products {
+			if _, err := tx.ExecContext(ctx, `UPDATE inventory_stock SET reserved = reserved + $2 WHERE product_id = $1`,
+				productID, quantities[productID]); err != nil {
+				return err
+			}
+			if err := recordTx(ctx, tx, productID, orderID, id, OpReserve, quantities[productID]); err != nil {
+				return err
+			}
+		}
+
+		// a released or expired reservation of a retried order is reserved again
+		reservation = Reservation{
+			ReservationID: id,
+			OrderID:       orderID,
+			Items:         append([]models.OrderItem(nil), items...),
+			Status:        StatusReserved,
+			ExpiresAt:     now.Add(ttl),
+			CreatedAt:     existing.CreatedAt,
+			UpdatedAt:     now,
+		}
+		itemsJSON, err := json.Marshal(reservation.Items)
+		if err != nil {
+			return err
+		}
+		query := `
+		UPDATE inventory_reservations
+		SET items = $2, status = $3, expires_at = $4, updated_at = $5
+		WHERE reservation_id = $1
+		`
+		_, err = tx.ExecContext(ctx, query, reservation.ReservationID, itemsJSON,
+			reservation.Status, reservation.ExpiresAt, reservation.UpdatedAt)
+		return err
+	})
+	return reservation, err
+}
+
+func (s *PostgresStore) Confirm(ctx context.Context, reservationID string) (Reservation, error) {
+	var reservation Reservation
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		reservation, err = getReservationTx(ctx, tx, reservationID, true)
+		if err != nil {
+			return err
+		}
+		switch reservation.Status {
+		case StatusConfirmed:
+			return nil
+		case StatusReserved:
+		default:
+			return &StateError{ReservationID: reservationID, Status: reservation.Status, Operation: OpConfirm}
+		}
+
+		products, quantities, _ := mergeItems(reservation.Items)
+		if _, err := lockStockTx(ctx, tx, products); err != nil {
+			return err
+		}
+		for _, productID := range products {
+			if _, err := tx.ExecContext(ctx, `UPDATE inventory_stock SET reserved = reserved - $2, on_hand = on_hand - $2 WHERE product_id = $1`,
+				productID, quantities[productID]); err != nil {
+				return err
+			}
+			if err := recordTx(ctx, tx, productID, reservation.OrderID, reservationID, OpConfirm, quantities[productID]); err != nil {
+				return err
+			}
+		}
+		return setStatusTx(ctx, tx, &reservation, StatusConfirmed, s.now())
+	})
+	return reservation, err
+}
+
+func (s *PostgresStore) Release(ctx context.Context, reservationID string) (Reservation, error) {
+	var reservation Reservation
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		reservation, err = getReservationTx(ctx, tx, reservationID, true)
+		if err != nil {
+			return err
+		}
+		return releaseTx(ctx, tx, &reservation, OpRelease, s.now())
+	})
+	return reservation, err
+}
+
+func (s *PostgresStore) GetReservation(ctx context.Context, reservationID string) (Reservation, error) {
+	return getReservationTx(ctx, s.db, reservationID, false)
+}
+
+func (s *PostgresStore) GetReservationByOrder(ctx context.Context, orderID string) (Reservation, error) {
+	return s.GetReservation(ctx, ReservationIDForOrder(orderID))
+}
+
+func (s *PostgresStore) ListReservations(ctx context.Context, after, before time.Time) ([]Reservation, error) {
+	query := `
+	SELECT reservation_id, order_id, items, status, expires_at, created_at, updated_at
+	FROM inventory_reservations
+	WHERE updated_at >= $1 AND updated_at < $2
+	ORDER BY updated_at, reservation_id
+	`
+	rows, err := s.db.QueryContext(ctx, query, after.UTC(), before.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []Reservation
+	for rows.Next() {
+		var r Reservation
+		var itemsJSON []byte
+		if err := rows.Scan(&r.ReservationID, &r.OrderID, &itemsJSON, &r.Status, &r.ExpiresAt, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(itemsJSON, &r.Items); err != nil {
+			return nil, fmt.Errorf("decode reservation items: %w", err)
+		}
+		reservations = append(reservations, r)
+	}
+	return reservations, rows.Err()
+}
+
+func (s *PostgresStore) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	query := `
+	SELECT reservation_id FROM inventory_reservations
+	WHERE status = $1 AND expires_at <= $2
+	`
+	rows, err := s.db.QueryContext(ctx, query, StatusReserved, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// One transaction per reservation: a confirm racing with the sweep wins or loses cleanly
+	expired := 0
+	for _, id := range ids {
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			reservation, err := getReservationTx(ctx, tx, id, true)
+			if err != nil {
+				return err
+			}
+			if reservation.Status != StatusReserved || reservation.ExpiresAt.After(now) {
+				return nil
+			}
+			expired++
+			return releaseTx(ctx, tx, &reservation, OpExpire, s.now())
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+func (s *PostgresStore) Ledger(ctx context.Context, productID string) ([]LedgerEntry, error) {
+	query := `
+	SELECT id, product_id, order_id, reservation_id, operation, quantity, created_at
+	FROM inventory_ledger
+	WHERE product_id = $1
+	ORDER BY id
+	`
+	rows, err := s.db.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []LedgerEntry
+	for rows.Next() {
+		var e LedgerEntry
+		if err := rows.Scan(&e.ID, &e.ProductID, &e.OrderID, &e.ReservationID, &e.Operation, &e.Quantity, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *PostgresStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getReservationTx(ctx context.Context, q queryer, reservationID string, forUpdate bool) (Reservation, error) {
+	query := `
+	SELECT reservation_id, order_id, items, status, expires_at, created_at, updated_at
+	FROM inventory_reservations
+	WHERE reservation_id = $1
+	`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	var r Reservation
+	var itemsJSON []byte
+	err := q.QueryRowContext(ctx, query, reservationID).
+		Scan(&r.ReservationID, &r.OrderID, &itemsJSON, &r.Status, &r.ExpiresAt, &r.CreatedAt, &r.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return Reservation{}, ErrReservationNotFound
+	}
+	if err != nil {
+		return Reservation{}, err
+	}
+	if err := json.Unmarshal(itemsJSON, &r.Items); err != nil {
+		return Reservation{}, fmt.Errorf("decode reservation items: %w", err)
+	}
+	return r, nil
+}
+
+// lockStockTx locks the stock rows of products in a stable order and returns the known ones
+func lockStockTx(ctx context.Context, tx *sql.Tx, products []string) (map[string]StockLevel, error) {
+	sorted := append([]string(nil), products...)
+	sort.Strings(sorted)
+
+	levels := make(map[string]StockLevel, len(sorted))
+	for _, productID := range sorted {
+		level := StockLevel{}
+		err := tx.QueryRowContext(ctx, `SELECT product_id, on_hand, reserved FROM inventory_stock WHERE product_id = $1 FOR UPDATE`, productID).
+			Scan(&level.ProductID, &level.OnHand, &level.Reserved)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		levels[productID] = level
+	}
+	return levels, nil
+}
+
+func releaseTx(ctx context.Context, tx *sql.Tx, reservation *Reservation, op string, now time.Time) error {
+	products, quantities, _ := mergeItems(reservation.Items)
+
+	var update, entryOp string
+	switch reservation.Status {
+	case StatusReserved:
+		update, entryOp = `UPDATE inventory_stock SET reserved = reserved - $2 WHERE product_id = $1`, op
+	case StatusConfirmed:
+		update, entryOp = `UPDATE inventory_stock SET on_hand = on_hand + $2 WHERE product_id = $1`, OpReturn
+	default:
+		return nil
+	}
+
+	if _, err := lockStockTx(ctx, tx, products); err != nil {
+		return err
+	}
+	for _, productID := range products {
+		if _, err := tx.ExecContext(ctx, update, productID, quantities[productID]); err != nil {
+			return err
+		}
+		if err := recordTx(ctx, tx, productID, reservation.OrderID, reservation.ReservationID, entryOp, quantities[productID]); err != nil {
+			return err
+		}
+	}
+
+	status := StatusReleased
+	if op == OpExpire {
+		status = StatusExpired
+	}
+	return setStatusTx(ctx, tx, reservation, status, now)
+}
+
+func setStatusTx(ctx context.Context, tx *sql.Tx, reservation *Reservation, status string, now time.Time) error {
+	reservation.Status = status
+	reservation.UpdatedAt = now.UTC()
+	_, err := tx.ExecContext(ctx, `UPDATE inventory_reservations SET status = $2, updated_at = $3 WHERE reservation_id = $1`,
+		reservation.ReservationID, reservation.Status, reservation.UpdatedAt)
+	return err
+}
+
+func recordTx(ctx context.Context, tx *sql.Tx, productID, orderID, reservationID, op string, quantity int) error {
+	query := `
+	INSERT INTO inventory_ledger (product_id, order_id, reservation_id, operation, quantity)
+	VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := tx.ExecContext(ctx, query, productID, orderID, reservationID, op, quantity)
+	return err
+}
@@ -0,0 +1,36 @@
+package inventory
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPostgresStore runs the store tests against the database of
+// INVENTORY_TEST_DSN, a scratch database:
+//
+//	INVENTORY_TEST_DSN="host=localhost user=temporal password=temporal dbname=inventory_test sslmode=disable" go test ./inventory
+//
+// The inventory tables of that database are emptied before each test.
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("INVENTORY_TEST_DSN")
+	if dsn == "" {
+		t.Skip("INVENTORY_TEST_DSN is not set")
+	}
+
+	store, err := OpenPostgres(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+	require.NoError(t, store.Migrate(ctx))
+
+	testStore(t, func(t *testing.T, now func() time.Time) Store {
+		_, err := store.db.ExecContext(ctx, `TRUNCATE inventory_stock, inventory_reservations, inventory_ledger RESTART IDENTITY`)
+		require.NoError(t, err)
+		store.now = now
+		return store
+	})
+}
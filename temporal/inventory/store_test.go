@@ -0,0 +1,240 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"temporal/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newStoreFunc returns an empty store whose clock is now
+type newStoreFunc func(t *testing.T, now func() time.Time) Store
+
+// testStore runs the behaviour every Store must share against newStore
+func testStore(t *testing.T, newStore newStoreFunc) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, newStore newStoreFunc)
+	}{
+		{"Reserve_IdempotentPerOrder", testReserveIdempotentPerOrder},
+		{"Reserve_ConcurrentFirstReservations", testReserveConcurrentFirstReservations},
+		{"Reserve_InsufficientStock_ReservesNothing", testReserveInsufficientStockReservesNothing},
+		{"Reserve_MergesDuplicateItems", testReserveMergesDuplicateItems},
+		{"ConfirmAndRelease_ReturnsStock", testConfirmAndReleaseReturnsStock},
+		{"ExpireReservations", testExpireReservations},
+		{"SetOnHand_RecordsDifferenceOnce", testSetOnHandRecordsDifferenceOnce},
+		{"ListReservations_ByLastUpdate", testListReservationsByLastUpdate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newStore)
+		})
+	}
+}
+
+// newTestStore returns a store holding 5 units of prod-001 and 2 of prod-002
+func newTestStore(t *testing.T, newStore newStoreFunc, now func() time.Time) Store {
+	t.Helper()
+	store := newStore(t, now)
+	require.NoError(t, store.Restock(context.Background(), "prod-001", 5))
+	require.NoError(t, store.Restock(context.Background(), "prod-002", 2))
+	return store
+}
+
+func requireStock(t *testing.T, store Store, productID string, onHand, reserved int) {
+	t.Helper()
+	level, err := store.Stock(context.Background(), productID)
+	require.NoError(t, err)
+	require.Equal(t, onHand, level.OnHand, "on hand of %s", productID)
+	require.Equal(t, reserved, level.Reserved, "reserved of %s", productID)
+}
+
+func testReserveIdempotentPerOrder(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	store := newTestStore(t, newStore, time.Now)
+	items := []models.OrderItem{{ProductID: "prod-001", Quantity: 2}}
+
+	first, err := store.Reserve(ctx, "order-1", items, time.Minute)
+	require.NoError(t, err)
+	second, err := store.Reserve(ctx, "order-1", items, time.Minute)
+	require.NoError(t, err)
+
+	require.Equal(t, first.ReservationID, second.ReservationID)
+	require.Equal(t, StatusReserved, second.Status)
+	requireStock(t, store, "prod-001", 5, 2)
+}
+
+// retried activities of the same order may reserve at the same time
+func testReserveConcurrentFirstReservations(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	store := newTestStore(t, newStore, time.Now)
+	items := []models.OrderItem{{ProductID: "prod-001", Quantity: 2}}
+
+	const attempts = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Reserve(ctx, "order-1", items, time.Minute)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	requireStock(t, store, "prod-001", 5, 2)
+	ledger, err := store.Ledger(ctx, "prod-001")
+	require.NoError(t, err)
+	require.Len(t, ledger, 2, "one restock and one reservation")
+	require.Equal(t, OpReserve, ledger[1].Operation)
+
+	// the single reservation still returns all the reserved stock
+	_, err = store.Release(ctx, ReservationIDForOrder("order-1"))
+	require.NoError(t, err)
+	requireStock(t, store, "prod-001", 5, 0)
+}
+
+func testReserveInsufficientStockReservesNothing(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	store := newTestStore(t, newStore, time.Now)
+
+	_, err := store.Reserve(ctx, "order-1", []models.OrderItem{
+		{ProductID: "prod-001", Quantity: 1},
+		{ProductID: "prod-002", Quantity: 3},
+	}, time.Minute)
+
+	var stockErr *InsufficientStockError
+	require.True(t, errors.As(err, &stockErr))
+	require.Equal(t, "prod-002", stockErr.ProductID)
+	require.Equal(t, 2, stockErr.Available)
+	requireStock(t, store, "prod-001", 5, 0)
+	requireStock(t, store, "prod-002", 2, 0)
+}
+
+func testReserveMergesDuplicateItems(t *testing.T, newStore newStoreFunc) {
+	store := newTestStore(t, newStore, time.Now)
+
+	_, err := store.Reserve(context.Background(), "order-1", []models.OrderItem{
+		{ProductID: "prod-002", Quantity: 2},
+		{ProductID: "prod-002", Quantity: 1},
+	}, time.Minute)
+
+	var stockErr *InsufficientStockError
+	require.True(t, errors.As(err, &stockErr))
+	require.Equal(t, 3, stockErr.Requested)
+}
+
+func testConfirmAndReleaseReturnsStock(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	store := newTestStore(t, newStore, time.Now)
+
+	r, err := store.Reserve(ctx, "order-1", []models.OrderItem{{ProductID: "prod-001", Quantity: 2}}, time.Minute)
+	require.NoError(t, err)
+
+	_, err = store.Confirm(ctx, r.ReservationID)
+	require.NoError(t, err)
+	_, err = store.Confirm(ctx, r.ReservationID)
+	require.NoError(t, err)
+	requireStock(t, store, "prod-001", 3, 0)
+
+	released, err := store.Release(ctx, r.ReservationID)
+	require.NoError(t, err)
+	require.Equal(t, StatusReleased, released.Status)
+	_, err = store.Release(ctx, r.ReservationID)
+	require.NoError(t, err)
+	requireStock(t, store, "prod-001", 5, 0)
+
+	ledger, err := store.Ledger(ctx, "prod-001")
+	require.NoError(t, err)
+	var ops []string
+	for _, entry := range ledger {
+		ops = append(ops, entry.Operation)
+	}
+	require.Equal(t, []string{OpRestock, OpReserve, OpConfirm, OpReturn}, ops)
+}
+
+func testExpireReservations(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	store := newTestStore(t, newStore, time.Now)
+
+	r, err := store.Reserve(ctx, "order-1", []models.OrderItem{{ProductID: "prod-001", Quantity: 2}}, time.Minute)
+	require.NoError(t, err)
+
+	n, err := store.ExpireReservations(ctx, time.Now())
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	n, err = store.ExpireReservations(ctx, time.Now().Add(2*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	requireStock(t, store, "prod-001", 5, 0)
+
+	_, err = store.Confirm(ctx, r.ReservationID)
+	var stateErr *StateError
+	require.True(t, errors.As(err, &stateErr))
+	require.Equal(t, StatusExpired, stateErr.Status)
+
+	// a retried order reserves again after its reservation expired
+	again, err := store.Reserve(ctx, "order-1", r.Items, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, StatusReserved, again.Status)
+	requireStock(t, store, "prod-001", 5, 2)
+}
+
+func testSetOnHandRecordsDifferenceOnce(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	store := newTestStore(t, newStore, time.Now)
+
+	require.NoError(t, store.SetOnHand(ctx, "prod-001", 8))
+	require.NoError(t, store.SetOnHand(ctx, "prod-001", 8))
+	require.NoError(t, store.SetOnHand(ctx, "prod-new", 3))
+	requireStock(t, store, "prod-001", 8, 0)
+	requireStock(t, store, "prod-new", 3, 0)
+
+	ledger, err := store.Ledger(ctx, "prod-001")
+	require.NoError(t, err)
+	require.Len(t, ledger, 2)
+	require.Equal(t, OpCount, ledger[1].Operation)
+	require.Equal(t, 3, ledger[1].Quantity)
+}
+
+func testListReservationsByLastUpdate(t *testing.T, newStore newStoreFunc) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	store := newTestStore(t, newStore, func() time.Time { return now })
+
+	items := []models.OrderItem{{ProductID: "prod-001", Quantity: 1}}
+	_, err := store.Reserve(ctx, "order-1", items, time.Hour)
+	require.NoError(t, err)
+	now = start.Add(time.Minute)
+	r2, err := store.Reserve(ctx, "order-2", items, time.Hour)
+	require.NoError(t, err)
+
+	// releasing order-1 later moves it after order-2
+	now = start.Add(2 * time.Minute)
+	_, err = store.Release(ctx, ReservationIDForOrder("order-1"))
+	require.NoError(t, err)
+
+	listed, err := store.ListReservations(ctx, start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	require.Equal(t, r2.ReservationID, listed[0].ReservationID)
+	require.Equal(t, StatusReleased, listed[1].Status)
+
+	// the upper bound is exclusive
+	listed, err = store.ListReservations(ctx, start, start.Add(2*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	require.Equal(t, r2.ReservationID, listed[0].ReservationID)
+}
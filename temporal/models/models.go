@@ -1,11 +1,21 @@
 package models
 
+import "time"
+
 type Order struct {
-	OrderID     string
-	CustomerID  string
-	Items       []OrderItem
-	TotalAmount float64
-	Status      string
+	OrderID         string
+	CustomerID      string
+	Items           []OrderItem
+	TotalAmount     float64
+	Status          string
+	ShippingAddress ShippingAddress
+}
+
+type ShippingAddress struct {
+	Street     string
+	City       string
+	PostalCode string
+	Country    string
 }
 
 type OrderItem struct {
@@ -21,6 +31,7 @@ type PaymentInfo struct {
 	PaymentMethod   string
 	AuthorizationID string
 	CaptureID       string
+	RefundID        string
 	Status          string
 }
 
@@ -29,6 +40,7 @@ type InventoryReservation struct {
 	OrderID       string
 	Items         []OrderItem
 	Status        string
+	ExpiresAt     time.Time
 }
 
 type NotificationRequest struct {
@@ -37,3 +49,322 @@ type NotificationRequest struct {
 	MessageType string
 	Message     string
 }
+
+// NotificationDelivery is the input of NotificationWorkflow
+type NotificationDelivery struct {
+	Notification NotificationRequest
+	Channels     []string          // in order of preference, e.g. email, sms, webhook
+	Recipients   map[string]string // channel => address: email address, phone number or webhook URL
+	RetryFor     time.Duration     // how long each channel is retried before falling back to the next
+}
+
+// NotificationMessage is one notification sent through one channel
+type NotificationMessage struct {
+	Channel      string
+	Recipient    string
+	Notification NotificationRequest
+}
+
+// NotificationAttempt is the outcome of delivering a notification through one channel
+type NotificationAttempt struct {
+	Channel string
+	Status  string // delivered, failed
+	Error   string
+	Time    time.Time
+}
+
+// NotificationResult is the result of NotificationWorkflow
+type NotificationResult struct {
+	Status   string // delivered, dead_lettered
+	Channel  string // the channel that delivered it
+	Attempts []NotificationAttempt
+}
+
+// NotificationDeadLetter records a notification that no channel could deliver
+type NotificationDeadLetter struct {
+	WorkflowID   string
+	Notification NotificationRequest
+	Attempts     []NotificationAttempt
+	RecordedAt   time.Time
+}
+
+// StepRecord is one entry in an order's step history
+type StepRecord struct {
+	Step    string
+	Status  string
+	Time    time.Time
+	Message string
+}
+
+// OrderStatus is returned by the OrderWorkflow get-status query
+type OrderStatus struct {
+	OrderID         string
+	Status          string
+	ShippingAddress ShippingAddress
+	Steps           []StepRecord
+}
+
+// CancelOrderRequest is the payload of the cancel-order signal
+type CancelOrderRequest struct {
+	Reason string
+}
+
+// UpdateShippingRequest is the payload of the update-shipping signal
+type UpdateShippingRequest struct {
+	Address ShippingAddress
+}
+
+// ApprovalRequest is a high-value order waiting for a reviewer
+type ApprovalRequest struct {
+	OrderID     string
+	CustomerID  string
+	WorkflowID  string
+	Amount      float64
+	Status      string // pending, escalated, approved, rejected
+	RequestedAt time.Time
+	ResolvedAt  time.Time
+	Decision    *ApprovalDecision
+}
+
+// ApprovalDecision is the payload of the approval-decision signal
+type ApprovalDecision struct {
+	Approved bool
+	Reviewer string
+	Comment  string
+}
+
+// FulfillmentRequest is the input of the FulfillmentWorkflow child
+type FulfillmentRequest struct {
+	Order         Order
+	ReservationID string
+	// DeliveryTimeout: a parcel without a final status after this long is treated as lost
+	DeliveryTimeout time.Duration
+	// PollInterval: how often to ask the carrier when no tracking update has been pushed
+	PollInterval time.Duration
+}
+
+type Shipment struct {
+	ShipmentID     string
+	OrderID        string
+	Carrier        string
+	TrackingNumber string
+	Address        ShippingAddress
+	Status         string
+	CreatedAt      time.Time
+}
+
+// TrackingUpdate is pushed by the carrier (tracking-update signal) or polled
+// Status: label_created, in_transit, out_for_delivery, delivered, lost, returned
+type TrackingUpdate struct {
+	TrackingNumber string
+	Status         string
+	Location       string
+	Description    string
+	Time           time.Time
+}
+
+// FulfillmentResult is returned by the FulfillmentWorkflow child
+type FulfillmentResult struct {
+	Shipment    Shipment
+	Status      string
+	DeliveredAt time.Time
+	Updates     []TrackingUpdate
+}
+
+// BatchRequest is the input of BatchOrderWorkflow
+// Offset, Cursor and Report carry the progress across continue-as-new
+type BatchRequest struct {
+	BatchID     string
+	Source      string // CSV or JSON file of orders, readable by the workers
+	ReportPath  string // per-order results are appended here as CSV (empty to skip)
+	Concurrency int    // order workflows running at the same time
+	PageSize    int    // orders loaded per LoadOrderPage activity
+	// OrdersPerRun: orders processed before the workflow continues as new
+	OrdersPerRun int
+
+	Offset int   // orders processed
+	Cursor int64 // byte offset of the next order in Source
+	Report BatchReport
+}
+
+// BatchPage is one page of a batch file
+type BatchPage struct {
+	Orders []Order
+	Total  int   // orders in the whole file, counted by the first page only
+	Next   int64 // byte offset of the order after the page, the Cursor of the next page
+}
+
+// BatchOrderResult is the outcome of one order of a batch
+// Status: the order workflow's final status, "failed" or "duplicate"
+type BatchOrderResult struct {
+	OrderID    string
+	WorkflowID string
+	Status     string
+	Error      string
+}
+
+// BatchReport is the BatchOrderWorkflow result and get-batch-progress query result
+type BatchReport struct {
+	BatchID    string
+	Total      int
+	Processed  int
+	Succeeded  int
+	Failed     int
+	Duplicates int
+	InFlight   int
+	Runs       int                // workflow runs so far, one per continue-as-new
+	Failures   []BatchOrderResult // the first failures; the full list is in the report file
+	Done       bool
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// SubscriptionRequest is the input of SubscriptionWorkflow
+// State carries the subscription across continue-as-new
+type SubscriptionRequest struct {
+	SubscriptionID  string
+	CustomerID      string
+	Items           []OrderItem
+	ShippingAddress ShippingAddress
+	// Schedule: cron expression ("0 9 1 * *"), "@monthly", "@weekly" or "@every 720h", in UTC
+	Schedule string
+	// MaxPaymentFailures: consecutive payment failures before the subscription stops
+	MaxPaymentFailures int
+
+	State *SubscriptionStatus
+}
+
+// SubscriptionStatus is returned by the SubscriptionWorkflow get-subscription query
+// Status: active, paused, cancelled or stopped (too many payment failures)
+type SubscriptionStatus struct {
+	SubscriptionID  string
+	CustomerID      string
+	Schedule        string
+	Status          string
+	Reason          string
+	NextRunAt       time.Time
+	SkipNext        bool
+	PaymentFailures int // consecutive
+	OrdersCreated   int
+	Orders          []SubscriptionOrder // the most recent generated and skipped orders
+}
+
+// SubscriptionOrder is one scheduled run of a subscription
+// Status: the order workflow's final status, "skipped" or "failed"
+type SubscriptionOrder struct {
+	OrderID     string
+	WorkflowID  string
+	ScheduledAt time.Time
+	Status      string
+	Error       string
+}
+
+// SubscriptionChange is the payload of the pause, resume, skip-next and cancel subscription signals
+type SubscriptionChange struct {
+	Reason string
+}
+
+// InventorySyncRequest is the input of InventorySyncWorkflow
+// Source is a CSV stock count (product_id,on_hand) readable by the workers
+type InventorySyncRequest struct {
+	Source    string
+	BatchSize int
+}
+
+// InventorySyncProgress is the heartbeat detail of the SyncInventory activity
+type InventorySyncProgress struct {
+	Processed int // rows applied, in file order
+	Changed   int // products whose stock changed
+}
+
+// InventorySyncResult is the outcome of a bulk inventory sync
+type InventorySyncResult struct {
+	Source      string
+	Total       int
+	Processed   int
+	Changed     int
+	ResumedFrom int // row the last attempt started from, 0 if it started fresh
+	Attempts    int32
+}
+
+// Reconciliation repairs, the Action of a ReconciliationCase
+const (
+	RepairVoidAuthorization = "void_authorization"
+	RepairRefundPayment     = "refund_payment"
+	RepairReleaseInventory  = "release_inventory"
+	RepairManualReview      = "manual_review"
+)
+
+// ReconciliationRequest is the input of ReconciliationWorkflow
+// State carries the reconciliation across continue-as-new
+type ReconciliationRequest struct {
+	Interval time.Duration // between passes
+	Grace    time.Duration // records updated more recently may belong to running orders and wait for a later pass
+	Lookback time.Duration // how far back the first pass looks
+
+	State *ReconciliationStatus
+}
+
+// ReconciliationWindow selects the payments and reservations last updated in [From, To)
+type ReconciliationWindow struct {
+	From time.Time
+	To   time.Time
+}
+
+// ReconciliationScan is the result of FindReconciliationCases
+type ReconciliationScan struct {
+	Checked int // orders with a payment or reservation updated in the window
+	Cases   []ReconciliationCase
+}
+
+// ReconciliationCase is an order whose payment and inventory states disagree,
+// and the repair that brings them back in line
+type ReconciliationCase struct {
+	OrderID     string
+	Issue       string
+	Action      string
+	Detail      string
+	Payment     *PaymentInfo          // the payment to void or refund
+	Reservation *InventoryReservation // the reservation to release
+}
+
+// ReconciliationOutcome is what happened to a case
+// Result: repaired or manual_review
+type ReconciliationOutcome struct {
+	Case     ReconciliationCase
+	Result   string
+	Error    string // why the repair failed, for cases that needed one
+	ReviewID string
+}
+
+// ReconciliationPass is one run over a window
+type ReconciliationPass struct {
+	Window   ReconciliationWindow
+	Checked  int
+	Repaired int
+	Reviews  int
+	Outcomes []ReconciliationOutcome
+}
+
+// ReconciliationStatus is returned by the ReconciliationWorkflow get-reconciliation query
+type ReconciliationStatus struct {
+	Checkpoint time.Time // everything updated before it was reconciled
+	Passes     int
+	Repaired   int
+	Reviews    int
+	NextRunAt  time.Time
+	LastPass   *ReconciliationPass
+	LastError  string // of the last failed pass; its window is retried
+}
+
+// ManualReview is an inconsistency reconciliation could not repair, for follow-up by support
+// ReviewID is the order and issue, so reconciling an order again doesn't open a second review
+type ManualReview struct {
+	ReviewID    string
+	OrderID     string
+	Issue       string
+	Detail      string
+	Payment     *PaymentInfo
+	Reservation *InventoryReservation
+	OpenedAt    time.Time
+}
@@ -0,0 +1,282 @@
+// Package orders holds the order operations shared by the orders CLI and the HTTP API:
+// starting orders, looking them up by order ID, cancelling, listing and retrying them
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"temporal/models"
+	"temporal/workflows"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+)
+
+// DefaultTaskQueue is the task queue the order workers poll unless configured
+// otherwise (-task-queue, TEMPORAL_TASK_QUEUE); clients must use the same one
+const DefaultTaskQueue = "order-processing-queue"
+
+var (
+	// ErrNotFound: no order workflow exists for the order ID
+	ErrNotFound = errors.New("order not found")
+	// ErrNotRetryable: only orders whose workflow failed, timed out or was terminated can be retried
+	ErrNotRetryable = errors.New("order cannot be retried")
+	// ErrAlreadyStarted: the order's workflow is running or completed
+	ErrAlreadyStarted = errors.New("order already started")
+)
+
+// Summary is an order as found by a visibility query
+type Summary struct {
+	OrderID         string    `json:"order_id"`
+	WorkflowID      string    `json:"workflow_id"`
+	RunID           string    `json:"run_id"`
+	CustomerID      string    `json:"customer_id"`
+	OrderTotal      float64   `json:"order_total"`
+	Status          string    `json:"status"`           // order status (Status search attribute)
+	ExecutionStatus string    `json:"execution_status"` // workflow status: Running, Completed, Failed, ...
+	StartTime       time.Time `json:"start_time"`
+	CloseTime       time.Time `json:"close_time"`
+}
+
+// Details is an order's workflow execution and the result of its get-status query
+type Details struct {
+	Summary
+	Steps []models.StepRecord `json:"steps"`
+}
+
+// Filter selects orders for List; zero fields match everything
+type Filter struct {
+	CustomerID string
+	Status     string
+	MinTotal   float64
+	Running    bool // only orders whose workflow is still running
+	Limit      int
+}
+
+// StartOptions are the workflow options of a new order on the workers of taskQueue
+func StartOptions(taskQueue string, order models.Order) client.StartWorkflowOptions {
+	return client.StartWorkflowOptions{
+		// One workflow per order: starting an order whose workflow is running or
+		// completed fails instead of returning the existing run. Only a run that
+		// failed, timed out, was cancelled or terminated can be started again (Retry)
+		ID:                                       workflows.OrderWorkflowID(order.OrderID),
+		TaskQueue:                                taskQueue,
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
+		WorkflowIDReusePolicy:                    enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE_FAILED_ONLY,
+
+		// WORKFLOW-LEVEL RETRY POLICY
+		// Applied when the ENTIRE workflow fails and needs to restart from beginning
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    2 * time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    1 * time.Minute,
+			MaximumAttempts:    3,
+		},
+
+		// High-value orders may wait days for a reviewer, and delivery takes days too
+		WorkflowExecutionTimeout: 30 * 24 * time.Hour,
+		WorkflowTaskTimeout:      10 * time.Second,
+
+		// Indexed fields, so orders can be listed by customer, total and status
+		TypedSearchAttributes: workflows.OrderSearchAttributes(order),
+	}
+}
+
+// Start starts the OrderWorkflow of an order on the workers of taskQueue
+func Start(ctx context.Context, c client.Client, taskQueue string, order models.Order) (client.WorkflowRun, error) {
+	if order.OrderID == "" {
+		return nil, fmt.Errorf("order has no OrderID")
+	}
+	if order.Status == "" {
+		order.Status = "pending"
+	}
+	run, err := c.ExecuteWorkflow(ctx, StartOptions(taskQueue, order), workflows.OrderWorkflow, order)
+	var started *serviceerror.WorkflowExecutionAlreadyStarted
+	if errors.As(err, &started) {
+		return nil, fmt.Errorf("%w: %s", ErrAlreadyStarted, order.OrderID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("start order %s: %w", order.OrderID, err)
+	}
+	return run, nil
+}
+
+// Get looks up an order by order ID: its latest workflow run and step history
+func Get(ctx context.Context, c client.Client, orderID string) (*Details, error) {
+	workflowID := workflows.OrderWorkflowID(orderID)
+	desc, err := c.DescribeWorkflowExecution(ctx, workflowID, "")
+	if err != nil {
+		return nil, notFound(err)
+	}
+	info := desc.GetWorkflowExecutionInfo()
+	details := &Details{Summary: Summary{
+		OrderID:         orderID,
+		WorkflowID:      workflowID,
+		RunID:           info.GetExecution().GetRunId(),
+		ExecutionStatus: info.GetStatus().String(),
+		StartTime:       info.GetStartTime().AsTime(),
+	}}
+	if info.GetCloseTime() != nil {
+		details.CloseTime = info.GetCloseTime().AsTime()
+	}
+
+	// Queries work on closed workflows too, as long as a worker can replay them
+	value, err := c.QueryWorkflow(ctx, workflowID, "", workflows.QueryGetStatus)
+	if err != nil {
+		return nil, fmt.Errorf("query order %s: %w", orderID, err)
+	}
+	var status models.OrderStatus
+	if err := value.Get(&status); err != nil {
+		return nil, fmt.Errorf("decode order status: %w", err)
+	}
+	details.Status = status.Status
+	details.Steps = status.Steps
+	return details, nil
+}
+
+// Cancel sends the cancel-order signal; the workflow unwinds its saga
+func Cancel(ctx context.Context, c client.Client, orderID, reason string) error {
+	err := c.SignalWorkflow(ctx, workflows.OrderWorkflowID(orderID), "", workflows.SignalCancelOrder, models.CancelOrderRequest{Reason: reason})
+	if err != nil {
+		return notFound(err)
+	}
+	return nil
+}
+
+// List finds orders through the CustomerID, OrderTotal and Status search attributes
+func List(ctx context.Context, c client.Client, filter Filter) ([]Summary, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	var summaries []Summary
+	var pageToken []byte
+	for len(summaries) < limit {
+		resp, err := c.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Query:         filter.Query(),
+			PageSize:      int32(min(limit-len(summaries), 1000)),
+			NextPageToken: pageToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list orders: %w", err)
+		}
+		for _, info := range resp.GetExecutions() {
+			summaries = append(summaries, summaryOf(info))
+		}
+		if pageToken = resp.GetNextPageToken(); len(pageToken) == 0 {
+			break
+		}
+	}
+	return summaries[:min(len(summaries), limit)], nil
+}
+
+// Query is the visibility query of the filter
+func (f Filter) Query() string {
+	clauses := []string{"WorkflowType = 'OrderWorkflow'"}
+	if f.CustomerID != "" {
+		clauses = append(clauses, fmt.Sprintf("%s = %s", workflows.SearchAttributeCustomerID.GetName(), quote(f.CustomerID)))
+	}
+	if f.Status != "" {
+		clauses = append(clauses, fmt.Sprintf("%s = %s", workflows.SearchAttributeStatus.GetName(), quote(f.Status)))
+	}
+	if f.MinTotal > 0 {
+		clauses = append(clauses, fmt.Sprintf("%s >= %g", workflows.SearchAttributeOrderTotal.GetName(), f.MinTotal))
+	}
+	if f.Running {
+		clauses = append(clauses, "ExecutionStatus = 'Running'")
+	}
+	return strings.Join(clauses, " AND ") + " ORDER BY StartTime DESC"
+}
+
+// Retry starts a failed order again with its original input
+// The new run keeps the workflow ID, so the order is still found by its order ID
+func Retry(ctx context.Context, c client.Client, taskQueue string, orderID string) (client.WorkflowRun, error) {
+	workflowID := workflows.OrderWorkflowID(orderID)
+	desc, err := c.DescribeWorkflowExecution(ctx, workflowID, "")
+	if err != nil {
+		return nil, notFound(err)
+	}
+	switch status := desc.GetWorkflowExecutionInfo().GetStatus(); status {
+	case enumspb.WORKFLOW_EXECUTION_STATUS_FAILED,
+		enumspb.WORKFLOW_EXECUTION_STATUS_TIMED_OUT,
+		enumspb.WORKFLOW_EXECUTION_STATUS_TERMINATED:
+	default:
+		return nil, fmt.Errorf("%w: workflow is %s", ErrNotRetryable, status)
+	}
+
+	order, err := originalOrder(ctx, c, workflowID, desc.GetWorkflowExecutionInfo().GetExecution().GetRunId())
+	if err != nil {
+		return nil, err
+	}
+	return Start(ctx, c, taskQueue, order)
+}
+
+// originalOrder decodes the models.Order an order workflow run was started with
+func originalOrder(ctx context.Context, c client.Client, workflowID, runID string) (models.Order, error) {
+	var order models.Order
+	iter := c.GetWorkflowHistory(ctx, workflowID, runID, false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	if !iter.HasNext() {
+		return order, fmt.Errorf("order %s: empty workflow history", workflowID)
+	}
+	event, err := iter.Next()
+	if err != nil {
+		return order, fmt.Errorf("read workflow history: %w", err)
+	}
+	started := event.GetWorkflowExecutionStartedEventAttributes()
+	if started == nil {
+		return order, fmt.Errorf("order %s: history does not start with WorkflowExecutionStarted", workflowID)
+	}
+	if err := converter.GetDefaultDataConverter().FromPayloads(started.GetInput(), &order); err != nil {
+		return order, fmt.Errorf("decode order input: %w", err)
+	}
+	return order, nil
+}
+
+func summaryOf(info *workflowpb.WorkflowExecutionInfo) Summary {
+	s := Summary{
+		WorkflowID:      info.GetExecution().GetWorkflowId(),
+		RunID:           info.GetExecution().GetRunId(),
+		OrderID:         strings.TrimPrefix(info.GetExecution().GetWorkflowId(), workflows.OrderWorkflowID("")),
+		ExecutionStatus: info.GetStatus().String(),
+		StartTime:       info.GetStartTime().AsTime(),
+	}
+	if info.GetCloseTime() != nil {
+		s.CloseTime = info.GetCloseTime().AsTime()
+	}
+
+	fields := info.GetSearchAttributes().GetIndexedFields()
+	dc := converter.GetDefaultDataConverter()
+	if p, ok := fields[workflows.SearchAttributeCustomerID.GetName()]; ok {
+		dc.FromPayload(p, &s.CustomerID)
+	}
+	if p, ok := fields[workflows.SearchAttributeOrderTotal.GetName()]; ok {
+		dc.FromPayload(p, &s.OrderTotal)
+	}
+	if p, ok := fields[workflows.SearchAttributeStatus.GetName()]; ok {
+		dc.FromPayload(p, &s.Status)
+	}
+	return s
+}
+
+// quote quotes a value for a visibility query
+func quote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+// notFound maps a missing workflow to ErrNotFound
+func notFound(err error) error {
+	var nf *serviceerror.NotFound
+	if errors.As(err, &nf) {
+		return ErrNotFound
+	}
+	return err
+}
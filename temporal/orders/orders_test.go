@@ -0,0 +1,34 @@
+package orders
+
+import (
+	"testing"
+
+	"temporal/models"
+	"temporal/workflows"
+
+	"github.com/stretchr/testify/require"
+	enumspb "go.temporal.io/api/enums/v1"
+)
+
+func TestFilterQuery(t *testing.T) {
+	require.Equal(t, "WorkflowType = 'OrderWorkflow' ORDER BY StartTime DESC", Filter{}.Query())
+
+	query := Filter{CustomerID: "o'brien", Status: "payment_failed", MinTotal: 100, Running: true}.Query()
+	require.Equal(t, "WorkflowType = 'OrderWorkflow' AND CustomerID = 'o\\'brien' AND Status = 'payment_failed'"+
+		" AND OrderTotal >= 100 AND ExecutionStatus = 'Running' ORDER BY StartTime DESC", query)
+}
+
+func TestStartOptions(t *testing.T) {
+	options := StartOptions("orders-eu", models.Order{OrderID: "order-1", CustomerID: "customer-1", TotalAmount: 42.5, Status: "pending"})
+
+	require.Equal(t, "order-workflow-order-1", options.ID)
+	require.Equal(t, "orders-eu", options.TaskQueue)
+	require.True(t, options.WorkflowExecutionErrorWhenAlreadyStarted)
+	require.Equal(t, enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE_FAILED_ONLY, options.WorkflowIDReusePolicy)
+	customerID, _ := options.TypedSearchAttributes.GetKeyword(workflows.SearchAttributeCustomerID)
+	require.Equal(t, "customer-1", customerID)
+	total, _ := options.TypedSearchAttributes.GetFloat64(workflows.SearchAttributeOrderTotal)
+	require.Equal(t, 42.5, total)
+	status, _ := options.TypedSearchAttributes.GetKeyword(workflows.SearchAttributeStatus)
+	require.Equal(t, "pending", status)
+}
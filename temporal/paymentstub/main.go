@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"temporal/activities"
+	"time"
+)
+
+/*
+PAYMENT GATEWAY STUB:
+
+Serves the payment gateway HTTP API backed by activities.FakeGateway, so the
+worker can exercise its HTTP gateway without a provider sandbox:
+
+  go run ./paymentstub -addr :8082 -decline-above 10000
+  go run ./worker -payment-gateway-url http://localhost:8082
+
+Requests with a known Idempotency-Key return the first response again.
+*/
+
+func main() {
+	addr := flag.String("addr", ":8082", "Listen address")
+	declineAbove := flag.Float64("decline-above", 0, "Decline authorizations above this amount for insufficient funds (0 disables)")
+	flag.Parse()
+
+	gateway := activities.NewFakeGateway()
+	gateway.DeclineAbove = *declineAbove
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           logRequests(activities.PaymentStubHandler(gateway)),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	log.Println("Payment gateway stub listening on", *addr)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalln("Payment gateway stub stopped", err)
+	}
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Println(r.Method, r.URL.Path, "key:", r.Header.Get("Idempotency-Key"))
+		next.ServeHTTP(w, r)
+	})
+}
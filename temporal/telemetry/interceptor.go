@@ -0,0 +1,215 @@
+package telemetry
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/contrib/opentelemetry"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/workflow"
+)
+
+/*
+ORDER CONTEXT INTERCEPTORS:
+
+Every workflow and activity log line of an order carries its OrderID, so one
+`grep OrderID=order-123` follows the order through the workflow, its payment
+and fulfillment children and every activity attempt.
+
+1. Logging: GetLogger of workflows and activities returns a logger with the OrderID
+2. Headers: the OrderID travels in the "order-id" header from a workflow to its
+   activities and child workflows, so steps whose input has no OrderID (tracking
+   polls, dead letters) still log it
+3. Spans: behind the tracing interceptor (NewTracing), the span of the workflow
+   or activity gets the OrderID as its order.id attribute
+
+The OrderID comes from the first argument with an OrderID field (models.Order,
+models.PaymentRequest, ...), or else from the header.
+*/
+
+// HeaderOrderID is the header carrying the OrderID to activities and child workflows
+const HeaderOrderID = "order-id"
+
+type orderIDKey struct{}
+
+// NewOrderInterceptor returns the worker interceptor attaching OrderID to logs, headers and spans
+func NewOrderInterceptor() interceptor.WorkerInterceptor {
+	return &orderInterceptor{}
+}
+
+type orderInterceptor struct {
+	interceptor.WorkerInterceptorBase
+}
+
+func (i *orderInterceptor) InterceptWorkflow(ctx workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	return &workflowInbound{WorkflowInboundInterceptorBase: interceptor.WorkflowInboundInterceptorBase{Next: next}}
+}
+
+func (i *orderInterceptor) InterceptActivity(ctx context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	return &activityInbound{ActivityInboundInterceptorBase: interceptor.ActivityInboundInterceptorBase{Next: next}}
+}
+
+// workflowInbound finds the OrderID of a workflow execution
+type workflowInbound struct {
+	interceptor.WorkflowInboundInterceptorBase
+	orderID string
+}
+
+func (w *workflowInbound) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
+	return w.Next.Init(&workflowOutbound{
+		WorkflowOutboundInterceptorBase: interceptor.WorkflowOutboundInterceptorBase{Next: outbound},
+		inbound:                         w,
+	})
+}
+
+func (w *workflowInbound) ExecuteWorkflow(ctx workflow.Context, in *interceptor.ExecuteWorkflowInput) (interface{}, error) {
+	w.orderID = orderIDFromArgs(in.Args)
+	if w.orderID == "" {
+		w.orderID = orderIDFromHeader(interceptor.WorkflowHeader(ctx))
+	}
+	if span, ok := opentelemetry.SpanFromWorkflowContext(ctx); ok && w.orderID != "" {
+		span.SetAttributes(attribute.String(AttributeOrderID, w.orderID))
+	}
+	return w.Next.ExecuteWorkflow(ctx, in)
+}
+
+// workflowOutbound adds the OrderID to the workflow logger and to outgoing headers
+type workflowOutbound struct {
+	interceptor.WorkflowOutboundInterceptorBase
+	inbound *workflowInbound
+}
+
+func (w *workflowOutbound) GetLogger(ctx workflow.Context) log.Logger {
+	logger := w.Next.GetLogger(ctx)
+	if w.inbound.orderID == "" {
+		return logger
+	}
+	return log.With(logger, "OrderID", w.inbound.orderID)
+}
+
+func (w *workflowOutbound) ExecuteActivity(ctx workflow.Context, activityType string, args ...interface{}) workflow.Future {
+	w.setHeader(ctx)
+	return w.Next.ExecuteActivity(ctx, activityType, args...)
+}
+
+func (w *workflowOutbound) ExecuteLocalActivity(ctx workflow.Context, activityType string, args ...interface{}) workflow.Future {
+	w.setHeader(ctx)
+	return w.Next.ExecuteLocalActivity(ctx, activityType, args...)
+}
+
+func (w *workflowOutbound) ExecuteChildWorkflow(ctx workflow.Context, childWorkflowType string, args ...interface{}) workflow.ChildWorkflowFuture {
+	w.setHeader(ctx)
+	return w.Next.ExecuteChildWorkflow(ctx, childWorkflowType, args...)
+}
+
+func (w *workflowOutbound) setHeader(ctx workflow.Context) {
+	if w.inbound.orderID == "" {
+		return
+	}
+	if payload, err := converter.GetDefaultDataConverter().ToPayload(w.inbound.orderID); err == nil {
+		interceptor.WorkflowHeader(ctx)[HeaderOrderID] = payload
+	}
+}
+
+// activityInbound finds the OrderID of an activity and logs the duration of each attempt
+type activityInbound struct {
+	interceptor.ActivityInboundInterceptorBase
+}
+
+func (a *activityInbound) Init(outbound interceptor.ActivityOutboundInterceptor) error {
+	return a.Next.Init(&activityOutbound{
+		ActivityOutboundInterceptorBase: interceptor.ActivityOutboundInterceptorBase{Next: outbound},
+	})
+}
+
+func (a *activityInbound) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (interface{}, error) {
+	orderID := orderIDFromArgs(in.Args)
+	if orderID == "" {
+		orderID = orderIDFromHeader(interceptor.Header(ctx))
+	}
+	if orderID != "" {
+		ctx = context.WithValue(ctx, orderIDKey{}, orderID)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String(AttributeOrderID, orderID))
+	}
+
+	start := time.Now()
+	result, err := a.Next.ExecuteActivity(ctx, in)
+
+	logger := activity.GetLogger(ctx)
+	if err != nil {
+		logger.Debug("Activity attempt failed", "Duration", time.Since(start), "Error", err)
+	} else {
+		logger.Debug("Activity attempt completed", "Duration", time.Since(start))
+	}
+	return result, err
+}
+
+// activityOutbound adds the OrderID to the activity logger
+type activityOutbound struct {
+	interceptor.ActivityOutboundInterceptorBase
+}
+
+func (a *activityOutbound) GetLogger(ctx context.Context) log.Logger {
+	logger := a.Next.GetLogger(ctx)
+	if orderID, ok := ctx.Value(orderIDKey{}).(string); ok {
+		return log.With(logger, "OrderID", orderID)
+	}
+	return logger
+}
+
+func orderIDFromHeader(header map[string]*commonpb.Payload) string {
+	payload, ok := header[HeaderOrderID]
+	if !ok {
+		return ""
+	}
+	var orderID string
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, &orderID); err != nil {
+		return ""
+	}
+	return orderID
+}
+
+// orderIDFromArgs returns the OrderID field of the first argument that has one,
+// looking one level into nested structs (models.NotificationDelivery.Notification)
+func orderIDFromArgs(args []interface{}) string {
+	for _, arg := range args {
+		if id := orderIDOf(reflect.ValueOf(arg), 1); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+func orderIDOf(v reflect.Value, depth int) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	if f := v.FieldByName("OrderID"); f.IsValid() && f.Kind() == reflect.String {
+		return f.String()
+	}
+	if depth == 0 {
+		return ""
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		if id := orderIDOf(v.Field(i), depth-1); id != "" {
+			return id
+		}
+	}
+	return ""
+}
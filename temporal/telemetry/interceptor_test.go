@@ -0,0 +1,119 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"temporal/models"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// captureLogger records log lines as "msg key=value ..."
+type captureLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *captureLogger) record(msg string, keyvals ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	line := msg
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		line += fmt.Sprintf(" %v=%v", keyvals[i], keyvals[i+1])
+	}
+	l.lines = append(l.lines, line)
+}
+
+func (l *captureLogger) Debug(msg string, keyvals ...interface{}) { l.record(msg, keyvals...) }
+func (l *captureLogger) Info(msg string, keyvals ...interface{})  { l.record(msg, keyvals...) }
+func (l *captureLogger) Warn(msg string, keyvals ...interface{})  { l.record(msg, keyvals...) }
+func (l *captureLogger) Error(msg string, keyvals ...interface{}) { l.record(msg, keyvals...) }
+
+// find returns the first line starting with msg
+func (l *captureLogger) find(msg string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, msg) {
+			return line
+		}
+	}
+	return ""
+}
+
+func trackParcel(ctx context.Context, trackingNumber string) error {
+	activity.GetLogger(ctx).Info("Tracking parcel", "TrackingNumber", trackingNumber)
+	return nil
+}
+
+func shipOrder(ctx workflow.Context, order models.Order) error {
+	workflow.GetLogger(ctx).Info("Shipping order")
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+	return workflow.ExecuteActivity(ctx, trackParcel, "FAKE-1").Get(ctx, nil)
+}
+
+func TestOrderInterceptor_AddsOrderIDToLogs(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	logger := &captureLogger{}
+	suite.SetLogger(logger)
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{NewOrderInterceptor()}})
+	env.RegisterWorkflow(shipOrder)
+	env.RegisterActivity(trackParcel)
+
+	env.ExecuteWorkflow(shipOrder, models.Order{OrderID: "order-1"})
+
+	require.NoError(t, env.GetWorkflowError())
+	require.Contains(t, logger.find("Shipping order"), "OrderID=order-1")
+	// The activity input has no OrderID; it comes from the header
+	require.Contains(t, logger.find("Tracking parcel"), "OrderID=order-1")
+	require.Contains(t, logger.find("Activity attempt completed"), "OrderID=order-1")
+}
+
+func TestOrderIDFromArgs(t *testing.T) {
+	require.Equal(t, "order-1", orderIDFromArgs([]interface{}{models.Order{OrderID: "order-1"}}))
+	require.Equal(t, "order-2", orderIDFromArgs([]interface{}{"FAKE-1", &models.InventoryReservation{OrderID: "order-2"}}))
+	require.Equal(t, "order-3", orderIDFromArgs([]interface{}{models.NotificationDelivery{
+		Notification: models.NotificationRequest{OrderID: "order-3"},
+	}}))
+	require.Empty(t, orderIDFromArgs([]interface{}{"FAKE-1", nil, (*models.Order)(nil)}))
+}
+
+func TestTracing_SpansCarryOrderID(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracing, err := newTracingInterceptor(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	require.NoError(t, err)
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{tracing, NewOrderInterceptor()}})
+	env.RegisterWorkflow(shipOrder)
+	env.RegisterActivity(trackParcel)
+
+	env.ExecuteWorkflow(shipOrder, models.Order{OrderID: "order-1"})
+
+	require.NoError(t, env.GetWorkflowError())
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	workflowSpan, activitySpan := spans["RunWorkflow:shipOrder"], spans["RunActivity:trackParcel"]
+	require.NotNil(t, workflowSpan, "spans: %v", spans)
+	require.NotNil(t, activitySpan, "spans: %v", spans)
+	// The activity's input has no OrderID; the span gets it from the header
+	require.Contains(t, workflowSpan.Attributes(), attribute.String(AttributeOrderID, "order-1"))
+	require.Contains(t, activitySpan.Attributes(), attribute.String(AttributeOrderID, "order-1"))
+	require.Equal(t, workflowSpan.SpanContext().TraceID(), activitySpan.SpanContext().TraceID())
+}
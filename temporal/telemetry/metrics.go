@@ -0,0 +1,46 @@
+package telemetry
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/contrib/opentelemetry"
+)
+
+/*
+METRICS:
+
+The SDK reports its metrics (workflow task latency, activity failures, poller
+counts, sticky cache hits, ...) through a client.MetricsHandler set on the
+client. The SDK's OpenTelemetry handler records them on an OpenTelemetry meter,
+whose Prometheus exporter registers them in the registry the worker serves on
+/metrics. Counters are exported as gauges: the SDK's counters are up-down
+counters.
+*/
+
+// timerBuckets cover the SDK's latencies, from cache hits to long polls, in seconds
+var timerBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// NewMetricsHandler returns the SDK metrics handler reporting to a Prometheus registry
+func NewMetricsHandler(registerer prometheus.Registerer) (client.MetricsHandler, error) {
+	exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registerer))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(exporter),
+		sdkmetric.WithResource(serviceResource()),
+		// The default buckets are meant for milliseconds; the SDK's timers are in seconds
+		sdkmetric.WithView(sdkmetric.NewView(
+			sdkmetric.Instrument{Kind: sdkmetric.InstrumentKindHistogram, Unit: "s"},
+			sdkmetric.Stream{Aggregation: sdkmetric.AggregationExplicitBucketHistogram{Boundaries: timerBuckets}},
+		)),
+	)
+	return opentelemetry.NewMetricsHandler(opentelemetry.MetricsHandlerOptions{
+		Meter: provider.Meter("temporal-sdk-go"),
+		// A metric the meter refuses is reported, not a reason to crash the worker
+		OnError: otel.Handle,
+	}), nil
+}
@@ -0,0 +1,45 @@
+package telemetry
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	handler, err := NewMetricsHandler(registry)
+	require.NoError(t, err)
+	handler = handler.WithTags(map[string]string{"namespace": "default"})
+
+	handler.WithTags(map[string]string{"task_queue": "orders"}).Counter("temporal_request").Inc(2)
+	handler.WithTags(map[string]string{"task_queue": "orders"}).Counter("temporal_request").Inc(1)
+	handler.Gauge("temporal_num_pollers").Update(4)
+	handler.Timer("temporal_request_latency").Record(250 * time.Millisecond)
+
+	expected := `
+# HELP temporal_request 
+# TYPE temporal_request gauge
+temporal_request{namespace="default",otel_scope_name="temporal-sdk-go",otel_scope_version="",task_queue="orders"} 3
+# HELP temporal_num_pollers 
+# TYPE temporal_num_pollers gauge
+temporal_num_pollers{namespace="default",otel_scope_name="temporal-sdk-go",otel_scope_version=""} 4
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "temporal_request", "temporal_num_pollers"))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == "temporal_request_latency_seconds" {
+			histogram := family.GetMetric()[0].GetHistogram()
+			require.Equal(t, uint64(1), histogram.GetSampleCount())
+			require.Len(t, histogram.GetBucket(), len(timerBuckets))
+			return
+		}
+	}
+	t.Fatal("temporal_request_latency_seconds not exported")
+}
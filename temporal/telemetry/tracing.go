@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/contrib/opentelemetry"
+	"go.temporal.io/sdk/interceptor"
+)
+
+/*
+TRACING:
+
+The SDK's OpenTelemetry interceptor starts a span for every workflow start and
+run, activity attempt, child workflow, signal and query, and passes the trace
+context on in the "_tracer-data" header. An order, its payment and fulfillment
+children and all their activities form one trace; the order interceptor tags
+the spans with the order.id attribute.
+
+Spans are exported over OTLP/gRPC to a collector (Jaeger, Tempo, the
+OpenTelemetry collector, ...) such as http://localhost:4317.
+*/
+
+// serviceName names the worker in its traces and metrics
+const serviceName = "order-worker"
+
+// AttributeOrderID is the span attribute holding the OrderID
+const AttributeOrderID = "order.id"
+
+func serviceResource() *resource.Resource {
+	return resource.NewSchemaless(attribute.String("service.name", serviceName))
+}
+
+// NewTracing returns the tracing interceptor exporting spans to the OTLP
+// collector at endpoint, and the function flushing the remaining spans on shutdown
+func NewTracing(ctx context.Context, endpoint string) (interceptor.Interceptor, func(context.Context) error, error) {
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(serviceResource()))
+	tracing, err := newTracingInterceptor(provider)
+	if err != nil {
+		provider.Shutdown(ctx)
+		return nil, nil, err
+	}
+	return tracing, provider.Shutdown, nil
+}
+
+func newTracingInterceptor(provider trace.TracerProvider) (interceptor.Interceptor, error) {
+	return opentelemetry.NewTracingInterceptor(opentelemetry.TracerOptions{
+		Tracer: provider.Tracer("temporal-sdk-go"),
+	})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"temporal/activities"
+	"temporal/models"
+	"temporal/workflows"
+
+	"go.temporal.io/sdk/client"
+)
+
+/*
+REVIEWER ENDPOINT:
+
+Runs next to the worker and shares its approval store:
+  GET  /approvals                    list pending approvals
+  GET  /approvals/{orderID}          show one approval
+  POST /approvals/{orderID}/approve  body: {"reviewer": "...", "comment": "..."}
+  POST /approvals/{orderID}/reject   body: {"reviewer": "...", "comment": "..."}
+
+A decision is delivered to the order workflow as an approval-decision signal.
+*/
+
+type approvalServer struct {
+	client client.Client
+	store  activities.ApprovalStore
+}
+
+type decisionBody struct {
+	Reviewer string `json:"reviewer"`
+	Comment  string `json:"comment"`
+}
+
+func newApprovalHandler(c client.Client, store activities.ApprovalStore) http.Handler {
+	s := &approvalServer{client: c, store: store}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /approvals", s.handleList)
+	mux.HandleFunc("GET /approvals/{orderID}", s.handleGet)
+	mux.HandleFunc("POST /approvals/{orderID}/approve", s.handleDecision(true))
+	mux.HandleFunc("POST /approvals/{orderID}/reject", s.handleDecision(false))
+	return mux
+}
+
+func (s *approvalServer) handleList(w http.ResponseWriter, r *http.Request) {
+	pending, err := s.store.ListPending(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, pending)
+}
+
+func (s *approvalServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	req, ok, err := s.store.Get(r.Context(), r.PathValue("orderID"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "approval not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, req)
+}
+
+func (s *approvalServer) handleDecision(approved bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok, err := s.store.Get(r.Context(), r.PathValue("orderID"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "approval not found", http.StatusNotFound)
+			return
+		}
+		if req.Status != "pending" && req.Status != "escalated" {
+			http.Error(w, "approval already "+req.Status, http.StatusConflict)
+			return
+		}
+
+		var body decisionBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if body.Reviewer == "" {
+			http.Error(w, "reviewer is required", http.StatusBadRequest)
+			return
+		}
+
+		decision := models.ApprovalDecision{Approved: approved, Reviewer: body.Reviewer, Comment: body.Comment}
+		err = s.client.SignalWorkflow(r.Context(), req.WorkflowID, "", workflows.SignalApprovalDecision, decision)
+		if err != nil {
+			log.Println("Unable to signal approval decision", err)
+			http.Error(w, "unable to deliver decision", http.StatusBadGateway)
+			return
+		}
+
+		writeJSON(w, http.StatusAccepted, decision)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Unable to encode response", err)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"temporal/orders"
+	"temporal/telemetry"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/worker"
+)
+
+// connectionConfig is how the worker reaches Temporal and what it polls
+// Every flag falls back to an environment variable, so containers need no arguments
+type connectionConfig struct {
+	address   string
+	namespace string
+	taskQueue string
+
+	maxConcurrentActivities    int
+	maxConcurrentWorkflowTasks int
+	shutdownTimeout            time.Duration
+
+	// TLS is used when enabled or when any file is given; a client
+	// certificate and key turn it into mTLS (e.g. Temporal Cloud)
+	tls           bool
+	tlsCert       string
+	tlsKey        string
+	tlsCA         string
+	tlsServerName string
+}
+
+func (cfg *connectionConfig) register(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.address, "address", envOr("TEMPORAL_ADDRESS", "localhost:7233"), "Temporal server address")
+	fs.StringVar(&cfg.namespace, "namespace", envOr("TEMPORAL_NAMESPACE", "default"), "Temporal namespace")
+	fs.StringVar(&cfg.taskQueue, "task-queue", envOr("TEMPORAL_TASK_QUEUE", orders.DefaultTaskQueue), "Task queue of the order workflows and activities")
+	fs.IntVar(&cfg.maxConcurrentActivities, "max-concurrent-activities", envInt("WORKER_MAX_CONCURRENT_ACTIVITIES", 10), "Activities executed in parallel")
+	fs.IntVar(&cfg.maxConcurrentWorkflowTasks, "max-concurrent-workflow-tasks", envInt("WORKER_MAX_CONCURRENT_WORKFLOW_TASKS", 10), "Workflow tasks executed in parallel")
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", envDuration("WORKER_SHUTDOWN_TIMEOUT", 30*time.Second), "How long in-flight activities may finish on shutdown")
+	fs.BoolVar(&cfg.tls, "tls", os.Getenv("TEMPORAL_TLS") == "true", "Connect over TLS")
+	fs.StringVar(&cfg.tlsCert, "tls-cert", os.Getenv("TEMPORAL_TLS_CERT"), "Client certificate for mTLS (PEM)")
+	fs.StringVar(&cfg.tlsKey, "tls-key", os.Getenv("TEMPORAL_TLS_KEY"), "Client private key for mTLS (PEM)")
+	fs.StringVar(&cfg.tlsCA, "tls-ca", os.Getenv("TEMPORAL_TLS_CA"), "CA certificates of the server (PEM, default: system roots)")
+	fs.StringVar(&cfg.tlsServerName, "tls-server-name", os.Getenv("TEMPORAL_TLS_SERVER_NAME"), "Server name to verify (default: host of -address)")
+}
+
+// clientOptions builds the options of the Temporal client
+// The interceptors also apply to the workers created from the client
+func (cfg *connectionConfig) clientOptions(metrics client.MetricsHandler, interceptors []interceptor.ClientInterceptor) (client.Options, error) {
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return client.Options{}, err
+	}
+	return client.Options{
+		HostPort:          cfg.address,
+		Namespace:         cfg.namespace,
+		ConnectionOptions: client.ConnectionOptions{TLS: tlsConfig},
+		MetricsHandler:    metrics,
+		Interceptors:      interceptors,
+	}, nil
+}
+
+// workerOptions are the options shared by every worker of the process
+func (cfg *connectionConfig) workerOptions() worker.Options {
+	return worker.Options{
+		MaxConcurrentActivityExecutionSize:     cfg.maxConcurrentActivities,
+		MaxConcurrentWorkflowTaskExecutionSize: cfg.maxConcurrentWorkflowTasks,
+
+		// On shutdown, pollers stop at once while running activities get this long
+		// to complete before their context is cancelled
+		WorkerStopTimeout: cfg.shutdownTimeout,
+
+		// OrderID on every workflow and activity log line
+		Interceptors: []interceptor.WorkerInterceptor{telemetry.NewOrderInterceptor()},
+	}
+}
+
+func (cfg *connectionConfig) tlsConfig() (*tls.Config, error) {
+	if !cfg.tls && cfg.tlsCert == "" && cfg.tlsKey == "" && cfg.tlsCA == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: cfg.tlsServerName}
+	if cfg.tlsCert != "" || cfg.tlsKey != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.tlsCert, cfg.tlsKey)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.tlsCA != "" {
+		pem, err := os.ReadFile(cfg.tlsCA)
+		if err != nil {
+			return nil, fmt.Errorf("read CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", cfg.tlsCA)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// newMetrics returns the SDK metrics handler and the registry to serve
+func newMetrics() (client.MetricsHandler, *prometheus.Registry, error) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	handler, err := telemetry.NewMetricsHandler(registry)
+	return handler, registry, err
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"temporal/inventory"
+	"time"
+)
+
+// demoStock seeds the in-memory inventory so the sample client orders succeed
+// "prod-unavailable" is deliberately left without stock
+var demoStock = map[string]int{
+	"prod-001": 100,
+	"prod-002": 100,
+}
+
+// openInventory connects to Postgres when a DSN is given, otherwise it uses
+// the in-memory store seeded with demo stock
+func openInventory(ctx context.Context, dsn string) (inventory.Store, func(), error) {
+	if dsn == "" {
+		store := inventory.NewMemoryStore()
+		for productID, quantity := range demoStock {
+			if err := store.Restock(ctx, productID, quantity); err != nil {
+				return nil, nil, err
+			}
+		}
+		log.Println("Using in-memory inventory with demo stock")
+		return store, func() {}, nil
+	}
+
+	store, err := inventory.OpenPostgres(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := store.Migrate(ctx); err != nil {
+		store.Close()
+		return nil, nil, fmt.Errorf("inventory: migrate %w", err)
+	}
+	log.Println("Using Postgres inventory")
+	return store, func() { store.Close() }, nil
+}
+
+// sweepReservations releases expired reservations until ctx is cancelled
+// Every worker may run the sweep; each expiry is its own transaction
+func sweepReservations(ctx context.Context, store inventory.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := store.ExpireReservations(ctx, now)
+			if err != nil {
+				log.Println("Reservation sweep failed", err)
+				continue
+			}
+			if n > 0 {
+				log.Println("Released expired reservations:", n)
+			}
+		}
+	}
+}
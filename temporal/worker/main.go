@@ -1,12 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"net/http"
+	"os"
 	"temporal/activities"
+	"temporal/telemetry"
 	"temporal/workflows"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
 )
 
 /*
@@ -25,18 +35,67 @@ Key concepts:
 */
 
 func main() {
-	// Create Temporal client
-	// This connects to the Temporal server
-	c, err := client.Dial(client.Options{
-		HostPort: "localhost:7233", // Temporal server address
+	var conn connectionConfig
+	conn.register(flag.CommandLine)
+	metricsAddr := flag.String("metrics-addr", envOr("METRICS_ADDR", ":9090"), "Address serving Prometheus metrics on /metrics (empty to disable)")
+	otlpEndpoint := flag.String("otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/gRPC collector receiving traces, e.g. http://localhost:4317 (empty to disable)")
+	defaultPolicy := workflows.DefaultApprovalPolicy()
+	approvalAddr := flag.String("approval-addr", ":8081", "Address of the reviewer HTTP endpoint (empty to disable)")
+	approvalThreshold := flag.Float64("approval-threshold", defaultPolicy.Threshold, "Orders above this amount need manual approval (0 disables)")
+	escalateAfter := flag.Duration("approval-escalate-after", defaultPolicy.EscalateAfter, "Escalate pending approvals after this long")
+	rejectAfter := flag.Duration("approval-reject-after", defaultPolicy.RejectAfter, "Reject pending approvals after this long")
+	inventoryDSN := flag.String("inventory-dsn", os.Getenv("INVENTORY_DSN"), "Postgres DSN of the inventory database (empty for in-memory)")
+	paymentGatewayURL := flag.String("payment-gateway-url", os.Getenv("PAYMENT_GATEWAY_URL"), "Base URL of the payment gateway HTTP API (empty for in-memory)")
+	faultSpec := flag.String("faults", activities.DemoFaults, "Injected activity faults as activity=rate[/latency],... (empty disables)")
+	reservationTTL := flag.Duration("reservation-ttl", activities.DefaultReservationTTL, "Release reserved stock that is not confirmed within this long")
+	buildID := flag.String("build-id", os.Getenv("WORKER_BUILD_ID"), "Build ID of this worker's workflow code, e.g. the git commit")
+	deploymentName := flag.String("deployment-name", "order-processing", "Worker deployment the build ID belongs to")
+	useVersioning := flag.Bool("use-versioning", false, "Pin workflows to the build that started them (Worker Deployment Versioning, needs -build-id)")
+	var notifications notificationConfig
+	flag.StringVar(&notifications.smtpAddr, "smtp-addr", os.Getenv("SMTP_ADDR"), "SMTP server for email notifications (empty for a fake)")
+	flag.StringVar(&notifications.smtpFrom, "smtp-from", "orders@example.com", "Sender of email notifications")
+	flag.StringVar(&notifications.smsURL, "sms-gateway-url", os.Getenv("SMS_GATEWAY_URL"), "SMS provider HTTP API (empty for a fake)")
+	flag.StringVar(&notifications.webhookURL, "webhook-url", os.Getenv("NOTIFICATION_WEBHOOK_URL"), "Webhook receiving notifications (empty for a fake)")
+	flag.StringVar(&notifications.rates, "notification-rates", defaultNotificationRates, "Deliveries per second per channel as channel=rate,... (0 is unlimited)")
+	deadLetterFile := flag.String("dead-letter-file", "", "File receiving undeliverable notifications as JSON lines (empty for in-memory)")
+	recipientFile := flag.String("recipient-file", "", "JSON file of the customers' notification addresses, customer ID => channel => address (empty for none)")
+	reviewFile := flag.String("review-file", "", "File receiving the manual reviews opened by reconciliation as JSON lines (empty for in-memory)")
+	flag.Parse()
 
-		// Namespace: logical isolation of workflows
-		// "default" is the default namespace
-		Namespace: "default",
+	if *useVersioning && *buildID == "" {
+		log.Fatalln("-use-versioning needs a -build-id")
+	}
 
-		// Logger: configure logging for debugging
-		// Logger: log.NewStructuredLogger(slog.New(...)),
+	// Approval policy is recorded by each new workflow execution
+	workflows.SetApprovalPolicy(workflows.ApprovalPolicy{
+		Threshold:     *approvalThreshold,
+		EscalateAfter: *escalateAfter,
+		RejectAfter:   *rejectAfter,
 	})
+
+	// Create Temporal client
+	// This connects to the Temporal server; the SDK reports its metrics through the handler
+	// and, with an OTLP collector, traces workflows and activities through the interceptor
+	metricsHandler, metricsRegistry, err := newMetrics()
+	if err != nil {
+		log.Fatalln("Unable to set up metrics", err)
+	}
+	var clientInterceptors []interceptor.ClientInterceptor
+	flushTraces := func(context.Context) error { return nil }
+	if *otlpEndpoint != "" {
+		var tracing interceptor.Interceptor
+		tracing, flushTraces, err = telemetry.NewTracing(context.Background(), *otlpEndpoint)
+		if err != nil {
+			log.Fatalln("Unable to set up tracing", err)
+		}
+		clientInterceptors = append(clientInterceptors, tracing)
+		log.Println("Exporting traces to", *otlpEndpoint)
+	}
+	clientOptions, err := conn.clientOptions(metricsHandler, clientInterceptors)
+	if err != nil {
+		log.Fatalln("Invalid TLS configuration", err)
+	}
+	c, err := client.Dial(clientOptions)
 	if err != nil {
 		log.Fatalln("Unable to create Temporal client", err)
 	}
@@ -44,48 +103,173 @@ func main() {
 
 	// Create worker
 	// Workers poll a specific task queue for work
-	w := worker.New(c, "order-processing-queue", worker.Options{
-		// MaxConcurrentActivityExecutionSize: Max parallel activities
-		MaxConcurrentActivityExecutionSize: 10,
-
-		// MaxConcurrentWorkflowTaskExecutionSize: Max parallel workflow tasks
-		MaxConcurrentWorkflowTaskExecutionSize: 10,
+	workerOptions := conn.workerOptions()
 
-		// Enable session workers for file activities, etc.
-		// EnableSessionWorker: true,
-	})
+	// Build ID identifies the workflow code this worker runs
+	// With versioning, executions stay on the build that started them,
+	// so incompatible workflow changes can roll out next to the old workers
+	if *buildID != "" {
+		workerOptions.DeploymentOptions = worker.DeploymentOptions{
+			UseVersioning: *useVersioning,
+			Version: worker.WorkerDeploymentVersion{
+				DeploymentName: *deploymentName,
+				BuildID:        *buildID,
+			},
+		}
+		if *useVersioning {
+			workerOptions.DeploymentOptions.DefaultVersioningBehavior = workflow.VersioningBehaviorPinned
+		}
+		log.Printf("Worker build %s (deployment %s, versioning %t)", *buildID, *deploymentName, *useVersioning)
+	}
+	w := worker.New(c, conn.taskQueue, workerOptions)
 
 	// Register workflows
 	// The worker needs to know about all workflows it can execute
-	w.RegisterWorkflow(workflows.OrderWorkflow)
-	w.RegisterWorkflow(workflows.PaymentWorkflow) // Child workflow must also be registered
+	// OrderWorkflow and its child workflows
+	workflows.RegisterWorkflows(w)
 
 	// Register activities
 	// Activities are registered as methods on a struct
 	// This allows for dependency injection and better testability
-	orderActivities := &activities.OrderActivities{}
+	inventoryStore, closeInventory, err := openInventory(context.Background(), *inventoryDSN)
+	if err != nil {
+		log.Fatalln("Unable to open inventory", err)
+	}
+	defer closeInventory()
+
+	// Simulated transient failures, so retries are visible in the Web UI
+	faults, err := activities.ParseFaults(*faultSpec, time.Now().UnixNano())
+	if err != nil {
+		log.Fatalln("Invalid -faults", err)
+	}
+
+	orderActivities := &activities.OrderActivities{Inventory: inventoryStore, ReservationTTL: *reservationTTL, Faults: faults}
 	w.RegisterActivity(orderActivities.ValidateOrder)
 	w.RegisterActivity(orderActivities.ReserveInventory)
+	w.RegisterActivity(orderActivities.ConfirmInventory)
 	w.RegisterActivity(orderActivities.SendNotification)
 	w.RegisterActivity(orderActivities.CompensateInventory)
 
-	paymentActivities := &activities.PaymentActivities{}
+	// Payment gateway is pluggable: a sandbox or the local stub server over HTTP, or an in-memory fake
+	var paymentGateway activities.PaymentGateway = activities.NewFakeGateway()
+	if *paymentGatewayURL != "" {
+		paymentGateway = activities.NewHTTPGateway(*paymentGatewayURL)
+		log.Println("Using payment gateway at", *paymentGatewayURL)
+	}
+	paymentActivities := &activities.PaymentActivities{Gateway: paymentGateway, Faults: faults}
 	w.RegisterActivity(paymentActivities.AuthorizePayment)
 	w.RegisterActivity(paymentActivities.CapturePayment)
 	w.RegisterActivity(paymentActivities.RefundPayment)
 	w.RegisterActivity(paymentActivities.VoidAuthorization)
 
+	approvalStore := activities.NewMemoryApprovalStore()
+	approvalActivities := &activities.ApprovalActivities{Store: approvalStore}
+	w.RegisterActivity(approvalActivities.RequestApproval)
+	w.RegisterActivity(approvalActivities.EscalateApproval)
+	w.RegisterActivity(approvalActivities.ResolveApproval)
+
+	// Carrier is pluggable; the fake carrier delivers after a few tracking polls
+	shippingActivities := &activities.ShippingActivities{Carrier: activities.NewFakeCarrier()}
+	w.RegisterActivity(shippingActivities.CreateShipment)
+	w.RegisterActivity(shippingActivities.GetTrackingStatus)
+	w.RegisterActivity(shippingActivities.CancelShipment)
+
+	// Bulk stock counts run for minutes: the activity heartbeats and resumes after a crash
+	syncActivities := &activities.InventorySyncActivities{Inventory: inventoryStore, Faults: faults}
+	w.RegisterActivity(syncActivities.SyncInventory)
+
+	// Reconciliation compares the gateway with the inventory and repairs through the activities above
+	var reviews activities.ReviewStore = activities.NewMemoryReviewStore()
+	if *reviewFile != "" {
+		reviews = &activities.FileReviewStore{Path: *reviewFile}
+	}
+	reconciliationActivities := &activities.ReconciliationActivities{Gateway: paymentGateway, Inventory: inventoryStore, Reviews: reviews}
+	w.RegisterActivity(reconciliationActivities.FindReconciliationCases)
+	w.RegisterActivity(reconciliationActivities.OpenManualReview)
+
+	// Batch files are read from the worker's disk
+	batchActivities := &activities.BatchActivities{}
+	w.RegisterActivity(batchActivities.LoadOrderPage)
+	w.RegisterActivity(batchActivities.AppendBatchResults)
+
+	// Notifications the order could not send are retried by NotificationWorkflow,
+	// one task queue per channel so every channel has its own rate limit
+	var deadLetters activities.DeadLetterStore = activities.NewMemoryDeadLetterStore()
+	if *deadLetterFile != "" {
+		deadLetters = &activities.FileDeadLetterStore{Path: *deadLetterFile}
+	}
+	var recipients activities.RecipientDirectory = activities.NewMemoryRecipientDirectory()
+	if *recipientFile != "" {
+		recipients = &activities.FileRecipientDirectory{Path: *recipientFile}
+	}
+	notificationActivities := &activities.NotificationActivities{Channels: notifications.channels(), DeadLetters: deadLetters, Recipients: recipients}
+	w.RegisterActivity(notificationActivities.ResolveRecipients)
+	w.RegisterActivity(notificationActivities.RecordDeadLetter)
+	notificationWorkers, err := startNotificationWorkers(c, conn.workerOptions(), notificationActivities, notifications.rates)
+	if err != nil {
+		log.Fatalln("Unable to start notification workers", err)
+	}
+
 	// Alternative: Register all methods on a struct
 	// w.RegisterActivity(orderActivities)
 	// w.RegisterActivity(paymentActivities)
 
+	// Reviewer endpoint for high-value order approvals
+	var approvalHTTP *http.Server
+	if *approvalAddr != "" {
+		approvalHTTP = &http.Server{
+			Addr:              *approvalAddr,
+			Handler:           newApprovalHandler(c, approvalStore),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		go func() {
+			log.Println("Approval endpoint listening on", *approvalAddr)
+			if err := approvalHTTP.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Println("Approval endpoint error", err)
+			}
+		}()
+	}
+
+	// Prometheus metrics of the SDK and the process
+	var metricsHTTP *http.Server
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+		metricsHTTP = &http.Server{Addr: *metricsAddr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			log.Println("Metrics listening on", *metricsAddr)
+			if err := metricsHTTP.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Println("Metrics endpoint error", err)
+			}
+		}()
+	}
+
+	// Reservations not confirmed in time go back to stock
+	sweepCtx, stopSweep := context.WithCancel(context.Background())
+	go sweepReservations(sweepCtx, inventoryStore, time.Minute)
+
 	log.Println("Worker starting...")
-	log.Println("Listening on task queue: order-processing-queue")
+	log.Printf("Listening on task queue %s (namespace %s at %s)", conn.taskQueue, conn.namespace, conn.address)
 	log.Println("Press Ctrl+C to stop the worker")
 
 	// Start worker
 	// This is a blocking call - the worker will run until stopped
+	// On SIGINT/SIGTERM it stops polling and waits up to -shutdown-timeout
+	// for the activities in flight, as do the notification workers
 	err = w.Run(worker.InterruptCh())
+	log.Println("Worker draining...")
+	stopWorkers(notificationWorkers)
+	stopSweep()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	for _, server := range []*http.Server{approvalHTTP, metricsHTTP} {
+		if server != nil {
+			server.Shutdown(shutdownCtx)
+		}
+	}
+	if err := flushTraces(shutdownCtx); err != nil {
+		log.Println("Unable to flush traces", err)
+	}
+	cancel()
 	if err != nil {
 		log.Fatalln("Unable to start worker", err)
 	}
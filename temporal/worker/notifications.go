@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"temporal/activities"
+	"temporal/workflows"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/worker"
+)
+
+// notificationConfig selects the notification channels; an empty address or URL uses the channel's fake
+type notificationConfig struct {
+	smtpAddr   string
+	smtpFrom   string
+	smsURL     string
+	webhookURL string
+	rates      string
+}
+
+// defaultNotificationRates are deliveries per second per channel, across all workers
+const defaultNotificationRates = "email=10,sms=1,webhook=20"
+
+func (cfg notificationConfig) channels() map[string]activities.NotificationChannel {
+	var email activities.NotificationChannel = activities.NewFakeChannel(activities.ChannelEmail)
+	if cfg.smtpAddr != "" {
+		email = &activities.SMTPChannel{Addr: cfg.smtpAddr, From: cfg.smtpFrom}
+	}
+	var sms activities.NotificationChannel = activities.NewFakeChannel(activities.ChannelSMS)
+	if cfg.smsURL != "" {
+		sms = activities.NewSMSChannel(cfg.smsURL)
+	}
+	var webhook activities.NotificationChannel = activities.NewFakeChannel(activities.ChannelWebhook)
+	if cfg.webhookURL != "" {
+		webhook = activities.NewWebhookChannel(cfg.webhookURL)
+	}
+	return map[string]activities.NotificationChannel{
+		activities.ChannelEmail:   email,
+		activities.ChannelSMS:     sms,
+		activities.ChannelWebhook: webhook,
+	}
+}
+
+// parseRates parses "channel=rate,..." into deliveries per second per channel
+func parseRates(spec string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate %q, want channel=rate", entry)
+		}
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid rate %q for %s", value, name)
+		}
+		rates[name] = rate
+	}
+	return rates, nil
+}
+
+// startNotificationWorkers starts one worker per channel task queue
+// TaskQueueActivitiesPerSecond is enforced by the server for the whole queue,
+// so the rate holds however many workers poll it
+func startNotificationWorkers(c client.Client, options worker.Options, acts *activities.NotificationActivities, spec string) ([]worker.Worker, error) {
+	rates, err := parseRates(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var workers []worker.Worker
+	for name := range acts.Channels {
+		options.TaskQueueActivitiesPerSecond = rates[name]
+		w := worker.New(c, workflows.NotificationTaskQueue(name), options)
+		w.RegisterActivity(acts.DeliverNotification)
+		if err := w.Start(); err != nil {
+			stopWorkers(workers)
+			return nil, fmt.Errorf("start %s notification worker: %w", name, err)
+		}
+		workers = append(workers, w)
+		log.Printf("Notification channel %s on task queue %s (%v/s)", name, workflows.NotificationTaskQueue(name), rates[name])
+	}
+	return workers, nil
+}
+
+// stopWorkers stops the workers in parallel, so their drain timeouts overlap
+func stopWorkers(workers []worker.Worker) {
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.Stop()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,172 @@
+package workflows
+
+import (
+	"fmt"
+	"temporal/models"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+/*
+HUMAN-IN-THE-LOOP:
+
+Workflows can wait for people as easily as for activities:
+- The workflow blocks on a signal channel (the reviewer's decision)
+- A durable timer bounds the wait - it survives worker restarts
+- Nothing runs while waiting, so waiting for days costs nothing
+
+High-value orders pause before payment:
+1. RequestApproval registers the order for reviewers
+2. No decision within EscalateAfter => EscalateApproval + escalation notification
+3. No decision within RejectAfter   => automatic rejection
+*/
+
+// SignalApprovalDecision delivers a reviewer's decision
+// Payload: models.ApprovalDecision
+const SignalApprovalDecision = "approval-decision"
+
+// ApprovalPolicy decides which orders need a reviewer and how long to wait
+type ApprovalPolicy struct {
+	// Threshold: orders with TotalAmount above it need approval (<= 0 disables approvals)
+	Threshold float64
+	// EscalateAfter: how long to wait before escalating (0 disables escalation)
+	EscalateAfter time.Duration
+	// RejectAfter: how long to wait in total before rejecting automatically
+	RejectAfter time.Duration
+}
+
+// DefaultApprovalPolicy sends orders above 10000 to a reviewer before payment
+func DefaultApprovalPolicy() ApprovalPolicy {
+	return ApprovalPolicy{
+		Threshold:     10000,
+		EscalateAfter: 24 * time.Hour,
+		RejectAfter:   72 * time.Hour,
+	}
+}
+
+var approvalPolicy = DefaultApprovalPolicy()
+
+// SetApprovalPolicy configures the policy used by new workflow executions on this worker
+// Running executions keep the policy recorded in their history
+func SetApprovalPolicy(policy ApprovalPolicy) {
+	approvalPolicy = policy
+}
+
+// currentApprovalPolicy reads the worker policy through a SideEffect,
+// so a replay sees the same policy even if the worker configuration changed
+func currentApprovalPolicy(ctx workflow.Context) ApprovalPolicy {
+	var policy ApprovalPolicy
+	encoded := workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+		return approvalPolicy
+	})
+	if err := encoded.Get(&policy); err != nil {
+		return DefaultApprovalPolicy()
+	}
+	return policy
+}
+
+func (p ApprovalPolicy) requiresApproval(order models.Order) bool {
+	return p.Threshold > 0 && order.TotalAmount > p.Threshold
+}
+
+// awaitApproval blocks until a reviewer approves or rejects the order,
+// escalating and finally auto-rejecting when nobody answers in time
+// Returns nil when approved
+func awaitApproval(ctx workflow.Context, state *orderState, policy ApprovalPolicy) error {
+	logger := workflow.GetLogger(ctx)
+	order := state.order
+
+	previousStatus := order.Status
+	order.Status = "awaiting_approval"
+	state.record(ctx, "approval", "requested", fmt.Sprintf("amount %.2f exceeds %.2f", order.TotalAmount, policy.Threshold))
+	state.publishStatus(ctx)
+
+	if err := workflow.ExecuteActivity(ctx, "RequestApproval", *order).Get(ctx, nil); err != nil {
+		return fmt.Errorf("request approval failed: %w", err)
+	}
+
+	decisionCh := workflow.GetSignalChannel(ctx, SignalApprovalDecision)
+	deadline := workflow.Now(ctx).Add(policy.RejectAfter)
+	escalated := policy.EscalateAfter <= 0 || policy.EscalateAfter >= policy.RejectAfter
+
+	var decision *models.ApprovalDecision
+	for decision == nil {
+		wait := deadline.Sub(workflow.Now(ctx))
+		if !escalated {
+			wait = policy.EscalateAfter
+		}
+
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		timer := workflow.NewTimer(timerCtx, wait)
+
+		var timerErr error
+		timedOut := false
+		selector := workflow.NewSelector(ctx)
+		selector.AddReceive(decisionCh, func(c workflow.ReceiveChannel, more bool) {
+			var d models.ApprovalDecision
+			c.Receive(ctx, &d)
+			decision = &d
+		})
+		selector.AddFuture(timer, func(f workflow.Future) {
+			timerErr = f.Get(ctx, nil)
+			timedOut = timerErr == nil
+		})
+		selector.Select(ctx)
+		cancelTimer()
+
+		switch {
+		case decision != nil:
+		case timerErr != nil:
+			// the order itself was cancelled while waiting
+			return timerErr
+		case timedOut && !escalated:
+			escalated = true
+			if err := escalateApproval(ctx, state); err != nil {
+				logger.Error("Approval escalation failed", "Error", err)
+			}
+		case timedOut:
+			decision = &models.ApprovalDecision{
+				Approved: false,
+				Reviewer: "system",
+				Comment:  fmt.Sprintf("no decision within %s", policy.RejectAfter),
+			}
+		}
+	}
+
+	if err := workflow.ExecuteActivity(ctx, "ResolveApproval", order.OrderID, *decision).Get(ctx, nil); err != nil {
+		// the decision is already durable in our history; the store is best effort
+		logger.Warn("Failed to record approval decision", "Error", err)
+	}
+
+	if !decision.Approved {
+		order.Status = "rejected"
+		state.record(ctx, "approval", "rejected", fmt.Sprintf("%s: %s", decision.Reviewer, decision.Comment))
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("order rejected by %s", decision.Reviewer), "ORDER_REJECTED", nil)
+	}
+
+	order.Status = previousStatus
+	state.record(ctx, "approval", "approved", decision.Reviewer)
+	state.publishStatus(ctx)
+	logger.Info("Order approved", "OrderID", order.OrderID, "Reviewer", decision.Reviewer)
+	return nil
+}
+
+func escalateApproval(ctx workflow.Context, state *orderState) error {
+	order := state.order
+	state.record(ctx, "approval", "escalated", "")
+
+	if err := workflow.ExecuteActivity(ctx, "EscalateApproval", order.OrderID).Get(ctx, nil); err != nil {
+		return err
+	}
+
+	notification := models.NotificationRequest{
+		OrderID:     order.OrderID,
+		CustomerID:  order.CustomerID,
+		MessageType: "approval_escalation",
+		Message:     fmt.Sprintf("Order %s (%.2f) is still waiting for approval", order.OrderID, order.TotalAmount),
+	}
+	return workflow.ExecuteActivity(ctx, "SendNotification", notification).Get(ctx, nil)
+}
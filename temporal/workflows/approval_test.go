@@ -0,0 +1,181 @@
+package workflows
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"temporal/models"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+type ApprovalTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestApprovalTestSuite(t *testing.T) {
+	suite.Run(t, new(ApprovalTestSuite))
+}
+
+func (s *ApprovalTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	registerOrderWorkflows(s.env)
+
+	s.env.OnActivity("ValidateOrder", mock.Anything, mock.Anything).Return(nil)
+}
+
+func (s *ApprovalTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+func highValueOrder() models.Order {
+	order := testOrder()
+	order.TotalAmount = 25000
+	return order
+}
+
+func (s *ApprovalTestSuite) expectFulfillment() {
+	s.env.OnWorkflow(PaymentWorkflow, mock.Anything, mock.Anything).Return(testPayment(), nil)
+	s.env.OnActivity("ReserveInventory", mock.Anything, mock.Anything).Return(testReservation(), nil)
+	s.env.OnActivity("ConfirmInventory", mock.Anything, mock.Anything).Return(testReservation(), nil)
+	s.env.OnActivity("SendNotification", mock.Anything, mock.Anything).Return(nil)
+	s.env.OnWorkflow(FulfillmentWorkflow, mock.Anything, mock.Anything).Return(testFulfillment(), nil)
+}
+
+func (s *ApprovalTestSuite) Test_BelowThreshold_NoApproval() {
+	s.expectFulfillment()
+
+	s.env.ExecuteWorkflow(OrderWorkflow, testOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+	s.env.AssertActivityNotCalled(s.T(), "RequestApproval", mock.Anything, mock.Anything)
+}
+
+func (s *ApprovalTestSuite) Test_Approved() {
+	s.expectFulfillment()
+	s.env.OnActivity("RequestApproval", mock.Anything, mock.Anything).Return(nil).Once()
+	s.env.OnActivity("ResolveApproval", mock.Anything, "order-1", mock.MatchedBy(func(d models.ApprovalDecision) bool {
+		return d.Approved && d.Reviewer == "alice"
+	})).Return(nil).Once()
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(SignalApprovalDecision, models.ApprovalDecision{Approved: true, Reviewer: "alice"})
+	}, 2*time.Hour)
+
+	s.env.ExecuteWorkflow(OrderWorkflow, highValueOrder())
+
+	var status string
+	s.NoError(s.env.GetWorkflowResult(&status))
+	s.Equal("completed", status)
+	s.env.AssertActivityNotCalled(s.T(), "EscalateApproval", mock.Anything, mock.Anything)
+}
+
+func (s *ApprovalTestSuite) Test_RejectedByReviewer() {
+	s.env.OnActivity("RequestApproval", mock.Anything, mock.Anything).Return(nil).Once()
+	s.env.OnActivity("ResolveApproval", mock.Anything, "order-1", mock.Anything).Return(nil).Once()
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(SignalApprovalDecision, models.ApprovalDecision{Approved: false, Reviewer: "alice", Comment: "fraud"})
+	}, time.Hour)
+
+	s.env.ExecuteWorkflow(OrderWorkflow, highValueOrder())
+
+	s.True(hasErrorType(s.env.GetWorkflowError(), "ORDER_REJECTED"))
+	s.env.AssertWorkflowNotCalled(s.T(), "PaymentWorkflow", mock.Anything, mock.Anything)
+}
+
+func (s *ApprovalTestSuite) Test_NoDecision_EscalatesThenAutoRejects() {
+	policy := DefaultApprovalPolicy()
+	start := s.env.Now()
+
+	s.env.OnActivity("RequestApproval", mock.Anything, mock.Anything).Return(nil).Once()
+	s.env.OnActivity("EscalateApproval", mock.Anything, "order-1").Return(nil).Once()
+	s.env.OnActivity("SendNotification", mock.Anything, mock.MatchedBy(func(n models.NotificationRequest) bool {
+		return n.MessageType == "approval_escalation"
+	})).Return(nil).Once()
+	s.env.OnActivity("ResolveApproval", mock.Anything, "order-1", mock.MatchedBy(func(d models.ApprovalDecision) bool {
+		return !d.Approved && d.Reviewer == "system"
+	})).Return(nil).Once()
+
+	var escalatedAt time.Time
+	s.env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+		if info.ActivityType.Name == "EscalateApproval" {
+			escalatedAt = s.env.Now()
+		}
+	})
+
+	s.env.ExecuteWorkflow(OrderWorkflow, highValueOrder())
+
+	s.Error(s.env.GetWorkflowError())
+	s.env.AssertWorkflowNotCalled(s.T(), "PaymentWorkflow", mock.Anything, mock.Anything)
+	s.Equal(policy.EscalateAfter, escalatedAt.Sub(start).Round(time.Minute))
+	s.GreaterOrEqual(s.env.Now().Sub(start), policy.RejectAfter)
+
+	value, err := s.env.QueryWorkflow(QueryGetStatus)
+	s.Require().NoError(err)
+	var status models.OrderStatus
+	s.Require().NoError(value.Get(&status))
+	s.Equal("rejected", status.Status)
+}
+
+func (s *ApprovalTestSuite) Test_CancelledWhileAwaitingApproval() {
+	s.env.OnActivity("RequestApproval", mock.Anything, mock.Anything).Return(nil).Once()
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(SignalCancelOrder, models.CancelOrderRequest{Reason: "customer request"})
+	}, time.Hour)
+
+	s.env.ExecuteWorkflow(OrderWorkflow, highValueOrder())
+
+	s.True(temporal.IsCanceledError(s.env.GetWorkflowError()))
+	s.env.AssertActivityNotCalled(s.T(), "EscalateApproval", mock.Anything, mock.Anything)
+}
+
+// orderWithRetries runs OrderWorkflow with the workflow retry policy of orders.StartOptions;
+// the test environment only retries child workflows
+func orderWithRetries(ctx workflow.Context, order models.Order) (string, error) {
+	ctx = workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID: OrderWorkflowID(order.OrderID),
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    2 * time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    1 * time.Minute,
+			MaximumAttempts:    3,
+		},
+	})
+	var status string
+	err := workflow.ExecuteChildWorkflow(ctx, OrderWorkflow, order).Get(ctx, &status)
+	return status, err
+}
+
+func (s *ApprovalTestSuite) Test_RejectionIsNotRetried() {
+	s.env.RegisterWorkflow(OrderWorkflow)
+	s.env.RegisterWorkflow(orderWithRetries)
+	s.env.OnActivity("RequestApproval", mock.Anything, mock.Anything).Return(nil)
+	s.env.OnActivity("EscalateApproval", mock.Anything, mock.Anything).Return(nil)
+	s.env.OnActivity("SendNotification", mock.Anything, mock.Anything).Return(nil)
+	s.env.OnActivity("ResolveApproval", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	requests := 0
+	s.env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+		if info.ActivityType.Name == "RequestApproval" {
+			requests++
+		}
+	})
+
+	s.env.ExecuteWorkflow(orderWithRetries, highValueOrder())
+
+	err := s.env.GetWorkflowError()
+	s.True(hasErrorType(err, "ORDER_REJECTED"))
+	s.Equal(1, requests, "a rejected order must not start over with a new approval window")
+}
@@ -0,0 +1,190 @@
+package workflows
+
+import (
+	"fmt"
+	"temporal/models"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+/*
+BATCH PROCESSING:
+
+BatchOrderWorkflow runs one OrderWorkflow child per order of a batch file:
+
+1. Bounded fan-out
+   - At most Concurrency children run at a time; a Selector waits for the
+     next one to finish before starting another
+   - Each order keeps its own saga, retries and approval; a failed order
+     does not fail the batch, it is counted in the report
+
+2. Paging
+   - Orders are loaded a page at a time by an activity, never passed in bulk
+     through the workflow input
+
+3. Continue-as-new
+   - Every event (child started, child completed, ...) stays in the history,
+     which is capped at 51,200 events / 50 MB
+   - After OrdersPerRun orders (or when the server suggests it) the workflow
+     continues as new with the offset and the report so far; the new run
+     starts with an empty history and the same workflow ID
+*/
+
+// QueryBatchProgress returns the models.BatchReport of a running or finished batch
+const QueryBatchProgress = "get-batch-progress"
+
+const (
+	defaultBatchConcurrency  = 10
+	defaultBatchPageSize     = 100
+	defaultBatchOrdersPerRun = 1000
+	maxReportedFailures      = 50
+)
+
+// BatchOrderWorkflow processes every order of a batch file as an OrderWorkflow child
+func BatchOrderWorkflow(ctx workflow.Context, req models.BatchRequest) (*models.BatchReport, error) {
+	logger := workflow.GetLogger(ctx)
+
+	if req.Concurrency <= 0 {
+		req.Concurrency = defaultBatchConcurrency
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = defaultBatchPageSize
+	}
+	if req.OrdersPerRun <= 0 {
+		req.OrdersPerRun = defaultBatchOrdersPerRun
+	}
+
+	report := req.Report
+	if report.BatchID == "" {
+		report.BatchID = req.BatchID
+	}
+	if report.StartedAt.IsZero() {
+		report.StartedAt = workflow.Now(ctx)
+	}
+	report.Runs++
+	logger.Info("Batch workflow started", "BatchID", report.BatchID, "Offset", req.Offset, "Run", report.Runs)
+
+	if err := workflow.SetQueryHandler(ctx, QueryBatchProgress, func() (models.BatchReport, error) {
+		return report, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        1 * time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        1 * time.Minute,
+			MaximumAttempts:        5,
+			NonRetryableErrorTypes: []string{"INVALID_BATCH_FILE"},
+		},
+	})
+
+	processedThisRun := 0
+	for {
+		var page *models.BatchPage
+		err := workflow.ExecuteActivity(ctx, "LoadOrderPage", req.Source, req.Offset, req.PageSize, req.Cursor).Get(ctx, &page)
+		if err != nil {
+			return &report, fmt.Errorf("load batch page at %d failed: %w", req.Offset, err)
+		}
+		if req.Cursor == 0 {
+			report.Total = page.Total
+		}
+		if len(page.Orders) == 0 {
+			break
+		}
+
+		results := runBatchPage(ctx, page.Orders, req.Concurrency, &report)
+
+		if req.ReportPath != "" {
+			if err := workflow.ExecuteActivity(ctx, "AppendBatchResults", req.ReportPath, results).Get(ctx, nil); err != nil {
+				// The counts in the report are still correct; only the file misses this page
+				logger.Error("Batch report write failed", "Offset", req.Offset, "Error", err)
+			}
+		}
+
+		req.Offset += len(page.Orders)
+		req.Cursor = page.Next
+		processedThisRun += len(page.Orders)
+		if req.Offset >= report.Total {
+			break
+		}
+
+		// Keep the history short: hand the progress to a fresh run
+		if processedThisRun >= req.OrdersPerRun || workflow.GetInfo(ctx).GetContinueAsNewSuggested() {
+			logger.Info("Batch continues as new", "BatchID", report.BatchID, "Offset", req.Offset)
+			req.Report = report
+			return nil, workflow.NewContinueAsNewError(ctx, BatchOrderWorkflow, req)
+		}
+	}
+
+	report.Done = true
+	report.FinishedAt = workflow.Now(ctx)
+	logger.Info("Batch workflow completed", "BatchID", report.BatchID,
+		"Succeeded", report.Succeeded, "Failed", report.Failed, "Duplicates", report.Duplicates)
+	return &report, nil
+}
+
+// runBatchPage runs the orders of one page with at most concurrency children at a time
+// and returns their results in page order
+func runBatchPage(ctx workflow.Context, orders []models.Order, concurrency int, report *models.BatchReport) []models.BatchOrderResult {
+	results := make([]models.BatchOrderResult, len(orders))
+	selector := workflow.NewSelector(ctx)
+
+	for i, order := range orders {
+		// Wait for a free slot
+		if report.InFlight >= concurrency {
+			selector.Select(ctx)
+		}
+
+		workflowID := OrderWorkflowID(order.OrderID)
+		childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+			WorkflowID:            workflowID,
+			TypedSearchAttributes: OrderSearchAttributes(order),
+
+			// Cancelling the batch cancels the orders, which unwind their sagas;
+			// TERMINATE would skip the compensations
+			ParentClosePolicy: enumspb.PARENT_CLOSE_POLICY_REQUEST_CANCEL,
+
+			// Re-running a batch file skips the orders that already went through
+			WorkflowIDReusePolicy: enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE_FAILED_ONLY,
+		})
+
+		report.InFlight++
+		future := workflow.ExecuteChildWorkflow(childCtx, OrderWorkflow, order)
+		selector.AddFuture(future, func(f workflow.Future) {
+			report.InFlight--
+			report.Processed++
+
+			result := models.BatchOrderResult{OrderID: order.OrderID, WorkflowID: workflowID}
+			var status string
+			err := f.Get(ctx, &status)
+			switch {
+			case err == nil:
+				result.Status = status
+				report.Succeeded++
+			case temporal.IsWorkflowExecutionAlreadyStartedError(err):
+				result.Status = "duplicate"
+				result.Error = err.Error()
+				report.Duplicates++
+			default:
+				result.Status = "failed"
+				result.Error = err.Error()
+				report.Failed++
+				if len(report.Failures) < maxReportedFailures {
+					report.Failures = append(report.Failures, result)
+				}
+			}
+			results[i] = result
+		})
+	}
+
+	for report.InFlight > 0 {
+		selector.Select(ctx)
+	}
+	return results
+}
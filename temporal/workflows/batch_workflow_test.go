@@ -0,0 +1,187 @@
+package workflows
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"temporal/activities"
+	"temporal/models"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+type BatchWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+	dir string
+}
+
+func TestBatchWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(BatchWorkflowTestSuite))
+}
+
+func (s *BatchWorkflowTestSuite) SetupTest() {
+	s.env = s.newEnv()
+	s.dir = s.T().TempDir()
+}
+
+func (s *BatchWorkflowTestSuite) newEnv() *testsuite.TestWorkflowEnvironment {
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(OrderWorkflow)
+	env.RegisterActivity(&activities.BatchActivities{})
+	return env
+}
+
+// writeBatch writes n orders order-1..order-n as a JSON batch file
+func (s *BatchWorkflowTestSuite) writeBatch(n int) string {
+	orders := make([]models.Order, n)
+	for i := range orders {
+		orders[i] = models.Order{OrderID: fmt.Sprintf("order-%d", i+1), CustomerID: "customer-1"}
+	}
+	data, err := json.Marshal(orders)
+	s.Require().NoError(err)
+	path := filepath.Join(s.dir, "orders.json")
+	s.Require().NoError(os.WriteFile(path, data, 0o644))
+	return path
+}
+
+// mockOrders completes every order after a minute, failing the ones in failed
+func mockOrders(env *testsuite.TestWorkflowEnvironment, failed ...string) {
+	env.OnWorkflow(OrderWorkflow, mock.Anything, mock.Anything).After(time.Minute).Return(
+		func(_ workflow.Context, order models.Order) (string, error) {
+			for _, id := range failed {
+				if order.OrderID == id {
+					return "payment_failed", temporal.NewNonRetryableApplicationError("declined", "INSUFFICIENT_FUNDS", nil)
+				}
+			}
+			return "completed", nil
+		})
+}
+
+func (s *BatchWorkflowTestSuite) Test_ProcessesEveryOrder() {
+	mockOrders(s.env, "order-3")
+	report := filepath.Join(s.dir, "report.csv")
+
+	s.env.ExecuteWorkflow(BatchOrderWorkflow, models.BatchRequest{
+		BatchID:    "batch-1",
+		Source:     s.writeBatch(5),
+		ReportPath: report,
+		PageSize:   2,
+	})
+
+	s.Require().NoError(s.env.GetWorkflowError())
+	var result models.BatchReport
+	s.Require().NoError(s.env.GetWorkflowResult(&result))
+	s.True(result.Done)
+	s.Equal(5, result.Total)
+	s.Equal(5, result.Processed)
+	s.Equal(4, result.Succeeded)
+	s.Equal(1, result.Failed)
+	s.Require().Len(result.Failures, 1)
+	s.Equal("order-3", result.Failures[0].OrderID)
+
+	orders, err := os.ReadFile(report)
+	s.Require().NoError(err)
+	s.Contains(string(orders), "order-3,order-workflow-order-3,failed,")
+	s.Contains(string(orders), "order-5,order-workflow-order-5,completed,")
+}
+
+func (s *BatchWorkflowTestSuite) Test_BoundedConcurrency() {
+	mockOrders(s.env)
+	running, maxRunning := 0, 0
+	s.env.SetOnChildWorkflowStartedListener(func(*workflow.Info, workflow.Context, converter.EncodedValues) {
+		running++
+		maxRunning = max(maxRunning, running)
+	})
+	s.env.SetOnChildWorkflowCompletedListener(func(*workflow.Info, converter.EncodedValue, error) {
+		running--
+	})
+
+	s.env.ExecuteWorkflow(BatchOrderWorkflow, models.BatchRequest{Source: s.writeBatch(10), Concurrency: 3})
+
+	s.Require().NoError(s.env.GetWorkflowError())
+	s.Equal(3, maxRunning)
+}
+
+func (s *BatchWorkflowTestSuite) Test_ProgressQuery() {
+	mockOrders(s.env)
+	s.env.RegisterDelayedCallback(func() {
+		value, err := s.env.QueryWorkflow(QueryBatchProgress)
+		s.Require().NoError(err)
+		var progress models.BatchReport
+		s.Require().NoError(value.Get(&progress))
+		s.False(progress.Done)
+		s.Equal(4, progress.Total)
+		s.Equal(2, progress.InFlight)
+		s.Equal(0, progress.Processed)
+	}, 30*time.Second)
+
+	s.env.ExecuteWorkflow(BatchOrderWorkflow, models.BatchRequest{Source: s.writeBatch(4), Concurrency: 2})
+
+	s.Require().NoError(s.env.GetWorkflowError())
+}
+
+func (s *BatchWorkflowTestSuite) Test_ContinuesAsNew() {
+	mockOrders(s.env, "order-4")
+	source := s.writeBatch(5)
+
+	s.env.ExecuteWorkflow(BatchOrderWorkflow, models.BatchRequest{
+		BatchID:      "batch-1",
+		Source:       source,
+		PageSize:     2,
+		OrdersPerRun: 2,
+	})
+
+	// The first run stops after one page and hands its progress to the next run
+	var canErr *workflow.ContinueAsNewError
+	s.Require().True(errors.As(s.env.GetWorkflowError(), &canErr))
+	var next models.BatchRequest
+	s.Require().NoError(converter.GetDefaultDataConverter().FromPayloads(canErr.Input, &next))
+	s.Equal(2, next.Offset)
+	s.NotZero(next.Cursor)
+	s.Equal(2, next.Report.Succeeded)
+	s.Equal(1, next.Report.Runs)
+
+	// Run the remaining runs until the batch is done
+	var result models.BatchReport
+	for run := 2; ; run++ {
+		env := s.newEnv()
+		mockOrders(env, "order-4")
+		env.ExecuteWorkflow(BatchOrderWorkflow, next)
+		err := env.GetWorkflowError()
+		if errors.As(err, &canErr) {
+			s.Require().NoError(converter.GetDefaultDataConverter().FromPayloads(canErr.Input, &next))
+			continue
+		}
+		s.Require().NoError(err)
+		s.Require().NoError(env.GetWorkflowResult(&result))
+		s.Equal(run, result.Runs)
+		break
+	}
+
+	s.True(result.Done)
+	s.Equal("batch-1", result.BatchID)
+	s.Equal(5, result.Processed)
+	s.Equal(4, result.Succeeded)
+	s.Equal(1, result.Failed)
+	s.Equal(3, result.Runs)
+}
+
+func (s *BatchWorkflowTestSuite) Test_InvalidBatchFile() {
+	s.env.ExecuteWorkflow(BatchOrderWorkflow, models.BatchRequest{Source: filepath.Join(s.dir, "missing.csv")})
+
+	err := s.env.GetWorkflowError()
+	s.True(hasErrorType(err, "INVALID_BATCH_FILE"), "unexpected error: %v", err)
+}
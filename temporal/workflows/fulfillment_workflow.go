@@ -0,0 +1,175 @@
+package workflows
+
+import (
+	"fmt"
+	"temporal/models"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+/*
+LONG-RUNNING CHILD WORKFLOWS:
+
+Delivery takes days. A workflow can simply wait that long:
+- workflow.NewTimer is durable - it is stored on the server, not in worker memory
+- Workers can restart or be redeployed while the timer is pending
+- Tracking updates arrive as signals (carrier webhooks) and are buffered
+  in the signal channel until the workflow reads them
+
+The carrier is polled as a fallback when no update has been pushed for a while.
+If the parcel is lost or returned, the child fails with a non-retryable error
+and the parent's saga refunds the payment and compensates the inventory.
+*/
+
+// SignalTrackingUpdate delivers a carrier tracking update to FulfillmentWorkflow
+// Payload: models.TrackingUpdate
+const SignalTrackingUpdate = "tracking-update"
+
+const (
+	defaultDeliveryTimeout = 14 * 24 * time.Hour
+	defaultPollInterval    = 12 * time.Hour
+)
+
+// FulfillmentWorkflow ships an order and waits for the parcel to be delivered
+// It demonstrates:
+// - Durable timers spanning days
+// - Signals from external systems (carrier tracking)
+// - Polling fallback and compensation on cancellation
+func FulfillmentWorkflow(ctx workflow.Context, req models.FulfillmentRequest) (*models.FulfillmentResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Fulfillment workflow started", "OrderID", req.Order.OrderID)
+
+	if req.DeliveryTimeout <= 0 {
+		req.DeliveryTimeout = defaultDeliveryTimeout
+	}
+	if req.PollInterval <= 0 {
+		req.PollInterval = defaultPollInterval
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        1 * time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        1 * time.Minute,
+			MaximumAttempts:        10,
+			NonRetryableErrorTypes: []string{"INVALID_ADDRESS"},
+		},
+	})
+
+	// Step 1: Book the shipment
+	var shipment *models.Shipment
+	err := workflow.ExecuteActivity(ctx, "CreateShipment", req.Order).Get(ctx, &shipment)
+	if err != nil {
+		logger.Error("Shipment creation failed", "Error", err)
+		return nil, fmt.Errorf("create shipment failed: %w", err)
+	}
+
+	result := &models.FulfillmentResult{Shipment: *shipment, Status: shipment.Status}
+	if err := workflow.SetQueryHandler(ctx, QueryGetStatus, func() (models.FulfillmentResult, error) {
+		return *result, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// COMPENSATION: cancel the shipment if the order is cancelled while in transit
+	defer func() {
+		if !temporal.IsCanceledError(ctx.Err()) || isFinalTrackingStatus(result.Status) {
+			return
+		}
+		disconnectedCtx, _ := workflow.NewDisconnectedContext(ctx)
+		if err := workflow.ExecuteActivity(disconnectedCtx, "CancelShipment", shipment).Get(disconnectedCtx, nil); err != nil {
+			logger.Error("Shipment cancellation failed", "TrackingNumber", shipment.TrackingNumber, "Error", err)
+		}
+	}()
+
+	// Step 2: Wait for delivery
+	trackingCh := workflow.GetSignalChannel(ctx, SignalTrackingUpdate)
+	deadline := workflow.Now(ctx).Add(req.DeliveryTimeout)
+
+	for !isFinalTrackingStatus(result.Status) {
+		remaining := deadline.Sub(workflow.Now(ctx))
+		if remaining <= 0 {
+			logger.Warn("No delivery before deadline, treating parcel as lost", "TrackingNumber", shipment.TrackingNumber)
+			applyTrackingUpdate(result, models.TrackingUpdate{
+				TrackingNumber: shipment.TrackingNumber,
+				Status:         "lost",
+				Description:    fmt.Sprintf("not delivered within %s", req.DeliveryTimeout),
+				Time:           workflow.Now(ctx),
+			})
+			break
+		}
+
+		wait := req.PollInterval
+		if remaining < wait {
+			wait = remaining
+		}
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		timer := workflow.NewTimer(timerCtx, wait)
+
+		var waitErr error
+		selector := workflow.NewSelector(ctx)
+		selector.AddReceive(trackingCh, func(c workflow.ReceiveChannel, more bool) {
+			var update models.TrackingUpdate
+			c.Receive(ctx, &update)
+			applyTrackingUpdate(result, update)
+		})
+		selector.AddFuture(timer, func(f workflow.Future) {
+			if waitErr = f.Get(ctx, nil); waitErr != nil {
+				return
+			}
+			// No push update for a while: ask the carrier
+			var update *models.TrackingUpdate
+			if err := workflow.ExecuteActivity(ctx, "GetTrackingStatus", shipment.TrackingNumber).Get(ctx, &update); err != nil {
+				if temporal.IsCanceledError(err) {
+					waitErr = err
+					return
+				}
+				logger.Warn("Tracking poll failed", "Error", err)
+				return
+			}
+			applyTrackingUpdate(result, *update)
+		})
+		selector.Select(ctx)
+		cancelTimer()
+
+		if waitErr != nil {
+			return nil, waitErr
+		}
+	}
+
+	switch result.Status {
+	case "delivered":
+		logger.Info("Order delivered", "OrderID", req.Order.OrderID, "TrackingNumber", shipment.TrackingNumber)
+		return result, nil
+	case "returned":
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("shipment %s returned to sender", shipment.TrackingNumber), "SHIPMENT_RETURNED", nil, *result)
+	default:
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("shipment %s lost", shipment.TrackingNumber), "SHIPMENT_LOST", nil, *result)
+	}
+}
+
+func applyTrackingUpdate(result *models.FulfillmentResult, update models.TrackingUpdate) {
+	// Ignore stale updates once the parcel reached a final state
+	if isFinalTrackingStatus(result.Status) {
+		return
+	}
+	result.Updates = append(result.Updates, update)
+	result.Status = update.Status
+	result.Shipment.Status = update.Status
+	if update.Status == "delivered" {
+		result.DeliveredAt = update.Time
+	}
+}
+
+func isFinalTrackingStatus(status string) bool {
+	switch status {
+	case "delivered", "lost", "returned":
+		return true
+	}
+	return false
+}
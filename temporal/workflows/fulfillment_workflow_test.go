@@ -0,0 +1,154 @@
+package workflows
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"temporal/activities"
+	"temporal/models"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+type FulfillmentTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env     *testsuite.TestWorkflowEnvironment
+	carrier *activities.FakeCarrier
+}
+
+func TestFulfillmentTestSuite(t *testing.T) {
+	suite.Run(t, new(FulfillmentTestSuite))
+}
+
+func (s *FulfillmentTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.carrier = activities.NewFakeCarrier()
+	s.env.RegisterActivity(&activities.ShippingActivities{Carrier: s.carrier})
+}
+
+func fulfillmentRequest() models.FulfillmentRequest {
+	return models.FulfillmentRequest{Order: testOrder(), ReservationID: "res-1"}
+}
+
+func (s *FulfillmentTestSuite) Test_DeliveredThroughTrackingSignals() {
+	start := s.env.Now()
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(SignalTrackingUpdate, models.TrackingUpdate{Status: "in_transit", Location: "hub"})
+	}, time.Hour)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(SignalTrackingUpdate, models.TrackingUpdate{Status: "delivered", Time: start.Add(6 * time.Hour)})
+	}, 6*time.Hour)
+
+	s.env.ExecuteWorkflow(FulfillmentWorkflow, fulfillmentRequest())
+
+	var result models.FulfillmentResult
+	s.Require().NoError(s.env.GetWorkflowResult(&result))
+	s.Equal("delivered", result.Status)
+	s.Equal("FAKE-order-1", result.Shipment.TrackingNumber)
+	s.Len(result.Updates, 2)
+	s.False(result.DeliveredAt.IsZero())
+}
+
+func (s *FulfillmentTestSuite) Test_DeliveredThroughPolling() {
+	start := s.env.Now()
+
+	s.env.ExecuteWorkflow(FulfillmentWorkflow, fulfillmentRequest())
+
+	var result models.FulfillmentResult
+	s.Require().NoError(s.env.GetWorkflowResult(&result))
+	s.Equal("delivered", result.Status)
+	// label_created => in_transit => out_for_delivery => delivered, one poll every 12h
+	s.Equal(3*defaultPollInterval, s.env.Now().Sub(start).Round(time.Hour))
+}
+
+func (s *FulfillmentTestSuite) Test_NoDeliveryBeforeDeadline_Lost() {
+	s.carrier.Route = []string{"label_created", "in_transit"}
+	start := s.env.Now()
+
+	s.env.ExecuteWorkflow(FulfillmentWorkflow, fulfillmentRequest())
+
+	s.True(hasErrorType(s.env.GetWorkflowError(), "SHIPMENT_LOST"))
+	s.Equal(defaultDeliveryTimeout, s.env.Now().Sub(start).Round(time.Hour))
+}
+
+func (s *FulfillmentTestSuite) Test_Returned() {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(SignalTrackingUpdate, models.TrackingUpdate{Status: "returned", Description: "refused by recipient"})
+	}, 2*time.Hour)
+
+	s.env.ExecuteWorkflow(FulfillmentWorkflow, fulfillmentRequest())
+
+	s.True(hasErrorType(s.env.GetWorkflowError(), "SHIPMENT_RETURNED"))
+}
+
+func (s *FulfillmentTestSuite) Test_InvalidAddress_NotRetried() {
+	req := fulfillmentRequest()
+	req.Order.ShippingAddress = models.ShippingAddress{}
+
+	s.env.ExecuteWorkflow(FulfillmentWorkflow, req)
+
+	s.True(hasErrorType(s.env.GetWorkflowError(), "INVALID_ADDRESS"))
+}
+
+func (s *FulfillmentTestSuite) Test_Cancelled_CancelsShipment() {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.CancelWorkflow()
+	}, time.Hour)
+
+	s.env.ExecuteWorkflow(FulfillmentWorkflow, fulfillmentRequest())
+
+	s.True(temporal.IsCanceledError(s.env.GetWorkflowError()))
+	update, err := s.carrier.GetTracking(context.Background(), "FAKE-order-1")
+	s.Require().NoError(err)
+	s.Equal("cancelled", update.Status)
+}
+
+// OrderWorkflow compensates payment and inventory when the parcel never arrives
+type OrderFulfillmentTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestOrderFulfillmentTestSuite(t *testing.T) {
+	suite.Run(t, new(OrderFulfillmentTestSuite))
+}
+
+func (s *OrderFulfillmentTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	registerOrderWorkflows(s.env)
+}
+
+func (s *OrderFulfillmentTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *OrderFulfillmentTestSuite) Test_LostParcel_RefundsAndReleasesInventory() {
+	s.env.OnActivity("ValidateOrder", mock.Anything, mock.Anything).Return(nil)
+	s.env.OnWorkflow(PaymentWorkflow, mock.Anything, mock.Anything).Return(testPayment(), nil)
+	s.env.OnActivity("ReserveInventory", mock.Anything, mock.Anything).Return(testReservation(), nil)
+	s.env.OnActivity("ConfirmInventory", mock.Anything, mock.Anything).Return(testReservation(), nil)
+	s.env.OnActivity("SendNotification", mock.Anything, mock.Anything).Return(nil)
+	s.env.OnWorkflow(FulfillmentWorkflow, mock.Anything, mock.Anything).
+		Return(nil, temporal.NewNonRetryableApplicationError("shipment FAKE-order-1 lost", "SHIPMENT_LOST", nil))
+	s.env.OnActivity("CompensateInventory", mock.Anything, mock.Anything).Return(nil).Once()
+	s.env.OnActivity("RefundPayment", mock.Anything, mock.Anything).Return(testPayment(), nil).Once()
+
+	s.env.ExecuteWorkflow(OrderWorkflow, testOrder())
+
+	err := s.env.GetWorkflowError()
+	s.True(hasErrorType(err, "SHIPMENT_LOST"), "unexpected error: %v", err)
+
+	value, qErr := s.env.QueryWorkflow(QueryGetStatus)
+	s.Require().NoError(qErr)
+	var status models.OrderStatus
+	s.Require().NoError(value.Get(&status))
+	s.Equal("shipment_lost", status.Status)
+}
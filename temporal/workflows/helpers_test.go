@@ -0,0 +1,79 @@
+package workflows
+
+import (
+	"errors"
+	"temporal/activities"
+	"temporal/models"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// registerOrderWorkflows registers the child workflows and every activity set used by OrderWorkflow
+func registerOrderWorkflows(env *testsuite.TestWorkflowEnvironment) {
+	env.RegisterWorkflow(PaymentWorkflow)
+	env.RegisterWorkflow(FulfillmentWorkflow)
+	env.RegisterWorkflow(NotificationWorkflow)
+	env.RegisterActivity(&activities.OrderActivities{})
+	env.RegisterActivity(&activities.PaymentActivities{})
+	env.RegisterActivity(&activities.ApprovalActivities{})
+	env.RegisterActivity(&activities.ShippingActivities{})
+	env.RegisterActivity(&activities.NotificationActivities{})
+}
+
+// hasErrorType reports whether any ApplicationError in err's chain has the given type
+func hasErrorType(err error, errType string) bool {
+	for err != nil {
+		var appErr *temporal.ApplicationError
+		if !errors.As(err, &appErr) {
+			return false
+		}
+		if appErr.Type() == errType {
+			return true
+		}
+		err = appErr.Unwrap()
+	}
+	return false
+}
+
+func testOrder() models.Order {
+	return models.Order{
+		OrderID:     "order-1",
+		CustomerID:  "customer-1",
+		Items:       []models.OrderItem{{ProductID: "prod-001", Quantity: 1, Price: 10}},
+		TotalAmount: 10,
+		Status:      "pending",
+		ShippingAddress: models.ShippingAddress{
+			Street: "1 Main St", City: "Springfield", PostalCode: "12345", Country: "US",
+		},
+	}
+}
+
+func testPayment() *models.PaymentInfo {
+	return &models.PaymentInfo{
+		PaymentID:       "pay-order-1",
+		OrderID:         "order-1",
+		Amount:          10,
+		AuthorizationID: "auth-1",
+		CaptureID:       "cap-1",
+		Status:          "captured",
+	}
+}
+
+func authorizedPayment() *models.PaymentInfo {
+	p := testPayment()
+	p.CaptureID = ""
+	p.Status = "authorized"
+	return p
+}
+
+func testReservation() *models.InventoryReservation {
+	return &models.InventoryReservation{ReservationID: "res-1", OrderID: "order-1", Status: "reserved"}
+}
+
+func testFulfillment() *models.FulfillmentResult {
+	return &models.FulfillmentResult{
+		Shipment: models.Shipment{ShipmentID: "shp-order-1", OrderID: "order-1", TrackingNumber: "FAKE-order-1", Status: "delivered"},
+		Status:   "delivered",
+	}
+}
@@ -0,0 +1,34 @@
+package workflows
+
+import (
+	"temporal/models"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// InventorySyncWorkflow applies a warehouse stock count to the inventory
+// The SyncInventory activity may run for a long time: HeartbeatTimeout detects a
+// lost worker within a minute, and the retry resumes from the last heartbeat
+// instead of starting over (see activities/heartbeat.go)
+func InventorySyncWorkflow(ctx workflow.Context, req models.InventorySyncRequest) (*models.InventorySyncResult, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 2 * time.Hour,
+		HeartbeatTimeout:    time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        5 * time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        time.Minute,
+			MaximumAttempts:        10,
+			NonRetryableErrorTypes: []string{"INVALID_STOCK_FILE"},
+		},
+	})
+
+	var result models.InventorySyncResult
+	if err := workflow.ExecuteActivity(ctx, "SyncInventory", req).Get(ctx, &result); err != nil {
+		return nil, err
+	}
+	workflow.GetLogger(ctx).Info("Inventory synced", "Total", result.Total, "Changed", result.Changed, "Attempts", result.Attempts)
+	return &result, nil
+}
@@ -0,0 +1,39 @@
+package workflows
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"temporal/activities"
+	"temporal/inventory"
+	"temporal/models"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestInventorySyncWorkflow_ResumesAfterFailure(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	store := inventory.NewMemoryStore()
+	faults := activities.NewFaultModel(1).Set("SyncInventory", activities.Fault{FailAttempts: 1})
+	env.RegisterActivity(&activities.InventorySyncActivities{Inventory: store, Faults: faults})
+
+	source := filepath.Join(t.TempDir(), "stock.csv")
+	require.NoError(t, os.WriteFile(source, []byte("product_id,on_hand\nprod-001,10\nprod-002,5\nprod-003,7\n"), 0o644))
+
+	env.ExecuteWorkflow(InventorySyncWorkflow, models.InventorySyncRequest{Source: source, BatchSize: 2})
+
+	require.NoError(t, env.GetWorkflowError())
+	var result models.InventorySyncResult
+	require.NoError(t, env.GetWorkflowResult(&result))
+	// The first attempt failed after heartbeating its first batch
+	require.Equal(t, int32(2), result.Attempts)
+	require.Equal(t, 2, result.ResumedFrom)
+	require.Equal(t, 3, result.Processed)
+	level, err := store.Stock(context.Background(), "prod-003")
+	require.NoError(t, err)
+	require.Equal(t, 7, level.OnHand)
+}
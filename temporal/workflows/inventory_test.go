@@ -0,0 +1,97 @@
+package workflows
+
+import (
+	"context"
+	"testing"
+
+	"temporal/activities"
+	"temporal/inventory"
+	"temporal/models"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// OrderWorkflow against the real inventory activities backed by the in-memory store
+type InventoryTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env   *testsuite.TestWorkflowEnvironment
+	store *inventory.MemoryStore
+}
+
+func TestInventoryTestSuite(t *testing.T) {
+	suite.Run(t, new(InventoryTestSuite))
+}
+
+func (s *InventoryTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.store = inventory.NewMemoryStore()
+	s.Require().NoError(s.store.Restock(context.Background(), "prod-001", 3))
+
+	s.env.RegisterWorkflow(PaymentWorkflow)
+	s.env.RegisterWorkflow(FulfillmentWorkflow)
+	s.env.RegisterActivity(&activities.OrderActivities{Inventory: s.store})
+	s.env.RegisterActivity(&activities.PaymentActivities{})
+
+	s.env.OnActivity("ValidateOrder", mock.Anything, mock.Anything).Return(nil)
+	s.env.OnWorkflow(PaymentWorkflow, mock.Anything, mock.Anything).Return(testPayment(), nil)
+	s.env.OnActivity("SendNotification", mock.Anything, mock.Anything).Return(nil).Maybe()
+}
+
+func (s *InventoryTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *InventoryTestSuite) stock() inventory.StockLevel {
+	level, err := s.store.Stock(context.Background(), "prod-001")
+	s.Require().NoError(err)
+	return level
+}
+
+func (s *InventoryTestSuite) Test_Completed_ConfirmsReservation() {
+	s.env.OnWorkflow(FulfillmentWorkflow, mock.Anything, mock.Anything).Return(testFulfillment(), nil)
+
+	s.env.ExecuteWorkflow(OrderWorkflow, testOrder())
+
+	s.Require().NoError(s.env.GetWorkflowError())
+	s.Equal(inventory.StockLevel{ProductID: "prod-001", OnHand: 2}, s.stock())
+	reservation, err := s.store.GetReservationByOrder(context.Background(), "order-1")
+	s.Require().NoError(err)
+	s.Equal(inventory.StatusConfirmed, reservation.Status)
+}
+
+func (s *InventoryTestSuite) Test_LostParcel_ReturnsStock() {
+	s.env.OnWorkflow(FulfillmentWorkflow, mock.Anything, mock.Anything).
+		Return(nil, temporal.NewNonRetryableApplicationError("shipment FAKE-order-1 lost", "SHIPMENT_LOST", nil))
+	s.env.OnActivity("RefundPayment", mock.Anything, mock.Anything).Return(testPayment(), nil).Once()
+
+	s.env.ExecuteWorkflow(OrderWorkflow, testOrder())
+
+	s.True(hasErrorType(s.env.GetWorkflowError(), "SHIPMENT_LOST"))
+	s.Equal(inventory.StockLevel{ProductID: "prod-001", OnHand: 3}, s.stock())
+}
+
+func (s *InventoryTestSuite) Test_OutOfStock_NotRetriedAndRefunded() {
+	order := testOrder()
+	order.Items = []models.OrderItem{{ProductID: "prod-001", Quantity: 4, Price: 10}}
+	s.env.OnActivity("RefundPayment", mock.Anything, mock.Anything).Return(testPayment(), nil).Once()
+
+	attempts := 0
+	s.env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+		if info.ActivityType.Name == "ReserveInventory" {
+			attempts++
+		}
+	})
+
+	s.env.ExecuteWorkflow(OrderWorkflow, order)
+
+	s.True(hasErrorType(s.env.GetWorkflowError(), "OUT_OF_STOCK"))
+	s.Equal(1, attempts)
+	s.Equal(inventory.StockLevel{ProductID: "prod-001", OnHand: 3}, s.stock())
+}
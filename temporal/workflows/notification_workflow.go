@@ -0,0 +1,172 @@
+package workflows
+
+import (
+	"fmt"
+	"slices"
+	"temporal/models"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+/*
+NOTIFICATION DELIVERY:
+
+OrderWorkflow sends its confirmation with a few quick retries and moves on.
+When that fails, it hands the notification to a NotificationWorkflow that
+keeps trying for hours, without holding up the order:
+
+1. Channels in order of preference (email, then SMS, then webhook)
+   - Each channel is retried with exponential backoff for RetryFor (default 4h)
+   - A permanent refusal (NOTIFICATION_REJECTED, e.g. an invalid address)
+     falls back to the next channel right away
+   - Throttled deliveries wait for the provider's Retry-After hint
+
+2. Rate limits
+   - Every channel has its own task queue (notifications-<channel>); the worker
+     polling it sets TaskQueueActivitiesPerSecond, a limit the server enforces
+     across all workers of the queue
+   - A burst of notifications queues up instead of tripping the provider's limits
+
+3. Dead letter
+   - When every channel failed, RecordDeadLetter stores the notification and
+     all attempts for support to follow up; the workflow still completes
+*/
+
+const (
+	// QueryNotificationStatus returns models.NotificationResult with the attempts so far
+	QueryNotificationStatus = "get-notification-status"
+
+	defaultNotificationRetryFor = 4 * time.Hour
+)
+
+// DefaultNotificationChannels is the order in which channels are tried
+var DefaultNotificationChannels = []string{"email", "sms", "webhook"}
+
+// NotificationTaskQueue is the task queue delivering the notifications of one channel
+func NotificationTaskQueue(channel string) string {
+	return "notifications-" + channel
+}
+
+// NotificationWorkflowID is the workflow ID delivering one notification of an order
+func NotificationWorkflowID(notif models.NotificationRequest) string {
+	return fmt.Sprintf("notification-%s-%s", notif.OrderID, notif.MessageType)
+}
+
+// NotificationWorkflow delivers a notification through the first channel that accepts it,
+// or records it as a dead letter
+func NotificationWorkflow(ctx workflow.Context, req models.NotificationDelivery) (*models.NotificationResult, error) {
+	logger := workflow.GetLogger(ctx)
+
+	if len(req.Channels) == 0 {
+		req.Channels = DefaultNotificationChannels
+	}
+	if req.RetryFor <= 0 {
+		req.RetryFor = defaultNotificationRetryFor
+	}
+
+	result := &models.NotificationResult{Status: "delivering"}
+	if err := workflow.SetQueryHandler(ctx, QueryNotificationStatus, func() (models.NotificationResult, error) {
+		snapshot := *result
+		snapshot.Attempts = slices.Clone(result.Attempts)
+		return snapshot, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	for i, channel := range req.Channels {
+		channelCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			TaskQueue:           NotificationTaskQueue(channel),
+			StartToCloseTimeout: 30 * time.Second,
+
+			// Retries of one channel stop after RetryFor, then the next channel is tried
+			ScheduleToCloseTimeout: req.RetryFor,
+			RetryPolicy: &temporal.RetryPolicy{
+				InitialInterval:        time.Minute,
+				BackoffCoefficient:     2.0,
+				MaximumInterval:        30 * time.Minute,
+				NonRetryableErrorTypes: []string{"NOTIFICATION_REJECTED", "NOTIFICATION_CHANNEL_UNKNOWN"},
+			},
+		})
+
+		msg := models.NotificationMessage{
+			Channel:      channel,
+			Recipient:    req.Recipients[channel],
+			Notification: req.Notification,
+		}
+		err := workflow.ExecuteActivity(channelCtx, "DeliverNotification", msg).Get(channelCtx, nil)
+		if temporal.IsCanceledError(err) {
+			return result, err
+		}
+
+		attempt := models.NotificationAttempt{Channel: channel, Status: "delivered", Time: workflow.Now(ctx)}
+		if err != nil {
+			attempt.Status = "failed"
+			attempt.Error = err.Error()
+		}
+		result.Attempts = append(result.Attempts, attempt)
+
+		if err == nil {
+			result.Status = "delivered"
+			result.Channel = channel
+			logger.Info("Notification delivered", "OrderID", req.Notification.OrderID, "Channel", channel)
+			return result, nil
+		}
+		if i < len(req.Channels)-1 {
+			logger.Warn("Notification channel failed, falling back", "Channel", channel, "Next", req.Channels[i+1], "Error", err)
+		}
+	}
+
+	// DEAD LETTER: every channel failed
+	letter := models.NotificationDeadLetter{
+		WorkflowID:   workflow.GetInfo(ctx).WorkflowExecution.ID,
+		Notification: req.Notification,
+		Attempts:     result.Attempts,
+		RecordedAt:   workflow.Now(ctx),
+	}
+	deadLetterCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Second,
+	})
+	if err := workflow.ExecuteActivity(deadLetterCtx, "RecordDeadLetter", letter).Get(deadLetterCtx, nil); err != nil {
+		return result, err
+	}
+
+	result.Status = "dead_lettered"
+	logger.Error("Notification could not be delivered", "OrderID", req.Notification.OrderID, "Channels", req.Channels)
+	return result, nil
+}
+
+// startNotificationRetry hands a notification that failed to a NotificationWorkflow
+// Only the start is awaited: the child outlives the order (ParentClosePolicy ABANDON)
+func startNotificationRetry(ctx workflow.Context, notif models.NotificationRequest) (string, error) {
+	delivery := models.NotificationDelivery{Notification: notif}
+
+	// The channels need the customer's addresses. Without them the email and SMS
+	// channels reject the notification and it ends up as a dead letter for support
+	if changeVersion(ctx, ChangeNotificationRecipients) >= 1 {
+		resolveCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			StartToCloseTimeout: 10 * time.Second,
+			RetryPolicy: &temporal.RetryPolicy{
+				InitialInterval: time.Second,
+				MaximumAttempts: 3,
+			},
+		})
+		err := workflow.ExecuteActivity(resolveCtx, "ResolveRecipients", notif.CustomerID).Get(resolveCtx, &delivery.Recipients)
+		if err != nil {
+			workflow.GetLogger(ctx).Warn("Failed to resolve notification recipients", "CustomerID", notif.CustomerID, "Error", err)
+		}
+	}
+
+	workflowID := NotificationWorkflowID(notif)
+	childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID:        workflowID,
+		ParentClosePolicy: enumspb.PARENT_CLOSE_POLICY_ABANDON,
+	})
+	future := workflow.ExecuteChildWorkflow(childCtx, NotificationWorkflow, delivery)
+	if err := future.GetChildWorkflowExecution().Get(ctx, nil); err != nil {
+		return workflowID, err
+	}
+	return workflowID, nil
+}
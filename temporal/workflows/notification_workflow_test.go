@@ -0,0 +1,156 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	"temporal/activities"
+	"temporal/models"
+
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// NotificationWorkflowTestSuite delivers through fake channels
+type NotificationWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env         *testsuite.TestWorkflowEnvironment
+	email       *activities.FakeChannel
+	sms         *activities.FakeChannel
+	webhook     *activities.FakeChannel
+	deadLetters *activities.MemoryDeadLetterStore
+}
+
+func TestNotificationWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(NotificationWorkflowTestSuite))
+}
+
+func (s *NotificationWorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.email = activities.NewFakeChannel(activities.ChannelEmail)
+	s.sms = activities.NewFakeChannel(activities.ChannelSMS)
+	s.webhook = activities.NewFakeChannel(activities.ChannelWebhook)
+	s.deadLetters = activities.NewMemoryDeadLetterStore()
+
+	s.env.RegisterActivity(&activities.NotificationActivities{
+		Channels: map[string]activities.NotificationChannel{
+			activities.ChannelEmail:   s.email,
+			activities.ChannelSMS:     s.sms,
+			activities.ChannelWebhook: s.webhook,
+		},
+		DeadLetters: s.deadLetters,
+	})
+}
+
+func testDelivery() models.NotificationDelivery {
+	return models.NotificationDelivery{
+		Notification: models.NotificationRequest{
+			OrderID:     "order-1",
+			CustomerID:  "customer-1",
+			MessageType: "order_confirmation",
+			Message:     "Your order order-1 has been confirmed!",
+		},
+	}
+}
+
+func (s *NotificationWorkflowTestSuite) run(req models.NotificationDelivery) models.NotificationResult {
+	s.env.ExecuteWorkflow(NotificationWorkflow, req)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Require().NoError(s.env.GetWorkflowError())
+	var result models.NotificationResult
+	s.Require().NoError(s.env.GetWorkflowResult(&result))
+	return result
+}
+
+func (s *NotificationWorkflowTestSuite) Test_DeliversThroughFirstChannel() {
+	result := s.run(testDelivery())
+
+	s.Equal("delivered", result.Status)
+	s.Equal("email", result.Channel)
+	s.Len(s.email.Sent(), 1)
+	s.Empty(s.sms.Sent())
+}
+
+func (s *NotificationWorkflowTestSuite) Test_TransientFailuresRetried() {
+	s.email.FailNext(4)
+	start := s.env.Now()
+
+	result := s.run(testDelivery())
+
+	s.Equal("email", result.Channel)
+	s.Len(result.Attempts, 1)
+	s.Len(s.email.Sent(), 1)
+	// Backoff 1m, 2m, 4m, 8m before the fifth attempt
+	s.GreaterOrEqual(result.Attempts[0].Time.Sub(start), 15*time.Minute)
+}
+
+func (s *NotificationWorkflowTestSuite) Test_RejectedFallsBackImmediately() {
+	s.email.Reject("invalid email address")
+
+	result := s.run(testDelivery())
+
+	s.Equal("sms", result.Channel)
+	s.Require().Len(result.Attempts, 2)
+	s.Equal("failed", result.Attempts[0].Status)
+	s.Contains(result.Attempts[0].Error, "invalid email address")
+	s.Len(s.sms.Sent(), 1)
+}
+
+func (s *NotificationWorkflowTestSuite) Test_UnavailableFallsBackAfterRetryFor() {
+	s.email.FailNext(1000)
+	req := testDelivery()
+	req.RetryFor = 2 * time.Hour
+	start := s.env.Now()
+
+	result := s.run(req)
+
+	s.Equal("sms", result.Channel)
+	s.Require().Len(result.Attempts, 2)
+	// Retried with backoff up to 30m apart until RetryFor ran out
+	s.GreaterOrEqual(result.Attempts[0].Time.Sub(start), 90*time.Minute)
+	s.LessOrEqual(result.Attempts[0].Time.Sub(start), req.RetryFor)
+	s.Empty(s.email.Sent())
+}
+
+func (s *NotificationWorkflowTestSuite) Test_UnknownChannelSkipped() {
+	req := testDelivery()
+	req.Channels = []string{"pigeon", "webhook"}
+
+	result := s.run(req)
+
+	s.Equal("webhook", result.Channel)
+	s.Contains(result.Attempts[0].Error, "not configured")
+}
+
+func (s *NotificationWorkflowTestSuite) Test_DeadLetterWhenAllChannelsFail() {
+	s.email.Reject("bounced")
+	s.sms.Reject("no phone number")
+	s.webhook.Reject("no webhook")
+
+	result := s.run(testDelivery())
+
+	s.Equal("dead_lettered", result.Status)
+	s.Len(result.Attempts, 3)
+	letters := s.deadLetters.List()
+	s.Require().Len(letters, 1)
+	s.Equal("order-1", letters[0].Notification.OrderID)
+	s.Len(letters[0].Attempts, 3)
+}
+
+func (s *NotificationWorkflowTestSuite) Test_StatusQuery() {
+	s.email.FailNext(1000)
+	s.env.RegisterDelayedCallback(func() {
+		value, err := s.env.QueryWorkflow(QueryNotificationStatus)
+		s.Require().NoError(err)
+		var result models.NotificationResult
+		s.Require().NoError(value.Get(&result))
+		s.Equal("delivering", result.Status)
+		s.Empty(result.Attempts)
+	}, time.Hour)
+
+	result := s.run(testDelivery())
+
+	s.Equal("delivered", result.Status)
+}
@@ -0,0 +1,134 @@
+package workflows
+
+import (
+	"temporal/models"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+/*
+SIGNALS AND QUERIES:
+
+1. Signals:
+   - Asynchronous messages sent to a running workflow
+   - Recorded in the workflow history, so they survive worker restarts
+   - Cannot return a result - rejections are visible through the query/history
+
+2. Queries:
+   - Synchronous, read-only look at the workflow state
+   - Must NOT mutate state or block (no activities, no timers)
+   - Work on completed workflows too (the worker replays the history)
+
+Signal and query names are part of the workflow's public API,
+so the client uses the constants below instead of string literals.
+*/
+
+const (
+	// SignalCancelOrder cancels the order and unwinds every completed step
+	// Payload: models.CancelOrderRequest
+	SignalCancelOrder = "cancel-order"
+
+	// SignalUpdateShipping changes the shipping address until fulfillment starts
+	// Payload: models.UpdateShippingRequest
+	SignalUpdateShipping = "update-shipping"
+
+	// QueryGetStatus returns models.OrderStatus with the step history
+	QueryGetStatus = "get-status"
+)
+
+// orderState is the state of a running OrderWorkflow shared by
+// the workflow body, the signal handlers and the query handler
+type orderState struct {
+	order              *models.Order
+	steps              []models.StepRecord
+	fulfillmentStarted bool
+	cancelReason       string
+
+	// searchableStatus: the order status is published as the Status search attribute
+	searchableStatus bool
+	publishedStatus  string
+}
+
+func newOrderState(order *models.Order) *orderState {
+	return &orderState{order: order}
+}
+
+// record appends a step to the history returned by the get-status query
+func (s *orderState) record(ctx workflow.Context, step, status, message string) {
+	s.steps = append(s.steps, models.StepRecord{
+		Step:    step,
+		Status:  status,
+		Time:    workflow.Now(ctx),
+		Message: message,
+	})
+}
+
+// snapshot copies the state so the query result can't alias workflow memory
+func (s *orderState) snapshot() models.OrderStatus {
+	steps := make([]models.StepRecord, len(s.steps))
+	copy(steps, s.steps)
+	return models.OrderStatus{
+		OrderID:         s.order.OrderID,
+		Status:          s.order.Status,
+		ShippingAddress: s.order.ShippingAddress,
+		Steps:           steps,
+	}
+}
+
+// setupOrderHandlers registers the get-status query and starts a goroutine
+// that handles cancel-order and update-shipping signals
+// ctx must not be the cancellable order context, so signals keep being
+// received while the saga unwinds
+func setupOrderHandlers(ctx workflow.Context, state *orderState, cancelOrder workflow.CancelFunc) error {
+	err := workflow.SetQueryHandler(ctx, QueryGetStatus, func() (models.OrderStatus, error) {
+		return state.snapshot(), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cancelCh := workflow.GetSignalChannel(ctx, SignalCancelOrder)
+	shippingCh := workflow.GetSignalChannel(ctx, SignalUpdateShipping)
+
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		logger := workflow.GetLogger(ctx)
+		for {
+			selector := workflow.NewSelector(ctx)
+
+			selector.AddReceive(cancelCh, func(c workflow.ReceiveChannel, more bool) {
+				var req models.CancelOrderRequest
+				c.Receive(ctx, &req)
+
+				if state.cancelReason != "" {
+					logger.Info("Order already cancelled, ignoring signal")
+					return
+				}
+				if req.Reason == "" {
+					req.Reason = "cancelled by request"
+				}
+				logger.Info("Cancel order signal received", "OrderID", state.order.OrderID, "Reason", req.Reason)
+				state.cancelReason = req.Reason
+				state.record(ctx, SignalCancelOrder, "received", req.Reason)
+				cancelOrder()
+			})
+
+			selector.AddReceive(shippingCh, func(c workflow.ReceiveChannel, more bool) {
+				var req models.UpdateShippingRequest
+				c.Receive(ctx, &req)
+
+				if state.fulfillmentStarted {
+					logger.Warn("Shipping address update rejected: fulfillment already started", "OrderID", state.order.OrderID)
+					state.record(ctx, SignalUpdateShipping, "rejected", "fulfillment already started")
+					return
+				}
+				logger.Info("Shipping address updated", "OrderID", state.order.OrderID)
+				state.order.ShippingAddress = req.Address
+				state.record(ctx, SignalUpdateShipping, "applied", "")
+			})
+
+			selector.Select(ctx)
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	"temporal/models"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+)
+
+type OrderSignalsTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestOrderSignalsTestSuite(t *testing.T) {
+	suite.Run(t, new(OrderSignalsTestSuite))
+}
+
+func (s *OrderSignalsTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	registerOrderWorkflows(s.env)
+
+	s.env.OnActivity("ValidateOrder", mock.Anything, mock.Anything).Return(nil)
+	// payment takes a minute so signals can arrive before fulfillment starts
+	s.env.OnWorkflow(PaymentWorkflow, mock.Anything, mock.Anything).After(time.Minute).Return(testPayment(), nil)
+}
+
+func (s *OrderSignalsTestSuite) queryStatus() models.OrderStatus {
+	value, err := s.env.QueryWorkflow(QueryGetStatus)
+	s.Require().NoError(err)
+	var status models.OrderStatus
+	s.Require().NoError(value.Get(&status))
+	return status
+}
+
+func (s *OrderSignalsTestSuite) Test_CancelSignal_CompensatesAndCancels() {
+	s.env.OnActivity("RefundPayment", mock.Anything, mock.Anything).Return(testPayment(), nil).Maybe()
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(SignalCancelOrder, models.CancelOrderRequest{Reason: "changed my mind"})
+	}, 30*time.Second)
+
+	s.env.ExecuteWorkflow(OrderWorkflow, testOrder())
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.Error(s.env.GetWorkflowError())
+	s.env.AssertActivityNotCalled(s.T(), "ReserveInventory", mock.Anything, mock.Anything)
+
+	status := s.queryStatus()
+	s.Equal("cancelled", status.Status)
+	i := findStep(status.Steps, SignalCancelOrder)
+	s.Require().GreaterOrEqual(i, 0)
+	s.Equal("received", status.Steps[i].Status)
+	s.Equal("changed my mind", status.Steps[i].Message)
+}
+
+func (s *OrderSignalsTestSuite) Test_UpdateShipping_AppliedBeforeFulfillment() {
+	newAddress := models.ShippingAddress{Street: "221B Baker St", City: "London", PostalCode: "NW1 6XE", Country: "UK"}
+	s.env.OnActivity("ReserveInventory", mock.Anything, mock.MatchedBy(func(order models.Order) bool {
+		return order.ShippingAddress == newAddress
+	})).Return(testReservation(), nil)
+	s.env.OnActivity("ConfirmInventory", mock.Anything, mock.Anything).Return(testReservation(), nil)
+	s.env.OnActivity("SendNotification", mock.Anything, mock.Anything).Return(nil)
+	s.env.OnWorkflow(FulfillmentWorkflow, mock.Anything, mock.Anything).Return(testFulfillment(), nil)
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(SignalUpdateShipping, models.UpdateShippingRequest{Address: newAddress})
+	}, 30*time.Second)
+
+	s.env.ExecuteWorkflow(OrderWorkflow, testOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+	status := s.queryStatus()
+	s.Equal("completed", status.Status)
+	s.Equal(newAddress, status.ShippingAddress)
+}
+
+func (s *OrderSignalsTestSuite) Test_UpdateShipping_RejectedAfterFulfillment() {
+	s.env.OnActivity("ReserveInventory", mock.Anything, mock.Anything).Return(testReservation(), nil)
+	s.env.OnActivity("ConfirmInventory", mock.Anything, mock.Anything).Return(testReservation(), nil)
+	s.env.OnActivity("SendNotification", mock.Anything, mock.Anything).Return(nil)
+	// the parcel is in transit for a day
+	s.env.OnWorkflow(FulfillmentWorkflow, mock.Anything, mock.Anything).After(24*time.Hour).Return(testFulfillment(), nil)
+
+	original := testOrder().ShippingAddress
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(SignalUpdateShipping, models.UpdateShippingRequest{
+			Address: models.ShippingAddress{Street: "too late"},
+		})
+	}, time.Hour)
+
+	s.env.ExecuteWorkflow(OrderWorkflow, testOrder())
+
+	s.NoError(s.env.GetWorkflowError())
+	status := s.queryStatus()
+	s.Equal(original, status.ShippingAddress)
+	i := findStep(status.Steps, SignalUpdateShipping)
+	s.Require().GreaterOrEqual(i, 0)
+	s.Equal("rejected", status.Steps[i].Status)
+}
+
+func (s *OrderSignalsTestSuite) Test_GetStatus_RecordsStepHistory() {
+	s.env.OnActivity("ReserveInventory", mock.Anything, mock.Anything).Return(testReservation(), nil)
+	s.env.OnActivity("ConfirmInventory", mock.Anything, mock.Anything).Return(testReservation(), nil)
+	s.env.OnActivity("SendNotification", mock.Anything, mock.Anything).Return(nil)
+	s.env.OnWorkflow(FulfillmentWorkflow, mock.Anything, mock.Anything).Return(testFulfillment(), nil)
+
+	s.env.ExecuteWorkflow(OrderWorkflow, testOrder())
+	s.NoError(s.env.GetWorkflowError())
+
+	var steps []string
+	for _, step := range s.queryStatus().Steps {
+		steps = append(steps, step.Step+":"+step.Status)
+	}
+	s.Equal([]string{
+		"validate:started", "validate:completed",
+		"payment:started", "payment:completed",
+		"inventory:started", "inventory:completed",
+		"notification:started", "notification:completed",
+		"inventory:confirmed",
+		"fulfillment:started", "fulfillment:completed",
+		"order:completed",
+	}, steps)
+}
+
+// findStep returns the index of the last record for step, or -1
+func findStep(steps []models.StepRecord, step string) int {
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i].Step == step {
+			return i
+		}
+	}
+	return -1
+}
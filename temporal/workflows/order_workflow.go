@@ -1,6 +1,7 @@
 package workflows
 
 import (
+	"errors"
 	"fmt"
 	"temporal/models"
 	"time"
@@ -50,17 +51,60 @@ RETRY POLICIES EXPLAINED:
 
 // OrderWorkflow is the main workflow that orchestrates order processing
 // This demonstrates the complete order fulfillment process with proper error handling
-func OrderWorkflow(ctx workflow.Context, order models.Order) (string, error) {
+// Every completed step registers a compensation on a Saga; if a later step fails
+// or the workflow is cancelled, the compensations run in reverse order
+// Signals: cancel-order, update-shipping. Query: get-status (see order_signals.go)
+func OrderWorkflow(ctx workflow.Context, order models.Order) (status string, err error) {
 	logger := workflow.GetLogger(ctx)
 	logger.Info("Order workflow started", "OrderID", order.OrderID)
 
 	// Update order status
 	order.Status = "processing"
 
+	// SIGNALS & QUERIES
+	// A cancel-order signal cancels ctx: the running step fails with a
+	// CanceledError and the saga below unwinds everything done so far
+	state := newOrderState(&order)
+	signalCtx := ctx
+	ctx, cancelOrder := workflow.WithCancel(ctx)
+	if err := setupOrderHandlers(signalCtx, state, cancelOrder); err != nil {
+		return order.Status, err
+	}
+
+	// SEARCH ATTRIBUTES: publish the status so orders can be listed by it
+	// The final status is published last, after the saga has unwound
+	state.searchableStatus = changeVersion(ctx, ChangeStatusSearchAttribute) >= 1
+	state.publishedStatus = "pending"
+	state.publishStatus(ctx)
+	defer func() {
+		state.publishStatus(signalCtx)
+	}()
+
 	// Track what we've done for compensation
 	var inventoryReservation *models.InventoryReservation
 	var paymentInfo *models.PaymentInfo
 
+	// SAGA: compensations are recorded as steps complete and unwound on any failure
+	saga := NewSaga(DefaultSagaOptions())
+	defer func() {
+		if err == nil {
+			return
+		}
+		if temporal.IsCanceledError(err) {
+			order.Status = "cancelled"
+			status = order.Status
+			state.record(ctx, "order", "cancelled", state.cancelReason)
+		}
+		logger.Info("Unwinding completed steps", "Steps", saga.Steps())
+		if compensationErr := saga.Compensate(ctx); compensationErr != nil {
+			logger.Error("Order compensation failed", "Error", compensationErr)
+			state.record(ctx, "compensation", "failed", compensationErr.Error())
+			// ReconciliationWorkflow repairs what is left, or opens a manual review
+		} else if len(saga.Steps()) > 0 {
+			state.record(ctx, "compensation", "completed", "")
+		}
+	}()
+
 	// Configure default activity options
 	// These apply to all activities unless overridden
 	activityOptions := workflow.ActivityOptions{
@@ -85,14 +129,40 @@ func OrderWorkflow(ctx workflow.Context, order models.Order) (string, error) {
 
 	// STEP 1: Validate Order
 	// Activity retries will happen automatically if this fails with transient errors
-	err := workflow.ExecuteActivity(ctx, "ValidateOrder", order).Get(ctx, nil)
+	// HeartbeatTimeout: Maximum time between heartbeats
+	// Only validation waits on a slow service, heartbeating while it waits (activities.Sleep);
+	// a worker that dies meanwhile is noticed after 10s instead of StartToCloseTimeout.
+	// The other activities are quick calls that don't heartbeat, so they have no HeartbeatTimeout
+	validateOptions := activityOptions
+	validateOptions.HeartbeatTimeout = 10 * time.Second
+	validateCtx := workflow.WithActivityOptions(ctx, validateOptions)
+
+	state.record(ctx, "validate", "started", "")
+	err = workflow.ExecuteActivity(validateCtx, "ValidateOrder", order).Get(validateCtx, nil)
 	if err != nil {
 		logger.Error("Order validation failed", "Error", err)
 		order.Status = "validation_failed"
+		state.record(ctx, "validate", "failed", err.Error())
 		return order.Status, fmt.Errorf("order validation failed: %w", err)
 	}
+	state.record(ctx, "validate", "completed", "")
 	logger.Info("Order validated successfully")
 
+	// STEP 1b: Manual approval for high-value orders
+	// Nothing has been charged yet, so a rejection needs no compensation
+	if policy := currentApprovalPolicy(ctx); policy.requiresApproval(order) {
+		err = awaitApproval(ctx, state, policy)
+		if err != nil {
+			logger.Error("Order not approved", "Error", err)
+			if order.Status == "awaiting_approval" {
+				order.Status = "approval_failed"
+			}
+			// returned as is: wrapping the non-retryable ORDER_REJECTED would let the
+			// workflow retry policy start the order over with a new approval window
+			return order.Status, err
+		}
+	}
+
 	// STEP 2: Process Payment using Child Workflow
 	// Child workflows have their own lifecycle and retry policy
 	childWorkflowOptions := workflow.ChildWorkflowOptions{
@@ -125,38 +195,45 @@ func OrderWorkflow(ctx workflow.Context, order models.Order) (string, error) {
 
 	// Execute child workflow
 	var paymentResult *models.PaymentInfo
+	state.record(ctx, "payment", "started", "")
 	childWorkflowFuture := workflow.ExecuteChildWorkflow(childCtx, PaymentWorkflow, order)
 
 	err = childWorkflowFuture.Get(childCtx, &paymentResult)
 	if err != nil {
 		logger.Error("Payment workflow failed", "Error", err)
 		order.Status = "payment_failed"
+		state.record(ctx, "payment", "failed", err.Error())
 		// No compensation needed - child workflow handles its own compensation
 		return order.Status, fmt.Errorf("payment processing failed: %w", err)
 	}
 
 	paymentInfo = paymentResult
+	state.record(ctx, "payment", "completed", paymentInfo.PaymentID)
 	logger.Info("Payment processed successfully", "PaymentID", paymentInfo.PaymentID)
 
+	// COMPENSATION for step 2: Refund the payment
+	saga.AddCompensation("payment", func(ctx workflow.Context) error {
+		return compensatePayment(ctx, paymentInfo)
+	})
+
 	// STEP 3: Reserve Inventory
-	// If this fails, we need to compensate the payment
+	// If this fails, the saga refunds the payment
+	state.record(ctx, "inventory", "started", "")
 	err = workflow.ExecuteActivity(ctx, "ReserveInventory", order).Get(ctx, &inventoryReservation)
 	if err != nil {
 		logger.Error("Inventory reservation failed", "Error", err)
 		order.Status = "inventory_failed"
-
-		// COMPENSATION: Refund the payment
-		logger.Info("Initiating compensation: refunding payment")
-		compensationErr := compensatePayment(ctx, paymentInfo)
-		if compensationErr != nil {
-			logger.Error("Payment compensation failed", "Error", compensationErr)
-			// In production, this would trigger manual intervention alerts
-		}
-
+		state.record(ctx, "inventory", "failed", err.Error())
 		return order.Status, fmt.Errorf("inventory reservation failed: %w", err)
 	}
+	state.record(ctx, "inventory", "completed", inventoryReservation.ReservationID)
 	logger.Info("Inventory reserved successfully", "ReservationID", inventoryReservation.ReservationID)
 
+	// COMPENSATION for step 3: Release the reservation
+	saga.AddCompensation("inventory", func(ctx workflow.Context) error {
+		return compensateInventory(ctx, inventoryReservation)
+	})
+
 	// STEP 4: Send Order Confirmation
 	// This is a non-critical step - if it fails, we don't rollback everything
 	notificationCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
@@ -174,17 +251,90 @@ func OrderWorkflow(ctx workflow.Context, order models.Order) (string, error) {
 		Message:     fmt.Sprintf("Your order %s has been confirmed!", order.OrderID),
 	}
 
+	state.record(ctx, "notification", "started", "")
 	err = workflow.ExecuteActivity(notificationCtx, "SendNotification", notification).Get(notificationCtx, nil)
 	if err != nil {
+		if temporal.IsCanceledError(err) {
+			return order.Status, err
+		}
 		// Log but don't fail the workflow
 		logger.Warn("Failed to send notification (non-critical)", "Error", err)
-		// In production, this could trigger a separate notification retry workflow
+		state.record(ctx, "notification", "failed", err.Error())
+
+		// A NotificationWorkflow keeps trying other channels for hours
+		if changeVersion(ctx, ChangeNotificationRetryWorkflow) >= 1 {
+			workflowID, err := startNotificationRetry(ctx, notification)
+			if err != nil {
+				logger.Warn("Failed to start notification retry workflow", "Error", err)
+			} else {
+				state.record(ctx, "notification", "retrying", workflowID)
+			}
+		}
+		err = nil
 	} else {
 		logger.Info("Notification sent successfully")
+		state.record(ctx, "notification", "completed", "")
+
+		// COMPENSATION for step 4: Tell the customer the confirmed order was cancelled
+		saga.AddCompensation("notification", func(ctx workflow.Context) error {
+			return compensateNotification(ctx, notification)
+		})
+	}
+
+	// STEP 5: Commit the reserved stock to the shipment
+	// A reservation that expired while the order waited fails the order
+	err = workflow.ExecuteActivity(ctx, "ConfirmInventory", inventoryReservation).Get(ctx, &inventoryReservation)
+	if err != nil {
+		logger.Error("Inventory confirmation failed", "Error", err)
+		order.Status = "inventory_failed"
+		state.record(ctx, "inventory", "failed", err.Error())
+		return order.Status, fmt.Errorf("inventory confirmation failed: %w", err)
+	}
+	state.record(ctx, "inventory", "confirmed", inventoryReservation.ReservationID)
+
+	// STEP 6: Ship and wait for delivery using a long-running Child Workflow
+	// The shipping address is locked from here on
+	state.fulfillmentStarted = true
+	order.Status = "fulfilling"
+	state.publishStatus(ctx)
+	fulfillmentCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID: fmt.Sprintf("fulfillment-%s", order.OrderID),
+
+		// Cancelling the order cancels the shipment; the child cancels it with the carrier
+		ParentClosePolicy: enumspb.PARENT_CLOSE_POLICY_REQUEST_CANCEL,
+
+		// Delivery can take days; the child enforces its own delivery deadline
+		WorkflowExecutionTimeout: 30 * 24 * time.Hour,
+	})
+
+	state.record(ctx, "fulfillment", "started", "")
+	var fulfillment *models.FulfillmentResult
+	err = workflow.ExecuteChildWorkflow(fulfillmentCtx, FulfillmentWorkflow, models.FulfillmentRequest{
+		Order:         order,
+		ReservationID: inventoryReservation.ReservationID,
+	}).Get(fulfillmentCtx, &fulfillment)
+	if err != nil {
+		// Lost or returned parcels fail the child; the saga refunds and compensates inventory
+		logger.Error("Fulfillment failed", "Error", err)
+		order.Status = "fulfillment_failed"
+		var appErr *temporal.ApplicationError
+		if errors.As(err, &appErr) {
+			switch appErr.Type() {
+			case "SHIPMENT_LOST":
+				order.Status = "shipment_lost"
+			case "SHIPMENT_RETURNED":
+				order.Status = "shipment_returned"
+			}
+		}
+		state.record(ctx, "fulfillment", "failed", err.Error())
+		return order.Status, fmt.Errorf("fulfillment failed: %w", err)
 	}
+	state.record(ctx, "fulfillment", "completed", fulfillment.Shipment.TrackingNumber)
+	logger.Info("Order delivered", "TrackingNumber", fulfillment.Shipment.TrackingNumber)
 
 	// WORKFLOW COMPLETED SUCCESSFULLY
 	order.Status = "completed"
+	state.record(ctx, "order", "completed", "")
 	logger.Info("Order workflow completed successfully",
 		"OrderID", order.OrderID,
 		"Status", order.Status,
@@ -196,6 +346,7 @@ func OrderWorkflow(ctx workflow.Context, order models.Order) (string, error) {
 
 // compensatePayment is a helper function to refund payment
 // This demonstrates the SAGA pattern for distributed transactions
+// ctx is expected to carry the saga's compensation activity options
 func compensatePayment(ctx workflow.Context, paymentInfo *models.PaymentInfo) error {
 	logger := workflow.GetLogger(ctx)
 
@@ -205,15 +356,30 @@ func compensatePayment(ctx workflow.Context, paymentInfo *models.PaymentInfo) er
 
 	logger.Info("Compensating payment", "PaymentID", paymentInfo.PaymentID)
 
-	compensationOptions := workflow.ActivityOptions{
-		StartToCloseTimeout: 30 * time.Second,
-		RetryPolicy: &temporal.RetryPolicy{
-			InitialInterval: 1 * time.Second,
-			MaximumAttempts: 5, // Compensation is critical, retry more
-		},
+	return workflow.ExecuteActivity(ctx, "RefundPayment", paymentInfo).Get(ctx, nil)
+}
+
+// compensateInventory releases a reservation made by ReserveInventory
+func compensateInventory(ctx workflow.Context, reservation *models.InventoryReservation) error {
+	logger := workflow.GetLogger(ctx)
+
+	if reservation == nil {
+		return nil
 	}
 
-	compensationCtx := workflow.WithActivityOptions(ctx, compensationOptions)
+	logger.Info("Compensating inventory", "ReservationID", reservation.ReservationID)
+
+	return workflow.ExecuteActivity(ctx, "CompensateInventory", reservation).Get(ctx, nil)
+}
+
+// compensateNotification follows up a confirmation with a cancellation notice
+func compensateNotification(ctx workflow.Context, confirmation models.NotificationRequest) error {
+	notification := models.NotificationRequest{
+		OrderID:     confirmation.OrderID,
+		CustomerID:  confirmation.CustomerID,
+		MessageType: "order_cancelled",
+		Message:     fmt.Sprintf("Your order %s has been cancelled and any payment refunded.", confirmation.OrderID),
+	}
 
-	return workflow.ExecuteActivity(compensationCtx, "RefundPayment", paymentInfo).Get(compensationCtx, nil)
+	return workflow.ExecuteActivity(ctx, "SendNotification", notification).Get(ctx, nil)
 }
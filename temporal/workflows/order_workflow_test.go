@@ -0,0 +1,274 @@
+package workflows
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"temporal/activities"
+	"temporal/inventory"
+	"temporal/models"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// OrderWorkflowTestSuite runs OrderWorkflow end to end against the real activities
+// Failures are injected with the fault model and the fakes, not with mocks
+type OrderWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env        *testsuite.TestWorkflowEnvironment
+	faults     *activities.FaultModel
+	gateway    *activities.FakeGateway
+	store      *inventory.MemoryStore
+	recipients *activities.MemoryRecipientDirectory
+	email      *recipientChannel
+}
+
+func TestOrderWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(OrderWorkflowTestSuite))
+}
+
+func (s *OrderWorkflowTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.faults = activities.NewFaultModel(1)
+	s.gateway = activities.NewFakeGateway()
+	s.store = inventory.NewMemoryStore()
+	s.Require().NoError(s.store.Restock(context.Background(), "prod-001", 5))
+
+	s.env.RegisterWorkflow(PaymentWorkflow)
+	s.env.RegisterWorkflow(FulfillmentWorkflow)
+	s.env.RegisterWorkflow(NotificationWorkflow)
+	s.env.RegisterActivity(&activities.OrderActivities{Inventory: s.store, Faults: s.faults})
+	s.env.RegisterActivity(&activities.PaymentActivities{Gateway: s.gateway, Faults: s.faults})
+	s.env.RegisterActivity(&activities.ApprovalActivities{Store: activities.NewMemoryApprovalStore()})
+	s.env.RegisterActivity(&activities.ShippingActivities{Carrier: activities.NewFakeCarrier()})
+
+	s.recipients = activities.NewMemoryRecipientDirectory()
+	s.email = &recipientChannel{FakeChannel: activities.NewFakeChannel(activities.ChannelEmail)}
+	s.env.RegisterActivity(&activities.NotificationActivities{
+		Channels:    map[string]activities.NotificationChannel{activities.ChannelEmail: s.email},
+		DeadLetters: activities.NewMemoryDeadLetterStore(),
+		Recipients:  s.recipients,
+	})
+}
+
+// recipientChannel rejects notifications without a recipient, like the SMTP and SMS channels
+type recipientChannel struct {
+	*activities.FakeChannel
+
+	mu         sync.Mutex
+	recipients []string
+}
+
+func (c *recipientChannel) Send(ctx context.Context, idempotencyKey string, recipient string, notif models.NotificationRequest) error {
+	if recipient == "" {
+		return &activities.RejectedError{Channel: c.Name(), Reason: "no recipient"}
+	}
+	c.mu.Lock()
+	c.recipients = append(c.recipients, recipient)
+	c.mu.Unlock()
+	return c.FakeChannel.Send(ctx, idempotencyKey, recipient, notif)
+}
+
+func (s *OrderWorkflowTestSuite) run(order models.Order) (models.OrderStatus, error) {
+	s.env.ExecuteWorkflow(OrderWorkflow, order)
+	s.True(s.env.IsWorkflowCompleted())
+
+	value, err := s.env.QueryWorkflow(QueryGetStatus)
+	s.Require().NoError(err)
+	var status models.OrderStatus
+	s.Require().NoError(value.Get(&status))
+	return status, s.env.GetWorkflowError()
+}
+
+func (s *OrderWorkflowTestSuite) stock() inventory.StockLevel {
+	level, err := s.store.Stock(context.Background(), "prod-001")
+	s.Require().NoError(err)
+	return level
+}
+
+func (s *OrderWorkflowTestSuite) Test_Success() {
+	status, err := s.run(testOrder())
+
+	s.Require().NoError(err)
+	s.Equal("completed", status.Status)
+	s.Equal(1, s.gateway.Calls("capture"))
+	s.Equal(4, s.stock().OnHand)
+}
+
+func (s *OrderWorkflowTestSuite) Test_HeartbeatTimeoutOnlyForValidation() {
+	heartbeatTimeouts := make(map[string]time.Duration)
+	s.env.SetOnActivityStartedListener(func(info *activity.Info, _ context.Context, _ converter.EncodedValues) {
+		heartbeatTimeouts[info.ActivityType.Name] = info.HeartbeatTimeout
+	})
+
+	_, err := s.run(testOrder())
+
+	s.Require().NoError(err)
+	s.Equal(10*time.Second, heartbeatTimeouts["ValidateOrder"])
+	s.Zero(heartbeatTimeouts["ReserveInventory"])
+	s.Zero(heartbeatTimeouts["ConfirmInventory"])
+}
+
+func (s *OrderWorkflowTestSuite) Test_TransientFaults_Retried() {
+	s.faults.
+		Set("ValidateOrder", activities.Fault{FailAttempts: 2}).
+		Set("ReserveInventory", activities.Fault{FailAttempts: 1}).
+		Set("CapturePayment", activities.Fault{FailAttempts: 2})
+
+	status, err := s.run(testOrder())
+
+	s.Require().NoError(err)
+	s.Equal("completed", status.Status)
+	s.Equal(1, s.gateway.Calls("capture"))
+}
+
+func (s *OrderWorkflowTestSuite) Test_ValidationFailure() {
+	order := testOrder()
+	order.CustomerID = ""
+
+	status, err := s.run(order)
+
+	s.True(hasErrorType(err, "INVALID_ORDER"), "unexpected error: %v", err)
+	s.Equal("validation_failed", status.Status)
+	s.Zero(s.gateway.Calls("authorize"))
+}
+
+func (s *OrderWorkflowTestSuite) Test_PaymentFailure() {
+	s.gateway.DeclineAbove = 5
+
+	status, err := s.run(testOrder())
+
+	s.True(hasErrorType(err, "INSUFFICIENT_FUNDS"), "unexpected error: %v", err)
+	s.Equal("payment_failed", status.Status)
+	s.Zero(s.gateway.Calls("capture"))
+	s.Equal(inventory.StockLevel{ProductID: "prod-001", OnHand: 5}, s.stock())
+}
+
+func (s *OrderWorkflowTestSuite) Test_CaptureFailure_VoidsAuthorization() {
+	s.faults.Set("CapturePayment", activities.Fault{Rate: 1, NonRetryable: true, Type: "CAPTURE_REJECTED"})
+
+	status, err := s.run(testOrder())
+
+	s.True(hasErrorType(err, "CAPTURE_REJECTED"), "unexpected error: %v", err)
+	s.Equal("payment_failed", status.Status)
+	// the payment child is retried as a whole: every run voids its own authorization
+	s.Equal(s.gateway.Calls("authorize"), s.gateway.Calls("void"))
+	s.Positive(s.gateway.Calls("void"))
+	s.Zero(s.gateway.Calls("refund"))
+}
+
+func (s *OrderWorkflowTestSuite) Test_InventoryFailure_RefundsPayment() {
+	order := testOrder()
+	order.Items = []models.OrderItem{{ProductID: "prod-001", Quantity: 6, Price: 10}}
+
+	status, err := s.run(order)
+
+	s.True(hasErrorType(err, "OUT_OF_STOCK"), "unexpected error: %v", err)
+	s.Equal("inventory_failed", status.Status)
+	s.Equal(1, s.gateway.Calls("refund"))
+	s.Equal(inventory.StockLevel{ProductID: "prod-001", OnHand: 5}, s.stock())
+}
+
+func (s *OrderWorkflowTestSuite) Test_NotificationFailure_Tolerated() {
+	s.faults.Set("SendNotification", activities.Fault{FailAttempts: 100})
+	var delivery models.NotificationDelivery
+	s.env.OnWorkflow(NotificationWorkflow, mock.Anything, mock.Anything).Return(
+		func(_ workflow.Context, req models.NotificationDelivery) (*models.NotificationResult, error) {
+			delivery = req
+			return &models.NotificationResult{Status: "delivered", Channel: "sms"}, nil
+		})
+
+	status, err := s.run(testOrder())
+
+	s.Require().NoError(err)
+	s.Equal("completed", status.Status)
+	i := findStep(status.Steps, "notification")
+	s.Require().GreaterOrEqual(i, 1)
+	s.Equal("failed", status.Steps[i-1].Status)
+	s.Equal("retrying", status.Steps[i].Status)
+	s.Equal("notification-order-1-order_confirmation", status.Steps[i].Message)
+	s.Equal("order_confirmation", delivery.Notification.MessageType)
+	s.Zero(s.gateway.Calls("refund"))
+}
+
+func (s *OrderWorkflowTestSuite) Test_NotificationFailure_RetriedToCustomerRecipient() {
+	s.faults.Set("SendNotification", activities.Fault{FailAttempts: 100})
+	s.recipients.Set("customer-1", map[string]string{activities.ChannelEmail: "customer-1@example.com"})
+	var result models.NotificationResult
+	s.env.SetOnChildWorkflowCompletedListener(func(info *workflow.Info, value converter.EncodedValue, err error) {
+		if info.WorkflowType.Name == "NotificationWorkflow" {
+			s.Require().NoError(err)
+			s.Require().NoError(value.Get(&result))
+		}
+	})
+
+	status, err := s.run(testOrder())
+
+	s.Require().NoError(err)
+	s.Equal("completed", status.Status)
+	s.Equal("delivered", result.Status)
+	s.Equal(activities.ChannelEmail, result.Channel)
+	s.Equal([]string{"customer-1@example.com"}, s.email.recipients)
+}
+
+func (s *OrderWorkflowTestSuite) Test_NotificationFailure_NoRetryWorkflowForOldExecutions() {
+	s.faults.Set("SendNotification", activities.Fault{FailAttempts: 100})
+	s.env.OnGetVersion(ChangeNotificationRetryWorkflow, workflow.DefaultVersion, workflow.Version(1)).Return(workflow.DefaultVersion)
+
+	status, err := s.run(testOrder())
+
+	s.Require().NoError(err)
+	i := findStep(status.Steps, "notification")
+	s.Require().GreaterOrEqual(i, 0)
+	s.Equal("failed", status.Steps[i].Status)
+}
+
+// publishedStatuses collects the Status search attribute upserts of the run
+func (s *OrderWorkflowTestSuite) publishedStatuses() *[]string {
+	var statuses []string
+	s.env.OnUpsertTypedSearchAttributes(mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		if status, ok := args.Get(0).(temporal.SearchAttributes).GetKeyword(SearchAttributeStatus); ok {
+			statuses = append(statuses, status)
+		}
+	}).Maybe()
+	return &statuses
+}
+
+func (s *OrderWorkflowTestSuite) Test_StatusSearchAttribute() {
+	statuses := s.publishedStatuses()
+
+	_, err := s.run(testOrder())
+
+	s.Require().NoError(err)
+	s.Equal([]string{"processing", "fulfilling", "completed"}, *statuses)
+}
+
+func (s *OrderWorkflowTestSuite) Test_StatusSearchAttribute_Failure() {
+	s.gateway.DeclineAbove = 5
+	statuses := s.publishedStatuses()
+
+	_, err := s.run(testOrder())
+
+	s.Require().Error(err)
+	s.Equal([]string{"processing", "payment_failed"}, *statuses)
+}
+
+func (s *OrderWorkflowTestSuite) Test_StatusSearchAttribute_NotForOldExecutions() {
+	s.env.OnGetVersion(ChangeStatusSearchAttribute, workflow.DefaultVersion, workflow.Version(1)).Return(workflow.DefaultVersion)
+	statuses := s.publishedStatuses()
+
+	_, err := s.run(testOrder())
+
+	s.Require().NoError(err)
+	s.Empty(*statuses)
+}
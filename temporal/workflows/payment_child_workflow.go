@@ -29,6 +29,14 @@ CHILD WORKFLOW CONCEPTS:
    - Independent of activity retries within the child workflow
 */
 
+// paymentDeclineErrorTypes are the business failures of payment activities
+// Retrying them won't help
+var paymentDeclineErrorTypes = []string{
+	"INSUFFICIENT_FUNDS",
+	"PAYMENT_DECLINED",
+	"INVALID_PAYMENT_STATE",
+}
+
 // PaymentWorkflow is a child workflow that handles the payment process
 // It demonstrates:
 // - Child workflow pattern
@@ -64,17 +72,19 @@ func PaymentWorkflow(ctx workflow.Context, order models.Order) (*models.PaymentI
 			MaximumAttempts:    5, // Retry up to 5 times
 
 			// Don't retry on specific errors
-			NonRetryableErrorTypes: []string{
-				"INSUFFICIENT_FUNDS", // Business logic error
-			},
+			NonRetryableErrorTypes: paymentDeclineErrorTypes,
 		},
 	}
 	ctx = workflow.WithActivityOptions(ctx, activityOptions)
 
 	// Step 1: Authorize Payment
 	// This holds the funds but doesn't charge yet
-	var authErr error
-	err := workflow.ExecuteActivity(ctx, "AuthorizePayment", paymentInfo).Get(ctx, &authErr)
+	// Activities return the updated payment info; the gateway IDs only reach the
+	// workflow through activity results
+	// The steps have fixed activity IDs: a retried run of this workflow reuses the
+	// gateway idempotency keys of the first run and is never charged twice
+	authorizeCtx := withActivityID(ctx, "authorize")
+	err := workflow.ExecuteActivity(authorizeCtx, "AuthorizePayment", paymentInfo).Get(ctx, &paymentInfo)
 	if err != nil {
 		logger.Error("Payment authorization failed", "Error", err)
 		// No compensation needed as we haven't done anything yet
@@ -85,16 +95,24 @@ func PaymentWorkflow(ctx workflow.Context, order models.Order) (*models.PaymentI
 
 	// Step 2: Capture Payment
 	// This actually charges the customer
-	var captureErr error
-	err = workflow.ExecuteActivity(ctx, "CapturePayment", paymentInfo).Get(ctx, &captureErr)
+	var captured *models.PaymentInfo
+	captureCtx := withActivityID(ctx, "capture")
+	err = workflow.ExecuteActivity(captureCtx, "CapturePayment", paymentInfo).Get(ctx, &captured)
 	if err != nil {
 		logger.Error("Payment capture failed", "Error", err)
 
 		// COMPENSATION: Void the authorization since capture failed
 		logger.Info("Initiating payment compensation (void authorization)")
 
+		// The void runs even when the order was cancelled during capture: on the
+		// cancelled ctx it would fail at once and leave the authorization held
+		voidCtx := ctx
+		if changeVersion(ctx, ChangeDisconnectedVoid) >= 1 {
+			voidCtx, _ = workflow.NewDisconnectedContext(ctx)
+		}
+
 		// Use a separate context with longer timeout for compensation
-		compensationCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		compensationCtx := workflow.WithActivityOptions(voidCtx, workflow.ActivityOptions{
 			StartToCloseTimeout: 30 * time.Second,
 			RetryPolicy: &temporal.RetryPolicy{
 				InitialInterval: 1 * time.Second,
@@ -102,15 +120,16 @@ func PaymentWorkflow(ctx workflow.Context, order models.Order) (*models.PaymentI
 			},
 		})
 
-		var voidErr error
-		compensationErr := workflow.ExecuteActivity(compensationCtx, "VoidAuthorization", paymentInfo).Get(compensationCtx, &voidErr)
+		compensationCtx = withActivityID(compensationCtx, "void")
+		compensationErr := workflow.ExecuteActivity(compensationCtx, "VoidAuthorization", paymentInfo).Get(compensationCtx, nil)
 		if compensationErr != nil {
 			logger.Error("Compensation failed (void authorization)", "Error", compensationErr)
-			// In production, this would trigger alerts
+			// ReconciliationWorkflow voids the authorization later
 		}
 
 		return nil, fmt.Errorf("payment capture failed: %w", err)
 	}
+	paymentInfo = captured
 
 	logger.Info("Payment workflow completed successfully",
 		"PaymentID", paymentInfo.PaymentID,
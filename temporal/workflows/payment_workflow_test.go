@@ -0,0 +1,175 @@
+package workflows
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"temporal/activities"
+	"temporal/models"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// PaymentWorkflow against the real payment activities and the fake gateway
+type PaymentGatewayTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env     *testsuite.TestWorkflowEnvironment
+	gateway *activities.FakeGateway
+	faults  *activities.FaultModel
+}
+
+func TestPaymentGatewayTestSuite(t *testing.T) {
+	suite.Run(t, new(PaymentGatewayTestSuite))
+}
+
+func (s *PaymentGatewayTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.gateway = activities.NewFakeGateway()
+	s.faults = activities.NewFaultModel(1)
+	s.env.RegisterActivity(&activities.PaymentActivities{Gateway: s.gateway, Faults: s.faults})
+}
+
+func (s *PaymentGatewayTestSuite) Test_GatewayIDsFlowBackToWorkflow() {
+	s.env.ExecuteWorkflow(PaymentWorkflow, testOrder())
+
+	var payment models.PaymentInfo
+	s.Require().NoError(s.env.GetWorkflowResult(&payment))
+	s.Equal("captured", payment.Status)
+	s.NotEmpty(payment.AuthorizationID)
+	s.NotEmpty(payment.CaptureID)
+	s.Equal("captured", s.gateway.Status(payment.AuthorizationID))
+}
+
+func (s *PaymentGatewayTestSuite) Test_LostResponse_RetriedWithoutDoubleCharge() {
+	s.gateway.LoseNextResponse("authorize", 2)
+	s.gateway.LoseNextResponse("capture", 1)
+
+	s.env.ExecuteWorkflow(PaymentWorkflow, testOrder())
+
+	var payment models.PaymentInfo
+	s.Require().NoError(s.env.GetWorkflowResult(&payment))
+	s.Equal(1, s.gateway.Calls("authorize"))
+	s.Equal(1, s.gateway.Calls("capture"))
+}
+
+func (s *PaymentGatewayTestSuite) Test_Declined_NotRetried() {
+	s.gateway.DeclineAbove = 5
+
+	s.env.ExecuteWorkflow(PaymentWorkflow, testOrder())
+
+	s.True(hasErrorType(s.env.GetWorkflowError(), "INSUFFICIENT_FUNDS"))
+	s.Equal(1, s.gateway.Calls("authorize"))
+}
+
+func (s *PaymentGatewayTestSuite) Test_CaptureFailure_VoidsAuthorization() {
+	s.gateway.FailNext("capture", 10)
+
+	s.env.ExecuteWorkflow(PaymentWorkflow, testOrder())
+
+	s.Error(s.env.GetWorkflowError())
+	s.Equal(1, s.gateway.Calls("void"))
+}
+
+func (s *PaymentGatewayTestSuite) Test_CancelledDuringCapture_VoidsAuthorization() {
+	s.faults.Set("CapturePayment", activities.Fault{Latency: time.Minute})
+	var authorizationID string
+	s.env.SetOnActivityStartedListener(func(info *activity.Info, _ context.Context, args converter.EncodedValues) {
+		if info.ActivityType.Name == "CapturePayment" {
+			var payment models.PaymentInfo
+			s.Require().NoError(args.Get(&payment))
+			authorizationID = payment.AuthorizationID
+			s.env.CancelWorkflow()
+		}
+	})
+
+	s.env.ExecuteWorkflow(PaymentWorkflow, testOrder())
+
+	s.True(temporal.IsCanceledError(s.env.GetWorkflowError()), "unexpected error: %v", s.env.GetWorkflowError())
+	s.Equal(1, s.gateway.Calls("void"))
+	s.Equal("voided", s.gateway.Status(authorizationID))
+}
+
+func (s *PaymentGatewayTestSuite) Test_TransientAuthorizeFaults_Retried() {
+	s.faults.Set("AuthorizePayment", activities.Fault{FailAttempts: 3})
+
+	s.env.ExecuteWorkflow(PaymentWorkflow, testOrder())
+
+	var payment models.PaymentInfo
+	s.Require().NoError(s.env.GetWorkflowResult(&payment))
+	s.Equal("captured", payment.Status)
+	s.Equal(1, s.gateway.Calls("authorize"))
+}
+
+func (s *PaymentGatewayTestSuite) Test_AuthorizeFaultsExhausted_Fails() {
+	s.faults.Set("AuthorizePayment", activities.Fault{Rate: 1})
+
+	s.env.ExecuteWorkflow(PaymentWorkflow, testOrder())
+
+	s.Error(s.env.GetWorkflowError())
+	s.Zero(s.gateway.Calls("authorize"))
+	s.Zero(s.gateway.Calls("void"))
+}
+
+// lostCaptures charges on every capture but reports the first lost ones as
+// failed, so the capture activity gives up after the customer was charged
+type lostCaptures struct {
+	*activities.FakeGateway
+	lost int
+}
+
+func (g *lostCaptures) Capture(ctx context.Context, key string, authorizationID string, amount float64) (activities.GatewayResult, error) {
+	result, err := g.FakeGateway.Capture(ctx, key, authorizationID, amount)
+	if err == nil && g.lost > 0 {
+		g.lost--
+		return activities.GatewayResult{}, activities.ErrGatewayUnavailable
+	}
+	return result, err
+}
+
+// paymentWithRetries runs PaymentWorkflow with the child options of OrderWorkflow
+func paymentWithRetries(ctx workflow.Context, order models.Order) (*models.PaymentInfo, error) {
+	ctx = workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID: "payment-" + order.OrderID,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    2 * time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    1 * time.Minute,
+			MaximumAttempts:    3,
+		},
+	})
+	var payment *models.PaymentInfo
+	err := workflow.ExecuteChildWorkflow(ctx, PaymentWorkflow, order).Get(ctx, &payment)
+	return payment, err
+}
+
+func TestPaymentWorkflow_RetriedRunDoesNotChargeAgain(t *testing.T) {
+	var ts testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+	gateway := &lostCaptures{FakeGateway: activities.NewFakeGateway(), lost: 5}
+	env.RegisterActivity(&activities.PaymentActivities{Gateway: gateway})
+	env.RegisterWorkflow(PaymentWorkflow)
+	env.RegisterWorkflow(paymentWithRetries)
+
+	var starts int
+	env.SetOnChildWorkflowStartedListener(func(info *workflow.Info, ctx workflow.Context, args converter.EncodedValues) {
+		starts++
+	})
+
+	env.ExecuteWorkflow(paymentWithRetries, testOrder())
+
+	var payment models.PaymentInfo
+	require.NoError(t, env.GetWorkflowResult(&payment))
+	require.Equal(t, 2, starts, "the first run fails once its capture attempts are exhausted")
+	require.Equal(t, "captured", payment.Status)
+	require.Equal(t, 1, gateway.Calls("authorize"))
+	require.Equal(t, 1, gateway.Calls("capture"))
+}
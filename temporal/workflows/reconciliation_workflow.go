@@ -0,0 +1,212 @@
+package workflows
+
+import (
+	"fmt"
+	"temporal/models"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+/*
+RECONCILIATION:
+
+Compensations can fail too: a void after a failed capture, or the saga of an
+order that ran out of retries, leaves the payment and the inventory disagreeing.
+The order workflows log and record it; ReconciliationWorkflow cleans up after them.
+
+1. Every Interval it asks the payment gateway and the inventory for the records
+   updated since the last pass (FindReconciliationCases) and compares the two
+   per order:
+   - captured payment, reservation released/expired/missing => RefundPayment
+   - authorization neither captured nor voided             => VoidAuthorization
+   - payment voided or refunded, stock still reserved      => CompensateInventory
+   - shipped without a captured payment, charged twice     => manual review
+
+2. The repairs are the activities the orders use, with their idempotency and
+   retries. A repair that still fails opens a manual review (OpenManualReview).
+
+3. Orders are left alone for Grace after their last change, so a running order
+   is never "repaired" under its own feet.
+
+4. It is a single long-lived workflow (ReconciliationWorkflowID) that continues
+   as new after a number of passes, carrying its checkpoint.
+*/
+
+// QueryGetReconciliation returns models.ReconciliationStatus
+const QueryGetReconciliation = "get-reconciliation"
+
+// ReconciliationWorkflowID is the ID of the one reconciliation workflow
+const ReconciliationWorkflowID = "order-reconciliation"
+
+const (
+	defaultReconciliationInterval = 15 * time.Minute
+	defaultReconciliationGrace    = time.Hour
+	defaultReconciliationLookback = 24 * time.Hour
+	reconciliationPassesPerRun    = 100
+)
+
+// ReconciliationWorkflow periodically repairs orders whose payment and
+// inventory states disagree
+func ReconciliationWorkflow(ctx workflow.Context, req models.ReconciliationRequest) (*models.ReconciliationStatus, error) {
+	logger := workflow.GetLogger(ctx)
+
+	if req.Interval <= 0 {
+		req.Interval = defaultReconciliationInterval
+	}
+	if req.Grace <= 0 {
+		req.Grace = defaultReconciliationGrace
+	}
+	if req.Lookback <= 0 {
+		req.Lookback = defaultReconciliationLookback
+	}
+
+	state := req.State
+	if state == nil {
+		state = &models.ReconciliationStatus{
+			Checkpoint: workflow.Now(ctx).Add(-req.Grace - req.Lookback),
+		}
+	}
+	logger.Info("Reconciliation workflow started", "Checkpoint", state.Checkpoint, "Interval", req.Interval)
+
+	if err := workflow.SetQueryHandler(ctx, QueryGetReconciliation, func() (models.ReconciliationStatus, error) {
+		return *state, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	for passes := 1; ; passes++ {
+		window := models.ReconciliationWindow{
+			From: state.Checkpoint,
+			To:   workflow.Now(ctx).Add(-req.Grace),
+		}
+		if window.To.After(window.From) {
+			pass, err := reconcile(ctx, window)
+			if err != nil {
+				// The window is retried by the next pass
+				logger.Error("Reconciliation pass failed", "Error", err)
+				state.LastError = err.Error()
+			} else {
+				state.Checkpoint = window.To
+				state.Passes++
+				state.Repaired += pass.Repaired
+				state.Reviews += pass.Reviews
+				state.LastPass = pass
+				state.LastError = ""
+			}
+		}
+
+		state.NextRunAt = workflow.Now(ctx).Add(req.Interval)
+		if err := workflow.Sleep(ctx, req.Interval); err != nil {
+			return state, err
+		}
+
+		if passes >= reconciliationPassesPerRun || workflow.GetInfo(ctx).GetContinueAsNewSuggested() {
+			req.State = state
+			logger.Info("Reconciliation continues as new", "Checkpoint", state.Checkpoint)
+			return nil, workflow.NewContinueAsNewError(ctx, ReconciliationWorkflow, req)
+		}
+	}
+}
+
+// reconcile finds the mismatches of one window and repairs them
+func reconcile(ctx workflow.Context, window models.ReconciliationWindow) (*models.ReconciliationPass, error) {
+	logger := workflow.GetLogger(ctx)
+
+	scanCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Minute,
+		HeartbeatTimeout:    time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    5 * time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    5,
+		},
+	})
+	var scan models.ReconciliationScan
+	if err := workflow.ExecuteActivity(scanCtx, "FindReconciliationCases", window).Get(ctx, &scan); err != nil {
+		return nil, err
+	}
+
+	// Repairs retry like the order's own compensations
+	repairCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        30 * time.Second,
+			MaximumAttempts:        5,
+			NonRetryableErrorTypes: paymentDeclineErrorTypes,
+		},
+	})
+	reviewCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+		},
+	})
+
+	pass := &models.ReconciliationPass{Window: window, Checked: scan.Checked}
+	for _, c := range scan.Cases {
+		outcome := models.ReconciliationOutcome{Case: c, Result: "repaired"}
+
+		err := repair(repairCtx, c)
+		if err == nil {
+			logger.Info("Order reconciled", "OrderID", c.OrderID, "Issue", c.Issue, "Action", c.Action)
+			pass.Repaired++
+			pass.Outcomes = append(pass.Outcomes, outcome)
+			continue
+		}
+
+		// MANUAL REVIEW: the case needs a person, or the repair failed
+		detail := c.Detail
+		if c.Action != models.RepairManualReview {
+			outcome.Error = err.Error()
+			detail = fmt.Sprintf("%s: %s failed: %v", c.Detail, c.Action, err)
+		}
+		var review models.ManualReview
+		if err := workflow.ExecuteActivity(reviewCtx, "OpenManualReview", models.ManualReview{
+			OrderID:     c.OrderID,
+			Issue:       c.Issue,
+			Detail:      detail,
+			Payment:     c.Payment,
+			Reservation: c.Reservation,
+			OpenedAt:    workflow.Now(ctx),
+		}).Get(ctx, &review); err != nil {
+			return nil, err
+		}
+		outcome.Result = "manual_review"
+		outcome.ReviewID = review.ReviewID
+		pass.Reviews++
+		pass.Outcomes = append(pass.Outcomes, outcome)
+	}
+
+	logger.Info("Reconciliation pass completed",
+		"From", window.From, "To", window.To,
+		"Checked", pass.Checked, "Repaired", pass.Repaired, "Reviews", pass.Reviews)
+	return pass, nil
+}
+
+// repair runs the activity that fixes a case
+// Cases that need a person return an error, like a repair that failed
+// Payment repairs are named after the order: their gateway idempotency key
+// must not be reused by another order's payment after the workflow continued as new
+func repair(ctx workflow.Context, c models.ReconciliationCase) error {
+	switch c.Action {
+	case models.RepairVoidAuthorization:
+		ctx = withActivityID(ctx, "void-"+c.OrderID)
+		return workflow.ExecuteActivity(ctx, "VoidAuthorization", c.Payment).Get(ctx, nil)
+	case models.RepairRefundPayment:
+		ctx = withActivityID(ctx, "refund-"+c.OrderID)
+		return workflow.ExecuteActivity(ctx, "RefundPayment", c.Payment).Get(ctx, nil)
+	case models.RepairReleaseInventory:
+		return workflow.ExecuteActivity(ctx, "CompensateInventory", c.Reservation).Get(ctx, nil)
+	case models.RepairManualReview:
+		return fmt.Errorf("%s needs a manual review", c.Issue)
+	default:
+		return fmt.Errorf("unknown reconciliation action %q", c.Action)
+	}
+}
@@ -0,0 +1,190 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"temporal/activities"
+	"temporal/inventory"
+	"temporal/models"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+type ReconciliationTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestReconciliationTestSuite(t *testing.T) {
+	suite.Run(t, new(ReconciliationTestSuite))
+}
+
+func (s *ReconciliationTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterActivity(&activities.ReconciliationActivities{})
+	s.env.RegisterActivity(&activities.PaymentActivities{})
+	s.env.RegisterActivity(&activities.OrderActivities{})
+}
+
+// stopAfter queries the reconciliation status and cancels the workflow
+func (s *ReconciliationTestSuite) stopAfter(d time.Duration, status *models.ReconciliationStatus) {
+	s.env.RegisterDelayedCallback(func() {
+		value, err := s.env.QueryWorkflow(QueryGetReconciliation)
+		s.Require().NoError(err)
+		s.Require().NoError(value.Get(status))
+		s.env.CancelWorkflow()
+	}, d)
+}
+
+func (s *ReconciliationTestSuite) Test_RepairsCasesAndReviewsTheRest() {
+	payment := testPayment()
+	reservation := &models.InventoryReservation{ReservationID: "res-order-3", OrderID: "order-3", Status: "reserved"}
+	s.env.OnActivity("FindReconciliationCases", mock.Anything, mock.Anything).Return(&models.ReconciliationScan{
+		Checked: 10,
+		Cases: []models.ReconciliationCase{
+			{OrderID: "order-1", Issue: activities.IssueAuthorizationNotVoided, Action: models.RepairVoidAuthorization, Payment: payment},
+			{OrderID: "order-2", Issue: activities.IssueCapturedWithoutReservation, Action: models.RepairRefundPayment, Payment: payment},
+			{OrderID: "order-3", Issue: activities.IssueReservedWithoutPayment, Action: models.RepairReleaseInventory, Reservation: reservation},
+			{OrderID: "order-4", Issue: activities.IssueShippedWithoutPayment, Action: models.RepairManualReview},
+		},
+	}, nil).Once()
+	s.env.OnActivity("FindReconciliationCases", mock.Anything, mock.Anything).Return(&models.ReconciliationScan{}, nil)
+	s.env.OnActivity("VoidAuthorization", mock.Anything, payment).Return(payment, nil).Once()
+	s.env.OnActivity("RefundPayment", mock.Anything, payment).Return(nil,
+		temporal.NewNonRetryableApplicationError("refund exceeds captured amount", "INVALID_PAYMENT_STATE", nil)).Once()
+	s.env.OnActivity("CompensateInventory", mock.Anything, reservation).Return(nil).Once()
+
+	var reviews []models.ManualReview
+	s.env.OnActivity("OpenManualReview", mock.Anything, mock.Anything).Return(
+		func(_ context.Context, review models.ManualReview) (*models.ManualReview, error) {
+			review.ReviewID = review.OrderID + "/" + review.Issue
+			reviews = append(reviews, review)
+			return &review, nil
+		})
+
+	var status models.ReconciliationStatus
+	s.stopAfter(time.Minute, &status)
+	s.env.ExecuteWorkflow(ReconciliationWorkflow, models.ReconciliationRequest{})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(temporal.IsCanceledError(s.env.GetWorkflowError()))
+	s.env.AssertExpectations(s.T())
+
+	s.Equal(1, status.Passes)
+	s.Equal(2, status.Repaired)
+	s.Equal(2, status.Reviews)
+	s.Equal(10, status.LastPass.Checked)
+	var results []string
+	for _, o := range status.LastPass.Outcomes {
+		results = append(results, o.Result)
+	}
+	s.Equal([]string{"repaired", "manual_review", "repaired", "manual_review"}, results)
+	s.Equal("order-2/"+activities.IssueCapturedWithoutReservation, status.LastPass.Outcomes[1].ReviewID)
+	s.Contains(status.LastPass.Outcomes[1].Error, "refund exceeds captured amount")
+
+	s.Require().Len(reviews, 2)
+	s.Equal("order-2", reviews[0].OrderID)
+	s.Contains(reviews[0].Detail, models.RepairRefundPayment+" failed")
+	s.Equal(payment, reviews[0].Payment)
+	s.Equal("order-4", reviews[1].OrderID)
+}
+
+func (s *ReconciliationTestSuite) Test_WindowsFollowEachOtherAndFailedPassesRetry() {
+	var windows []models.ReconciliationWindow
+	s.env.OnActivity("FindReconciliationCases", mock.Anything, mock.Anything).Return(
+		func(_ context.Context, window models.ReconciliationWindow) (*models.ReconciliationScan, error) {
+			windows = append(windows, window)
+			if len(windows) == 2 {
+				return nil, temporal.NewNonRetryableApplicationError("gateway down", "TEST", nil)
+			}
+			return &models.ReconciliationScan{}, nil
+		})
+
+	var status models.ReconciliationStatus
+	s.stopAfter(25*time.Minute, &status)
+	start := s.env.Now()
+	s.env.ExecuteWorkflow(ReconciliationWorkflow, models.ReconciliationRequest{
+		Interval: 10 * time.Minute,
+		Grace:    time.Hour,
+		Lookback: 2 * time.Hour,
+	})
+
+	s.True(temporal.IsCanceledError(s.env.GetWorkflowError()))
+	s.Require().Len(windows, 3)
+	s.True(windows[0].From.Equal(start.Add(-3 * time.Hour)))
+	s.True(windows[0].To.Equal(start.Add(-time.Hour)))
+	s.True(windows[1].From.Equal(windows[0].To))
+	// the failed window is reconciled again by the next pass
+	s.True(windows[2].From.Equal(windows[1].From))
+	s.True(windows[2].To.Equal(start.Add(20*time.Minute - time.Hour)))
+
+	s.Equal(2, status.Passes)
+	s.True(status.Checkpoint.Equal(windows[2].To))
+	s.Empty(status.LastError)
+}
+
+func (s *ReconciliationTestSuite) Test_ContinuesAsNewWithCheckpoint() {
+	s.env.OnActivity("FindReconciliationCases", mock.Anything, mock.Anything).Return(&models.ReconciliationScan{}, nil)
+
+	s.env.ExecuteWorkflow(ReconciliationWorkflow, models.ReconciliationRequest{Interval: time.Minute})
+
+	var canErr *workflow.ContinueAsNewError
+	s.Require().True(errors.As(s.env.GetWorkflowError(), &canErr))
+	var next models.ReconciliationRequest
+	s.Require().NoError(converter.GetDefaultDataConverter().FromPayloads(canErr.Input, &next))
+	s.Require().NotNil(next.State)
+	s.Equal(reconciliationPassesPerRun, next.State.Passes)
+	s.Equal(time.Minute, next.Interval)
+	s.Equal(defaultReconciliationGrace, next.Grace)
+}
+
+// Test_RepairsThroughOrderActivities runs the real activities against the fake
+// gateway and the in-memory inventory: a capture whose reservation was released
+// is refunded, a void that failed is retried
+func (s *ReconciliationTestSuite) Test_RepairsThroughOrderActivities() {
+	ctx := context.Background()
+	gateway := activities.NewFakeGateway()
+	store := inventory.NewMemoryStore()
+	s.Require().NoError(store.Restock(ctx, "prod-001", 10))
+	reviews := activities.NewMemoryReviewStore()
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterActivity(&activities.ReconciliationActivities{Gateway: gateway, Inventory: store, Reviews: reviews})
+	s.env.RegisterActivity(&activities.PaymentActivities{Gateway: gateway})
+	s.env.RegisterActivity(&activities.OrderActivities{Inventory: store})
+
+	// order-1 was charged, then the order failed and released its stock,
+	// but the refund never happened
+	charged, err := gateway.Authorize(ctx, "order-1/authorize", activities.AuthorizeRequest{PaymentID: "pay-order-1", OrderID: "order-1", Amount: 25})
+	s.Require().NoError(err)
+	_, err = gateway.Capture(ctx, "order-1/capture", charged.ID, 25)
+	s.Require().NoError(err)
+	_, err = store.Reserve(ctx, "order-1", []models.OrderItem{{ProductID: "prod-001", Quantity: 1}}, time.Hour)
+	s.Require().NoError(err)
+	_, err = store.Release(ctx, inventory.ReservationIDForOrder("order-1"))
+	s.Require().NoError(err)
+
+	// order-2's capture failed and so did the void
+	held, err := gateway.Authorize(ctx, "order-2/authorize", activities.AuthorizeRequest{PaymentID: "pay-order-2", OrderID: "order-2", Amount: 40})
+	s.Require().NoError(err)
+
+	var status models.ReconciliationStatus
+	s.stopAfter(90*time.Second, &status)
+	s.env.ExecuteWorkflow(ReconciliationWorkflow, models.ReconciliationRequest{Interval: time.Minute, Grace: time.Second})
+
+	s.True(temporal.IsCanceledError(s.env.GetWorkflowError()))
+	s.Equal(activities.PaymentRefunded, gateway.Status(charged.ID))
+	s.Equal(activities.PaymentVoided, gateway.Status(held.ID))
+	s.Equal(2, status.Repaired)
+	s.Zero(status.Reviews)
+	s.Empty(reviews.List())
+}
@@ -0,0 +1,17 @@
+package workflows
+
+import "go.temporal.io/sdk/worker"
+
+// RegisterWorkflows registers every workflow of the order service
+// The worker, the replay test and the history replay tool share it, so a
+// replayed history always runs against the same registrations as production
+func RegisterWorkflows(r worker.WorkflowRegistry) {
+	r.RegisterWorkflow(OrderWorkflow)
+	r.RegisterWorkflow(PaymentWorkflow) // Child workflow must also be registered
+	r.RegisterWorkflow(FulfillmentWorkflow)
+	r.RegisterWorkflow(BatchOrderWorkflow)
+	r.RegisterWorkflow(SubscriptionWorkflow)
+	r.RegisterWorkflow(NotificationWorkflow)
+	r.RegisterWorkflow(InventorySyncWorkflow)
+	r.RegisterWorkflow(ReconciliationWorkflow)
+}
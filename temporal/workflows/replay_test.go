@@ -0,0 +1,34 @@
+package workflows
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/worker"
+)
+
+// TestReplayHistories replays every recorded history in testdata/histories
+// against the current workflow code. A failure means the change is not
+// deterministic for executions that are already running: guard it with
+// workflow.GetVersion instead
+//
+// Record more histories from a running server with:
+//
+//	go run ./history record -workflow-id order-1234 -out workflows/testdata/histories/order_1234.json
+func TestReplayHistories(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "histories", "*.json"))
+	require.NoError(t, err)
+	if len(files) == 0 {
+		t.Skip("no recorded histories")
+	}
+
+	replayer := worker.NewWorkflowReplayer()
+	RegisterWorkflows(replayer)
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			require.NoError(t, replayer.ReplayWorkflowHistoryFromJSONFile(nil, file))
+		})
+	}
+}
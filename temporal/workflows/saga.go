@@ -0,0 +1,153 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+/*
+SAGA PATTERN:
+
+A saga is a sequence of local transactions where each step has a matching
+compensation that semantically undoes it (refund a capture, release a
+reservation, ...). Temporal gives us durable execution, so the saga only
+needs to remember which compensations to run:
+
+1. After a step completes, register its compensation
+2. If a later step fails (or the workflow is cancelled), run the registered
+   compensations in REVERSE order
+3. Compensations run on a disconnected context so they still execute when
+   the workflow itself has been cancelled
+
+Compensations must be idempotent - they are activities and may be retried.
+*/
+
+// CompensationFunc undoes a completed step
+type CompensationFunc func(ctx workflow.Context) error
+
+type compensation struct {
+	step string
+	fn   CompensationFunc
+}
+
+// SagaOptions configures how compensations are executed
+type SagaOptions struct {
+	// ActivityOptions applied to the compensation context
+	// Compensation is critical, so the defaults retry more than normal steps
+	ActivityOptions workflow.ActivityOptions
+
+	// ParallelCompensation runs all compensations concurrently instead of in reverse order
+	ParallelCompensation bool
+
+	// StopOnError aborts the remaining compensations after the first failure
+	// By default every compensation is attempted and the errors are joined
+	StopOnError bool
+}
+
+// DefaultSagaOptions returns the options used by OrderWorkflow
+func DefaultSagaOptions() SagaOptions {
+	return SagaOptions{
+		ActivityOptions: workflow.ActivityOptions{
+			StartToCloseTimeout: 30 * time.Second,
+			RetryPolicy: &temporal.RetryPolicy{
+				InitialInterval: 1 * time.Second,
+				MaximumAttempts: 5, // Compensation is critical, retry more
+			},
+		},
+	}
+}
+
+// Saga records a compensation for each completed step
+// It is not safe to share between workflow executions
+type Saga struct {
+	options       SagaOptions
+	compensations []compensation
+	compensated   bool
+}
+
+// NewSaga creates an empty saga
+func NewSaga(options SagaOptions) *Saga {
+	return &Saga{options: options}
+}
+
+// AddCompensation registers the compensation for a step that has just completed
+func (s *Saga) AddCompensation(step string, fn CompensationFunc) {
+	s.compensations = append(s.compensations, compensation{step: step, fn: fn})
+}
+
+// AddActivityCompensation registers an activity (by name or function) as the compensation for a step
+func (s *Saga) AddActivityCompensation(step string, activity interface{}, args ...interface{}) {
+	s.AddCompensation(step, func(ctx workflow.Context) error {
+		return workflow.ExecuteActivity(ctx, activity, args...).Get(ctx, nil)
+	})
+}
+
+// Steps returns the names of the steps that would be compensated, in registration order
+func (s *Saga) Steps() []string {
+	steps := make([]string, len(s.compensations))
+	for i, c := range s.compensations {
+		steps[i] = c.step
+	}
+	return steps
+}
+
+// Compensate runs the registered compensations in reverse order
+// It runs on a disconnected context, so it works after the workflow was cancelled
+// Calling it more than once is a no-op
+func (s *Saga) Compensate(ctx workflow.Context) error {
+	if s.compensated || len(s.compensations) == 0 {
+		return nil
+	}
+	s.compensated = true
+
+	logger := workflow.GetLogger(ctx)
+
+	// A cancelled context would make every compensation activity fail immediately
+	compensationCtx, _ := workflow.NewDisconnectedContext(ctx)
+	compensationCtx = workflow.WithActivityOptions(compensationCtx, s.options.ActivityOptions)
+
+	if s.options.ParallelCompensation {
+		return s.compensateParallel(compensationCtx)
+	}
+
+	var errs []error
+	for i := len(s.compensations) - 1; i >= 0; i-- {
+		c := s.compensations[i]
+		logger.Info("Compensating step", "Step", c.step)
+
+		if err := c.fn(compensationCtx); err != nil {
+			logger.Error("Compensation failed", "Step", c.step, "Error", err)
+			errs = append(errs, fmt.Errorf("compensate %s: %w", c.step, err))
+			if s.options.StopOnError {
+				break
+			}
+			continue
+		}
+		logger.Info("Step compensated", "Step", c.step)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Saga) compensateParallel(ctx workflow.Context) error {
+	logger := workflow.GetLogger(ctx)
+	errs := make([]error, len(s.compensations))
+	wg := workflow.NewWaitGroup(ctx)
+
+	for i, c := range s.compensations {
+		wg.Add(1)
+		workflow.Go(ctx, func(ctx workflow.Context) {
+			defer wg.Done()
+			logger.Info("Compensating step", "Step", c.step)
+			if err := c.fn(ctx); err != nil {
+				logger.Error("Compensation failed", "Step", c.step, "Error", err)
+				errs[i] = fmt.Errorf("compensate %s: %w", c.step, err)
+			}
+		})
+	}
+	wg.Wait(ctx)
+	return errors.Join(errs...)
+}
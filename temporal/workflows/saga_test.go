@@ -0,0 +1,171 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"temporal/models"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+type SagaTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestSagaTestSuite(t *testing.T) {
+	suite.Run(t, new(SagaTestSuite))
+}
+
+func (s *SagaTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	registerOrderWorkflows(s.env)
+}
+
+func (s *SagaTestSuite) AfterTest(suiteName, testName string) {
+	s.env.AssertExpectations(s.T())
+}
+
+// recordCompensations records the order in which compensation activities run
+func (s *SagaTestSuite) recordCompensations() *[]string {
+	var calls []string
+	s.env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+		switch info.ActivityType.Name {
+		case "RefundPayment", "CompensateInventory":
+			calls = append(calls, info.ActivityType.Name)
+		case "SendNotification":
+			var notification models.NotificationRequest
+			if args.Get(&notification) == nil && notification.MessageType == "order_cancelled" {
+				calls = append(calls, "SendNotification:order_cancelled")
+			}
+		}
+	})
+	return &calls
+}
+
+func (s *SagaTestSuite) Test_Success_NoCompensation() {
+	s.env.OnActivity("ValidateOrder", mock.Anything, mock.Anything).Return(nil)
+	s.env.OnWorkflow(PaymentWorkflow, mock.Anything, mock.Anything).Return(testPayment(), nil)
+	s.env.OnActivity("ReserveInventory", mock.Anything, mock.Anything).Return(testReservation(), nil)
+	s.env.OnActivity("ConfirmInventory", mock.Anything, mock.Anything).Return(testReservation(), nil)
+	s.env.OnActivity("SendNotification", mock.Anything, mock.Anything).Return(nil)
+	s.env.OnWorkflow(FulfillmentWorkflow, mock.Anything, mock.Anything).Return(testFulfillment(), nil)
+
+	s.env.ExecuteWorkflow(OrderWorkflow, testOrder())
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.env.AssertActivityNotCalled(s.T(), "RefundPayment", mock.Anything, mock.Anything)
+	s.env.AssertActivityNotCalled(s.T(), "CompensateInventory", mock.Anything, mock.Anything)
+}
+
+func (s *SagaTestSuite) Test_InventoryFailure_RefundsPayment() {
+	calls := s.recordCompensations()
+	s.env.OnActivity("ValidateOrder", mock.Anything, mock.Anything).Return(nil)
+	s.env.OnWorkflow(PaymentWorkflow, mock.Anything, mock.Anything).Return(testPayment(), nil)
+	s.env.OnActivity("ReserveInventory", mock.Anything, mock.Anything).Return(nil, errors.New("out of stock"))
+	s.env.OnActivity("RefundPayment", mock.Anything, mock.Anything).Return(testPayment(), nil).Once()
+
+	s.env.ExecuteWorkflow(OrderWorkflow, testOrder())
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.Error(s.env.GetWorkflowError())
+	s.Equal([]string{"RefundPayment"}, *calls)
+	s.env.AssertActivityNotCalled(s.T(), "CompensateInventory", mock.Anything, mock.Anything)
+}
+
+func (s *SagaTestSuite) Test_Cancelled_UnwindsInReverseOrder() {
+	calls := s.recordCompensations()
+	s.env.OnActivity("ValidateOrder", mock.Anything, mock.Anything).Return(nil)
+	s.env.OnWorkflow(PaymentWorkflow, mock.Anything, mock.Anything).Return(testPayment(), nil)
+	s.env.OnActivity("ReserveInventory", mock.Anything, mock.Anything).Return(testReservation(), nil)
+	// the confirmation takes long enough for the cancellation to arrive first
+	s.env.OnActivity("SendNotification", mock.Anything, mock.Anything).After(time.Minute).Return(nil)
+	s.env.OnActivity("CompensateInventory", mock.Anything, mock.Anything).Return(nil).Once()
+	s.env.OnActivity("RefundPayment", mock.Anything, mock.Anything).Return(testPayment(), nil).Once()
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.CancelWorkflow()
+	}, 10*time.Second)
+
+	s.env.ExecuteWorkflow(OrderWorkflow, testOrder())
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.Error(s.env.GetWorkflowError())
+	s.Equal([]string{"CompensateInventory", "RefundPayment"}, *calls)
+}
+
+func (s *SagaTestSuite) Test_CompensationFailure_ContinuesUnwinding() {
+	calls := s.recordCompensations()
+	s.env.OnActivity("ValidateOrder", mock.Anything, mock.Anything).Return(nil)
+	s.env.OnWorkflow(PaymentWorkflow, mock.Anything, mock.Anything).Return(testPayment(), nil)
+	s.env.OnActivity("ReserveInventory", mock.Anything, mock.Anything).Return(testReservation(), nil)
+	s.env.OnActivity("SendNotification", mock.Anything, mock.Anything).After(time.Minute).Return(nil)
+	s.env.OnActivity("CompensateInventory", mock.Anything, mock.Anything).Return(errors.New("inventory service down"))
+	s.env.OnActivity("RefundPayment", mock.Anything, mock.Anything).Return(testPayment(), nil).Once()
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.CancelWorkflow()
+	}, 10*time.Second)
+
+	s.env.ExecuteWorkflow(OrderWorkflow, testOrder())
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.Error(s.env.GetWorkflowError())
+	s.Contains(*calls, "RefundPayment")
+}
+
+// sagaOrderWorkflow exercises Saga directly with local compensations
+// a compensation error is reported as a trailing "error" entry
+func sagaOrderWorkflow(ctx workflow.Context, failAt int, stopOnError bool) ([]string, error) {
+	var compensated []string
+	saga := NewSaga(SagaOptions{StopOnError: stopOnError})
+	for i := 1; i <= 3; i++ {
+		step := []string{"", "one", "two", "three"}[i]
+		saga.AddCompensation(step, func(ctx workflow.Context) error {
+			compensated = append(compensated, step)
+			if step == "two" && stopOnError {
+				return errors.New("cannot undo two")
+			}
+			return nil
+		})
+		if i == failAt {
+			err := saga.Compensate(ctx)
+			// a second call must not run anything again
+			_ = saga.Compensate(ctx)
+			if err != nil {
+				compensated = append(compensated, "error")
+			}
+			return compensated, nil
+		}
+	}
+	return compensated, nil
+}
+
+func (s *SagaTestSuite) Test_Saga_ReverseOrder() {
+	s.env.RegisterWorkflow(sagaOrderWorkflow)
+	s.env.ExecuteWorkflow(sagaOrderWorkflow, 3, false)
+
+	var compensated []string
+	s.NoError(s.env.GetWorkflowResult(&compensated))
+	s.Equal([]string{"three", "two", "one"}, compensated)
+}
+
+func (s *SagaTestSuite) Test_Saga_StopOnError() {
+	s.env.RegisterWorkflow(sagaOrderWorkflow)
+	s.env.ExecuteWorkflow(sagaOrderWorkflow, 3, true)
+
+	var compensated []string
+	s.NoError(s.env.GetWorkflowResult(&compensated))
+	s.Equal([]string{"three", "two", "error"}, compensated)
+}
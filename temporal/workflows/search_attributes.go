@@ -0,0 +1,67 @@
+package workflows
+
+import (
+	"fmt"
+	"temporal/models"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+/*
+SEARCH ATTRIBUTES:
+
+Search attributes are indexed fields of a workflow execution, so orders can be
+found with visibility queries instead of knowing their workflow ID:
+
+  CustomerID = 'customer-123' AND Status = 'payment_failed'
+  OrderTotal > 1000 AND ExecutionStatus = 'Running'
+
+- CustomerID and OrderTotal are set when the order starts
+- Status is upserted by OrderWorkflow whenever the order status changes
+
+Custom search attributes must be registered in the namespace before use,
+otherwise starting an order fails:
+
+  temporal operator search-attribute create --name CustomerID --type Keyword
+  temporal operator search-attribute create --name OrderTotal --type Double
+  temporal operator search-attribute create --name Status --type Keyword
+*/
+
+var (
+	SearchAttributeCustomerID = temporal.NewSearchAttributeKeyKeyword("CustomerID")
+	SearchAttributeOrderTotal = temporal.NewSearchAttributeKeyFloat64("OrderTotal")
+	SearchAttributeStatus     = temporal.NewSearchAttributeKeyKeyword("Status")
+)
+
+// OrderWorkflowID is the workflow ID of an order's OrderWorkflow
+// Orders are looked up by order ID everywhere, so every starter must use it
+func OrderWorkflowID(orderID string) string {
+	return fmt.Sprintf("order-workflow-%s", orderID)
+}
+
+// OrderSearchAttributes are the search attributes an order starts with
+func OrderSearchAttributes(order models.Order) temporal.SearchAttributes {
+	status := order.Status
+	if status == "" {
+		status = "pending"
+	}
+	return temporal.NewSearchAttributes(
+		SearchAttributeCustomerID.ValueSet(order.CustomerID),
+		SearchAttributeOrderTotal.ValueSet(order.TotalAmount),
+		SearchAttributeStatus.ValueSet(status),
+	)
+}
+
+// publishStatus upserts the Status search attribute if the order status changed
+// Executions started before ChangeStatusSearchAttribute never upsert
+func (s *orderState) publishStatus(ctx workflow.Context) {
+	if !s.searchableStatus || s.publishedStatus == s.order.Status {
+		return
+	}
+	if err := workflow.UpsertTypedSearchAttributes(ctx, SearchAttributeStatus.ValueSet(s.order.Status)); err != nil {
+		workflow.GetLogger(ctx).Warn("Status search attribute not updated", "Status", s.order.Status, "Error", err)
+		return
+	}
+	s.publishedStatus = s.order.Status
+}
@@ -0,0 +1,333 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"temporal/models"
+	"time"
+
+	"github.com/robfig/cron"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+/*
+SUBSCRIPTIONS:
+
+A SubscriptionWorkflow is a long-lived workflow per customer subscription
+(an "entity workflow") that places an OrderWorkflow child on a schedule:
+
+1. Scheduling
+   - The cron schedule is evaluated in workflow code with workflow.Now, which
+     is deterministic, and waited for with a durable timer
+   - A Temporal Schedule (or the cron option of StartWorkflowOptions) could start
+     the orders too, but the subscription has state of its own: pause, skip-next,
+     the payment failure count and the order history live here, next to the timer
+
+2. Signals change the subscription while it waits or while an order runs:
+   - pause-subscription / resume-subscription
+   - skip-next-order: the next scheduled order is recorded as skipped
+   - cancel-subscription: no more orders; a running order still completes
+
+3. Payment failures
+   - An order that fails in its payment step counts as a payment failure
+   - After MaxPaymentFailures in a row the subscription stops
+
+4. Continue-as-new after a number of orders keeps the history bounded,
+   carrying the subscription state into the next run.
+*/
+
+const (
+	// SignalPauseSubscription stops placing orders until resumed
+	// Payload: models.SubscriptionChange
+	SignalPauseSubscription = "pause-subscription"
+
+	// SignalResumeSubscription resumes a paused subscription from the next scheduled time
+	// Payload: models.SubscriptionChange
+	SignalResumeSubscription = "resume-subscription"
+
+	// SignalSkipNextOrder skips the next scheduled order
+	// Payload: models.SubscriptionChange
+	SignalSkipNextOrder = "skip-next-order"
+
+	// SignalCancelSubscription ends the subscription
+	// Payload: models.SubscriptionChange
+	SignalCancelSubscription = "cancel-subscription"
+
+	// QueryGetSubscription returns models.SubscriptionStatus with the order history
+	QueryGetSubscription = "get-subscription"
+)
+
+const (
+	defaultMaxPaymentFailures = 3
+	subscriptionOrdersPerRun  = 100
+	maxSubscriptionHistory    = 50
+)
+
+// SubscriptionWorkflowID is the workflow ID of a subscription
+func SubscriptionWorkflowID(subscriptionID string) string {
+	return fmt.Sprintf("subscription-%s", subscriptionID)
+}
+
+// SubscriptionWorkflow places recurring orders for a customer until cancelled
+// or stopped by payment failures
+func SubscriptionWorkflow(ctx workflow.Context, req models.SubscriptionRequest) (*models.SubscriptionStatus, error) {
+	logger := workflow.GetLogger(ctx)
+
+	schedule, err := cron.ParseStandard(req.Schedule)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("invalid schedule %q: %v", req.Schedule, err), "INVALID_SCHEDULE", err)
+	}
+	if req.MaxPaymentFailures <= 0 {
+		req.MaxPaymentFailures = defaultMaxPaymentFailures
+	}
+
+	state := req.State
+	if state == nil {
+		state = &models.SubscriptionStatus{
+			SubscriptionID: req.SubscriptionID,
+			CustomerID:     req.CustomerID,
+			Schedule:       req.Schedule,
+			Status:         "active",
+		}
+	}
+	logger.Info("Subscription workflow started", "SubscriptionID", state.SubscriptionID, "Status", state.Status)
+
+	if err := workflow.SetQueryHandler(ctx, QueryGetSubscription, func() (models.SubscriptionStatus, error) {
+		snapshot := *state
+		snapshot.Orders = slices.Clone(state.Orders)
+		return snapshot, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sub := newSubscription(ctx, state)
+	ordersThisRun := 0
+
+	for state.Status == "active" || state.Status == "paused" {
+		// Paused: only signals can change anything
+		if state.Status == "paused" {
+			state.NextRunAt = time.Time{}
+			selector := workflow.NewSelector(ctx)
+			sub.addSignals(ctx, selector)
+			selector.Select(ctx)
+			continue
+		}
+
+		// Wait for the next scheduled time, or for a signal
+		now := workflow.Now(ctx)
+		state.NextRunAt = schedule.Next(now)
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		timer := workflow.NewTimer(timerCtx, state.NextRunAt.Sub(now))
+
+		due := false
+		selector := workflow.NewSelector(ctx)
+		sub.addSignals(ctx, selector)
+		selector.AddFuture(timer, func(f workflow.Future) {
+			due = f.Get(ctx, nil) == nil
+		})
+		selector.Select(ctx)
+		if !due {
+			// A signal came first; the loop re-evaluates the state and restarts the timer
+			cancelTimer()
+			continue
+		}
+
+		if state.SkipNext {
+			state.SkipNext = false
+			sub.appendOrder(models.SubscriptionOrder{ScheduledAt: state.NextRunAt, Status: "skipped"})
+			logger.Info("Scheduled order skipped", "SubscriptionID", state.SubscriptionID, "ScheduledAt", state.NextRunAt)
+			continue
+		}
+
+		sub.placeOrder(ctx, req)
+		ordersThisRun++
+
+		// Keep the history short: hand the state to a fresh run
+		if (state.Status == "active" || state.Status == "paused") &&
+			(ordersThisRun >= subscriptionOrdersPerRun || workflow.GetInfo(ctx).GetContinueAsNewSuggested()) {
+			sub.drainSignals(ctx)
+			req.State = state
+			logger.Info("Subscription continues as new", "SubscriptionID", state.SubscriptionID)
+			return nil, workflow.NewContinueAsNewError(ctx, SubscriptionWorkflow, req)
+		}
+	}
+
+	state.NextRunAt = time.Time{}
+	logger.Info("Subscription ended", "SubscriptionID", state.SubscriptionID, "Status", state.Status, "Reason", state.Reason)
+	return state, nil
+}
+
+// subscription holds the state and signal channels of a running SubscriptionWorkflow
+type subscription struct {
+	state   *models.SubscriptionStatus
+	signals []subscriptionSignal // fixed order, so selectors stay deterministic
+}
+
+type subscriptionSignal struct {
+	ch    workflow.ReceiveChannel
+	apply func(ctx workflow.Context, change models.SubscriptionChange)
+}
+
+func newSubscription(ctx workflow.Context, state *models.SubscriptionStatus) *subscription {
+	s := &subscription{state: state}
+	s.signals = []subscriptionSignal{
+		{workflow.GetSignalChannel(ctx, SignalCancelSubscription), s.cancel},
+		{workflow.GetSignalChannel(ctx, SignalPauseSubscription), s.pause},
+		{workflow.GetSignalChannel(ctx, SignalResumeSubscription), s.resume},
+		{workflow.GetSignalChannel(ctx, SignalSkipNextOrder), s.skipNext},
+	}
+	return s
+}
+
+// addSignals adds a case per subscription signal to the selector
+func (s *subscription) addSignals(ctx workflow.Context, selector workflow.Selector) {
+	for _, sig := range s.signals {
+		selector.AddReceive(sig.ch, func(c workflow.ReceiveChannel, more bool) {
+			var change models.SubscriptionChange
+			c.Receive(ctx, &change)
+			sig.apply(ctx, change)
+		})
+	}
+}
+
+// drainSignals applies the signals received but not handled yet,
+// which would otherwise be lost on continue-as-new
+func (s *subscription) drainSignals(ctx workflow.Context) {
+	for _, sig := range s.signals {
+		var change models.SubscriptionChange
+		for sig.ch.ReceiveAsync(&change) {
+			sig.apply(ctx, change)
+		}
+	}
+}
+
+func (s *subscription) cancel(ctx workflow.Context, change models.SubscriptionChange) {
+	if s.state.Status == "cancelled" || s.state.Status == "stopped" {
+		return
+	}
+	workflow.GetLogger(ctx).Info("Subscription cancelled", "SubscriptionID", s.state.SubscriptionID, "Reason", change.Reason)
+	s.state.Status = "cancelled"
+	s.state.Reason = change.Reason
+}
+
+func (s *subscription) pause(ctx workflow.Context, change models.SubscriptionChange) {
+	if s.state.Status != "active" {
+		return
+	}
+	workflow.GetLogger(ctx).Info("Subscription paused", "SubscriptionID", s.state.SubscriptionID, "Reason", change.Reason)
+	s.state.Status = "paused"
+	s.state.Reason = change.Reason
+}
+
+func (s *subscription) resume(ctx workflow.Context, change models.SubscriptionChange) {
+	if s.state.Status != "paused" {
+		return
+	}
+	workflow.GetLogger(ctx).Info("Subscription resumed", "SubscriptionID", s.state.SubscriptionID)
+	s.state.Status = "active"
+	s.state.Reason = ""
+}
+
+func (s *subscription) skipNext(ctx workflow.Context, change models.SubscriptionChange) {
+	if s.state.Status != "active" && s.state.Status != "paused" {
+		return
+	}
+	workflow.GetLogger(ctx).Info("Next subscription order will be skipped", "SubscriptionID", s.state.SubscriptionID, "Reason", change.Reason)
+	s.state.SkipNext = true
+}
+
+// appendOrder adds an entry to the order history, keeping the most recent ones
+func (s *subscription) appendOrder(entry models.SubscriptionOrder) {
+	s.state.Orders = append(s.state.Orders, entry)
+	if len(s.state.Orders) > maxSubscriptionHistory {
+		s.state.Orders = slices.Clone(s.state.Orders[len(s.state.Orders)-maxSubscriptionHistory:])
+	}
+}
+
+// placeOrder runs one OrderWorkflow child and records its outcome
+// Signals keep being handled while the order runs
+func (s *subscription) placeOrder(ctx workflow.Context, req models.SubscriptionRequest) {
+	logger := workflow.GetLogger(ctx)
+
+	s.state.OrdersCreated++
+	order := models.Order{
+		OrderID:         fmt.Sprintf("%s-%04d", s.state.SubscriptionID, s.state.OrdersCreated),
+		CustomerID:      s.state.CustomerID,
+		Items:           req.Items,
+		Status:          "pending",
+		ShippingAddress: req.ShippingAddress,
+	}
+	for _, item := range order.Items {
+		order.TotalAmount += float64(item.Quantity) * item.Price
+	}
+
+	workflowID := OrderWorkflowID(order.OrderID)
+	s.appendOrder(models.SubscriptionOrder{
+		OrderID:     order.OrderID,
+		WorkflowID:  workflowID,
+		ScheduledAt: s.state.NextRunAt,
+		Status:      "running",
+	})
+	logger.Info("Placing subscription order", "SubscriptionID", s.state.SubscriptionID, "OrderID", order.OrderID)
+
+	childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID:            workflowID,
+		TypedSearchAttributes: OrderSearchAttributes(order),
+
+		// A cancelled subscription lets the order in progress finish
+		ParentClosePolicy: enumspb.PARENT_CLOSE_POLICY_ABANDON,
+	})
+	future := workflow.ExecuteChildWorkflow(childCtx, OrderWorkflow, order)
+
+	done := false
+	for !done && s.state.Status != "cancelled" {
+		selector := workflow.NewSelector(ctx)
+		s.addSignals(ctx, selector)
+		selector.AddFuture(future, func(f workflow.Future) {
+			done = true
+			entry := &s.state.Orders[len(s.state.Orders)-1]
+
+			var status string
+			err := f.Get(ctx, &status)
+			if err == nil {
+				entry.Status = status
+				s.state.PaymentFailures = 0
+				return
+			}
+			entry.Status = "failed"
+			entry.Error = err.Error()
+			if !isPaymentFailure(err) {
+				return
+			}
+
+			s.state.PaymentFailures++
+			logger.Warn("Subscription order payment failed", "OrderID", order.OrderID, "Failures", s.state.PaymentFailures)
+			if s.state.PaymentFailures >= req.MaxPaymentFailures {
+				s.state.Status = "stopped"
+				s.state.Reason = fmt.Sprintf("%d payment failures in a row", s.state.PaymentFailures)
+			}
+		})
+		selector.Select(ctx)
+	}
+}
+
+// isPaymentFailure reports whether an order failed in its payment step
+func isPaymentFailure(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		switch e := err.(type) {
+		case *temporal.ChildWorkflowExecutionError:
+			if e.WorkflowType() == "PaymentWorkflow" {
+				return true
+			}
+		case *temporal.ApplicationError:
+			if slices.Contains(paymentDeclineErrorTypes, e.Type()) {
+				return true
+			}
+		}
+	}
+	return false
+}
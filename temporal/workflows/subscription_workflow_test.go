@@ -0,0 +1,188 @@
+package workflows
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"temporal/models"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+type SubscriptionTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestSubscriptionTestSuite(t *testing.T) {
+	suite.Run(t, new(SubscriptionTestSuite))
+}
+
+func (s *SubscriptionTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterWorkflow(OrderWorkflow)
+}
+
+func subscriptionRequest() models.SubscriptionRequest {
+	return models.SubscriptionRequest{
+		SubscriptionID:  "sub-1",
+		CustomerID:      "customer-1",
+		Items:           []models.OrderItem{{ProductID: "prod-001", Quantity: 2, Price: 10}},
+		ShippingAddress: testOrder().ShippingAddress,
+		Schedule:        "@every 24h",
+	}
+}
+
+// mockOrders completes every order an hour after it starts, with err if not nil
+func (s *SubscriptionTestSuite) mockOrders(err error) {
+	status := "completed"
+	if err != nil {
+		status = "payment_failed"
+	}
+	s.env.OnWorkflow(OrderWorkflow, mock.Anything, mock.Anything).After(time.Hour).Return(status, err)
+}
+
+func (s *SubscriptionTestSuite) signalAt(after time.Duration, name string) {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(name, models.SubscriptionChange{Reason: "test"})
+	}, after)
+}
+
+func (s *SubscriptionTestSuite) run(req models.SubscriptionRequest) models.SubscriptionStatus {
+	s.env.ExecuteWorkflow(SubscriptionWorkflow, req)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Require().NoError(s.env.GetWorkflowError())
+	var status models.SubscriptionStatus
+	s.Require().NoError(s.env.GetWorkflowResult(&status))
+	return status
+}
+
+func orderStatuses(status models.SubscriptionStatus) []string {
+	var statuses []string
+	for _, o := range status.Orders {
+		statuses = append(statuses, o.Status)
+	}
+	return statuses
+}
+
+func (s *SubscriptionTestSuite) Test_PlacesOrdersOnSchedule() {
+	s.mockOrders(nil)
+	s.signalAt(3*24*time.Hour+12*time.Hour, SignalCancelSubscription)
+
+	status := s.run(subscriptionRequest())
+
+	s.Equal("cancelled", status.Status)
+	s.Equal(3, status.OrdersCreated)
+	s.Equal([]string{"completed", "completed", "completed"}, orderStatuses(status))
+	s.Equal("sub-1-0001", status.Orders[0].OrderID)
+	s.Equal("order-workflow-sub-1-0001", status.Orders[0].WorkflowID)
+	s.True(status.NextRunAt.IsZero())
+}
+
+func (s *SubscriptionTestSuite) Test_OrderTotalFromItems() {
+	var placed models.Order
+	s.env.OnWorkflow(OrderWorkflow, mock.Anything, mock.Anything).Return(
+		func(_ workflow.Context, order models.Order) (string, error) {
+			placed = order
+			return "completed", nil
+		})
+	s.signalAt(36*time.Hour, SignalCancelSubscription)
+
+	s.run(subscriptionRequest())
+
+	s.Equal("customer-1", placed.CustomerID)
+	s.Equal(20.0, placed.TotalAmount)
+	s.Equal("Springfield", placed.ShippingAddress.City)
+}
+
+func (s *SubscriptionTestSuite) Test_SkipNext() {
+	s.mockOrders(nil)
+	s.signalAt(time.Hour, SignalSkipNextOrder)
+	s.signalAt(2*24*time.Hour+12*time.Hour, SignalCancelSubscription)
+
+	status := s.run(subscriptionRequest())
+
+	s.Equal([]string{"skipped", "completed"}, orderStatuses(status))
+	s.Equal(1, status.OrdersCreated)
+}
+
+func (s *SubscriptionTestSuite) Test_PauseAndResume() {
+	s.mockOrders(nil)
+	s.signalAt(time.Hour, SignalPauseSubscription)
+	s.env.RegisterDelayedCallback(func() {
+		value, err := s.env.QueryWorkflow(QueryGetSubscription)
+		s.Require().NoError(err)
+		var status models.SubscriptionStatus
+		s.Require().NoError(value.Get(&status))
+		s.Equal("paused", status.Status)
+		s.Zero(status.OrdersCreated)
+	}, 2*24*time.Hour)
+	s.signalAt(3*24*time.Hour+time.Hour, SignalResumeSubscription)
+	s.signalAt(4*24*time.Hour+4*time.Hour, SignalCancelSubscription)
+
+	status := s.run(subscriptionRequest())
+
+	// Nothing while paused; the first order comes a day after resuming
+	s.Equal(1, status.OrdersCreated)
+	s.Equal("cancelled", status.Status)
+}
+
+func (s *SubscriptionTestSuite) Test_StopsAfterPaymentFailures() {
+	s.mockOrders(temporal.NewNonRetryableApplicationError("declined", "INSUFFICIENT_FUNDS", nil))
+	req := subscriptionRequest()
+	req.MaxPaymentFailures = 2
+
+	status := s.run(req)
+
+	s.Equal("stopped", status.Status)
+	s.Equal(2, status.PaymentFailures)
+	s.Equal([]string{"failed", "failed"}, orderStatuses(status))
+	s.Contains(status.Orders[0].Error, "declined")
+}
+
+func (s *SubscriptionTestSuite) Test_OtherFailuresDoNotCount() {
+	s.mockOrders(temporal.NewNonRetryableApplicationError("out of stock", "OUT_OF_STOCK", nil))
+	s.signalAt(3*24*time.Hour+12*time.Hour, SignalCancelSubscription)
+	req := subscriptionRequest()
+	req.MaxPaymentFailures = 1
+
+	status := s.run(req)
+
+	s.Equal("cancelled", status.Status)
+	s.Zero(status.PaymentFailures)
+	s.Equal(3, status.OrdersCreated)
+}
+
+func (s *SubscriptionTestSuite) Test_ContinuesAsNew() {
+	s.mockOrders(nil)
+	s.signalAt(subscriptionOrdersPerRun*24*time.Hour+2*time.Hour, SignalSkipNextOrder)
+
+	s.env.ExecuteWorkflow(SubscriptionWorkflow, subscriptionRequest())
+
+	var canErr *workflow.ContinueAsNewError
+	s.Require().True(errors.As(s.env.GetWorkflowError(), &canErr))
+	var next models.SubscriptionRequest
+	s.Require().NoError(converter.GetDefaultDataConverter().FromPayloads(canErr.Input, &next))
+	s.Require().NotNil(next.State)
+	s.Equal(subscriptionOrdersPerRun, next.State.OrdersCreated)
+	s.Len(next.State.Orders, maxSubscriptionHistory)
+	s.Equal("active", next.State.Status)
+	s.Equal("sub-1", next.SubscriptionID)
+}
+
+func (s *SubscriptionTestSuite) Test_InvalidSchedule() {
+	req := subscriptionRequest()
+	req.Schedule = "every tuesday"
+
+	s.env.ExecuteWorkflow(SubscriptionWorkflow, req)
+
+	s.True(hasErrorType(s.env.GetWorkflowError(), "INVALID_SCHEDULE"))
+}
@@ -0,0 +1,105 @@
+package workflows
+
+import (
+	"go.temporal.io/sdk/workflow"
+)
+
+/*
+WORKFLOW VERSIONING:
+
+Workflow code must replay every running execution exactly: the same commands
+(activities, timers, child workflows, markers) in the same order. Adding,
+removing or reordering a step breaks executions started by the old code with a
+non-determinism error. Two tools let the code evolve anyway:
+
+1. workflow.GetVersion - branch inside the code
+   - New executions record a version marker and take the new branch
+   - Executions that started before the change have no marker and replay the old branch
+   - Good for small changes to long-running workflows
+
+2. Worker build IDs (worker -build-id, -use-versioning) - run old and new code side by side
+   - With Worker Deployment Versioning, executions are pinned to the build that started them
+   - New executions go to the new build; old workers drain the old ones
+   - Good for big rewrites, at the cost of keeping old workers alive
+
+PATTERN for a change guarded by GetVersion:
+
+	// 1. Register the change ID and its latest version below
+	const ChangeSkipNotification = "skip-notification"
+	...
+	ChangeSkipNotification: 1,
+
+	// 2. Branch on the version where the change happens
+	if changeVersion(ctx, ChangeSkipNotification) == workflow.DefaultVersion {
+		// old behavior, kept for executions started before the change
+	} else {
+		// new behavior
+	}
+
+	// 3. Record a history of the new path:
+	//    go run ./history record -workflow-id <id>
+	// 4. Once no execution from before the change is left (check with
+	//    `temporal workflow list --query 'ExecutionStatus="Running"'`), delete the old branch
+	//    and raise the minimum: workflow.GetVersion(ctx, ChangeSkipNotification, 1, 1)
+
+Rules:
+- Never reuse or rename a change ID; a new change to the same code gets a new ID
+- Only raise the latest version of an existing change, never lower it
+- Changes to activity options, retry policies or timeouts do not need versioning
+- `go run ./history replay` (and TestReplayHistories) replays the recorded histories in
+  workflows/testdata/histories and fails on any non-deterministic change
+*/
+
+// Changes made to workflows after executions were already running
+const (
+	// ChangeStatusSearchAttribute: OrderWorkflow upserts the Status search attribute
+	ChangeStatusSearchAttribute = "status-search-attribute"
+
+	// ChangeNotificationRetryWorkflow: a failed confirmation starts a NotificationWorkflow
+	ChangeNotificationRetryWorkflow = "notification-retry-workflow"
+
+	// ChangePaymentActivityIDs: PaymentWorkflow names its activities after their step and
+	// ReconciliationWorkflow names its payment repairs after the order, so the gateway
+	// idempotency keys survive retried runs and continue-as-new
+	ChangePaymentActivityIDs = "payment-activity-ids"
+
+	// ChangeNotificationRecipients: OrderWorkflow resolves the customer's recipients
+	// before it starts the NotificationWorkflow
+	ChangeNotificationRecipients = "notification-recipients"
+
+	// ChangeDisconnectedVoid: PaymentWorkflow voids the authorization on a disconnected
+	// context, so a cancellation during capture does not skip the void
+	ChangeDisconnectedVoid = "disconnected-void"
+)
+
+// latestVersions holds the newest version of every change made to running workflows
+// Keeping them in one place makes the change log of the workflow code reviewable
+var latestVersions = map[string]workflow.Version{
+	ChangeStatusSearchAttribute:     1,
+	ChangeNotificationRetryWorkflow: 1,
+	ChangePaymentActivityIDs:        1,
+	ChangeNotificationRecipients:    1,
+	ChangeDisconnectedVoid:          1,
+}
+
+// changeVersion returns the version of a change for this execution:
+// workflow.DefaultVersion for executions that started before the change,
+// the latest registered version for new ones
+func changeVersion(ctx workflow.Context, changeID string) workflow.Version {
+	latest, ok := latestVersions[changeID]
+	if !ok {
+		panic("workflow change " + changeID + " is not registered in latestVersions")
+	}
+	return workflow.GetVersion(ctx, changeID, workflow.DefaultVersion, latest)
+}
+
+// withActivityID sets the ID of the next activity scheduled with ctx
+// Executions that started before ChangePaymentActivityIDs keep the generated IDs
+func withActivityID(ctx workflow.Context, activityID string) workflow.Context {
+	if changeVersion(ctx, ChangePaymentActivityIDs) == workflow.DefaultVersion {
+		return ctx
+	}
+	options := workflow.GetActivityOptions(ctx)
+	options.ActivityID = activityID
+	return workflow.WithActivityOptions(ctx, options)
+}
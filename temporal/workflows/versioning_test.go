@@ -0,0 +1,60 @@
+package workflows
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+const testChange = "test-change"
+
+// versionedWorkflow reports which branch of testChange it took
+func versionedWorkflow(ctx workflow.Context) (string, error) {
+	if changeVersion(ctx, testChange) == workflow.DefaultVersion {
+		return "old", nil
+	}
+	return "new", nil
+}
+
+func runVersioned(t *testing.T, setup func(env *testsuite.TestWorkflowEnvironment)) (string, error) {
+	var s testsuite.WorkflowTestSuite
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(versionedWorkflow)
+	if setup != nil {
+		setup(env)
+	}
+	env.ExecuteWorkflow(versionedWorkflow)
+	require.True(t, env.IsWorkflowCompleted())
+	if err := env.GetWorkflowError(); err != nil {
+		return "", err
+	}
+	var branch string
+	require.NoError(t, env.GetWorkflowResult(&branch))
+	return branch, nil
+}
+
+func TestChangeVersion(t *testing.T) {
+	latestVersions[testChange] = 1
+	t.Cleanup(func() { delete(latestVersions, testChange) })
+
+	t.Run("new execution takes the new branch", func(t *testing.T) {
+		branch, err := runVersioned(t, nil)
+		require.NoError(t, err)
+		require.Equal(t, "new", branch)
+	})
+
+	t.Run("execution from before the change keeps the old branch", func(t *testing.T) {
+		branch, err := runVersioned(t, func(env *testsuite.TestWorkflowEnvironment) {
+			env.OnGetVersion(testChange, workflow.DefaultVersion, workflow.Version(1)).Return(workflow.DefaultVersion)
+		})
+		require.NoError(t, err)
+		require.Equal(t, "old", branch)
+	})
+}
+
+func TestChangeVersion_Unregistered(t *testing.T) {
+	_, err := runVersioned(t, nil)
+	require.ErrorContains(t, err, "not registered")
+}